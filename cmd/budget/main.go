@@ -1,12 +1,15 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 
+	"github.com/bioharz/budget/internal/cli"
 	"github.com/bioharz/budget/internal/db"
+	"github.com/bioharz/budget/internal/service"
 	"github.com/bioharz/budget/internal/ui"
 	tea "github.com/charmbracelet/bubbletea"
 )
@@ -21,6 +24,7 @@ var (
 func main() {
 	// Handle version flag
 	versionFlag := flag.Bool("version", false, "Print version information")
+	dbFlag := flag.String("db", "", "Path to the SQLite database (default $XDG_DATA_HOME/minimal-money/budget.db, or $"+db.EnvDBPath+")")
 	flag.Parse()
 
 	if *versionFlag {
@@ -29,14 +33,77 @@ func main() {
 		fmt.Printf("Built: %s\n", date)
 		os.Exit(0)
 	}
-	if err := db.Initialize(); err != nil {
-		log.Fatal("Failed to initialize database:", err)
+
+	dbPath, err := db.ResolvePath(*dbFlag)
+	if err != nil {
+		log.Fatal("Failed to resolve database path:", err)
+	}
+	if err := openDatabase(dbPath, flag.NArg() == 0); err != nil {
+		log.Fatal("Failed to initialize database: ", err)
+	}
+
+	// Any remaining arguments select a headless subcommand
+	if flag.NArg() > 0 {
+		os.Exit(runCLI(flag.Args()))
 	}
-	defer db.Close()
 
-	p := tea.NewProgram(ui.InitialModel(), tea.WithAltScreen())
-	if _, err := p.Run(); err != nil {
+	// Deleted rows are purged once they have been in the trash long enough
+	if _, err = service.NewTrashService().PurgeExpired(); err != nil {
+		err = fmt.Errorf("failed to empty the trash: %w", err)
+	} else {
+		p := tea.NewProgram(ui.InitialModel(), tea.WithAltScreen())
+		_, err = p.Run()
+	}
+	// An encrypted database is written to disk on close
+	if closeErr := db.Close(); closeErr != nil {
+		fmt.Printf("Error saving database: %v\n", closeErr)
+		os.Exit(1)
+	}
+	if err != nil {
 		fmt.Printf("Error running program: %v", err)
 		os.Exit(1)
 	}
 }
+
+func runCLI(args []string) int {
+	err := cli.New(os.Stdout).Run(args)
+	if closeErr := db.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// openDatabase opens the database at path. An encrypted one is unlocked
+// with the passphrase from the environment, the unlock screen when the
+// terminal UI is starting, or a prompt otherwise.
+func openDatabase(path string, interactive bool) error {
+	encrypted, err := db.IsEncrypted(path)
+	if err != nil {
+		return err
+	}
+	if !encrypted {
+		return db.Initialize(path)
+	}
+
+	open := func(passphrase string) error {
+		return db.InitializeEncrypted(path, passphrase)
+	}
+	if passphrase := os.Getenv(db.EnvPassphrase); passphrase != "" {
+		return open(passphrase)
+	}
+	if interactive {
+		return ui.Unlock(open)
+	}
+	passphrase, err := cli.ReadPassphrase("Passphrase: ")
+	if errors.Is(err, cli.ErrNoTerminal) {
+		return fmt.Errorf("%w, set %s", err, db.EnvPassphrase)
+	}
+	if err != nil {
+		return err
+	}
+	return open(passphrase)
+}
@@ -1,192 +1,127 @@
 package api
 
 import (
-	"encoding/json"
-	"fmt"
-	"net/http"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/bioharz/budget/internal/models"
 )
 
+// PriceClient fetches quotes through a provider registry and caches them in
+// memory so repeated refreshes do not hammer the APIs
 type PriceClient struct {
-	httpClient *http.Client
-	cache      map[string]cachedPrice
+	registry *Registry
+	mu       sync.Mutex
+	cache    map[string]cachedPrice
 }
 
 type cachedPrice struct {
-	price     float64
+	quote     Quote
 	timestamp time.Time
 }
 
+// cacheTTL is how long a quote of each asset type is reused
+var cacheTTL = map[models.AssetType]time.Duration{
+	models.AssetTypeCrypto: 5 * time.Minute,
+	models.AssetTypeFiat:   1 * time.Hour,
+	models.AssetTypeStock:  5 * time.Minute,
+}
+
+// NewPriceClient returns a client using the default providers, with base URLs
+// overridable through the environment
 func NewPriceClient() *PriceClient {
+	return NewPriceClientWithConfig(ConfigFromEnv())
+}
+
+func NewPriceClientWithConfig(cfg Config) *PriceClient {
+	return NewPriceClientWithRegistry(NewDefaultRegistry(cfg))
+}
+
+func NewPriceClientWithRegistry(registry *Registry) *PriceClient {
 	return &PriceClient{
-		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
-		},
-		cache: make(map[string]cachedPrice),
+		registry: registry,
+		cache:    make(map[string]cachedPrice),
 	}
 }
 
-// CoinGecko ID mapping for common crypto symbols
-var cryptoIDMapping = map[string]string{
-	"BTC":   "bitcoin",
-	"ETH":   "ethereum",
-	"USDT":  "tether",
-	"USDC":  "usd-coin",
-	"BNB":   "binancecoin",
-	"XRP":   "ripple",
-	"SOL":   "solana",
-	"ADA":   "cardano",
-	"DOGE":  "dogecoin",
-	"DOT":   "polkadot",
-	"MATIC": "matic-network",
-	"AVAX":  "avalanche-2",
+// Registry exposes the provider chains so callers can add providers
+func (c *PriceClient) Registry() *Registry {
+	return c.registry
 }
 
-func (c *PriceClient) GetCryptoPrices(symbols []string) (map[string]float64, error) {
-	prices := make(map[string]float64)
-	var idsToFetch []string
-	var symbolMap = make(map[string]string) // maps coingecko ID to original symbol
+// GetQuotes returns quotes for symbols of one asset type, serving fresh
+// entries from the cache and asking the provider chain for the rest
+func (c *PriceClient) GetQuotes(assetType models.AssetType, symbols []string) (map[string]Quote, error) {
+	quotes := make(map[string]Quote)
+	var toFetch []string
+	now := time.Now()
 
-	// Check cache first
+	c.mu.Lock()
 	for _, symbol := range symbols {
 		symbol = strings.ToUpper(symbol)
-		if cached, ok := c.cache[symbol]; ok {
-			if time.Since(cached.timestamp) < 5*time.Minute {
-				prices[symbol] = cached.price
+		if cached, ok := c.cache[cacheKey(assetType, symbol)]; ok {
+			if now.Sub(cached.timestamp) < cacheTTL[assetType] {
+				quotes[symbol] = cached.quote
 				continue
 			}
 		}
-
-		// Get CoinGecko ID
-		if id, ok := cryptoIDMapping[symbol]; ok {
-			idsToFetch = append(idsToFetch, id)
-			symbolMap[id] = symbol
-		}
+		toFetch = append(toFetch, symbol)
 	}
+	c.mu.Unlock()
 
-	if len(idsToFetch) == 0 {
-		return prices, nil
+	if len(toFetch) == 0 {
+		return quotes, nil
 	}
 
-	// Batch fetch from CoinGecko
-	ids := strings.Join(idsToFetch, ",")
-	url := fmt.Sprintf("https://api.coingecko.com/api/v3/simple/price?ids=%s&vs_currencies=usd", ids)
-
-	resp, err := c.httpClient.Get(url)
-	if err != nil {
-		return prices, fmt.Errorf("failed to fetch prices: %w", err)
-	}
-	defer resp.Body.Close()
+	fetched, err := c.registry.Fetch(assetType, toFetch)
 
-	// Check for rate limit or error response
-	if resp.StatusCode != http.StatusOK {
-		// Return empty prices on API error (don't fail the whole operation)
-		return prices, nil
-	}
-
-	var result map[string]map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		// Return empty prices on decode error (API might be down)
-		return prices, nil
-	}
-
-	// Map results back to symbols and update cache
-	for id, priceData := range result {
-		if symbol, ok := symbolMap[id]; ok {
-			if usdData, ok := priceData["usd"]; ok {
-				// Handle both float64 and string responses
-				var price float64
-				switch v := usdData.(type) {
-				case float64:
-					price = v
-				case string:
-					// Skip if string (might be error message)
-					continue
-				default:
-					continue
-				}
-				prices[symbol] = price
-				c.cache[symbol] = cachedPrice{
-					price:     price,
-					timestamp: time.Now(),
-				}
-			}
+	c.mu.Lock()
+	for symbol, quote := range fetched {
+		quotes[symbol] = quote
+		c.cache[cacheKey(assetType, symbol)] = cachedPrice{
+			quote:     quote,
+			timestamp: now,
 		}
 	}
+	c.mu.Unlock()
 
-	return prices, nil
+	return quotes, err
 }
 
-func (c *PriceClient) GetFiatRates(symbols []string) (map[string]float64, error) {
-	rates := make(map[string]float64)
-
-	// Handle fixed rate currencies
-	for _, symbol := range symbols {
-		upperSymbol := strings.ToUpper(symbol)
-		switch upperSymbol {
-		case "USD":
-			rates[symbol] = 1.0
-			continue
-		case "AED":
-			// AED is pegged to USD at 3.6725 AED = 1 USD
-			rates[symbol] = 1.0 / 3.6725
-			c.cache[upperSymbol] = cachedPrice{
-				price:     1.0 / 3.6725,
-				timestamp: time.Now(),
-			}
-			continue
-		}
-	}
-
-	// Check if we need to fetch any rates
-	var needsFetch bool
-	for _, symbol := range symbols {
-		symbol = strings.ToUpper(symbol)
-		if symbol != "USD" && symbol != "AED" {
-			if cached, ok := c.cache[symbol]; ok {
-				if time.Since(cached.timestamp) < 1*time.Hour {
-					rates[symbol] = cached.price
-				} else {
-					needsFetch = true
-				}
-			} else {
-				needsFetch = true
-			}
-		}
-	}
-
-	if !needsFetch {
-		return rates, nil
-	}
+// GetCryptoPrices returns USD prices keyed by upper-case symbol. Unknown
+// symbols are left out.
+func (c *PriceClient) GetCryptoPrices(symbols []string) (map[string]float64, error) {
+	quotes, err := c.GetQuotes(models.AssetTypeCrypto, symbols)
+	return quotePrices(quotes), err
+}
 
-	// Fetch from ExchangeRate-API
-	url := "https://api.exchangerate-api.com/v4/latest/USD"
+// GetFiatRates returns the USD value of one unit of each currency
+func (c *PriceClient) GetFiatRates(symbols []string) (map[string]float64, error) {
+	quotes, err := c.GetQuotes(models.AssetTypeFiat, symbols)
+	return quotePrices(quotes), err
+}
 
-	resp, err := c.httpClient.Get(url)
-	if err != nil {
-		return rates, fmt.Errorf("failed to fetch exchange rates: %w", err)
-	}
-	defer resp.Body.Close()
+// GetStockQuotes returns stock quotes in their trading currency
+func (c *PriceClient) GetStockQuotes(symbols []string) (map[string]Quote, error) {
+	return c.GetQuotes(models.AssetTypeStock, symbols)
+}
 
-	var result struct {
-		Rates map[string]float64 `json:"rates"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return rates, fmt.Errorf("failed to decode response: %w", err)
-	}
+// Invalidate drops a cached quote, e.g. after the symbol's provider ID changed
+func (c *PriceClient) Invalidate(assetType models.AssetType, symbol string) {
+	c.mu.Lock()
+	delete(c.cache, cacheKey(assetType, strings.ToUpper(symbol)))
+	c.mu.Unlock()
+}
 
-	// Update rates and cache
-	for _, symbol := range symbols {
-		symbol = strings.ToUpper(symbol)
-		if rate, ok := result.Rates[symbol]; ok {
-			rates[symbol] = 1.0 / rate // Convert to USD rate
-			c.cache[symbol] = cachedPrice{
-				price:     1.0 / rate,
-				timestamp: time.Now(),
-			}
-		}
+func quotePrices(quotes map[string]Quote) map[string]float64 {
+	prices := make(map[string]float64, len(quotes))
+	for symbol, quote := range quotes {
+		prices[symbol] = quote.Price
 	}
+	return prices
+}
 
-	return rates, nil
+func cacheKey(assetType models.AssetType, symbol string) string {
+	return string(assetType) + ":" + symbol
 }
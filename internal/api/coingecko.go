@@ -0,0 +1,179 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// CoinGecko ID mapping for common crypto symbols
+var cryptoIDMapping = map[string]string{
+	"BTC":   "bitcoin",
+	"ETH":   "ethereum",
+	"USDT":  "tether",
+	"USDC":  "usd-coin",
+	"BNB":   "binancecoin",
+	"XRP":   "ripple",
+	"SOL":   "solana",
+	"ADA":   "cardano",
+	"DOGE":  "dogecoin",
+	"DOT":   "polkadot",
+	"MATIC": "matic-network",
+	"AVAX":  "avalanche-2",
+}
+
+// CoinIDResolver maps ticker symbols to CoinGecko coin IDs. Symbols it cannot
+// resolve are left out of the result.
+type CoinIDResolver interface {
+	ResolveIDs(symbols []string) map[string]string
+}
+
+// Coin is one entry of the CoinGecko coin list
+type Coin struct {
+	ID     string `json:"id"`
+	Symbol string `json:"symbol"`
+	Name   string `json:"name"`
+}
+
+// KnownCoinID returns the built-in CoinGecko ID for a common symbol
+func KnownCoinID(symbol string) (string, bool) {
+	id, ok := cryptoIDMapping[strings.ToUpper(symbol)]
+	return id, ok
+}
+
+// CoinGeckoProvider prices crypto assets through the CoinGecko simple price API
+type CoinGeckoProvider struct {
+	baseURL    string
+	httpClient *http.Client
+	resolver   CoinIDResolver
+}
+
+func NewCoinGeckoProvider(baseURL string, httpClient *http.Client) *CoinGeckoProvider {
+	return &CoinGeckoProvider{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: httpClient,
+	}
+}
+
+// WithResolver makes the provider look up coin IDs through resolver before
+// falling back to the built-in mapping
+func (p *CoinGeckoProvider) WithResolver(resolver CoinIDResolver) *CoinGeckoProvider {
+	p.resolver = resolver
+	return p
+}
+
+// GetCoinList fetches every coin CoinGecko knows, with its symbol and name
+func (p *CoinGeckoProvider) GetCoinList() ([]Coin, error) {
+	resp, err := p.httpClient.Get(p.baseURL + "/coins/list")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch coin list: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("coin list request failed: %s", resp.Status)
+	}
+
+	var coins []Coin
+	if err := json.NewDecoder(resp.Body).Decode(&coins); err != nil {
+		return nil, fmt.Errorf("failed to decode coin list: %w", err)
+	}
+	return coins, nil
+}
+
+func (p *CoinGeckoProvider) Name() string {
+	return "coingecko"
+}
+
+func (p *CoinGeckoProvider) GetPrices(symbols []string) (map[string]Quote, error) {
+	quotes := make(map[string]Quote)
+	var idsToFetch []string
+	var symbolMap = make(map[string][]string) // maps coingecko ID to original symbols
+
+	for symbol, id := range p.resolveIDs(symbols) {
+		if _, ok := symbolMap[id]; !ok {
+			idsToFetch = append(idsToFetch, id)
+		}
+		symbolMap[id] = append(symbolMap[id], symbol)
+	}
+
+	if len(idsToFetch) == 0 {
+		return quotes, nil
+	}
+
+	// Batch fetch from CoinGecko
+	ids := strings.Join(idsToFetch, ",")
+	url := fmt.Sprintf("%s/simple/price?ids=%s&vs_currencies=usd&include_last_updated_at=true", p.baseURL, ids)
+
+	resp, err := p.httpClient.Get(url)
+	if err != nil {
+		return quotes, fmt.Errorf("failed to fetch prices: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Rate limits and outages are reported so the next provider can take over
+	if resp.StatusCode != http.StatusOK {
+		return quotes, fmt.Errorf("price request failed: %s", resp.Status)
+	}
+
+	var result map[string]map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return quotes, fmt.Errorf("failed to decode prices: %w", err)
+	}
+
+	// Map results back to symbols
+	for id, priceData := range result {
+		mapped, ok := symbolMap[id]
+		if !ok {
+			continue
+		}
+		// Skip anything that is not a number (might be an error message)
+		price, ok := priceData["usd"].(float64)
+		if !ok {
+			continue
+		}
+
+		timestamp := time.Now()
+		if updatedAt, ok := priceData["last_updated_at"].(float64); ok {
+			timestamp = time.Unix(int64(updatedAt), 0)
+		}
+
+		for _, symbol := range mapped {
+			quotes[symbol] = Quote{
+				Symbol:    symbol,
+				Price:     price,
+				Currency:  "USD",
+				Timestamp: timestamp,
+				Source:    p.Name(),
+			}
+		}
+	}
+
+	return quotes, nil
+}
+
+// resolveIDs maps upper-case symbols to coin IDs, preferring the resolver
+// over the built-in mapping
+func (p *CoinGeckoProvider) resolveIDs(symbols []string) map[string]string {
+	upper := make([]string, len(symbols))
+	for i, symbol := range symbols {
+		upper[i] = strings.ToUpper(symbol)
+	}
+
+	var resolved map[string]string
+	if p.resolver != nil {
+		resolved = p.resolver.ResolveIDs(upper)
+	}
+
+	ids := make(map[string]string)
+	for _, symbol := range upper {
+		if id, ok := resolved[symbol]; ok && id != "" {
+			ids[symbol] = id
+		} else if id, ok := cryptoIDMapping[symbol]; ok {
+			ids[symbol] = id
+		}
+	}
+	return ids
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// CryptoCompareProvider prices crypto assets by ticker symbol. It needs no ID
+// mapping, which makes it a useful fallback for CoinGecko.
+type CryptoCompareProvider struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+func NewCryptoCompareProvider(baseURL string, httpClient *http.Client) *CryptoCompareProvider {
+	return &CryptoCompareProvider{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: httpClient,
+	}
+}
+
+func (p *CryptoCompareProvider) Name() string {
+	return "cryptocompare"
+}
+
+func (p *CryptoCompareProvider) GetPrices(symbols []string) (map[string]Quote, error) {
+	quotes := make(map[string]Quote)
+	if len(symbols) == 0 {
+		return quotes, nil
+	}
+
+	upper := make([]string, len(symbols))
+	for i, symbol := range symbols {
+		upper[i] = strings.ToUpper(symbol)
+	}
+
+	endpoint := fmt.Sprintf("%s/data/pricemulti?fsyms=%s&tsyms=USD", p.baseURL, url.QueryEscape(strings.Join(upper, ",")))
+	resp, err := p.httpClient.Get(endpoint)
+	if err != nil {
+		return quotes, fmt.Errorf("failed to fetch prices: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return quotes, fmt.Errorf("price request failed: %s", resp.Status)
+	}
+
+	// Successful responses map symbols to {"USD": price}; errors use a flat
+	// {"Response": "Error", "Message": ...} object instead
+	var result map[string]json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return quotes, fmt.Errorf("failed to decode prices: %w", err)
+	}
+
+	now := time.Now()
+	for _, symbol := range upper {
+		raw, ok := result[symbol]
+		if !ok {
+			continue
+		}
+		var priceData map[string]float64
+		if err := json.Unmarshal(raw, &priceData); err != nil {
+			continue
+		}
+		price, ok := priceData["USD"]
+		if !ok {
+			continue
+		}
+		quotes[symbol] = Quote{
+			Symbol:    symbol,
+			Price:     price,
+			Currency:  "USD",
+			Timestamp: now,
+			Source:    p.Name(),
+		}
+	}
+
+	return quotes, nil
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ExchangeRateProvider prices fiat currencies in USD through ExchangeRate-API
+type ExchangeRateProvider struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+func NewExchangeRateProvider(baseURL string, httpClient *http.Client) *ExchangeRateProvider {
+	return &ExchangeRateProvider{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: httpClient,
+	}
+}
+
+func (p *ExchangeRateProvider) Name() string {
+	return "exchangerate-api"
+}
+
+func (p *ExchangeRateProvider) GetPrices(symbols []string) (map[string]Quote, error) {
+	quotes := make(map[string]Quote)
+	now := time.Now()
+
+	// Handle fixed rate currencies
+	var needsFetch bool
+	for _, symbol := range symbols {
+		symbol = strings.ToUpper(symbol)
+		switch symbol {
+		case "USD":
+			quotes[symbol] = p.quote(symbol, 1.0, now)
+		case "AED":
+			// AED is pegged to USD at 3.6725 AED = 1 USD
+			quotes[symbol] = p.quote(symbol, 1.0/3.6725, now)
+		default:
+			needsFetch = true
+		}
+	}
+
+	if !needsFetch {
+		return quotes, nil
+	}
+
+	resp, err := p.httpClient.Get(p.baseURL + "/latest/USD")
+	if err != nil {
+		return quotes, fmt.Errorf("failed to fetch exchange rates: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return quotes, fmt.Errorf("exchange rate request failed: %s", resp.Status)
+	}
+
+	var result struct {
+		Rates           map[string]float64 `json:"rates"`
+		TimeLastUpdated int64              `json:"time_last_updated"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return quotes, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	timestamp := now
+	if result.TimeLastUpdated > 0 {
+		timestamp = time.Unix(result.TimeLastUpdated, 0)
+	}
+
+	for _, symbol := range symbols {
+		symbol = strings.ToUpper(symbol)
+		if _, ok := quotes[symbol]; ok {
+			continue
+		}
+		if rate, ok := result.Rates[symbol]; ok && rate > 0 {
+			quotes[symbol] = p.quote(symbol, 1.0/rate, timestamp) // Convert to USD rate
+		}
+	}
+
+	return quotes, nil
+}
+
+func (p *ExchangeRateProvider) quote(symbol string, price float64, timestamp time.Time) Quote {
+	return Quote{
+		Symbol:    symbol,
+		Price:     price,
+		Currency:  "USD",
+		Timestamp: timestamp,
+		Source:    p.Name(),
+	}
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bioharz/budget/internal/models"
+)
+
+// Quote is the price of one unit of Symbol, expressed in Currency
+type Quote struct {
+	Symbol    string
+	Price     float64
+	Currency  string
+	Timestamp time.Time
+	Source    string
+}
+
+// PriceProvider fetches quotes for a batch of symbols. Symbols the provider
+// does not know are left out of the result rather than reported as errors.
+type PriceProvider interface {
+	Name() string
+	GetPrices(symbols []string) (map[string]Quote, error)
+}
+
+// Config holds the base URLs of the price APIs so they can be pointed at
+// mirrors, paid tiers or test servers
+type Config struct {
+	CoinGeckoURL     string
+	CryptoCompareURL string
+	ExchangeRateURL  string
+	StockQuoteURL    string
+	HTTPClient       *http.Client
+
+	// CoinIDResolver, when set, resolves crypto symbols beyond the built-in
+	// CoinGecko mapping
+	CoinIDResolver CoinIDResolver
+}
+
+// Environment variables that override the default base URLs
+const (
+	EnvCoinGeckoURL     = "MINIMAL_MONEY_COINGECKO_URL"
+	EnvCryptoCompareURL = "MINIMAL_MONEY_CRYPTOCOMPARE_URL"
+	EnvExchangeRateURL  = "MINIMAL_MONEY_EXCHANGERATE_URL"
+	EnvStockQuoteURL    = "MINIMAL_MONEY_STOCK_URL"
+)
+
+func DefaultConfig() Config {
+	return Config{
+		CoinGeckoURL:     "https://api.coingecko.com/api/v3",
+		CryptoCompareURL: "https://min-api.cryptocompare.com",
+		ExchangeRateURL:  "https://api.exchangerate-api.com/v4",
+		StockQuoteURL:    "https://query1.finance.yahoo.com",
+		HTTPClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+// ConfigFromEnv returns the default config with any base URL overrides from
+// the environment applied
+func ConfigFromEnv() Config {
+	cfg := DefaultConfig()
+	overrides := map[string]*string{
+		EnvCoinGeckoURL:     &cfg.CoinGeckoURL,
+		EnvCryptoCompareURL: &cfg.CryptoCompareURL,
+		EnvExchangeRateURL:  &cfg.ExchangeRateURL,
+		EnvStockQuoteURL:    &cfg.StockQuoteURL,
+	}
+	for env, field := range overrides {
+		if value := os.Getenv(env); value != "" {
+			*field = strings.TrimRight(value, "/")
+		}
+	}
+	return cfg
+}
+
+// Registry keeps an ordered fallback chain of providers per asset type
+type Registry struct {
+	providers map[models.AssetType][]PriceProvider
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		providers: make(map[models.AssetType][]PriceProvider),
+	}
+}
+
+// NewDefaultRegistry wires the built-in providers: CoinGecko with CryptoCompare
+// as fallback for crypto, ExchangeRate-API for fiat and Yahoo Finance for stocks
+func NewDefaultRegistry(cfg Config) *Registry {
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = DefaultConfig().HTTPClient
+	}
+
+	coingecko := NewCoinGeckoProvider(cfg.CoinGeckoURL, cfg.HTTPClient)
+	if cfg.CoinIDResolver != nil {
+		coingecko.WithResolver(cfg.CoinIDResolver)
+	}
+
+	r := NewRegistry()
+	r.Register(models.AssetTypeCrypto,
+		coingecko,
+		NewCryptoCompareProvider(cfg.CryptoCompareURL, cfg.HTTPClient),
+	)
+	r.Register(models.AssetTypeFiat, NewExchangeRateProvider(cfg.ExchangeRateURL, cfg.HTTPClient))
+	r.Register(models.AssetTypeStock, NewStockQuoteProvider(cfg.StockQuoteURL, cfg.HTTPClient))
+	return r
+}
+
+// Register appends providers to the chain for an asset type. Earlier
+// providers are asked first.
+func (r *Registry) Register(assetType models.AssetType, providers ...PriceProvider) {
+	r.providers[assetType] = append(r.providers[assetType], providers...)
+}
+
+// Providers returns the fallback chain for an asset type
+func (r *Registry) Providers(assetType models.AssetType) []PriceProvider {
+	return r.providers[assetType]
+}
+
+// Fetch walks the provider chain for assetType, asking each provider only for
+// the symbols that are still unpriced. It fails only when no provider
+// returned anything and at least one of them errored.
+func (r *Registry) Fetch(assetType models.AssetType, symbols []string) (map[string]Quote, error) {
+	quotes := make(map[string]Quote)
+	remaining := symbols
+	var errs []error
+
+	for _, provider := range r.providers[assetType] {
+		if len(remaining) == 0 {
+			break
+		}
+
+		result, err := provider.GetPrices(remaining)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", provider.Name(), err))
+		}
+		for symbol, quote := range result {
+			quotes[symbol] = quote
+		}
+
+		var missing []string
+		for _, symbol := range remaining {
+			if _, ok := quotes[symbol]; !ok {
+				missing = append(missing, symbol)
+			}
+		}
+		remaining = missing
+	}
+
+	if len(quotes) == 0 && len(errs) > 0 {
+		return quotes, errors.Join(errs...)
+	}
+	return quotes, nil
+}
@@ -0,0 +1,215 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bioharz/budget/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubProvider answers from a fixed price table and records what it was asked
+type stubProvider struct {
+	name   string
+	prices map[string]float64
+	err    error
+	asked  [][]string
+}
+
+func (p *stubProvider) Name() string {
+	return p.name
+}
+
+func (p *stubProvider) GetPrices(symbols []string) (map[string]Quote, error) {
+	p.asked = append(p.asked, symbols)
+	quotes := make(map[string]Quote)
+	if p.err != nil {
+		return quotes, p.err
+	}
+	for _, symbol := range symbols {
+		if price, ok := p.prices[symbol]; ok {
+			quotes[symbol] = Quote{Symbol: symbol, Price: price, Currency: "USD", Timestamp: time.Now(), Source: p.name}
+		}
+	}
+	return quotes, nil
+}
+
+func TestRegistry_FallbackChain(t *testing.T) {
+	primary := &stubProvider{name: "primary", prices: map[string]float64{"BTC": 50000}}
+	secondary := &stubProvider{name: "secondary", prices: map[string]float64{"BTC": 49000, "ARB": 1.2}}
+
+	registry := NewRegistry()
+	registry.Register(models.AssetTypeCrypto, primary, secondary)
+
+	quotes, err := registry.Fetch(models.AssetTypeCrypto, []string{"BTC", "ARB", "FAKE"})
+	require.NoError(t, err)
+
+	// Primary wins for symbols it knows, the fallback fills the gaps
+	assert.Equal(t, 50000.0, quotes["BTC"].Price)
+	assert.Equal(t, "primary", quotes["BTC"].Source)
+	assert.Equal(t, 1.2, quotes["ARB"].Price)
+	assert.Equal(t, "secondary", quotes["ARB"].Source)
+	assert.NotContains(t, quotes, "FAKE")
+
+	// The fallback is only asked for what is still missing
+	assert.Equal(t, [][]string{{"ARB", "FAKE"}}, secondary.asked)
+}
+
+func TestRegistry_PrimaryFailure(t *testing.T) {
+	primary := &stubProvider{name: "primary", err: errors.New("rate limited")}
+	secondary := &stubProvider{name: "secondary", prices: map[string]float64{"BTC": 49000}}
+
+	registry := NewRegistry()
+	registry.Register(models.AssetTypeCrypto, primary, secondary)
+
+	quotes, err := registry.Fetch(models.AssetTypeCrypto, []string{"BTC"})
+	require.NoError(t, err)
+	assert.Equal(t, 49000.0, quotes["BTC"].Price)
+
+	// When every provider fails the errors are reported
+	secondary.err = errors.New("down")
+	quotes, err = registry.Fetch(models.AssetTypeCrypto, []string{"BTC"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "primary: rate limited")
+	assert.Contains(t, err.Error(), "secondary: down")
+	assert.Empty(t, quotes)
+}
+
+func TestRegistry_UnregisteredType(t *testing.T) {
+	registry := NewRegistry()
+	quotes, err := registry.Fetch(models.AssetTypeOther, []string{"HOUSE"})
+	require.NoError(t, err)
+	assert.Empty(t, quotes)
+	assert.Empty(t, registry.Providers(models.AssetTypeOther))
+}
+
+func TestPriceClient_CachesQuotes(t *testing.T) {
+	provider := &stubProvider{name: "stub", prices: map[string]float64{"BTC": 50000}}
+	registry := NewRegistry()
+	registry.Register(models.AssetTypeCrypto, provider)
+	client := NewPriceClientWithRegistry(registry)
+
+	prices, err := client.GetCryptoPrices([]string{"btc"})
+	require.NoError(t, err)
+	assert.Equal(t, 50000.0, prices["BTC"])
+
+	_, err = client.GetCryptoPrices([]string{"BTC"})
+	require.NoError(t, err)
+	assert.Len(t, provider.asked, 1)
+}
+
+func TestDefaultRegistry_ConfigurableURLs(t *testing.T) {
+	var coingeckoCalls, cryptocompareCalls, fiatCalls int32
+
+	coingecko := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&coingeckoCalls, 1)
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer coingecko.Close()
+
+	cryptocompare := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&cryptocompareCalls, 1)
+		assert.Equal(t, "/data/pricemulti", r.URL.Path)
+		_, _ = w.Write([]byte(`{"BTC":{"USD":51000.5}}`))
+	}))
+	defer cryptocompare.Close()
+
+	fiat := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fiatCalls, 1)
+		assert.Equal(t, "/latest/USD", r.URL.Path)
+		_, _ = w.Write([]byte(`{"rates":{"USD":1,"EUR":0.8},"time_last_updated":1700000000}`))
+	}))
+	defer fiat.Close()
+
+	client := NewPriceClientWithConfig(Config{
+		CoinGeckoURL:     coingecko.URL,
+		CryptoCompareURL: cryptocompare.URL,
+		ExchangeRateURL:  fiat.URL,
+	})
+
+	prices, err := client.GetCryptoPrices([]string{"BTC"})
+	require.NoError(t, err)
+	assert.Equal(t, 51000.5, prices["BTC"])
+	assert.Equal(t, int32(1), atomic.LoadInt32(&coingeckoCalls))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&cryptocompareCalls))
+
+	rates, err := client.GetFiatRates([]string{"USD", "EUR", "AED"})
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, rates["USD"])
+	assert.Equal(t, 1.25, rates["EUR"])
+	assert.InDelta(t, 0.2723, rates["AED"], 1e-4)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fiatCalls))
+}
+
+func TestCoinGeckoProvider_GetPrices(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/simple/price", r.URL.Path)
+		assert.Equal(t, "bitcoin", r.URL.Query().Get("ids"))
+		_, _ = w.Write([]byte(`{"bitcoin":{"usd":50000,"last_updated_at":1700000000}}`))
+	}))
+	defer server.Close()
+
+	provider := NewCoinGeckoProvider(server.URL, server.Client())
+	quotes, err := provider.GetPrices([]string{"BTC", "NOTMAPPED"})
+	require.NoError(t, err)
+	require.Len(t, quotes, 1)
+	assert.Equal(t, 50000.0, quotes["BTC"].Price)
+	assert.Equal(t, int64(1700000000), quotes["BTC"].Timestamp.Unix())
+	assert.Equal(t, "coingecko", quotes["BTC"].Source)
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv(EnvCoinGeckoURL, "https://pro-api.coingecko.com/api/v3/")
+	t.Setenv(EnvStockQuoteURL, "")
+
+	cfg := ConfigFromEnv()
+	assert.Equal(t, "https://pro-api.coingecko.com/api/v3", cfg.CoinGeckoURL)
+	assert.Equal(t, DefaultConfig().StockQuoteURL, cfg.StockQuoteURL)
+}
+
+// staticResolver resolves from a fixed symbol to coin ID table
+type staticResolver map[string]string
+
+func (r staticResolver) ResolveIDs(symbols []string) map[string]string {
+	ids := make(map[string]string)
+	for _, symbol := range symbols {
+		if id, ok := r[symbol]; ok {
+			ids[symbol] = id
+		}
+	}
+	return ids
+}
+
+func TestCoinGeckoProvider_Resolver(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/coins/list":
+			_, _ = w.Write([]byte(`[{"id":"chainlink","symbol":"link","name":"Chainlink"}]`))
+		case "/simple/price":
+			assert.ElementsMatch(t, []string{"chainlink", "bitcoin"}, strings.Split(r.URL.Query().Get("ids"), ","))
+			_, _ = w.Write([]byte(`{"chainlink":{"usd":15},"bitcoin":{"usd":50000}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	provider := NewCoinGeckoProvider(server.URL, server.Client())
+
+	coins, err := provider.GetCoinList()
+	require.NoError(t, err)
+	assert.Equal(t, []Coin{{ID: "chainlink", Symbol: "link", Name: "Chainlink"}}, coins)
+
+	// The resolver adds LINK; BTC still comes from the built-in mapping
+	provider.WithResolver(staticResolver{"LINK": "chainlink"})
+	quotes, err := provider.GetPrices([]string{"LINK", "BTC"})
+	require.NoError(t, err)
+	assert.Equal(t, 15.0, quotes["LINK"].Price)
+	assert.Equal(t, 50000.0, quotes["BTC"].Price)
+}
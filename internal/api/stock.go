@@ -0,0 +1,151 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// exchangeCurrencies maps exchange suffixes to their trading currency, used
+// when the chart response does not state one
+var exchangeCurrencies = map[string]string{
+	"L":  "GBp",
+	"DE": "EUR",
+	"F":  "EUR",
+	"PA": "EUR",
+	"AS": "EUR",
+	"MI": "EUR",
+	"MC": "EUR",
+	"SW": "CHF",
+	"TO": "CAD",
+	"V":  "CAD",
+	"AX": "AUD",
+	"T":  "JPY",
+	"HK": "HKD",
+	"ST": "SEK",
+	"OL": "NOK",
+	"CO": "DKK",
+}
+
+// minorUnits lists currencies quoted in hundredths of the major unit
+var minorUnits = map[string]string{
+	"GBp": "GBP",
+	"GBX": "GBP",
+	"ZAc": "ZAR",
+	"ILA": "ILS",
+}
+
+// StockQuoteProvider prices stocks and ETFs through the Yahoo Finance chart
+// API, one request per symbol. Symbols may carry an exchange suffix such as
+// VOD.L; quotes stay in the trading currency, with minor units (pence, cents)
+// converted to the major one.
+type StockQuoteProvider struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+func NewStockQuoteProvider(baseURL string, httpClient *http.Client) *StockQuoteProvider {
+	return &StockQuoteProvider{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: httpClient,
+	}
+}
+
+func (p *StockQuoteProvider) Name() string {
+	return "yahoo-finance"
+}
+
+// GetPrices fetches each symbol in turn. Unknown symbols are skipped; any
+// other failure stops the batch and returns the quotes fetched so far.
+func (p *StockQuoteProvider) GetPrices(symbols []string) (map[string]Quote, error) {
+	quotes := make(map[string]Quote)
+	for _, symbol := range symbols {
+		symbol = strings.ToUpper(symbol)
+		quote, ok, err := p.getQuote(symbol)
+		if err != nil {
+			return quotes, err
+		}
+		if ok {
+			quotes[symbol] = quote
+		}
+	}
+	return quotes, nil
+}
+
+// getQuote reads the latest price of one symbol from its chart metadata. It
+// reports false for a symbol Yahoo does not know or has no price for.
+func (p *StockQuoteProvider) getQuote(symbol string) (Quote, bool, error) {
+	endpoint := fmt.Sprintf("%s/v8/finance/chart/%s?range=1d&interval=1d", p.baseURL, url.PathEscape(symbol))
+	resp, err := p.httpClient.Get(endpoint)
+	if err != nil {
+		return Quote{}, false, fmt.Errorf("failed to fetch stock quote for %s: %w", symbol, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return Quote{}, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Quote{}, false, fmt.Errorf("stock quote request for %s failed: %s", symbol, resp.Status)
+	}
+
+	var result struct {
+		Chart struct {
+			Result []struct {
+				Meta struct {
+					RegularMarketPrice *float64 `json:"regularMarketPrice"`
+					RegularMarketTime  int64    `json:"regularMarketTime"`
+					Currency           string   `json:"currency"`
+				} `json:"meta"`
+			} `json:"result"`
+		} `json:"chart"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return Quote{}, false, fmt.Errorf("failed to decode stock quote for %s: %w", symbol, err)
+	}
+	if len(result.Chart.Result) == 0 || result.Chart.Result[0].Meta.RegularMarketPrice == nil {
+		return Quote{}, false, nil
+	}
+
+	meta := result.Chart.Result[0].Meta
+	currency := meta.Currency
+	if currency == "" {
+		currency = exchangeCurrency(symbol)
+	}
+
+	timestamp := time.Now()
+	if meta.RegularMarketTime > 0 {
+		timestamp = time.Unix(meta.RegularMarketTime, 0)
+	}
+
+	return normalizeQuote(Quote{
+		Symbol:    symbol,
+		Price:     *meta.RegularMarketPrice,
+		Currency:  currency,
+		Timestamp: timestamp,
+		Source:    p.Name(),
+	}), true, nil
+}
+
+// exchangeCurrency derives the trading currency from a ticker's exchange suffix
+func exchangeCurrency(symbol string) string {
+	if i := strings.LastIndex(symbol, "."); i >= 0 {
+		if currency, ok := exchangeCurrencies[symbol[i+1:]]; ok {
+			return currency
+		}
+	}
+	return "USD"
+}
+
+// normalizeQuote converts minor-unit quotes (e.g. GBp) to the major currency
+func normalizeQuote(quote Quote) Quote {
+	if major, ok := minorUnits[quote.Currency]; ok {
+		quote.Price /= 100
+		quote.Currency = major
+	}
+	quote.Currency = strings.ToUpper(quote.Currency)
+	return quote
+}
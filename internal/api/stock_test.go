@@ -0,0 +1,79 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStockQuoteProvider_GetPrices(t *testing.T) {
+	charts := map[string]string{
+		"AAPL":   `{"currency":"USD","symbol":"AAPL","regularMarketPrice":190.5,"regularMarketTime":1700000000}`,
+		"VOD.L":  `{"currency":"GBp","symbol":"VOD.L","regularMarketPrice":72.4}`,
+		"SAP.DE": `{"symbol":"SAP.DE","regularMarketPrice":120.0}`,
+		"HALTED": `{"currency":"USD","symbol":"HALTED"}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		symbol, ok := strings.CutPrefix(r.URL.Path, "/v8/finance/chart/")
+		require.True(t, ok, r.URL.Path)
+		meta, ok := charts[symbol]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"chart":{"result":null,"error":{"code":"Not Found","description":"No data found, symbol may be delisted"}}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"chart":{"result":[{"meta":` + meta + `,"timestamp":[],"indicators":{}}],"error":null}}`))
+	}))
+	defer server.Close()
+
+	provider := NewStockQuoteProvider(server.URL, server.Client())
+
+	quotes, err := provider.GetPrices([]string{"aapl", "VOD.L", "SAP.DE", "HALTED", "DELISTED"})
+	require.NoError(t, err)
+
+	assert.Equal(t, 190.5, quotes["AAPL"].Price)
+	assert.Equal(t, "USD", quotes["AAPL"].Currency)
+	assert.Equal(t, int64(1700000000), quotes["AAPL"].Timestamp.Unix())
+	assert.Equal(t, "yahoo-finance", quotes["AAPL"].Source)
+
+	// Pence are converted to pounds
+	assert.InDelta(t, 0.724, quotes["VOD.L"].Price, 1e-9)
+	assert.Equal(t, "GBP", quotes["VOD.L"].Currency)
+
+	// Missing currency falls back to the exchange suffix
+	assert.Equal(t, "EUR", quotes["SAP.DE"].Currency)
+
+	// Unknown symbols and charts without a price are skipped
+	assert.NotContains(t, quotes, "HALTED")
+	assert.NotContains(t, quotes, "DELISTED")
+}
+
+func TestStockQuoteProvider_ServerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	provider := NewStockQuoteProvider(server.URL, server.Client())
+	quotes, err := provider.GetPrices([]string{"AAPL"})
+	assert.Error(t, err)
+	assert.Empty(t, quotes)
+}
+
+func TestExchangeCurrency(t *testing.T) {
+	tests := map[string]string{
+		"AAPL":    "USD",
+		"VOD.L":   "GBp",
+		"SAP.DE":  "EUR",
+		"NESN.SW": "CHF",
+		"7203.T":  "JPY",
+		"BRK.B":   "USD",
+	}
+	for symbol, expected := range tests {
+		assert.Equal(t, expected, exchangeCurrency(symbol), symbol)
+	}
+}
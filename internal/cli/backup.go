@@ -0,0 +1,136 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/bioharz/budget/internal/db"
+)
+
+func (a *App) runBackup(args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "list":
+			return a.backupList(args[1:])
+		case "keep":
+			return a.backupKeep(args[1:])
+		}
+	}
+
+	fs := a.newFlagSet("backup")
+	dir := fs.String("dir", "", "Directory to write the backup to (default: backups next to the database)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unknown backup subcommand %q", fs.Arg(0))
+	}
+	if *dir == "" {
+		*dir = db.BackupDir()
+	}
+
+	path, err := db.CreateBackup(*dir, "")
+	if err != nil {
+		return err
+	}
+	removed, err := db.PruneBackups(*dir, db.BackupKeep())
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(a.out, "Backed up to %s\n", path); err != nil {
+		return err
+	}
+	if len(removed) > 0 {
+		_, err = fmt.Fprintf(a.out, "Removed %d old backups\n", len(removed))
+	}
+	return err
+}
+
+func (a *App) backupList(args []string) error {
+	fs := a.newFlagSet("backup list")
+	dir := fs.String("dir", "", "Directory holding the backups (default: backups next to the database)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *dir == "" {
+		*dir = db.BackupDir()
+	}
+
+	backups, err := db.ListBackups(*dir)
+	if err != nil {
+		return err
+	}
+	if len(backups) == 0 {
+		_, err := fmt.Fprintf(a.out, "No backups in %s\n", *dir)
+		return err
+	}
+
+	w := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TAKEN\tREASON\tSIZE\tFILE")
+	for _, backup := range backups {
+		reason := backup.Reason
+		if reason == "" {
+			reason = "manual"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d KB\t%s\n",
+			backup.Time.Format("2006-01-02 15:04:05"), reason, (backup.Size+1023)/1024, backup.Path)
+	}
+	return w.Flush()
+}
+
+// backupKeep prints how many backups are kept, or sets it when a number is
+// given
+func (a *App) backupKeep(args []string) error {
+	if len(args) == 0 {
+		_, err := fmt.Fprintln(a.out, db.BackupKeep())
+		return err
+	}
+
+	keep, err := strconv.Atoi(args[0])
+	if err != nil || keep < 0 {
+		return fmt.Errorf("invalid number of backups %q", args[0])
+	}
+	if err := a.settingRepo.Set(db.SettingBackupKeep, strconv.Itoa(keep)); err != nil {
+		return err
+	}
+	if keep == 0 {
+		_, err = fmt.Fprintln(a.out, "Keeping all backups")
+		return err
+	}
+	_, err = fmt.Fprintf(a.out, "Keeping the newest %d backups\n", keep)
+	return err
+}
+
+func (a *App) runRestore(args []string) error {
+	fs := a.newFlagSet("restore")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("restore needs exactly one backup file")
+	}
+
+	saved, err := db.Restore(fs.Arg(0), a.backupPassphrase)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(a.out, "Restored %s\nThe replaced database was saved to %s\n", fs.Arg(0), saved)
+	return err
+}
+
+// backupPassphrase asks for the passphrase of an encrypted backup that the
+// open database's key does not fit
+func (a *App) backupPassphrase() (string, error) {
+	if passphrase := os.Getenv(db.EnvPassphrase); passphrase != "" {
+		return passphrase, nil
+	}
+	passphrase, err := a.readPassphrase("Backup passphrase: ")
+	if errors.Is(err, ErrNoTerminal) {
+		return "", fmt.Errorf("%w, set %s", err, db.EnvPassphrase)
+	}
+	return passphrase, err
+}
@@ -0,0 +1,222 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/bioharz/budget/internal/models"
+	"github.com/bioharz/budget/internal/repository"
+	"github.com/bioharz/budget/internal/service"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+const usage = `Usage: budget [flags] <command> [arguments]
+
+Without a command the interactive terminal UI is started.
+
+Flags:
+  --db PATH                              Database file (default $XDG_DATA_HOME/minimal-money/budget.db,
+                                         overridden by $MINIMAL_MONEY_DB)
+
+An encrypted database asks for its passphrase, or reads it from
+$MINIMAL_MONEY_PASSPHRASE.
+
+Commands:
+  holdings list [--currency C]           List all holdings with cached values
+  holdings add --account A --asset S --amount N [--price P]
+                                         Add a holding
+  prices refresh                         Fetch current prices for all assets
+  prices set --asset S --price P [--date YYYY-MM-DD]
+                                         Set a manual price that refreshes keep
+  prices clear --asset S                 Return an asset to automatic pricing
+  transactions list [--account A] [--asset S]
+                                         Show the transaction ledger
+  transactions add --type T --account A --asset S --quantity N [--price P]
+                   [--fee F] [--to-account B] [--date YYYY-MM-DD] [--note TEXT]
+                                         Record a buy, sell, deposit, withdrawal,
+                                         transfer, fee, income or adjustment
+  tax report [--method fifo|lifo|hifo|specific] [--year YYYY] [--lot SALE:LOT=N ...]
+                                         Realized gains per sale and short/long-term
+                                         totals per tax year
+  tax lots [--method M] [--asset S]      Show open purchase lots
+  import csv [--map SPEC] [--dry-run] FILE
+                                         Import holdings or transactions from CSV;
+                                         SPEC maps fields to columns, e.g.
+                                         account=Wallet,asset=Coin,amount=Qty
+  import coinbase|kraken|binance [--account NAME] [--dry-run] [--allow-unpriced] FILE
+                                         Import an exchange's transaction export;
+                                         trades against other fiat currencies
+                                         need --allow-unpriced
+  export [--format csv|json|md] [--currency C] [--audit] [--output FILE]
+                                         Export accounts, assets and valued holdings,
+                                         optionally with the audit log
+  total [--refresh] [--currency C]       Print the total portfolio value in the base currency
+  currency [USD|EUR|GBP|CHF|BTC]         Show or set the base currency
+  undo                                   Revert the most recent change to holdings,
+                                         accounts or assets
+  redo                                   Apply the change undone last again
+  trash [list]                           List deleted holdings, accounts and assets
+  trash restore|purge holding|account|asset ID
+                                         Take an item out of the trash, or remove it
+                                         for good
+  trash purge                            Purge the items deleted more than trash days ago
+  trash days [N]                         Show or set after how many days deleted items
+                                         are purged (default 30, 0 keeps them)
+  encryption status|enable|disable|passphrase
+                                         Show, turn on or off, or change the passphrase
+                                         of database encryption
+  backup [--dir DIR]                     Save a timestamped copy of the database, safe
+                                         while the terminal UI is running
+  backup list [--dir DIR]                List backups, newest first
+  backup keep [N]                        Show or set how many backups are kept
+                                         (default 10, 0 keeps all)
+  restore FILE                           Check a backup and replace the database with it;
+                                         the replaced database is backed up first
+  help                                   Show this help
+`
+
+// App runs headless subcommands against the repository and service layers
+type App struct {
+	out             io.Writer
+	assetRepo       *repository.AssetRepository
+	exportService   *service.ExportService
+	transactionRepo *repository.TransactionRepository
+	holdingService  *service.HoldingService
+	importService   *service.ImportService
+	ledgerService   *service.LedgerService
+	priceService    *service.PriceService
+	settingRepo     *repository.SettingRepository
+	taxService      *service.TaxService
+	trashService    *service.TrashService
+	undoService     *service.UndoService
+	readPassphrase  func(prompt string) (string, error)
+}
+
+func New(out io.Writer) *App {
+	return &App{
+		out:             out,
+		assetRepo:       repository.NewAssetRepository(),
+		exportService:   service.NewExportService(),
+		transactionRepo: repository.NewTransactionRepository(),
+		holdingService:  service.NewHoldingService(),
+		importService:   service.NewImportService(),
+		ledgerService:   service.NewLedgerService(),
+		priceService:    service.NewPriceService(),
+		settingRepo:     repository.NewSettingRepository(),
+		taxService:      service.NewTaxService(),
+		trashService:    service.NewTrashService(),
+		undoService:     service.NewUndoService(),
+		readPassphrase:  ReadPassphrase,
+	}
+}
+
+func NewWithDB(database *gorm.DB, out io.Writer) *App {
+	return &App{
+		out:             out,
+		assetRepo:       repository.NewAssetRepositoryWithDB(database),
+		exportService:   service.NewExportServiceWithDB(database),
+		transactionRepo: repository.NewTransactionRepositoryWithDB(database),
+		holdingService:  service.NewHoldingServiceWithDB(database),
+		importService:   service.NewImportServiceWithDB(database),
+		ledgerService:   service.NewLedgerServiceWithDB(database),
+		priceService:    service.NewPriceServiceWithDB(database),
+		settingRepo:     repository.NewSettingRepositoryWithDB(database),
+		taxService:      service.NewTaxServiceWithDB(database),
+		trashService:    service.NewTrashServiceWithDB(database),
+		undoService:     service.NewUndoServiceWithDB(database),
+		readPassphrase:  ReadPassphrase,
+	}
+}
+
+// Run executes the subcommand named by args[0]
+func (a *App) Run(args []string) error {
+	if len(args) == 0 {
+		return a.help()
+	}
+
+	switch args[0] {
+	case "holdings":
+		return a.runHoldings(args[1:])
+	case "prices":
+		return a.runPrices(args[1:])
+	case "transactions":
+		return a.runTransactions(args[1:])
+	case "tax":
+		return a.runTax(args[1:])
+	case "import":
+		return a.runImport(args[1:])
+	case "export":
+		return a.runExport(args[1:])
+	case "encryption":
+		return a.runEncryption(args[1:])
+	case "backup":
+		return a.runBackup(args[1:])
+	case "restore":
+		return a.runRestore(args[1:])
+	case "total":
+		return a.runTotal(args[1:])
+	case "currency":
+		return a.runCurrency(args[1:])
+	case "undo":
+		return a.runUndo(args[1:])
+	case "redo":
+		return a.runRedo(args[1:])
+	case "trash":
+		return a.runTrash(args[1:])
+	case "help", "-h", "--help":
+		return a.help()
+	default:
+		return fmt.Errorf("unknown command %q (see 'budget help')", args[0])
+	}
+}
+
+func (a *App) help() error {
+	_, err := fmt.Fprint(a.out, usage)
+	return err
+}
+
+// findAsset looks up an existing asset by symbol
+func (a *App) findAsset(symbol string) (models.Asset, error) {
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+	if symbol == "" {
+		return models.Asset{}, fmt.Errorf("--asset is required")
+	}
+	asset, err := a.assetRepo.GetBySymbol(symbol)
+	if err != nil {
+		return models.Asset{}, fmt.Errorf("unknown asset %q", symbol)
+	}
+	return asset, nil
+}
+
+func (a *App) newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(a.out)
+	return fs
+}
+
+// decimalValue is a flag.Value that parses numbers without going through
+// float64
+type decimalValue decimal.Decimal
+
+func (d *decimalValue) String() string {
+	return (*decimal.Decimal)(d).String()
+}
+
+func (d *decimalValue) Set(value string) error {
+	parsed, err := decimal.NewFromString(value)
+	if err != nil {
+		return fmt.Errorf("invalid number %q", value)
+	}
+	*d = decimalValue(parsed)
+	return nil
+}
+
+// decimalFlag defines a decimal flag like fs.Float64 does for floats
+func decimalFlag(fs *flag.FlagSet, name string, value decimal.Decimal, usage string) *decimal.Decimal {
+	d := value
+	fs.Var((*decimalValue)(&d), name, usage)
+	return &d
+}
@@ -0,0 +1,476 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bioharz/budget/internal/db"
+	"github.com/bioharz/budget/internal/models"
+	"github.com/bioharz/budget/internal/repository"
+	"github.com/bioharz/budget/internal/service"
+	"github.com/bioharz/budget/test/helpers"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApp_HoldingsAddAndList(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	var out bytes.Buffer
+	app := NewWithDB(db, &out)
+
+	err := app.Run([]string{"holdings", "add", "--account", "Ledger", "--asset", "btc", "--amount", "0.5", "--price", "40000"})
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), "Added 0.5 BTC to Ledger")
+
+	// Account and asset are created on first use
+	account, err := repository.NewAccountRepositoryWithDB(db).GetByName("Ledger")
+	require.NoError(t, err)
+	asset, err := repository.NewAssetRepositoryWithDB(db).GetBySymbol("BTC")
+	require.NoError(t, err)
+	assert.Equal(t, models.AssetTypeCrypto, asset.Type)
+
+	require.NoError(t, repository.NewPriceCacheRepositoryWithDB(db).Upsert(asset.ID, decimal.NewFromInt(50000)))
+
+	out.Reset()
+	require.NoError(t, app.Run([]string{"holdings", "list"}))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], "ACCOUNT")
+	assert.Contains(t, lines[1], account.Name)
+	assert.Contains(t, lines[1], "BTC")
+	assert.Contains(t, lines[1], "25000.00")
+
+	// Audit trail records the CLI change like the TUI does, along with the
+	// account and asset it created
+	logs, err := repository.NewAuditLogRepository(db).GetAll(0)
+	require.NoError(t, err)
+	entities := make(map[models.AuditLogEntityType]int)
+	for _, log := range logs {
+		entities[log.EntityType]++
+	}
+	assert.Equal(t, map[models.AuditLogEntityType]int{
+		models.AuditEntityAccount: 1,
+		models.AuditEntityAsset:   1,
+		models.AuditEntityHolding: 1,
+	}, entities)
+}
+
+func TestApp_Total(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	var out bytes.Buffer
+	app := NewWithDB(db, &out)
+
+	require.NoError(t, app.Run([]string{"holdings", "add", "--account", "Bank", "--asset", "USD", "--amount", "1000"}))
+	require.NoError(t, app.Run([]string{"holdings", "add", "--account", "Bank", "--asset", "EUR", "--amount", "100"}))
+
+	usd, err := repository.NewAssetRepositoryWithDB(db).GetBySymbol("USD")
+	require.NoError(t, err)
+	eur, err := repository.NewAssetRepositoryWithDB(db).GetBySymbol("EUR")
+	require.NoError(t, err)
+	require.NoError(t, repository.NewPriceCacheRepositoryWithDB(db).UpsertBatch(map[uint]decimal.Decimal{
+		usd.ID: decimal.NewFromInt(1),
+		eur.ID: decimal.NewFromFloat(1.1),
+	}))
+
+	out.Reset()
+	require.NoError(t, app.Run([]string{"total"}))
+	assert.Equal(t, "1110.00\n", out.String())
+}
+
+func TestApp_UndoRedo(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	var out bytes.Buffer
+	app := NewWithDB(db, &out)
+
+	require.NoError(t, app.Run([]string{"holdings", "add", "--account", "Ledger", "--asset", "BTC", "--amount", "0.5"}))
+
+	out.Reset()
+	require.NoError(t, app.Run([]string{"undo"}))
+	assert.Equal(t, "Undid create of holding #1\n", out.String())
+	holdings, err := repository.NewHoldingRepositoryWithDB(db).GetAll()
+	require.NoError(t, err)
+	assert.Empty(t, holdings)
+
+	out.Reset()
+	require.NoError(t, app.Run([]string{"redo"}))
+	assert.Equal(t, "Redid create of holding #1\n", out.String())
+	holdings, err = repository.NewHoldingRepositoryWithDB(db).GetAll()
+	require.NoError(t, err)
+	require.Len(t, holdings, 1)
+	assert.Equal(t, "0.5", holdings[0].Amount.String())
+
+	assert.EqualError(t, app.Run([]string{"redo"}), "nothing to redo")
+}
+
+func TestApp_Trash(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	var out bytes.Buffer
+	app := NewWithDB(db, &out)
+
+	require.NoError(t, app.Run([]string{"holdings", "add", "--account", "Ledger", "--asset", "BTC", "--amount", "0.5"}))
+	holdings, err := repository.NewHoldingRepositoryWithDB(db).GetAll()
+	require.NoError(t, err)
+	require.NoError(t, service.NewLedgerServiceWithDB(db).CloseHolding(holdings[0]))
+
+	out.Reset()
+	require.NoError(t, app.Run([]string{"trash"}))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], "DELETED")
+	assert.Contains(t, lines[1], "holding")
+	assert.Contains(t, lines[1], "0.5 BTC in Ledger")
+
+	out.Reset()
+	require.NoError(t, app.Run([]string{"trash", "restore", "holding", "1"}))
+	assert.Equal(t, "Restored holding #1\n", out.String())
+	assert.ErrorContains(t, app.Run([]string{"trash", "purge", "holding", "1"}), "not in the trash")
+	assert.ErrorContains(t, app.Run([]string{"trash", "purge", "wallet", "1"}), "unknown trash item type")
+
+	require.NoError(t, service.NewLedgerServiceWithDB(db).CloseHolding(holdings[0]))
+	require.NoError(t, db.Exec("UPDATE holdings SET deleted_at = ?", time.Now().AddDate(0, 0, -40)).Error)
+	out.Reset()
+	require.NoError(t, app.Run([]string{"trash", "purge"}))
+	assert.Equal(t, "Purged 1 expired item(s)\n", out.String())
+
+	out.Reset()
+	require.NoError(t, app.Run([]string{"trash", "days", "7"}))
+	assert.Equal(t, "Purging deleted items after 7 days\n", out.String())
+	out.Reset()
+	require.NoError(t, app.Run([]string{"trash", "days"}))
+	assert.Equal(t, "7\n", out.String())
+	assert.Error(t, app.Run([]string{"trash", "days", "-1"}))
+}
+
+func TestApp_PricesSetAndClear(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	var out bytes.Buffer
+	app := NewWithDB(db, &out)
+
+	require.NoError(t, app.Run([]string{"holdings", "add", "--account", "Home", "--asset", "HOUSE", "--amount", "1"}))
+
+	out.Reset()
+	require.NoError(t, app.Run([]string{"prices", "set", "--asset", "house", "--price", "350000", "--date", "2025-03-01"}))
+	assert.Equal(t, "Set HOUSE to 350000.00 USD as of 2025-03-01\n", out.String())
+
+	out.Reset()
+	require.NoError(t, app.Run([]string{"total"}))
+	assert.Equal(t, "350000.00\n", out.String())
+
+	house, err := repository.NewAssetRepositoryWithDB(db).GetBySymbol("HOUSE")
+	require.NoError(t, err)
+	cache, err := repository.NewPriceCacheRepositoryWithDB(db).GetByAssetID(house.ID)
+	require.NoError(t, err)
+	assert.True(t, cache.Manual)
+
+	out.Reset()
+	require.NoError(t, app.Run([]string{"prices", "clear", "--asset", "HOUSE"}))
+	cache, err = repository.NewPriceCacheRepositoryWithDB(db).GetByAssetID(house.ID)
+	require.NoError(t, err)
+	assert.False(t, cache.Manual)
+
+	assert.Error(t, app.Run([]string{"prices", "set", "--asset", "HOUSE"}))
+	assert.Error(t, app.Run([]string{"prices", "set", "--asset", "HOUSE", "--price", "1", "--date", "03/01/2025"}))
+	assert.Error(t, app.Run([]string{"prices", "set", "--asset", "NOPE", "--price", "1"}))
+}
+
+func TestApp_Transactions(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	var out bytes.Buffer
+	app := NewWithDB(db, &out)
+
+	require.NoError(t, app.Run([]string{"transactions", "add", "--type", "buy", "--account", "Exchange", "--asset", "eth",
+		"--quantity", "3", "--price", "2000", "--date", "2024-02-01"}))
+	assert.Contains(t, out.String(), "ETH balance in Exchange: 3")
+
+	out.Reset()
+	require.NoError(t, app.Run([]string{"transactions", "add", "--type", "transfer", "--account", "Exchange",
+		"--to-account", "Wallet", "--asset", "ETH", "--quantity", "1"}))
+	assert.Contains(t, out.String(), "ETH balance in Exchange: 2")
+
+	out.Reset()
+	require.NoError(t, app.Run([]string{"holdings", "list"}))
+	assert.Contains(t, out.String(), "Wallet")
+
+	out.Reset()
+	require.NoError(t, app.Run([]string{"transactions", "list", "--account", "wallet"}))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[1], "Exchange -> Wallet")
+
+	assert.Error(t, app.Run([]string{"transactions", "add", "--type", "gift", "--account", "Exchange", "--asset", "ETH", "--quantity", "1"}))
+	assert.Error(t, app.Run([]string{"transactions", "add", "--type", "sell", "--account", "Exchange", "--asset", "ETH", "--quantity", "9"}))
+}
+
+func TestApp_InvalidInput(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	var out bytes.Buffer
+	app := NewWithDB(db, &out)
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "unknown command", args: []string{"frobnicate"}},
+		{name: "missing subcommand", args: []string{"holdings"}},
+		{name: "unknown flag", args: []string{"holdings", "add", "--bogus"}},
+		{name: "missing amount", args: []string{"holdings", "add", "--account", "A", "--asset", "BTC"}},
+		{name: "negative price", args: []string{"holdings", "add", "--account", "A", "--asset", "BTC", "--amount", "1", "--price", "-5"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Error(t, app.Run(tt.args))
+		})
+	}
+}
+
+func TestApp_Help(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	var out bytes.Buffer
+	app := NewWithDB(db, &out)
+
+	require.NoError(t, app.Run(nil))
+	assert.Contains(t, out.String(), "holdings list")
+}
+
+func TestApp_Tax(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	var out bytes.Buffer
+	app := NewWithDB(db, &out)
+
+	require.NoError(t, app.Run([]string{"transactions", "add", "--type", "buy", "--account", "Ledger", "--asset", "BTC", "--quantity", "1", "--price", "10000", "--date", "2023-01-10"}))
+	require.NoError(t, app.Run([]string{"transactions", "add", "--type", "buy", "--account", "Ledger", "--asset", "BTC", "--quantity", "1", "--price", "30000", "--date", "2024-03-10"}))
+	require.NoError(t, app.Run([]string{"transactions", "add", "--type", "sell", "--account", "Ledger", "--asset", "BTC", "--quantity", "1", "--price", "40000", "--date", "2024-04-01"}))
+
+	out.Reset()
+	require.NoError(t, app.Run([]string{"tax", "report", "--year", "2024"}))
+	assert.Contains(t, out.String(), "30000.00")
+	assert.Contains(t, out.String(), "long")
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Equal(t, []string{"2024", "40000.00", "10000.00", "0.00", "30000.00", "30000.00"}, strings.Fields(lines[len(lines)-1]))
+
+	out.Reset()
+	require.NoError(t, app.Run([]string{"tax", "report", "--method", "hifo"}))
+	assert.Contains(t, out.String(), "10000.00    0.00")
+
+	out.Reset()
+	require.NoError(t, app.Run([]string{"tax", "report", "--method", "specific", "--lot", "3:2=1"}))
+	assert.Contains(t, out.String(), "short")
+
+	out.Reset()
+	require.NoError(t, app.Run([]string{"tax", "lots", "--asset", "btc"}))
+	lines = strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[1], "30000.00")
+
+	assert.Error(t, app.Run([]string{"tax", "report", "--lot", "3:2=1"}))
+	assert.Error(t, app.Run([]string{"tax", "report", "--method", "average"}))
+}
+
+func TestApp_Currency(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	var out bytes.Buffer
+	app := NewWithDB(db, &out)
+
+	require.NoError(t, app.Run([]string{"currency"}))
+	assert.Equal(t, "USD\n", out.String())
+
+	out.Reset()
+	require.NoError(t, app.Run([]string{"currency", "chf"}))
+	assert.Equal(t, "Base currency set to CHF\n", out.String())
+
+	out.Reset()
+	require.NoError(t, app.Run([]string{"currency"}))
+	assert.Equal(t, "CHF\n", out.String())
+
+	assert.Error(t, app.Run([]string{"currency", "JPY"}))
+	assert.Error(t, app.Run([]string{"total", "--currency", "JPY"}))
+
+	// USD needs no rate
+	out.Reset()
+	require.NoError(t, app.Run([]string{"holdings", "list", "--currency", "usd"}))
+	assert.Contains(t, out.String(), "VALUE (USD)")
+}
+
+func TestApp_ImportCSV(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	var out bytes.Buffer
+	app := NewWithDB(db, &out)
+
+	path := filepath.Join(t.TempDir(), "portfolio.csv")
+	content := "Wallet,Coin,Qty,Cost\nLedger,BTC,0.5,40000\nBank,EUR,1000,\n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	mapping := "account=Wallet,asset=Coin,amount=Qty,price=Cost"
+
+	require.NoError(t, app.Run([]string{"import", "csv", "--map", mapping, path, "--dry-run"}))
+	assert.Contains(t, out.String(), "Dry run: 2 to create, 0 to update, 0 duplicates skipped")
+	holdings, err := repository.NewHoldingRepositoryWithDB(db).GetAll()
+	require.NoError(t, err)
+	assert.Empty(t, holdings)
+
+	out.Reset()
+	require.NoError(t, app.Run([]string{"import", "csv", "--map", mapping, path}))
+	assert.Contains(t, out.String(), "Imported 2 rows from portfolio.csv")
+	holdings, err = repository.NewHoldingRepositoryWithDB(db).GetAll()
+	require.NoError(t, err)
+	assert.Len(t, holdings, 2)
+
+	out.Reset()
+	require.NoError(t, app.Run([]string{"import", "csv", "--map", mapping, path}))
+	assert.Contains(t, out.String(), "0 created, 0 updated, 2 duplicates skipped")
+
+	assert.ErrorContains(t, app.Run([]string{"import", "csv", path}), "missing columns")
+	assert.Error(t, app.Run([]string{"import", "csv"}))
+	assert.Error(t, app.Run([]string{"import", "xlsx", path}))
+}
+
+func TestApp_ImportExchange(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	var out bytes.Buffer
+	app := NewWithDB(db, &out)
+	path := filepath.Join("..", "importer", "testdata", "kraken.csv")
+
+	require.NoError(t, app.Run([]string{"import", "kraken", "--account", "Kraken Pro", path}))
+	assert.Contains(t, out.String(), "Imported 11 rows from kraken.csv")
+
+	holdings, err := repository.NewHoldingRepositoryWithDB(db).GetAll()
+	require.NoError(t, err)
+	balances := make(map[string]string)
+	for _, holding := range holdings {
+		assert.Equal(t, "Kraken Pro", holding.Account.Name)
+		balances[holding.Asset.Symbol] = holding.Amount.String()
+	}
+	assert.Equal(t, map[string]string{"USD": "1244.9984", "BTC": "0.00095", "ETH": "0.08"}, balances)
+
+	out.Reset()
+	require.NoError(t, app.Run([]string{"import", "kraken", "--account", "Kraken Pro", path}))
+	assert.Contains(t, out.String(), "11 duplicates skipped")
+
+	// A euro trade has no USD price and needs confirming
+	path = filepath.Join(t.TempDir(), "euro.csv")
+	require.NoError(t, os.WriteFile(path, []byte("txid,refid,time,type,subtype,aclass,asset,amount,fee,balance\n"+
+		"L1,D1,2024-04-01 09:00:00,deposit,,currency,ZEUR,500,0,500\n"+
+		"L2,T1,2024-04-02 10:00:00,trade,,currency,ZEUR,-400,0,100\n"+
+		"L3,T1,2024-04-02 10:00:00,trade,,currency,XXBT,0.01,0,0.01\n"), 0o600))
+
+	out.Reset()
+	require.NoError(t, app.Run([]string{"import", "kraken", path, "--dry-run"}))
+	assert.Contains(t, out.String(), "unpriced")
+	assert.Contains(t, out.String(), "2 unpriced rows would be recorded without a cost basis")
+
+	err = app.Run([]string{"import", "kraken", path})
+	assert.ErrorContains(t, err, "--allow-unpriced")
+
+	out.Reset()
+	require.NoError(t, app.Run([]string{"import", "kraken", "--allow-unpriced", path}))
+	assert.Contains(t, out.String(), "Imported 3 rows from euro.csv")
+}
+
+func TestApp_Export(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	var out bytes.Buffer
+	app := NewWithDB(db, &out)
+	require.NoError(t, app.Run([]string{"holdings", "add", "--account", "Ledger", "--asset", "BTC", "--amount", "0.5", "--price", "40000"}))
+
+	out.Reset()
+	require.NoError(t, app.Run([]string{"export", "--format", "json", "--audit"}))
+	assert.Contains(t, out.String(), `"symbol": "BTC"`)
+	assert.Contains(t, out.String(), `"audit_log"`)
+
+	path := filepath.Join(t.TempDir(), "portfolio.md")
+	out.Reset()
+	require.NoError(t, app.Run([]string{"export", "--format", "md", "--output", path}))
+	assert.Equal(t, "Exported 1 holdings to "+path+"\n", out.String())
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "| Ledger | BTC | 0.5 | $40,000.00 |")
+
+	assert.ErrorContains(t, app.Run([]string{"export", "--format", "xlsx"}), "unsupported export format")
+}
+
+func TestApp_Encryption(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "budget.db")
+	require.NoError(t, db.Initialize(path))
+	var out bytes.Buffer
+	app := New(&out)
+	passphrases := []string{"secret", "secret"}
+	app.readPassphrase = func(string) (string, error) {
+		next := passphrases[0]
+		passphrases = passphrases[1:]
+		return next, nil
+	}
+
+	require.NoError(t, app.Run([]string{"holdings", "add", "--account", "Ledger", "--asset", "BTC", "--amount", "0.5"}))
+	assert.ErrorIs(t, app.Run([]string{"encryption", "passphrase"}), db.ErrNotEncrypted)
+
+	require.NoError(t, app.Run([]string{"encryption", "enable"}))
+	out.Reset()
+	require.NoError(t, app.Run([]string{"encryption", "status"}))
+	assert.Equal(t, "Database is encrypted\n", out.String())
+
+	passphrases = []string{"new", "typo"}
+	assert.ErrorContains(t, app.Run([]string{"encryption", "passphrase"}), "do not match")
+
+	require.NoError(t, db.Close())
+	encrypted, err := db.IsEncrypted(path)
+	require.NoError(t, err)
+	assert.True(t, encrypted)
+
+	require.NoError(t, db.InitializeEncrypted(path, "secret"))
+	require.NoError(t, app.Run([]string{"encryption", "disable"}))
+	require.NoError(t, db.Close())
+	encrypted, err = db.IsEncrypted(path)
+	require.NoError(t, err)
+	assert.False(t, encrypted)
+}
+
+func TestApp_BackupRestore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "budget.db")
+	require.NoError(t, db.Initialize(path))
+	t.Cleanup(func() { db.Close() })
+	var out bytes.Buffer
+	app := New(&out)
+
+	require.NoError(t, app.Run([]string{"holdings", "add", "--account", "Ledger", "--asset", "BTC", "--amount", "0.5"}))
+	require.NoError(t, app.Run([]string{"backup", "keep", "2"}))
+	out.Reset()
+	require.NoError(t, app.Run([]string{"backup", "keep"}))
+	assert.Equal(t, "2\n", out.String())
+
+	out.Reset()
+	require.NoError(t, app.Run([]string{"backup"}))
+	require.True(t, strings.HasPrefix(out.String(), "Backed up to "))
+	backup := strings.TrimSpace(strings.TrimPrefix(out.String(), "Backed up to "))
+	assert.FileExists(t, backup)
+
+	require.NoError(t, app.Run([]string{"backup"}))
+	out.Reset()
+	require.NoError(t, app.Run([]string{"backup"}))
+	assert.Contains(t, out.String(), "Removed 1 old backups")
+
+	out.Reset()
+	require.NoError(t, app.Run([]string{"backup", "list"}))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.Contains(t, lines[1], "manual")
+
+	require.NoError(t, app.Run([]string{"holdings", "add", "--account", "Ledger", "--asset", "ETH", "--amount", "2"}))
+	latest := strings.Fields(lines[1])[len(strings.Fields(lines[1]))-1]
+	out.Reset()
+	require.NoError(t, app.Run([]string{"restore", latest}))
+	assert.Contains(t, out.String(), "The replaced database was saved to ")
+
+	// The restore replaced the database the app was opened on
+	out.Reset()
+	require.NoError(t, NewWithDB(db.DB, &out).Run([]string{"holdings", "list"}))
+	assert.Contains(t, out.String(), "BTC")
+	assert.NotContains(t, out.String(), "ETH")
+
+	assert.ErrorContains(t, app.Run([]string{"restore"}), "exactly one backup file")
+	assert.ErrorContains(t, app.Run([]string{"backup", "keep", "-1"}), "invalid number")
+}
@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/bioharz/budget/internal/currency"
+	"github.com/shopspring/decimal"
+)
+
+// runCurrency prints the base currency, or sets it when a code is given
+func (a *App) runCurrency(args []string) error {
+	fs := a.newFlagSet("currency")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		base, err := a.priceService.BaseCurrency()
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(a.out, base.Code)
+		return err
+	}
+
+	base, err := a.priceService.SetBaseCurrency(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(a.out, "Base currency set to %s\n", base.Code)
+	return err
+}
+
+// converter returns the converter for code, or for the stored base
+// currency when code is empty
+func (a *App) converter(code string) (currency.Converter, error) {
+	if code == "" {
+		return a.priceService.Converter()
+	}
+	c, err := currency.Lookup(code)
+	if err != nil {
+		return currency.Converter{}, err
+	}
+	return a.priceService.ConverterFor(c)
+}
+
+// formatValue prints a USD value in the converter's currency as a plain
+// number, so output stays easy to parse in scripts
+func formatValue(converter currency.Converter, usd decimal.Decimal) string {
+	return converter.FromUSD(usd).StringFixed(int32(converter.Decimals))
+}
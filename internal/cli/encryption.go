@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/bioharz/budget/internal/db"
+	"github.com/charmbracelet/x/term"
+)
+
+func (a *App) runEncryption(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing encryption subcommand (status, enable, disable, passphrase)")
+	}
+
+	switch args[0] {
+	case "status":
+		status := "not encrypted"
+		if db.Encrypted() {
+			status = "encrypted"
+		}
+		_, err := fmt.Fprintf(a.out, "Database is %s\n", status)
+		return err
+	case "enable":
+		passphrase, err := a.newPassphrase()
+		if err != nil {
+			return err
+		}
+		if err := db.Encrypt(passphrase); err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(a.out, "Database encrypted. Keep the passphrase safe, it cannot be recovered.")
+		return err
+	case "disable":
+		if err := db.Decrypt(); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintln(a.out, "Database decrypted")
+		return err
+	case "passphrase":
+		if !db.Encrypted() {
+			return db.ErrNotEncrypted
+		}
+		passphrase, err := a.newPassphrase()
+		if err != nil {
+			return err
+		}
+		if err := db.ChangePassphrase(passphrase); err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(a.out, "Passphrase changed")
+		return err
+	default:
+		return fmt.Errorf("unknown encryption subcommand %q", args[0])
+	}
+}
+
+// EnvNewPassphrase names the environment variable that sets a new
+// passphrase without prompting
+const EnvNewPassphrase = "MINIMAL_MONEY_NEW_PASSPHRASE"
+
+// ErrNoTerminal is returned when a passphrase is needed but there is no
+// terminal to ask on
+var ErrNoTerminal = errors.New("no terminal to read the passphrase from")
+
+// newPassphrase asks for a new passphrase twice
+func (a *App) newPassphrase() (string, error) {
+	if passphrase := os.Getenv(EnvNewPassphrase); passphrase != "" {
+		return passphrase, nil
+	}
+	passphrase, err := a.readPassphrase("New passphrase: ")
+	if errors.Is(err, ErrNoTerminal) {
+		return "", fmt.Errorf("%w, set %s", err, EnvNewPassphrase)
+	}
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", fmt.Errorf("passphrase cannot be empty")
+	}
+	confirm, err := a.readPassphrase("Repeat passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if confirm != passphrase {
+		return "", fmt.Errorf("passphrases do not match")
+	}
+	return passphrase, nil
+}
+
+// ReadPassphrase prompts on standard error and reads a passphrase from the
+// terminal without echoing it
+func ReadPassphrase(prompt string) (string, error) {
+	if !term.IsTerminal(os.Stdin.Fd()) {
+		return "", ErrNoTerminal
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	passphrase, err := term.ReadPassword(os.Stdin.Fd())
+	fmt.Fprintln(os.Stderr)
+	return string(passphrase), err
+}
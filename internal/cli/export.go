@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/bioharz/budget/internal/export"
+)
+
+// runExport writes the portfolio valued at cached prices to stdout or a file
+func (a *App) runExport(args []string) error {
+	fs := a.newFlagSet("export")
+	name := fs.String("format", "csv", "Output format: csv, json or md")
+	code := fs.String("currency", "", "Currency to value holdings in (default: base currency)")
+	withAudit := fs.Bool("audit", false, "Include the audit log")
+	output := fs.String("output", "", "File to write (default: standard output)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	format, err := export.ParseFormat(*name)
+	if err != nil {
+		return err
+	}
+	converter, err := a.converter(*code)
+	if err != nil {
+		return err
+	}
+
+	portfolio, err := a.exportService.Portfolio(converter, *withAudit)
+	if err != nil {
+		return fmt.Errorf("failed to load portfolio: %w", err)
+	}
+
+	if *output == "" {
+		return export.Write(a.out, format, portfolio)
+	}
+
+	file, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := export.Write(file, format, portfolio); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(a.out, "Exported %d holdings to %s\n", len(portfolio.Holdings), *output)
+	return err
+}
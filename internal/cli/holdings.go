@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/shopspring/decimal"
+)
+
+func (a *App) runHoldings(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing holdings subcommand (list, add)")
+	}
+
+	switch args[0] {
+	case "list", "ls":
+		return a.listHoldings(args[1:])
+	case "add":
+		return a.addHolding(args[1:])
+	default:
+		return fmt.Errorf("unknown holdings subcommand %q", args[0])
+	}
+}
+
+func (a *App) listHoldings(args []string) error {
+	fs := a.newFlagSet("holdings list")
+	code := fs.String("currency", "", "Currency to show prices and values in (default: base currency)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	converter, err := a.converter(*code)
+	if err != nil {
+		return err
+	}
+
+	holdings, err := a.holdingService.GetHoldings()
+	if err != nil {
+		return fmt.Errorf("failed to load holdings: %w", err)
+	}
+
+	prices, err := a.priceService.GetCachedPrices()
+	if err != nil {
+		return fmt.Errorf("failed to load cached prices: %w", err)
+	}
+
+	w := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "ID\tACCOUNT\tASSET\tAMOUNT\tPRICE (%s)\tVALUE (%s)\n", converter.Code, converter.Code)
+	for _, holding := range holdings {
+		price := prices[holding.AssetID]
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n",
+			holding.ID,
+			holding.Account.Name,
+			holding.Asset.Symbol,
+			formatAmount(holding.Amount),
+			formatValue(converter, price),
+			formatValue(converter, holding.Amount.Mul(price)))
+	}
+	return w.Flush()
+}
+
+func (a *App) addHolding(args []string) error {
+	fs := a.newFlagSet("holdings add")
+	account := fs.String("account", "", "Account name (created if missing)")
+	asset := fs.String("asset", "", "Asset symbol, e.g. BTC (created if missing)")
+	amount := decimalFlag(fs, "amount", decimal.Zero, "Amount held")
+	price := decimalFlag(fs, "price", decimal.Zero, "Purchase price per unit in USD (optional)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	holding, err := a.holdingService.AddHolding(*account, *asset, *amount, *price)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(a.out, "Added %s %s to %s (holding %d)\n",
+		formatAmount(holding.Amount), holding.Asset.Symbol, holding.Account.Name, holding.ID)
+	return err
+}
+
+// formatAmount prints amounts without losing precision or padding zeros
+func formatAmount(amount decimal.Decimal) string {
+	return amount.String()
+}
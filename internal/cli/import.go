@@ -0,0 +1,154 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/bioharz/budget/internal/importer"
+	"github.com/bioharz/budget/internal/service"
+)
+
+func (a *App) runImport(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing import format (csv, coinbase, kraken, binance)")
+	}
+
+	if args[0] == "csv" {
+		return a.importCSV(args[1:])
+	}
+	for _, exchange := range importer.Exchanges {
+		if args[0] == string(exchange) {
+			return a.importExchange(exchange, args[1:])
+		}
+	}
+	return fmt.Errorf("unknown import format %q", args[0])
+}
+
+func (a *App) importCSV(args []string) error {
+	fs := a.newFlagSet("import csv")
+	spec := fs.String("map", "", "Column mapping, e.g. account=Wallet,asset=Coin,amount=Qty")
+	dryRun := fs.Bool("dry-run", false, "Show what would be imported without changing anything")
+	path, err := parseFileArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	mapping, err := importer.ParseMapping(*spec)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	rows, err := importer.ParseCSV(file, mapping)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return a.runImportPlan(filepath.Base(path), rows, *dryRun, false)
+}
+
+func (a *App) importExchange(exchange importer.Exchange, args []string) error {
+	fs := a.newFlagSet("import " + string(exchange))
+	account := fs.String("account", exchange.DisplayName(), "Account to book the export to")
+	dryRun := fs.Bool("dry-run", false, "Show what would be imported without changing anything")
+	allowUnpriced := fs.Bool("allow-unpriced", false, "Import trades against other fiat currencies without a cost basis")
+	path, err := parseFileArgs(fs, args)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	rows, err := importer.ParseExchange(exchange, file, *account)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return a.runImportPlan(filepath.Base(path), rows, *dryRun, *allowUnpriced)
+}
+
+// runImportPlan previews rows and, unless dryRun is set, applies them
+func (a *App) runImportPlan(source string, rows []importer.Row, dryRun, allowUnpriced bool) error {
+	plan, err := a.importService.Plan(source, rows)
+	if err != nil {
+		return err
+	}
+	plan.AllowUnpriced = allowUnpriced
+
+	w := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "LINE\tACTION\tTYPE\tACCOUNT\tASSET\tAMOUNT\tPRICE (USD)\tDATE")
+	for _, row := range plan.Rows {
+		kind := "balance"
+		if row.IsTransaction() {
+			kind = string(row.Type)
+		}
+		date := "-"
+		if !row.Date.IsZero() {
+			date = row.Date.Format(dateLayout)
+		}
+		price := row.Price.StringFixed(2)
+		if row.Unpriced {
+			price = "unpriced"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			row.Line, row.Action, kind, row.Account, row.Asset,
+			formatAmount(row.Amount), price, date)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	created := plan.Count(service.ImportCreate)
+	updated := plan.Count(service.ImportUpdate)
+	duplicates := plan.Count(service.ImportDuplicate)
+
+	if dryRun {
+		_, err = fmt.Fprintf(a.out, "\nDry run: %d to create, %d to update, %d duplicates skipped\n",
+			created, updated, duplicates)
+		if err == nil && plan.Unpriced() > 0 {
+			_, err = fmt.Fprintf(a.out, "%d unpriced rows would be recorded without a cost basis; import them with --allow-unpriced\n",
+				plan.Unpriced())
+		}
+		return err
+	}
+
+	if err := a.importService.Apply(plan); errors.Is(err, service.ErrUnpricedRows) {
+		return fmt.Errorf("import failed, nothing was changed: %w; check them with --dry-run and import them with --allow-unpriced to record them without a cost basis", err)
+	} else if err != nil {
+		return fmt.Errorf("import failed, nothing was changed: %w", err)
+	}
+	_, err = fmt.Fprintf(a.out, "\nImported %d rows from %s: %d created, %d updated, %d duplicates skipped\n",
+		created+updated, source, created, updated, duplicates)
+	return err
+}
+
+// parseFileArgs parses flags around a single file argument, so both
+// "--dry-run FILE" and "FILE --dry-run" work
+func parseFileArgs(fs *flag.FlagSet, args []string) (string, error) {
+	if err := fs.Parse(args); err != nil {
+		return "", err
+	}
+	rest := fs.Args()
+	if len(rest) == 0 {
+		return "", fmt.Errorf("missing file to import")
+	}
+	path := rest[0]
+	if err := fs.Parse(rest[1:]); err != nil {
+		return "", err
+	}
+	if len(fs.Args()) > 0 {
+		return "", fmt.Errorf("unexpected argument %q", fs.Args()[0])
+	}
+	return path, nil
+}
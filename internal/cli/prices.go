@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// dateLayout is the format of dates given on the command line
+const dateLayout = "2006-01-02"
+
+func (a *App) runPrices(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing prices subcommand (refresh, set, clear)")
+	}
+
+	switch args[0] {
+	case "refresh":
+		return a.refreshPrices(args[1:])
+	case "set":
+		return a.setPrice(args[1:])
+	case "clear":
+		return a.clearPrice(args[1:])
+	default:
+		return fmt.Errorf("unknown prices subcommand %q", args[0])
+	}
+}
+
+func (a *App) refreshPrices(args []string) error {
+	fs := a.newFlagSet("prices refresh")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	assets, err := a.assetRepo.GetAll()
+	if err != nil {
+		return fmt.Errorf("failed to load assets: %w", err)
+	}
+	if len(assets) == 0 {
+		_, err := fmt.Fprintln(a.out, "No assets to price")
+		return err
+	}
+
+	prices, err := a.priceService.FetchPrices(assets)
+	if err != nil {
+		return fmt.Errorf("failed to fetch prices: %w", err)
+	}
+	manual, err := a.priceService.GetManualPrices()
+	if err != nil {
+		return fmt.Errorf("failed to load manual prices: %w", err)
+	}
+
+	sort.Slice(assets, func(i, j int) bool {
+		return assets[i].Symbol < assets[j].Symbol
+	})
+
+	w := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ASSET\tTYPE\tPRICE")
+	for _, asset := range assets {
+		price, ok := prices[asset.ID]
+		if !ok {
+			fmt.Fprintf(w, "%s\t%s\tunavailable\n", asset.Symbol, asset.Type)
+			continue
+		}
+		if cache, ok := manual[asset.ID]; ok {
+			fmt.Fprintf(w, "%s\t%s\t%s (manual, %s)\n", asset.Symbol, asset.Type, price.StringFixed(2), cache.PriceDate.Format(dateLayout))
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", asset.Symbol, asset.Type, price.StringFixed(2))
+	}
+	return w.Flush()
+}
+
+func (a *App) setPrice(args []string) error {
+	fs := a.newFlagSet("prices set")
+	symbol := fs.String("asset", "", "Asset symbol")
+	price := decimalFlag(fs, "price", decimal.NewFromInt(-1), "Price per unit in USD")
+	date := fs.String("date", "", "Valuation date as YYYY-MM-DD (default today)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if price.IsNegative() {
+		return fmt.Errorf("--price is required and cannot be negative")
+	}
+
+	valuedAt := time.Now()
+	if *date != "" {
+		parsed, err := time.ParseInLocation(dateLayout, *date, time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid --date %q, expected YYYY-MM-DD", *date)
+		}
+		valuedAt = parsed
+	}
+
+	asset, err := a.findAsset(*symbol)
+	if err != nil {
+		return err
+	}
+	if err := a.priceService.SetManualPrice(asset.ID, *price, valuedAt); err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(a.out, "Set %s to %s USD as of %s\n", asset.Symbol, price.StringFixed(2), valuedAt.Format(dateLayout))
+	return err
+}
+
+func (a *App) clearPrice(args []string) error {
+	fs := a.newFlagSet("prices clear")
+	symbol := fs.String("asset", "", "Asset symbol")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	asset, err := a.findAsset(*symbol)
+	if err != nil {
+		return err
+	}
+	if err := a.priceService.ClearManualPrice(asset.ID); err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(a.out, "%s is priced automatically again\n", asset.Symbol)
+	return err
+}
@@ -0,0 +1,155 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bioharz/budget/internal/tax"
+	"github.com/shopspring/decimal"
+)
+
+func (a *App) runTax(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing tax subcommand (report, lots)")
+	}
+
+	switch args[0] {
+	case "report":
+		return a.taxReport(args[1:])
+	case "lots":
+		return a.taxLots(args[1:])
+	default:
+		return fmt.Errorf("unknown tax subcommand %q", args[0])
+	}
+}
+
+// lotSelections collects repeated --lot SALE:LOT=QTY flags
+type lotSelections map[uint][]tax.Selection
+
+func (l lotSelections) String() string {
+	return ""
+}
+
+func (l lotSelections) Set(value string) error {
+	sale, rest, ok := strings.Cut(value, ":")
+	lot, quantity, ok2 := strings.Cut(rest, "=")
+	if !ok || !ok2 {
+		return fmt.Errorf("expected SALE:LOT=QUANTITY, got %q", value)
+	}
+
+	saleID, err := strconv.ParseUint(sale, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid sale transaction ID %q", sale)
+	}
+	lotID, err := strconv.ParseUint(lot, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid lot ID %q", lot)
+	}
+	amount, err := decimal.NewFromString(quantity)
+	if err != nil || !amount.IsPositive() {
+		return fmt.Errorf("invalid lot quantity %q", quantity)
+	}
+
+	l[uint(saleID)] = append(l[uint(saleID)], tax.Selection{LotID: uint(lotID), Quantity: amount})
+	return nil
+}
+
+func (a *App) taxReport(args []string) error {
+	fs := a.newFlagSet("tax report")
+	methodName := fs.String("method", string(tax.MethodFIFO), "Lot method: fifo, lifo, hifo or specific")
+	year := fs.Int("year", 0, "Only list sales of this tax year")
+	selections := lotSelections{}
+	fs.Var(selections, "lot", "With --method specific: sell SALE from LOT, as SALE:LOT=QUANTITY (repeatable)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	method, err := tax.ParseMethod(*methodName)
+	if err != nil {
+		return err
+	}
+	if len(selections) > 0 && method != tax.MethodSpecific {
+		return fmt.Errorf("--lot requires --method specific")
+	}
+
+	report, err := a.taxService.Report(method, selections)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SALE\tLOT\tASSET\tACQUIRED\tSOLD\tQUANTITY\tPROCEEDS\tCOST BASIS\tGAIN\tTERM")
+	for _, d := range report.Disposals {
+		if *year != 0 && d.Sold.Year() != *year {
+			continue
+		}
+		term := "short"
+		if d.LongTerm {
+			term = "long"
+		}
+		fmt.Fprintf(w, "%d\t%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			d.SaleID, d.LotID, d.Symbol, d.Acquired.Format(dateLayout), d.Sold.Format(dateLayout),
+			formatAmount(d.Quantity), d.Proceeds.StringFixed(2), d.CostBasis.StringFixed(2), d.Gain.StringFixed(2), term)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(a.out)
+	w = tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "YEAR\tPROCEEDS\tCOST BASIS\tSHORT-TERM\tLONG-TERM\tTOTAL")
+	years := report.Years
+	if *year != 0 {
+		years = []tax.YearSummary{report.Year(*year)}
+	}
+	for _, y := range years {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n",
+			y.Year, y.Proceeds.StringFixed(2), y.CostBasis.StringFixed(2), y.ShortTerm.StringFixed(2),
+			y.LongTerm.StringFixed(2), y.Total().StringFixed(2))
+	}
+	return w.Flush()
+}
+
+func (a *App) taxLots(args []string) error {
+	fs := a.newFlagSet("tax lots")
+	methodName := fs.String("method", string(tax.MethodFIFO), "Lot method used to consume earlier sells")
+	symbol := fs.String("asset", "", "Only show lots of this asset")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	method, err := tax.ParseMethod(*methodName)
+	if err != nil {
+		return err
+	}
+
+	var assetID uint
+	if *symbol != "" {
+		asset, err := a.findAsset(*symbol)
+		if err != nil {
+			return err
+		}
+		assetID = asset.ID
+	}
+
+	lots, err := a.taxService.OpenLots(method, assetID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	w := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "LOT\tASSET\tACQUIRED\tQUANTITY\tUNIT COST\tTERM")
+	for _, lot := range lots {
+		term := "short"
+		if tax.IsLongTerm(lot.Acquired, now) {
+			term = "long"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n",
+			lot.ID, lot.Symbol, lot.Acquired.Format(dateLayout), formatAmount(lot.Quantity), lot.UnitCost.StringFixed(2), term)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/bioharz/budget/internal/service"
+	"github.com/shopspring/decimal"
+)
+
+func (a *App) runTotal(args []string) error {
+	fs := a.newFlagSet("total")
+	refresh := fs.Bool("refresh", false, "Fetch current prices before calculating")
+	code := fs.String("currency", "", "Currency to print the total in (default: base currency)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	converter, err := a.converter(*code)
+	if err != nil {
+		return err
+	}
+
+	holdings, err := a.holdingService.GetHoldings()
+	if err != nil {
+		return fmt.Errorf("failed to load holdings: %w", err)
+	}
+
+	var prices map[uint]decimal.Decimal
+	if *refresh {
+		assets, err := a.assetRepo.GetAll()
+		if err != nil {
+			return fmt.Errorf("failed to load assets: %w", err)
+		}
+		prices, err = a.priceService.FetchPrices(assets)
+		if err != nil {
+			return fmt.Errorf("failed to fetch prices: %w", err)
+		}
+	} else {
+		prices, err = a.priceService.GetCachedPrices()
+		if err != nil {
+			return fmt.Errorf("failed to load cached prices: %w", err)
+		}
+	}
+
+	_, err = fmt.Fprintln(a.out, formatValue(converter, service.CalculateTotal(holdings, prices)))
+	return err
+}
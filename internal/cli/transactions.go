@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bioharz/budget/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+func (a *App) runTransactions(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing transactions subcommand (list, add)")
+	}
+
+	switch args[0] {
+	case "list":
+		return a.listTransactions(args[1:])
+	case "add":
+		return a.addTransaction(args[1:])
+	default:
+		return fmt.Errorf("unknown transactions subcommand %q", args[0])
+	}
+}
+
+func (a *App) listTransactions(args []string) error {
+	fs := a.newFlagSet("transactions list")
+	account := fs.String("account", "", "Only show transactions of this account")
+	asset := fs.String("asset", "", "Only show transactions of this asset")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	transactions, err := a.transactionRepo.GetAll()
+	if err != nil {
+		return fmt.Errorf("failed to load transactions: %w", err)
+	}
+
+	w := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tDATE\tTYPE\tACCOUNT\tASSET\tQUANTITY\tPRICE\tFEE\tNOTE")
+	for _, t := range transactions {
+		accountName := t.Account.Name
+		if t.ToAccount != nil {
+			accountName += " -> " + t.ToAccount.Name
+		}
+		if *account != "" && !strings.EqualFold(t.Account.Name, *account) &&
+			(t.ToAccount == nil || !strings.EqualFold(t.ToAccount.Name, *account)) {
+			continue
+		}
+		if *asset != "" && !strings.EqualFold(t.Asset.Symbol, *asset) {
+			continue
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			t.ID, t.Timestamp.Format(dateLayout), t.Type, accountName, t.Asset.Symbol,
+			formatAmount(t.Quantity), t.PriceUSD.StringFixed(2), t.Fee.StringFixed(2), t.Note)
+	}
+	return w.Flush()
+}
+
+func (a *App) addTransaction(args []string) error {
+	fs := a.newFlagSet("transactions add")
+	txType := fs.String("type", "", "buy, sell, deposit, withdrawal, transfer, fee, income or adjustment")
+	account := fs.String("account", "", "Account name (created if missing)")
+	toAccount := fs.String("to-account", "", "Destination account of a transfer (created if missing)")
+	symbol := fs.String("asset", "", "Asset symbol (created if missing)")
+	quantity := decimalFlag(fs, "quantity", decimal.Zero, "Quantity of the asset")
+	price := decimalFlag(fs, "price", decimal.Zero, "Price per unit in USD")
+	fee := decimalFlag(fs, "fee", decimal.Zero, "Fee in USD")
+	date := fs.String("date", "", "Date as YYYY-MM-DD (default now)")
+	note := fs.String("note", "", "Free-form note")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(*account) == "" || strings.TrimSpace(*symbol) == "" {
+		return fmt.Errorf("--account and --asset are required")
+	}
+
+	timestamp := time.Now()
+	if *date != "" {
+		parsed, err := time.ParseInLocation(dateLayout, *date, time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid --date %q, expected YYYY-MM-DD", *date)
+		}
+		timestamp = parsed
+	}
+
+	from, err := a.holdingService.GetOrCreateAccount(*account)
+	if err != nil {
+		return err
+	}
+	asset, err := a.holdingService.GetOrCreateAsset(*symbol)
+	if err != nil {
+		return err
+	}
+
+	transaction := &models.Transaction{
+		Type:      models.TransactionType(strings.ToLower(*txType)),
+		AccountID: from.ID,
+		AssetID:   asset.ID,
+		Quantity:  *quantity,
+		PriceUSD:  *price,
+		Fee:       *fee,
+		Note:      *note,
+		Timestamp: timestamp,
+	}
+	if strings.TrimSpace(*toAccount) != "" {
+		to, err := a.holdingService.GetOrCreateAccount(*toAccount)
+		if err != nil {
+			return err
+		}
+		transaction.ToAccountID = &to.ID
+	}
+
+	holding, err := a.ledgerService.Record(transaction)
+	if err != nil {
+		return err
+	}
+
+	balance := decimal.Zero
+	if holding != nil {
+		balance = holding.Amount
+	}
+	_, err = fmt.Fprintf(a.out, "Recorded %s of %s %s (transaction %d), %s balance in %s: %s\n",
+		transaction.Type, formatAmount(transaction.Quantity), asset.Symbol, transaction.ID,
+		asset.Symbol, from.Name, formatAmount(balance))
+	return err
+}
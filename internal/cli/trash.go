@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/bioharz/budget/internal/models"
+)
+
+func (a *App) runTrash(args []string) error {
+	if len(args) == 0 {
+		return a.trashList()
+	}
+	switch args[0] {
+	case "list":
+		return a.trashList()
+	case "restore":
+		return a.trashChange("restore", args[1:])
+	case "purge":
+		if len(args) == 1 {
+			return a.trashPurgeExpired()
+		}
+		return a.trashChange("purge", args[1:])
+	case "days":
+		return a.trashDays(args[1:])
+	default:
+		return fmt.Errorf("unknown trash subcommand %q", args[0])
+	}
+}
+
+func (a *App) trashList() error {
+	items, err := a.trashService.GetAll()
+	if err != nil {
+		return fmt.Errorf("failed to load the trash: %w", err)
+	}
+	if len(items) == 0 {
+		_, err := fmt.Fprintln(a.out, "The trash is empty")
+		return err
+	}
+
+	w := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DELETED\tTYPE\tID\tNAME")
+	for _, item := range items {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n",
+			item.DeletedAt.Format("2006-01-02 15:04:05"), strings.ToLower(string(item.Entity)), item.ID, item.Name)
+	}
+	return w.Flush()
+}
+
+// trashChange restores or purges the item named by its type and ID, e.g.
+// "holding 12"
+func (a *App) trashChange(action string, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: budget trash %s holding|account|asset ID", action)
+	}
+	entity := models.AuditLogEntityType(strings.ToUpper(args[0]))
+	switch entity {
+	case models.AuditEntityHolding, models.AuditEntityAccount, models.AuditEntityAsset:
+	default:
+		return fmt.Errorf("unknown trash item type %q", args[0])
+	}
+	id, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid ID %q", args[1])
+	}
+
+	verb := "Restored"
+	if action == "restore" {
+		err = a.trashService.Restore(entity, uint(id))
+	} else {
+		verb = "Purged"
+		err = a.trashService.Purge(entity, uint(id))
+	}
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(a.out, "%s %s #%d\n", verb, strings.ToLower(string(entity)), id)
+	return err
+}
+
+// trashPurgeExpired purges what has been in the trash longer than
+// configured, like starting the terminal UI does
+func (a *App) trashPurgeExpired() error {
+	purged, err := a.trashService.PurgeExpired()
+	if err != nil {
+		return fmt.Errorf("failed to empty the trash: %w", err)
+	}
+	_, err = fmt.Fprintf(a.out, "Purged %d expired item(s)\n", purged)
+	return err
+}
+
+// trashDays prints after how many days the trash is purged, or sets it when
+// a number is given
+func (a *App) trashDays(args []string) error {
+	if len(args) == 0 {
+		days, err := a.trashService.Days()
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(a.out, days)
+		return err
+	}
+
+	days, err := strconv.Atoi(args[0])
+	if err != nil || days < 0 {
+		return fmt.Errorf("invalid number of days %q", args[0])
+	}
+	if err := a.trashService.SetDays(days); err != nil {
+		return err
+	}
+	if days == 0 {
+		_, err = fmt.Fprintln(a.out, "Keeping deleted items until they are purged")
+		return err
+	}
+	_, err = fmt.Fprintf(a.out, "Purging deleted items after %d days\n", days)
+	return err
+}
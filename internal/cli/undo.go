@@ -0,0 +1,31 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/bioharz/budget/internal/service"
+)
+
+func (a *App) runUndo(args []string) error {
+	if err := a.newFlagSet("undo").Parse(args); err != nil {
+		return err
+	}
+	entry, err := a.undoService.Undo()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(a.out, "Undid %s\n", service.DescribeChange(entry))
+	return err
+}
+
+func (a *App) runRedo(args []string) error {
+	if err := a.newFlagSet("redo").Parse(args); err != nil {
+		return err
+	}
+	entry, err := a.undoService.Redo()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(a.out, "Redid %s\n", service.DescribeChange(entry))
+	return err
+}
@@ -0,0 +1,144 @@
+// Package currency formats portfolio values in the user's base currency.
+package currency
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// Currency describes how amounts in one currency are written
+type Currency struct {
+	Code        string
+	Symbol      string
+	Decimals    int
+	Thousands   string // Group separator
+	Decimal     string // Decimal mark
+	SymbolAfter bool   // "1.234,56 €" rather than "€1,234.56"
+	Space       bool   // Space between symbol and amount
+	Crypto      bool   // Priced through the crypto providers, not fiat rates
+}
+
+var (
+	USD = Currency{Code: "USD", Symbol: "$", Decimals: 2, Thousands: ",", Decimal: "."}
+	EUR = Currency{Code: "EUR", Symbol: "€", Decimals: 2, Thousands: ".", Decimal: ",", SymbolAfter: true, Space: true}
+	GBP = Currency{Code: "GBP", Symbol: "£", Decimals: 2, Thousands: ",", Decimal: "."}
+	CHF = Currency{Code: "CHF", Symbol: "CHF", Decimals: 2, Thousands: "'", Decimal: ".", Space: true}
+	BTC = Currency{Code: "BTC", Symbol: "₿", Decimals: 8, Thousands: ",", Decimal: ".", Crypto: true}
+)
+
+// Supported lists the base currencies in the order the UI cycles through them
+var Supported = []Currency{USD, EUR, GBP, CHF, BTC}
+
+// Lookup finds a supported currency by code in any case
+func Lookup(code string) (Currency, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	for _, c := range Supported {
+		if c.Code == code {
+			return c, nil
+		}
+	}
+	return Currency{}, fmt.Errorf("unsupported currency %q (%s)", code, strings.Join(Codes(), ", "))
+}
+
+// Codes returns the codes of the supported currencies
+func Codes() []string {
+	codes := make([]string, len(Supported))
+	for i, c := range Supported {
+		codes[i] = c.Code
+	}
+	return codes
+}
+
+// Next returns the currency after c in Supported, wrapping around
+func Next(c Currency) Currency {
+	for i, s := range Supported {
+		if s.Code == c.Code {
+			return Supported[(i+1)%len(Supported)]
+		}
+	}
+	return Supported[0]
+}
+
+// Format writes amount with the currency's symbol, separators and decimals
+func (c Currency) Format(amount decimal.Decimal) string {
+	amount = amount.Round(int32(c.Decimals))
+	sign := ""
+	if amount.IsNegative() {
+		sign = "-"
+	}
+	return sign + c.withSymbol(c.FormatNumber(amount.Abs()))
+}
+
+// FormatSigned is Format with an explicit "+" for gains
+func (c Currency) FormatSigned(amount decimal.Decimal) string {
+	if amount.Round(int32(c.Decimals)).IsNegative() {
+		return c.Format(amount)
+	}
+	return "+" + c.Format(amount)
+}
+
+// FormatNumber writes amount with separators and decimals but no symbol
+func (c Currency) FormatNumber(amount decimal.Decimal) string {
+	amount = amount.Round(int32(c.Decimals))
+	sign := ""
+	if amount.IsNegative() {
+		sign = "-"
+		amount = amount.Abs()
+	}
+
+	digits := amount.StringFixed(int32(c.Decimals))
+	whole, fraction, _ := strings.Cut(digits, ".")
+
+	var grouped strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			grouped.WriteString(c.Thousands)
+		}
+		grouped.WriteRune(digit)
+	}
+
+	if fraction == "" {
+		return sign + grouped.String()
+	}
+	return sign + grouped.String() + c.Decimal + fraction
+}
+
+func (c Currency) withSymbol(number string) string {
+	space := ""
+	if c.Space {
+		space = " "
+	}
+	if c.SymbolAfter {
+		return number + space + c.Symbol
+	}
+	return c.Symbol + space + number
+}
+
+// Converter turns USD values into a base currency
+type Converter struct {
+	Currency
+	USDPerUnit decimal.Decimal // USD value of one unit of the base currency
+}
+
+// Identity is the converter for USD itself
+var Identity = Converter{Currency: USD, USDPerUnit: decimal.NewFromInt(1)}
+
+// FromUSD converts a USD value to the base currency
+func (c Converter) FromUSD(usd decimal.Decimal) decimal.Decimal {
+	if !c.USDPerUnit.IsPositive() {
+		return usd
+	}
+	return usd.Div(c.USDPerUnit)
+}
+
+// FormatUSD converts a USD value and formats it in the base currency
+func (c Converter) FormatUSD(usd decimal.Decimal) string {
+	return c.Format(c.FromUSD(usd))
+}
+
+// FormatSignedUSD converts a USD value and formats it with an explicit sign
+func (c Converter) FormatSignedUSD(usd decimal.Decimal) string {
+	return c.FormatSigned(c.FromUSD(usd))
+}
@@ -0,0 +1,61 @@
+package currency
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCurrency_Format(t *testing.T) {
+	tests := []struct {
+		currency Currency
+		amount   string
+		want     string
+	}{
+		{USD, "1234567.891", "$1,234,567.89"},
+		{USD, "-12.5", "-$12.50"},
+		{USD, "0", "$0.00"},
+		{USD, "-0.001", "$0.00"},
+		{EUR, "1234.5", "1.234,50 €"},
+		{GBP, "999.999", "£1,000.00"},
+		{CHF, "1234.5", "CHF 1'234.50"},
+		{BTC, "0.12345678", "₿0.12345678"},
+		{BTC, "12", "₿12.00000000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.currency.Format(decimal.RequireFromString(tt.amount)))
+		})
+	}
+
+	assert.Equal(t, "+$5.00", USD.FormatSigned(decimal.NewFromInt(5)))
+	assert.Equal(t, "-5,00 €", EUR.FormatSigned(decimal.NewFromInt(-5)))
+	assert.Equal(t, "1.234,50", EUR.FormatNumber(decimal.RequireFromString("1234.5")))
+}
+
+func TestLookupAndNext(t *testing.T) {
+	c, err := Lookup(" eur ")
+	require.NoError(t, err)
+	assert.Equal(t, EUR, c)
+
+	_, err = Lookup("JPY")
+	assert.Error(t, err)
+
+	assert.Equal(t, EUR, Next(USD))
+	assert.Equal(t, USD, Next(BTC))
+}
+
+func TestConverter(t *testing.T) {
+	hundred := decimal.NewFromInt(100)
+	eur := Converter{Currency: EUR, USDPerUnit: decimal.RequireFromString("1.25")}
+	assert.Equal(t, "80", eur.FromUSD(hundred).String())
+	assert.Equal(t, "80,00 €", eur.FormatUSD(hundred))
+	assert.Equal(t, "-8,00 €", eur.FormatSignedUSD(decimal.NewFromInt(-10)))
+
+	assert.Equal(t, "$100.00", Identity.FormatUSD(hundred))
+	// A missing rate leaves the value unconverted rather than dividing by zero
+	assert.True(t, hundred.Equal(Converter{Currency: BTC}.FromUSD(hundred)))
+}
@@ -0,0 +1,394 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SettingBackupKeep is the settings key holding how many backups are kept;
+// 0 keeps all of them
+const SettingBackupKeep = "backup_keep"
+
+// DefaultBackupKeep is the number of backups kept when it was never set
+const DefaultBackupKeep = 10
+
+// backupTime is the timestamp in backup file names; it sorts chronologically
+const backupTime = "20060102-150405"
+
+var (
+	ErrNewerSchema = errors.New("database was written by a newer version")
+	ErrNotBackup   = errors.New("not a Minimal Money database")
+)
+
+// requiredTables must exist in any database worth restoring
+var requiredTables = []string{"accounts", "assets", "holdings"}
+
+// Backup is a backup file of the database
+type Backup struct {
+	Path   string
+	Time   time.Time
+	Reason string // Why it was taken, empty for a manual backup
+	Size   int64
+
+	modified time.Time // Orders backups taken within the same second
+}
+
+// BackupDir returns the directory backups of the open database go to, next
+// to the database file
+func BackupDir() string {
+	return backupDir(dbFile)
+}
+
+func backupDir(dbPath string) string {
+	return filepath.Join(filepath.Dir(dbPath), "backups")
+}
+
+// CreateBackup writes a consistent copy of the open database to a
+// timestamped file in dir and returns its path. It is safe while other
+// processes use the database. A backup of an encrypted database is
+// encrypted with the same key.
+func CreateBackup(dir, reason string) (string, error) {
+	if DB == nil {
+		return "", fmt.Errorf("database is not open")
+	}
+	if v := current.Load(); v != nil {
+		plain, err := serialize(v.database)
+		if err != nil {
+			return "", err
+		}
+		v.mu.Lock()
+		sealed, err := v.seal(plain)
+		v.mu.Unlock()
+		if err != nil {
+			return "", err
+		}
+		return writeBackup(dbFile, dir, reason, sealed)
+	}
+	return vacuumInto(DB, dbFile, dir, reason)
+}
+
+// vacuumInto copies database, opened from dbPath, to a new backup file in
+// dir. VACUUM INTO reads within a single transaction, so the copy is
+// consistent even while another connection writes.
+func vacuumInto(database *gorm.DB, dbPath, dir, reason string) (string, error) {
+	path, err := newBackupPath(dbPath, dir, reason)
+	if err != nil {
+		return "", err
+	}
+	if err := database.Exec("VACUUM INTO ?", path).Error; err != nil {
+		return "", fmt.Errorf("failed to back up database: %w", err)
+	}
+	return path, nil
+}
+
+// writeBackup stores the bytes of the database file at dbPath as a new
+// backup in dir
+func writeBackup(dbPath, dir, reason string, data []byte) (string, error) {
+	path, err := newBackupPath(dbPath, dir, reason)
+	if err != nil {
+		return "", err
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return "", fmt.Errorf("failed to back up database: %w", err)
+	}
+	return path, nil
+}
+
+// newBackupPath names a backup of the database at dbPath taken now, for
+// example backups/budget-20240315-093000-pre-migrate.db
+func newBackupPath(dbPath, dir, reason string) (string, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	name := backupPrefix(dbPath) + time.Now().Format(backupTime)
+	if reason != "" {
+		name += "-" + reason
+	}
+	path := filepath.Join(dir, name+".db")
+	for i := 2; fileExists(path); i++ {
+		path = filepath.Join(dir, fmt.Sprintf("%s-%d.db", name, i))
+	}
+	return path, nil
+}
+
+// backupPrefix starts the names of backups of the database at dbPath, so
+// databases sharing a directory keep their backups apart
+func backupPrefix(dbPath string) string {
+	stem := strings.TrimSuffix(filepath.Base(dbPath), filepath.Ext(dbPath))
+	if stem == "" || stem == "." {
+		stem = "budget"
+	}
+	return stem + "-"
+}
+
+// ListBackups returns the backups of the open database in dir, newest first
+func ListBackups(dir string) ([]Backup, error) {
+	return listBackups(dbFile, dir)
+}
+
+func listBackups(dbPath, dir string) ([]Backup, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := backupPrefix(dbPath)
+	var backups []Backup
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) || filepath.Ext(name) != ".db" {
+			continue
+		}
+		rest := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".db")
+		if len(rest) < len(backupTime) {
+			continue
+		}
+		taken, err := time.ParseInLocation(backupTime, rest[:len(backupTime)], time.Local)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, Backup{
+			Path:     filepath.Join(dir, name),
+			Time:     taken,
+			Reason:   backupReason(rest[len(backupTime):]),
+			Size:     info.Size(),
+			modified: info.ModTime(),
+		})
+	}
+	sort.SliceStable(backups, func(i, j int) bool {
+		if !backups[i].Time.Equal(backups[j].Time) {
+			return backups[i].Time.After(backups[j].Time)
+		}
+		return backups[i].modified.After(backups[j].modified)
+	})
+	return backups, nil
+}
+
+// backupReason reads the reason from the end of a backup name, dropping
+// the counter newBackupPath adds to tell backups of the same second apart
+func backupReason(suffix string) string {
+	parts := strings.Split(strings.TrimPrefix(suffix, "-"), "-")
+	if last := parts[len(parts)-1]; last != "" {
+		if _, err := strconv.Atoi(last); err == nil {
+			parts = parts[:len(parts)-1]
+		}
+	}
+	return strings.Join(parts, "-")
+}
+
+// PruneBackups deletes all but the keep newest backups of the open database
+// in dir and returns the deleted paths. keep 0 deletes nothing.
+func PruneBackups(dir string, keep int) ([]string, error) {
+	return pruneBackups(dbFile, dir, keep)
+}
+
+func pruneBackups(dbPath, dir string, keep int) ([]string, error) {
+	if keep <= 0 {
+		return nil, nil
+	}
+	backups, err := listBackups(dbPath, dir)
+	if err != nil || len(backups) <= keep {
+		return nil, err
+	}
+
+	var removed []string
+	for _, backup := range backups[keep:] {
+		if err := os.Remove(backup.Path); err != nil {
+			return removed, err
+		}
+		removed = append(removed, backup.Path)
+	}
+	return removed, nil
+}
+
+// BackupKeep returns how many backups are kept
+func BackupKeep() int {
+	return backupKeep(DB)
+}
+
+func backupKeep(database *gorm.DB) int {
+	var values []string
+	err := database.Raw("SELECT value FROM settings WHERE key = ?", SettingBackupKeep).Scan(&values).Error
+	if err != nil || len(values) == 0 {
+		return DefaultBackupKeep
+	}
+	keep, err := strconv.Atoi(values[0])
+	if err != nil || keep < 0 {
+		return DefaultBackupKeep
+	}
+	return keep
+}
+
+// backupBeforeMigrate backs up database, opened from dbPath, when migrating
+// it would change its schema; backup writes the copy to dir. A new, empty
+// database has nothing to save.
+func backupBeforeMigrate(database *gorm.DB, dbPath string, backup func(dir string) error) error {
+	version, tables, err := schema(database)
+	if err != nil {
+		return err
+	}
+	if len(tables) == 0 {
+		return nil
+	}
+	changes, err := migrationChanges(database, version, tables)
+	if err != nil || !changes {
+		return err
+	}
+
+	dir := backupDir(dbPath)
+	if err := backup(dir); err != nil {
+		return fmt.Errorf("failed to back up database before migrating: %w", err)
+	}
+	_, err = pruneBackups(dbPath, dir, backupKeep(database))
+	return err
+}
+
+// errDryRun rolls back the trial migration
+var errDryRun = errors.New("dry run")
+
+// migrationChanges runs Migrate in a transaction that is rolled back and
+// reports whether it changed the schema. SQLite's schema changes are
+// transactional, so nothing is left behind.
+func migrationChanges(database *gorm.DB, version int, tables []string) (bool, error) {
+	var changed bool
+	err := database.Transaction(func(tx *gorm.DB) error {
+		if err := Migrate(tx); err != nil {
+			return err
+		}
+		newVersion, newTables, err := schema(tx)
+		if err != nil {
+			return err
+		}
+		changed = newVersion != version || strings.Join(newTables, "\n") != strings.Join(tables, "\n")
+		return errDryRun
+	})
+	if !errors.Is(err, errDryRun) {
+		return false, err
+	}
+	return changed, nil
+}
+
+// schema returns the schema version of database and the statements that
+// create its tables and indexes
+func schema(database *gorm.DB) (int, []string, error) {
+	version, err := currentVersion(database)
+	if err != nil {
+		return 0, nil, err
+	}
+	var statements []string
+	err = database.Raw("SELECT sql FROM sqlite_master WHERE sql IS NOT NULL ORDER BY type, name").
+		Scan(&statements).Error
+	return version, statements, err
+}
+
+// Restore replaces the open database with the backup at path once it has
+// checked that this build can read it. The current database is saved as a
+// backup first; its path is returned. An encrypted backup is opened with
+// the key of the open database or else with the passphrase asked for.
+func Restore(path string, passphrase func() (string, error)) (string, error) {
+	if DB == nil {
+		return "", fmt.Errorf("database is not open")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read backup: %w", err)
+	}
+	plain, err := decryptBackup(data, passphrase)
+	if err != nil {
+		return "", err
+	}
+	if err := checkBackup(plain); err != nil {
+		return "", err
+	}
+
+	saved, err := CreateBackup(BackupDir(), "pre-restore")
+	if err != nil {
+		return "", err
+	}
+
+	if v := current.Load(); v != nil {
+		if err := load(v.database, plain); err != nil {
+			return saved, fmt.Errorf("failed to load backup: %w", err)
+		}
+		if err := Migrate(v.database); err != nil {
+			return saved, err
+		}
+		return saved, v.sync()
+	}
+
+	closeDB(DB)
+	DB = nil
+	if err := writeFileAtomic(dbFile, plain); err != nil {
+		return saved, err
+	}
+	for _, suffix := range []string{"-journal", "-wal", "-shm"} {
+		os.Remove(dbFile + suffix)
+	}
+	return saved, Initialize(dbFile)
+}
+
+// decryptBackup returns the SQLite file of a backup, decrypting it if needed
+func decryptBackup(data []byte, passphrase func() (string, error)) ([]byte, error) {
+	if _, _, err := parseHeader(data); errors.Is(err, ErrNotEncrypted) {
+		return data, nil
+	}
+	if v := current.Load(); v != nil {
+		if plain, err := v.open(data); err == nil {
+			return plain, nil
+		}
+	}
+	secret, err := passphrase()
+	if err != nil {
+		return nil, err
+	}
+	_, plain, err := openVault("", secret, data)
+	return plain, err
+}
+
+// checkBackup verifies that plain is an intact database of a schema this
+// build can migrate
+func checkBackup(plain []byte) error {
+	database, err := openMemory(plain)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrNotBackup, err)
+	}
+	defer closeDB(database)
+
+	var integrity string
+	if err := database.Raw("PRAGMA integrity_check").Scan(&integrity).Error; err != nil {
+		return fmt.Errorf("%w: %v", ErrNotBackup, err)
+	}
+	if integrity != "ok" {
+		return fmt.Errorf("backup is damaged: %s", integrity)
+	}
+
+	if err := checkVersion(database); err != nil {
+		return err
+	}
+	for _, table := range requiredTables {
+		if !database.Migrator().HasTable(table) {
+			return fmt.Errorf("%w: no %s table", ErrNotBackup, table)
+		}
+	}
+	return nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
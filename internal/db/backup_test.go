@@ -0,0 +1,196 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bioharz/budget/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func noPassphrase() (string, error) {
+	return "", ErrWrongPassphrase
+}
+
+func TestBackup_CreateAndRestore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "budget.db")
+	require.NoError(t, Initialize(path))
+	t.Cleanup(func() { Close() })
+	require.NoError(t, DB.Create(&models.Account{Name: "Hardware Wallet", Type: "wallet"}).Error)
+
+	backup, err := CreateBackup(BackupDir(), "")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(filepath.Dir(path), "backups"), filepath.Dir(backup))
+	require.NoError(t, DB.Create(&models.Account{Name: "Bank", Type: "bank"}).Error)
+
+	saved, err := Restore(backup, noPassphrase)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), countAccounts(t))
+
+	backups, err := ListBackups(BackupDir())
+	require.NoError(t, err)
+	require.Len(t, backups, 2)
+	assert.Equal(t, saved, backups[0].Path)
+	assert.Equal(t, "pre-restore", backups[0].Reason)
+	assert.Equal(t, backup, backups[1].Path)
+	assert.Empty(t, backups[1].Reason)
+
+	// The saved state can itself be restored
+	_, err = Restore(saved, noPassphrase)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), countAccounts(t))
+}
+
+func TestBackup_Encrypted(t *testing.T) {
+	kdfIterations = 1000
+	t.Cleanup(func() { kdfIterations = 600_000 })
+	path := filepath.Join(t.TempDir(), "budget.db")
+	require.NoError(t, Initialize(path))
+	t.Cleanup(func() { Close() })
+	require.NoError(t, DB.Create(&models.Account{Name: "Hardware Wallet", Type: "wallet"}).Error)
+	require.NoError(t, Encrypt("correct horse"))
+
+	backup, err := CreateBackup(BackupDir(), "")
+	require.NoError(t, err)
+	encrypted, err := IsEncrypted(backup)
+	require.NoError(t, err)
+	assert.True(t, encrypted)
+
+	// Restored with the key of the open database
+	require.NoError(t, DB.Create(&models.Account{Name: "Bank", Type: "bank"}).Error)
+	_, err = Restore(backup, noPassphrase)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), countAccounts(t))
+	assert.True(t, Encrypted())
+
+	// A backup from before a passphrase change needs the old passphrase
+	require.NoError(t, ChangePassphrase("battery staple"))
+	_, err = Restore(backup, noPassphrase)
+	assert.ErrorIs(t, err, ErrWrongPassphrase)
+	_, err = Restore(backup, func() (string, error) { return "correct horse", nil })
+	require.NoError(t, err)
+
+	require.NoError(t, Close())
+	require.NoError(t, InitializeEncrypted(path, "battery staple"))
+	assert.Equal(t, int64(1), countAccounts(t))
+}
+
+func TestRestore_Rejects(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, Initialize(filepath.Join(dir, "budget.db")))
+	t.Cleanup(func() { Close() })
+
+	newer := filepath.Join(dir, "newer.db")
+	require.NoError(t, DB.Exec("VACUUM INTO ?", newer).Error)
+	other, err := gorm.Open(sqlite.Open(newer), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+	require.NoError(t, other.Create(&schemaVersion{Version: 99, Name: "from the future", AppliedAt: time.Now()}).Error)
+	closeDB(other)
+
+	foreign := filepath.Join(dir, "foreign.db")
+	other, err = gorm.Open(sqlite.Open(foreign), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+	require.NoError(t, other.Exec("CREATE TABLE notes (body TEXT)").Error)
+	closeDB(other)
+
+	text := filepath.Join(dir, "notes.txt")
+	require.NoError(t, os.WriteFile(text, []byte("not a database at all"), 0644))
+
+	_, err = Restore(newer, noPassphrase)
+	assert.ErrorIs(t, err, ErrNewerSchema)
+	_, err = Restore(foreign, noPassphrase)
+	assert.ErrorIs(t, err, ErrNotBackup)
+	_, err = Restore(text, noPassphrase)
+	assert.ErrorIs(t, err, ErrNotBackup)
+
+	// Nothing was replaced or saved
+	backups, err := ListBackups(BackupDir())
+	require.NoError(t, err)
+	assert.Empty(t, backups)
+}
+
+func TestPruneBackups(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, Initialize(filepath.Join(dir, "budget.db")))
+	t.Cleanup(func() { Close() })
+
+	backups := filepath.Join(dir, "backups")
+	require.NoError(t, os.MkdirAll(backups, 0700))
+	for _, name := range []string{
+		"budget-20240101-120000.db",
+		"budget-20240301-120000-pre-migrate.db",
+		"budget-20240201-120000.db",
+		"budget-20240201-120000-2.db",
+		"other-20230101-120000.db",
+		"budget-notes.db",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(backups, name), nil, 0600))
+	}
+
+	listed, err := ListBackups(backups)
+	require.NoError(t, err)
+	require.Len(t, listed, 4)
+	assert.Equal(t, time.Date(2024, 3, 1, 12, 0, 0, 0, time.Local), listed[0].Time)
+	assert.Equal(t, "pre-migrate", listed[0].Reason)
+	assert.Empty(t, listed[1].Reason)
+
+	removed, err := PruneBackups(backups, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(backups, "budget-20240101-120000.db")}, removed)
+
+	removed, err = PruneBackups(backups, 0)
+	require.NoError(t, err)
+	assert.Empty(t, removed)
+	entries, err := os.ReadDir(backups)
+	require.NoError(t, err)
+	assert.Len(t, entries, 5)
+}
+
+func TestInitialize_BacksUpBeforeSchemaChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "budget.db")
+	old, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+	require.NoError(t, Migrate(old))
+	require.NoError(t, old.Create(&models.Account{Name: "Hardware Wallet", Type: "wallet"}).Error)
+	// Assets had no provider ID before explicit price providers
+	require.NoError(t, old.Migrator().DropColumn(&models.Asset{}, "provider_id"))
+	closeDB(old)
+
+	require.NoError(t, Initialize(path))
+	backups, err := ListBackups(BackupDir())
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+	assert.Equal(t, "pre-migrate", backups[0].Reason)
+	assert.Equal(t, int64(1), countAccounts(t))
+
+	// The backup holds the schema from before the migration
+	copied, err := gorm.Open(sqlite.Open(backups[0].Path), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+	assert.False(t, copied.Migrator().HasColumn(&models.Asset{}, "provider_id"))
+	closeDB(copied)
+
+	// An up to date database is opened without another backup
+	require.NoError(t, Close())
+	require.NoError(t, Initialize(path))
+	t.Cleanup(func() { Close() })
+	backups, err = ListBackups(BackupDir())
+	require.NoError(t, err)
+	assert.Len(t, backups, 1)
+}
+
+func TestInitialize_NewDatabaseNeedsNoBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "budget.db")
+	require.NoError(t, Initialize(path))
+	t.Cleanup(func() { Close() })
+
+	version, err := currentVersion(DB)
+	require.NoError(t, err)
+	assert.Equal(t, SchemaVersion(), version)
+	assert.NoDirExists(t, BackupDir())
+}
@@ -5,7 +5,6 @@ import (
 	"os"
 	"path/filepath"
 
-	"github.com/bioharz/budget/internal/models"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -13,13 +12,20 @@ import (
 
 var DB *gorm.DB
 
-func Initialize() error {
-	dataDir := "./data"
-	if err := os.MkdirAll(dataDir, 0755); err != nil {
+// dbFile is the path of the open database
+var dbFile string
+
+// Initialize opens the database at dbPath, creating its directory if needed.
+// An existing database is backed up before its schema changes.
+func Initialize(dbPath string) error {
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
 		return fmt.Errorf("failed to create data directory: %w", err)
 	}
-
-	dbPath := filepath.Join(dataDir, "budget.db")
+	if encrypted, err := IsEncrypted(dbPath); err != nil {
+		return err
+	} else if encrypted {
+		return ErrEncrypted
+	}
 
 	db, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Silent),
@@ -28,25 +34,37 @@ func Initialize() error {
 		return fmt.Errorf("failed to connect to database: %w", err)
 	}
 
-	if err := db.AutoMigrate(
-		&models.Account{},
-		&models.Asset{},
-		&models.Holding{},
-		&models.AuditLog{},
-		&models.PortfolioSnapshot{},
-		&models.PriceCache{},
-	); err != nil {
-		return fmt.Errorf("failed to migrate database: %w", err)
+	err = backupBeforeMigrate(db, dbPath, func(dir string) error {
+		_, err := vacuumInto(db, dbPath, dir, "pre-migrate")
+		return err
+	})
+	if err != nil {
+		closeDB(db)
+		return err
+	}
+	if err := Migrate(db); err != nil {
+		closeDB(db)
+		return err
 	}
 
+	dbFile = dbPath
 	DB = db
 	return nil
 }
 
+// Close closes the database; an encrypted one is written to disk first
 func Close() error {
+	var syncErr error
+	if v := current.Load(); v != nil {
+		syncErr = v.shutdown()
+		defer v.lock.Close()
+	}
 	sqlDB, err := DB.DB()
 	if err != nil {
 		return err
 	}
-	return sqlDB.Close()
+	if err := sqlDB.Close(); err != nil {
+		return err
+	}
+	return syncErr
 }
@@ -0,0 +1,91 @@
+package db
+
+import (
+	"strings"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// decimalColumns were stored as REAL before amounts and prices became exact
+// decimals
+var decimalColumns = map[string][]string{
+	"holdings":            {"amount", "purchase_price"},
+	"transactions":        {"quantity", "price_usd", "fee"},
+	"portfolio_snapshots": {"total_value_usd", "total_value"},
+	"price_caches":        {"price_usd"},
+	"price_histories":     {"price_usd"},
+}
+
+type floatValue struct {
+	table  string
+	column string
+	id     uint
+	value  float64
+}
+
+// migrateToDecimal runs migrate and converts REAL amount columns to decimal
+// text on the way. SQLite would copy REAL values with 15 significant digits,
+// so they are read beforehand and written back in their shortest exact form.
+func migrateToDecimal(db *gorm.DB, migrate func() error) error {
+	var values []floatValue
+	for table, columns := range decimalColumns {
+		if !db.Migrator().HasTable(table) {
+			continue
+		}
+		columnTypes, err := db.Migrator().ColumnTypes(table)
+		if err != nil {
+			return err
+		}
+		for _, columnType := range columnTypes {
+			if !contains(columns, columnType.Name()) || !isFloatType(columnType.DatabaseTypeName()) {
+				continue
+			}
+			column := columnType.Name()
+
+			var rows []struct {
+				ID    uint
+				Value *float64
+			}
+			err := db.Table(table).Select("id, " + column + " AS value").Where(column + " IS NOT NULL").Scan(&rows).Error
+			if err != nil {
+				return err
+			}
+			for _, row := range rows {
+				values = append(values, floatValue{table: table, column: column, id: row.ID, value: *row.Value})
+			}
+		}
+	}
+
+	if err := migrate(); err != nil {
+		return err
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, v := range values {
+			exact := decimal.NewFromFloat(v.value).String()
+			if err := tx.Table(v.table).Where("id = ?", v.id).Update(v.column, exact).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func isFloatType(name string) bool {
+	name = strings.ToLower(name)
+	return strings.Contains(name, "real") || strings.Contains(name, "float") ||
+		strings.Contains(name, "double") || strings.Contains(name, "numeric")
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
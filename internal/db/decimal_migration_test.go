@@ -0,0 +1,47 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/bioharz/budget/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestMigrate_FloatColumnsToDecimal(t *testing.T) {
+	database, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "budget.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+
+	// The holdings table as it looked with float64 amounts
+	require.NoError(t, database.Exec(`CREATE TABLE holdings (
+		id integer PRIMARY KEY AUTOINCREMENT,
+		account_id integer NOT NULL,
+		asset_id integer NOT NULL,
+		amount real NOT NULL,
+		purchase_price real,
+		purchase_date datetime,
+		created_at datetime,
+		updated_at datetime,
+		deleted_at datetime
+	)`).Error)
+	require.NoError(t, database.Exec(
+		"INSERT INTO holdings (account_id, asset_id, amount, purchase_price) VALUES (1, 1, 21000000.00000001, 0.1)").Error)
+
+	require.NoError(t, Migrate(database))
+
+	var holding models.Holding
+	require.NoError(t, database.First(&holding).Error)
+	assert.Equal(t, "21000000.00000001", holding.Amount.String())
+	assert.Equal(t, "0.1", holding.PurchasePrice.String())
+
+	// Running again leaves the converted values alone
+	require.NoError(t, Migrate(database))
+	require.NoError(t, database.First(&holding).Error)
+	assert.Equal(t, "21000000.00000001", holding.Amount.String())
+}
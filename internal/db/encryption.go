@@ -0,0 +1,536 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// EnvPassphrase names the environment variable holding the passphrase of an
+// encrypted database, so scripts can run without a prompt
+const EnvPassphrase = "MINIMAL_MONEY_PASSPHRASE"
+
+// An encrypted database file is a header followed by the SQLite file sealed
+// with AES-256-GCM. The key is derived from the passphrase with
+// PBKDF2-SHA256; the header is authenticated along with the data.
+//
+//	magic (8) | iterations (4) | salt (16) | nonce (12) | ciphertext
+var vaultMagic = []byte("MMVAULT1")
+
+const (
+	saltSize   = 16
+	nonceSize  = 12
+	headerSize = 8 + 4 + saltSize + nonceSize
+)
+
+// kdfIterations is the PBKDF2 work factor of newly written files; each file
+// records its own, so it can be raised without breaking existing ones
+var kdfIterations = 600_000
+
+var (
+	ErrWrongPassphrase = errors.New("wrong passphrase or damaged database file")
+	ErrEncrypted       = errors.New("database is encrypted")
+	ErrNotEncrypted    = errors.New("database is not encrypted")
+	ErrLocked          = errors.New("database is open in another process")
+)
+
+// vault keeps an encrypted database file in sync with the in-memory
+// database that is used while the application runs. Every write marks the
+// database as changed; a background writer then re-encrypts it to disk.
+// Each write replaces the whole file, so only one process may have it open.
+type vault struct {
+	path     string
+	lock     *os.File // Held while the vault is open
+	salt     []byte
+	key      []byte
+	iter     int
+	database *gorm.DB
+	mu       sync.Mutex // Serializes writes of the file
+	changed  chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+	err      error // Last failed write, reported by Close
+}
+
+// current is the vault of the open database, nil when it is not encrypted
+var current atomic.Pointer[vault]
+
+// IsEncrypted reports whether the file at path is an encrypted database. A
+// missing file is not encrypted.
+func IsEncrypted(path string) (bool, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	magic := make([]byte, len(vaultMagic))
+	if _, err := io.ReadFull(file, magic); err != nil {
+		return false, nil
+	}
+	return bytes.Equal(magic, vaultMagic), nil
+}
+
+// Encrypted reports whether the open database is stored encrypted
+func Encrypted() bool {
+	return current.Load() != nil
+}
+
+// InitializeEncrypted decrypts the database at dbPath into memory and opens
+// it. Changes are written back encrypted as they happen and on Close.
+func InitializeEncrypted(dbPath, passphrase string) error {
+	lock, err := lockFile(dbPath)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(dbPath)
+	if err != nil {
+		lock.Close()
+		return fmt.Errorf("failed to read database: %w", err)
+	}
+	v, plain, err := openVault(dbPath, passphrase, data)
+	if err != nil {
+		lock.Close()
+		return err
+	}
+	v.lock = lock
+
+	database, err := openMemory(plain)
+	if err != nil {
+		lock.Close()
+		return err
+	}
+	// The file as read is the backup, still encrypted
+	err = backupBeforeMigrate(database, dbPath, func(dir string) error {
+		_, err := writeBackup(dbPath, dir, "pre-migrate", data)
+		return err
+	})
+	if err != nil {
+		closeDB(database)
+		lock.Close()
+		return err
+	}
+	if err := Migrate(database); err != nil {
+		closeDB(database)
+		lock.Close()
+		return err
+	}
+
+	dbFile = dbPath
+	DB = database
+	v.start(database)
+	return nil
+}
+
+// Encrypt seals the open plaintext database with passphrase, replacing the
+// file on disk, and keeps running on the encrypted copy
+func Encrypt(passphrase string) error {
+	if Encrypted() {
+		return ErrEncrypted
+	}
+	if passphrase == "" {
+		return fmt.Errorf("passphrase cannot be empty")
+	}
+
+	plain, err := serialize(DB)
+	if err != nil {
+		return err
+	}
+	v, err := newVault(dbFile, passphrase)
+	if err != nil {
+		return err
+	}
+	if v.lock, err = lockFile(dbFile); err != nil {
+		return err
+	}
+	if err := v.write(plain); err != nil {
+		v.lock.Close()
+		return err
+	}
+	// Leftovers of the plaintext file would defeat the encryption
+	for _, suffix := range []string{"-journal", "-wal", "-shm"} {
+		os.Remove(dbFile + suffix)
+	}
+
+	database, err := openMemory(plain)
+	if err != nil {
+		v.lock.Close()
+		return err
+	}
+	closeDB(DB)
+	DB = database
+	v.start(database)
+	return nil
+}
+
+// Decrypt writes the open encrypted database back as a plain SQLite file and
+// reopens it from there
+func Decrypt() error {
+	v := current.Load()
+	if v == nil {
+		return ErrNotEncrypted
+	}
+
+	if err := v.shutdown(); err != nil {
+		v.start(v.database)
+		return err
+	}
+	plain, err := serialize(v.database)
+	if err == nil {
+		err = writeFileAtomic(v.path, plain)
+	}
+	if err != nil {
+		v.start(v.database)
+		return err
+	}
+
+	closeDB(v.database)
+	v.lock.Close()
+	DB = nil
+	return Initialize(v.path)
+}
+
+// ChangePassphrase re-encrypts the open database with a new passphrase
+func ChangePassphrase(passphrase string) error {
+	v := current.Load()
+	if v == nil {
+		return ErrNotEncrypted
+	}
+	if passphrase == "" {
+		return fmt.Errorf("passphrase cannot be empty")
+	}
+
+	salt, key, err := deriveNewKey(passphrase)
+	if err != nil {
+		return err
+	}
+	plain, err := serialize(v.database)
+	if err != nil {
+		return err
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	oldSalt, oldKey, oldIter := v.salt, v.key, v.iter
+	v.salt, v.key, v.iter = salt, key, kdfIterations
+	if err := v.writeLocked(plain); err != nil {
+		v.salt, v.key, v.iter = oldSalt, oldKey, oldIter
+		return err
+	}
+	return nil
+}
+
+func newVault(dbPath, passphrase string) (*vault, error) {
+	salt, key, err := deriveNewKey(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return &vault{path: dbPath, salt: salt, key: key, iter: kdfIterations}, nil
+}
+
+func deriveNewKey(passphrase string) (salt, key []byte, err error) {
+	salt = make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, nil, err
+	}
+	key, err = pbkdf2.Key(sha256.New, passphrase, salt, kdfIterations, 32)
+	return salt, key, err
+}
+
+// openVault checks the header of an encrypted file and decrypts it
+func openVault(dbPath, passphrase string, data []byte) (*vault, []byte, error) {
+	iter, salt, err := parseHeader(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, iter, 32)
+	if err != nil {
+		return nil, nil, err
+	}
+	plain, err := unseal(key, data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	v := &vault{path: dbPath, salt: bytes.Clone(salt), key: key, iter: iter}
+	return v, plain, nil
+}
+
+// parseHeader returns the work factor and salt of an encrypted file
+func parseHeader(data []byte) (iter int, salt []byte, err error) {
+	if len(data) < headerSize || !bytes.Equal(data[:len(vaultMagic)], vaultMagic) {
+		return 0, nil, ErrNotEncrypted
+	}
+	return int(binary.BigEndian.Uint32(data[8:12])), data[12 : 12+saltSize], nil
+}
+
+func unseal(key, data []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	header := data[:headerSize]
+	plain, err := aead.Open(nil, header[12+saltSize:], data[headerSize:], header)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return plain, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// write encrypts plain with a fresh nonce and replaces the file
+func (v *vault) write(plain []byte) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.writeLocked(plain)
+}
+
+func (v *vault) writeLocked(plain []byte) error {
+	sealed, err := v.seal(plain)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(v.path, sealed)
+}
+
+// seal returns the encrypted file holding plain, under a fresh nonce
+func (v *vault) seal(plain []byte) ([]byte, error) {
+	header := make([]byte, headerSize)
+	copy(header, vaultMagic)
+	binary.BigEndian.PutUint32(header[8:12], uint32(v.iter))
+	copy(header[12:], v.salt)
+	nonce := header[12+saltSize:]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(v.key)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(header, nonce, plain, header), nil
+}
+
+// open decrypts an encrypted file written with the key of v
+func (v *vault) open(data []byte) ([]byte, error) {
+	iter, salt, err := parseHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if iter != v.iter || !bytes.Equal(salt, v.salt) {
+		return nil, ErrWrongPassphrase
+	}
+	return unseal(v.key, data)
+}
+
+// start runs the background writer that syncs database to the file
+func (v *vault) start(database *gorm.DB) {
+	v.database = database
+	v.changed = make(chan struct{}, 1)
+	v.stop = make(chan struct{})
+	v.done = make(chan struct{})
+	current.Store(v)
+
+	go func() {
+		defer close(v.done)
+		for {
+			select {
+			case <-v.changed:
+				// Waits for the connection, so open transactions finish first
+				if err := v.sync(); err != nil {
+					v.mu.Lock()
+					v.err = err
+					v.mu.Unlock()
+				}
+			case <-v.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (v *vault) sync() error {
+	plain, err := serialize(v.database)
+	if err != nil {
+		return err
+	}
+	return v.write(plain)
+}
+
+// shutdown stops the background writer and writes the database one last
+// time. The file stays locked until the caller closes v.lock.
+func (v *vault) shutdown() error {
+	current.Store(nil)
+	close(v.stop)
+	<-v.done
+
+	if err := v.sync(); err != nil {
+		return err
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.err
+}
+
+// markChanged tells the background writer of the open vault that the
+// database has changed
+func markChanged(*gorm.DB) {
+	v := current.Load()
+	if v == nil {
+		return
+	}
+	select {
+	case v.changed <- struct{}{}:
+	default:
+	}
+}
+
+// openMemory opens an in-memory database holding the SQLite file plain. An
+// in-memory database lives in its connection, so the pool keeps exactly one.
+func openMemory(plain []byte) (*gorm.DB, error) {
+	database, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	sqlDB, err := database.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(1)
+	sqlDB.SetMaxIdleConns(1)
+	sqlDB.SetConnMaxLifetime(0)
+	sqlDB.SetConnMaxIdleTime(0)
+
+	callbacks := database.Callback()
+	_ = callbacks.Create().After("*").Register("vault:changed", markChanged)
+	_ = callbacks.Update().After("*").Register("vault:changed", markChanged)
+	_ = callbacks.Delete().After("*").Register("vault:changed", markChanged)
+	_ = callbacks.Raw().After("*").Register("vault:changed", markChanged)
+
+	if len(plain) > 0 {
+		if err := load(database, plain); err != nil {
+			closeDB(database)
+			return nil, fmt.Errorf("failed to load database: %w", err)
+		}
+	}
+	return database, nil
+}
+
+// load replaces the contents of an in-memory database with the SQLite file
+// plain. Deserializing into the database itself would fix its size, so plain
+// is deserialized into a scratch connection and copied over with the backup
+// API; the copy grows like any in-memory database.
+func load(database *gorm.DB, plain []byte) error {
+	driverConn, err := (&sqlite3.SQLiteDriver{}).Open(":memory:")
+	if err != nil {
+		return err
+	}
+	source := driverConn.(*sqlite3.SQLiteConn)
+	defer source.Close()
+	if err := source.Deserialize(plain, "main"); err != nil {
+		return err
+	}
+
+	return withConn(database, func(conn *sqlite3.SQLiteConn) error {
+		backup, err := conn.Backup("main", source, "main")
+		if err != nil {
+			return err
+		}
+		if _, err := backup.Step(-1); err != nil {
+			backup.Finish()
+			return err
+		}
+		return backup.Finish()
+	})
+}
+
+// serialize returns the database as the bytes of a SQLite file
+func serialize(database *gorm.DB) ([]byte, error) {
+	var plain []byte
+	err := withConn(database, func(conn *sqlite3.SQLiteConn) error {
+		var err error
+		plain, err = conn.Serialize("main")
+		return err
+	})
+	return plain, err
+}
+
+func withConn(database *gorm.DB, fn func(*sqlite3.SQLiteConn) error) error {
+	sqlDB, err := database.DB()
+	if err != nil {
+		return err
+	}
+	conn, err := sqlDB.Conn(context.Background())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		sqliteConn, ok := driverConn.(*sqlite3.SQLiteConn)
+		if !ok {
+			return fmt.Errorf("unexpected database driver %T", driverConn)
+		}
+		return fn(sqliteConn)
+	})
+}
+
+// writeFileAtomic replaces path with data, never leaving a partial file
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func closeDB(database *gorm.DB) {
+	if sqlDB, err := database.DB(); err == nil {
+		sqlDB.Close()
+	}
+}
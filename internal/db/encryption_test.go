@@ -0,0 +1,138 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bioharz/budget/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func countAccounts(t *testing.T) int64 {
+	t.Helper()
+	var count int64
+	require.NoError(t, DB.Model(&models.Account{}).Count(&count).Error)
+	return count
+}
+
+func TestEncryption_RoundTrip(t *testing.T) {
+	kdfIterations = 1000
+	t.Cleanup(func() { kdfIterations = 600_000 })
+	path := filepath.Join(t.TempDir(), "budget.db")
+
+	require.NoError(t, Initialize(path))
+	require.NoError(t, DB.Create(&models.Account{Name: "Hardware Wallet", Type: "wallet"}).Error)
+	require.ErrorIs(t, Decrypt(), ErrNotEncrypted)
+
+	require.NoError(t, Encrypt("correct horse"))
+	assert.True(t, Encrypted())
+	require.NoError(t, DB.Create(&models.Account{Name: "Bank", Type: "bank"}).Error)
+	require.NoError(t, Close())
+
+	// Nothing readable is left on disk
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.False(t, bytes.Contains(data, []byte("SQLite format 3")))
+	assert.False(t, bytes.Contains(data, []byte("Hardware Wallet")))
+	encrypted, err := IsEncrypted(path)
+	require.NoError(t, err)
+	assert.True(t, encrypted)
+	assert.ErrorIs(t, Initialize(path), ErrEncrypted)
+
+	assert.ErrorIs(t, InitializeEncrypted(path, "wrong"), ErrWrongPassphrase)
+	require.NoError(t, InitializeEncrypted(path, "correct horse"))
+	assert.Equal(t, int64(2), countAccounts(t))
+
+	require.NoError(t, ChangePassphrase("battery staple"))
+	require.NoError(t, Close())
+	assert.ErrorIs(t, InitializeEncrypted(path, "correct horse"), ErrWrongPassphrase)
+	require.NoError(t, InitializeEncrypted(path, "battery staple"))
+
+	require.NoError(t, Decrypt())
+	assert.False(t, Encrypted())
+	require.NoError(t, Close())
+	require.NoError(t, Initialize(path))
+	assert.Equal(t, int64(2), countAccounts(t))
+	require.NoError(t, Close())
+}
+
+func TestEncryption_WritesChangesInBackground(t *testing.T) {
+	kdfIterations = 1000
+	t.Cleanup(func() { kdfIterations = 600_000 })
+	path := filepath.Join(t.TempDir(), "budget.db")
+
+	require.NoError(t, Initialize(path))
+	require.NoError(t, Encrypt("secret"))
+	before, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	// A committed change reaches the file without closing the database
+	require.NoError(t, DB.Create(&models.Account{Name: "Bank", Type: "bank"}).Error)
+	assert.Eventually(t, func() bool {
+		after, err := os.ReadFile(path)
+		return err == nil && !bytes.Equal(before, after)
+	}, 2*time.Second, 10*time.Millisecond)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	_, plain, err := openVault(path, "secret", data)
+	require.NoError(t, err)
+	assert.True(t, bytes.Contains(plain, []byte("Bank")))
+	require.NoError(t, Close())
+}
+
+func TestEncryption_DatabaseGrows(t *testing.T) {
+	kdfIterations = 1000
+	t.Cleanup(func() { kdfIterations = 600_000 })
+	path := filepath.Join(t.TempDir(), "budget.db")
+
+	require.NoError(t, Initialize(path))
+	require.NoError(t, Encrypt("secret"))
+	require.NoError(t, Close())
+
+	// The decrypted database takes more pages than the file it was read from
+	require.NoError(t, InitializeEncrypted(path, "secret"))
+	for i := 0; i < 500; i++ {
+		account := models.Account{Name: fmt.Sprintf("Account %d %s", i, strings.Repeat("x", 100)), Type: "bank"}
+		require.NoError(t, DB.Create(&account).Error)
+	}
+	require.NoError(t, Close())
+
+	require.NoError(t, InitializeEncrypted(path, "secret"))
+	assert.Equal(t, int64(500), countAccounts(t))
+	require.NoError(t, Close())
+}
+
+func TestEncryption_RefusesSecondOpener(t *testing.T) {
+	kdfIterations = 1000
+	t.Cleanup(func() { kdfIterations = 600_000 })
+	path := filepath.Join(t.TempDir(), "budget.db")
+
+	require.NoError(t, Initialize(path))
+	require.NoError(t, Encrypt("secret"))
+
+	// Another process would overwrite the file with its own copy
+	_, err := lockFile(path)
+	assert.ErrorIs(t, err, ErrLocked)
+	require.NoError(t, Close())
+
+	lock, err := lockFile(path)
+	require.NoError(t, err)
+	assert.ErrorIs(t, InitializeEncrypted(path, "secret"), ErrLocked)
+	lock.Close()
+
+	require.NoError(t, InitializeEncrypted(path, "secret"))
+	require.NoError(t, Decrypt())
+	require.NoError(t, Close())
+
+	// A plain database is locked by SQLite itself
+	lock, err = lockFile(path)
+	require.NoError(t, err)
+	lock.Close()
+}
@@ -0,0 +1,26 @@
+//go:build !windows
+
+package db
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// lockFile takes the lock next to the database at dbPath, failing with
+// ErrLocked while another process holds it. Closing the file releases it.
+func lockFile(dbPath string) (*os.File, error) {
+	file, err := os.OpenFile(dbPath+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		file.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, ErrLocked
+		}
+		return nil, err
+	}
+	return file, nil
+}
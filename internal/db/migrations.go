@@ -0,0 +1,175 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bioharz/budget/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// schemaVersion is a row of the schema_version table, one per applied
+// migration
+type schemaVersion struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (schemaVersion) TableName() string {
+	return "schema_version"
+}
+
+// migration is one numbered step in the history of the schema. Released
+// steps never change; correcting one takes a new step.
+type migration struct {
+	version int
+	name    string
+	up      func(tx *gorm.DB) error
+}
+
+// migrations run in order on every database that has not seen them yet.
+// AutoMigrate runs after them and adds the tables, columns and indexes the
+// models declare, so only what it cannot do needs a step here: renames,
+// type changes and data backfills.
+var migrations = []migration{
+	{1, "initial schema with decimal amounts", initialSchema},
+	{2, "opening balances for holdings", openingBalances},
+}
+
+// SchemaVersion returns the version of the newest migration, the schema
+// this build writes
+func SchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// Migrate brings the schema of db up to date. Each pending migration runs
+// in a transaction together with its schema_version row, so a failed step
+// leaves the database as it was before that step.
+func Migrate(db *gorm.DB) error {
+	if err := db.AutoMigrate(&schemaVersion{}); err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+	if err := checkVersion(db); err != nil {
+		return err
+	}
+	version, err := currentVersion(db)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if m.version <= version {
+			continue
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&schemaVersion{Version: m.version, Name: m.name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("failed to migrate database to version %d (%s): %w", m.version, m.name, err)
+		}
+	}
+
+	if err := autoMigrate(db); err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+	return nil
+}
+
+func autoMigrate(db *gorm.DB) error {
+	return db.AutoMigrate(
+		&models.Account{},
+		&models.Asset{},
+		&models.Holding{},
+		&models.Transaction{},
+		&models.AuditLog{},
+		&models.PortfolioSnapshot{},
+		&models.PriceCache{},
+		&models.PriceHistory{},
+		&models.CoinListing{},
+		&models.Setting{},
+	)
+}
+
+// initialSchema creates the tables as they were when versioning started.
+// Databases from before versioning already have them and get their REAL
+// amounts converted to decimal text.
+func initialSchema(tx *gorm.DB) error {
+	return migrateToDecimal(tx, func() error {
+		return tx.AutoMigrate(v1Tables...)
+	})
+}
+
+// openingBalances gives every live holding of a position without ledger
+// entries an opening deposit at its purchase price and date, so holdings
+// from before the ledger can be derived from it. These are the rows the
+// ledger itself creates for a position it meets without entries.
+func openingBalances(tx *gorm.DB) error {
+	type position struct {
+		AccountID uint
+		AssetID   uint
+	}
+	var recorded []position
+	if err := tx.Model(&v1Transaction{}).Distinct("account_id", "asset_id").Find(&recorded).Error; err != nil {
+		return err
+	}
+	hasLedger := make(map[position]bool, len(recorded))
+	for _, p := range recorded {
+		hasLedger[p] = true
+	}
+
+	var holdings []v1Holding
+	if err := tx.Order("id").Find(&holdings).Error; err != nil {
+		return err
+	}
+	for _, holding := range holdings {
+		if hasLedger[position{holding.AccountID, holding.AssetID}] || !holding.Amount.IsPositive() {
+			continue
+		}
+		timestamp := holding.PurchaseDate
+		if timestamp.IsZero() {
+			timestamp = holding.CreatedAt
+		}
+		opening := &v1Transaction{
+			Type:      "deposit",
+			AccountID: holding.AccountID,
+			AssetID:   holding.AssetID,
+			Quantity:  holding.Amount,
+			PriceUSD:  holding.PurchasePrice,
+			Note:      "Opening balance",
+			Timestamp: timestamp,
+		}
+		if err := tx.Omit(clause.Associations).Create(opening).Error; err != nil {
+			return fmt.Errorf("failed to create opening balance: %w", err)
+		}
+	}
+	return nil
+}
+
+// currentVersion returns the newest migration applied to db, 0 for a
+// database from before versioning
+func currentVersion(db *gorm.DB) (int, error) {
+	if !db.Migrator().HasTable(&schemaVersion{}) {
+		return 0, nil
+	}
+	var version int
+	err := db.Model(&schemaVersion{}).Select("COALESCE(MAX(version), 0)").Scan(&version).Error
+	return version, err
+}
+
+// checkVersion refuses a database migrated by a newer build, whose schema
+// this one does not know
+func checkVersion(db *gorm.DB) error {
+	version, err := currentVersion(db)
+	if err != nil {
+		return err
+	}
+	if version > SchemaVersion() {
+		return fmt.Errorf("%w (schema %d, this build supports up to %d)", ErrNewerSchema, version, SchemaVersion())
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package db
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bioharz/budget/internal/models"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+)
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	database, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "budget.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { closeDB(database) })
+	return database
+}
+
+// withMigrations appends steps to the migrations for the duration of a test
+func withMigrations(t *testing.T, steps ...migration) {
+	t.Helper()
+	saved := migrations
+	migrations = append(append([]migration{}, saved...), steps...)
+	t.Cleanup(func() { migrations = saved })
+}
+
+func appliedVersions(t *testing.T, database *gorm.DB) []int {
+	t.Helper()
+	var versions []int
+	require.NoError(t, database.Model(&schemaVersion{}).Order("version").Pluck("version", &versions).Error)
+	return versions
+}
+
+func TestMigrations_Numbered(t *testing.T) {
+	for i, m := range migrations {
+		assert.Equal(t, i+1, m.version, "migration %q", m.name)
+		assert.NotEmpty(t, m.name)
+	}
+}
+
+func TestMigrations_InitialSchemaFrozen(t *testing.T) {
+	database := openTestDB(t)
+
+	// Step 1 creates the tables it did when it was released; columns added
+	// to the models since come from AutoMigrate
+	require.NoError(t, initialSchema(database))
+	assert.True(t, database.Migrator().HasColumn("audit_logs", "user_note"))
+	assert.False(t, database.Migrator().HasColumn("audit_logs", "undone"))
+
+	require.NoError(t, Migrate(database))
+	assert.True(t, database.Migrator().HasColumn("audit_logs", "undone"))
+}
+
+func TestMigrate_RecordsVersions(t *testing.T) {
+	database := openTestDB(t)
+
+	require.NoError(t, Migrate(database))
+	require.NoError(t, Migrate(database))
+
+	var rows []schemaVersion
+	require.NoError(t, database.Order("version").Find(&rows).Error)
+	require.Len(t, rows, len(migrations))
+	assert.Equal(t, "initial schema with decimal amounts", rows[0].Name)
+	assert.False(t, rows[0].AppliedAt.IsZero())
+}
+
+func TestMigrate_PendingSteps(t *testing.T) {
+	database := openTestDB(t)
+	require.NoError(t, Migrate(database))
+	require.NoError(t, database.Create(&models.Account{Name: "Hardware Wallet", Type: "wallet"}).Error)
+
+	// A rename and a backfill, the kind of change AutoMigrate cannot make
+	withMigrations(t,
+		migration{SchemaVersion() + 1, "rename account colour", func(tx *gorm.DB) error {
+			return tx.Migrator().RenameColumn("accounts", "color", "colour")
+		}},
+		migration{SchemaVersion() + 2, "default account colour", func(tx *gorm.DB) error {
+			return tx.Exec("UPDATE accounts SET colour = ? WHERE colour IS NULL OR colour = ''", "#888888").Error
+		}},
+	)
+	require.NoError(t, Migrate(database))
+
+	var colour string
+	require.NoError(t, database.Raw("SELECT colour FROM accounts").Scan(&colour).Error)
+	assert.Equal(t, "#888888", colour)
+	assert.Equal(t, []int{1, 2, 3, 4}, appliedVersions(t, database))
+}
+
+func TestMigrate_FailedStepRollsBack(t *testing.T) {
+	database := openTestDB(t)
+	require.NoError(t, Migrate(database))
+	require.NoError(t, database.Create(&models.Account{Name: "Hardware Wallet", Type: "wallet"}).Error)
+
+	withMigrations(t, migration{SchemaVersion() + 1, "broken step", func(tx *gorm.DB) error {
+		if err := tx.Exec("UPDATE accounts SET name = 'changed'").Error; err != nil {
+			return err
+		}
+		return errors.New("step failed")
+	}})
+
+	err := Migrate(database)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "version 3 (broken step): step failed")
+
+	var account models.Account
+	require.NoError(t, database.First(&account).Error)
+	assert.Equal(t, "Hardware Wallet", account.Name)
+	assert.Equal(t, []int{1, 2}, appliedVersions(t, database))
+}
+
+func TestMigrate_RefusesNewerDatabase(t *testing.T) {
+	database := openTestDB(t)
+	require.NoError(t, Migrate(database))
+	require.NoError(t, database.Create(&schemaVersion{Version: SchemaVersion() + 1, Name: "from the future", AppliedAt: time.Now()}).Error)
+
+	assert.ErrorIs(t, Migrate(database), ErrNewerSchema)
+}
+
+func TestMigrate_AdoptsUnversionedDatabase(t *testing.T) {
+	database := openTestDB(t)
+	// A database from before versioning has the tables but no history
+	require.NoError(t, autoMigrate(database))
+	require.NoError(t, database.Create(&models.Account{Name: "Hardware Wallet", Type: "wallet"}).Error)
+
+	require.NoError(t, Migrate(database))
+	assert.Equal(t, []int{1, 2}, appliedVersions(t, database))
+	var count int64
+	require.NoError(t, database.Model(&models.Account{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestMigrate_OpeningBalances(t *testing.T) {
+	database := openTestDB(t)
+	require.NoError(t, initialSchema(database))
+	require.NoError(t, database.AutoMigrate(&schemaVersion{}))
+	require.NoError(t, database.Create(&schemaVersion{Version: 1, Name: migrations[0].name, AppliedAt: time.Now()}).Error)
+
+	wallet := v1Account{Name: "Hardware Wallet", Type: "wallet"}
+	bank := v1Account{Name: "Bank", Type: "bank"}
+	btc := v1Asset{Symbol: "BTC", Name: "Bitcoin", Type: "crypto"}
+	require.NoError(t, database.Create(&wallet).Error)
+	require.NoError(t, database.Create(&bank).Error)
+	require.NoError(t, database.Create(&btc).Error)
+	purchased := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	holdings := []v1Holding{
+		{AccountID: wallet.ID, AssetID: btc.ID, Amount: decimal.NewFromFloat(0.5), PurchasePrice: decimal.NewFromInt(20000), PurchaseDate: purchased},
+		{AccountID: wallet.ID, AssetID: btc.ID, Amount: decimal.NewFromInt(1), PurchasePrice: decimal.NewFromInt(30000)},
+		// A position that already has a ledger keeps it as it is
+		{AccountID: bank.ID, AssetID: btc.ID, Amount: decimal.NewFromInt(2)},
+	}
+	require.NoError(t, database.Omit(clause.Associations).Create(&holdings).Error)
+	require.NoError(t, database.Omit(clause.Associations).Create(&v1Transaction{
+		Type: "buy", AccountID: bank.ID, AssetID: btc.ID, Quantity: decimal.NewFromInt(2), Timestamp: purchased,
+	}).Error)
+
+	require.NoError(t, Migrate(database))
+	require.NoError(t, Migrate(database))
+	assert.Equal(t, []int{1, 2}, appliedVersions(t, database))
+
+	var openings []models.Transaction
+	require.NoError(t, database.Where("note = ?", "Opening balance").Order("id").Find(&openings).Error)
+	require.Len(t, openings, 2)
+	assert.Equal(t, models.TransactionDeposit, openings[0].Type)
+	assert.Equal(t, wallet.ID, openings[0].AccountID)
+	assert.Equal(t, "0.5", openings[0].Quantity.String())
+	assert.Equal(t, "20000", openings[0].PriceUSD.String())
+	assert.True(t, purchased.Equal(openings[0].Timestamp))
+	// Without a purchase date the holding's creation time stands in
+	assert.True(t, holdings[1].CreatedAt.Equal(openings[1].Timestamp))
+
+	var count int64
+	require.NoError(t, database.Model(&models.Transaction{}).Where("account_id = ?", bank.ID).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+}
@@ -0,0 +1,107 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// EnvDBPath names the environment variable that overrides the database location
+const EnvDBPath = "MINIMAL_MONEY_DB"
+
+// legacyDBPath is where releases before the XDG layout stored the database
+var legacyDBPath = filepath.Join("data", "budget.db")
+
+// ResolvePath picks the database path from the --db flag, then MINIMAL_MONEY_DB,
+// then the XDG data directory. Only the XDG default adopts a legacy ./data/budget.db.
+func ResolvePath(flagValue string) (string, error) {
+	if flagValue != "" {
+		return flagValue, nil
+	}
+	if envValue := os.Getenv(EnvDBPath); envValue != "" {
+		return envValue, nil
+	}
+
+	path, err := DefaultPath()
+	if err != nil {
+		return "", err
+	}
+	if err := migrateLegacyDB(legacyDBPath, path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// DefaultPath returns $XDG_DATA_HOME/minimal-money/budget.db, falling back to
+// ~/.local/share when XDG_DATA_HOME is unset as the XDG spec prescribes
+func DefaultPath() (string, error) {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" || !filepath.IsAbs(dataHome) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to determine home directory: %w", err)
+		}
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dataHome, "minimal-money", "budget.db"), nil
+}
+
+// migrateLegacyDB moves a database from the old working-directory location to
+// target, unless target already exists
+func migrateLegacyDB(legacy, target string) error {
+	if _, err := os.Stat(legacy); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to check legacy database: %w", err)
+	}
+	if _, err := os.Stat(target); err == nil {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	// SQLite may keep write-ahead and shared-memory files next to the database
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		src := legacy + suffix
+		if _, err := os.Stat(src); err != nil {
+			continue
+		}
+		if err := moveFile(src, target+suffix); err != nil {
+			return fmt.Errorf("failed to move legacy database: %w", err)
+		}
+	}
+	return nil
+}
+
+// moveFile renames src to dst, copying when they live on different filesystems
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return os.Remove(src)
+}
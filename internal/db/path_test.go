@@ -0,0 +1,66 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolvePath_Precedence(t *testing.T) {
+	dataHome := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", dataHome)
+	t.Setenv(EnvDBPath, "")
+
+	path, err := ResolvePath("")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dataHome, "minimal-money", "budget.db"), path)
+
+	t.Setenv(EnvDBPath, "/tmp/from-env.db")
+	path, err = ResolvePath("")
+	require.NoError(t, err)
+	assert.Equal(t, "/tmp/from-env.db", path)
+
+	path, err = ResolvePath("/tmp/from-flag.db")
+	require.NoError(t, err)
+	assert.Equal(t, "/tmp/from-flag.db", path)
+}
+
+func TestDefaultPath_IgnoresRelativeXDG(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_DATA_HOME", "relative/dir")
+
+	path, err := DefaultPath()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(home, ".local", "share", "minimal-money", "budget.db"), path)
+}
+
+func TestMigrateLegacyDB(t *testing.T) {
+	dir := t.TempDir()
+	legacy := filepath.Join(dir, "data", "budget.db")
+	target := filepath.Join(dir, "xdg", "minimal-money", "budget.db")
+
+	// Nothing to migrate
+	require.NoError(t, migrateLegacyDB(legacy, target))
+	assert.NoFileExists(t, target)
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(legacy), 0755))
+	require.NoError(t, os.WriteFile(legacy, []byte("portfolio"), 0644))
+
+	require.NoError(t, migrateLegacyDB(legacy, target))
+	assert.NoFileExists(t, legacy)
+	content, err := os.ReadFile(target)
+	require.NoError(t, err)
+	assert.Equal(t, "portfolio", string(content))
+
+	// An existing target is never overwritten
+	require.NoError(t, os.WriteFile(legacy, []byte("stale"), 0644))
+	require.NoError(t, migrateLegacyDB(legacy, target))
+	assert.FileExists(t, legacy)
+	content, err = os.ReadFile(target)
+	require.NoError(t, err)
+	assert.Equal(t, "portfolio", string(content))
+}
@@ -0,0 +1,153 @@
+package db
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// The schema as migration 1 created it. These copies of the models are
+// frozen: models change as the application grows, but what a released
+// migration does must not.
+
+type v1Account struct {
+	ID        uint   `gorm:"primaryKey"`
+	Name      string `gorm:"not null"`
+	Type      string `gorm:"not null"`
+	Color     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+func (v1Account) TableName() string { return "accounts" }
+
+type v1Asset struct {
+	ID         uint   `gorm:"primaryKey"`
+	Symbol     string `gorm:"uniqueIndex;not null"`
+	Name       string `gorm:"not null"`
+	Type       string `gorm:"not null"`
+	ProviderID string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	DeletedAt  gorm.DeletedAt `gorm:"index"`
+}
+
+func (v1Asset) TableName() string { return "assets" }
+
+type v1Holding struct {
+	ID            uint            `gorm:"primaryKey"`
+	AccountID     uint            `gorm:"not null"`
+	Account       v1Account       `gorm:"foreignKey:AccountID"`
+	AssetID       uint            `gorm:"not null"`
+	Asset         v1Asset         `gorm:"foreignKey:AssetID"`
+	Amount        decimal.Decimal `gorm:"type:text;not null"`
+	PurchasePrice decimal.Decimal `gorm:"type:text"`
+	PurchaseDate  time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	DeletedAt     gorm.DeletedAt `gorm:"index"`
+}
+
+func (v1Holding) TableName() string { return "holdings" }
+
+type v1Transaction struct {
+	ID          uint            `gorm:"primaryKey"`
+	Type        string          `gorm:"not null;index"`
+	AccountID   uint            `gorm:"not null;index"`
+	Account     v1Account       `gorm:"foreignKey:AccountID"`
+	AssetID     uint            `gorm:"not null;index"`
+	Asset       v1Asset         `gorm:"foreignKey:AssetID"`
+	ToAccountID *uint           `gorm:"index"`
+	ToAccount   *v1Account      `gorm:"foreignKey:ToAccountID"`
+	Quantity    decimal.Decimal `gorm:"type:text;not null"`
+	PriceUSD    decimal.Decimal `gorm:"type:text"`
+	Fee         decimal.Decimal `gorm:"type:text"`
+	Note        string
+	Timestamp   time.Time `gorm:"not null;index"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   gorm.DeletedAt `gorm:"index"`
+}
+
+func (v1Transaction) TableName() string { return "transactions" }
+
+type v1AuditLog struct {
+	ID         uint   `gorm:"primaryKey"`
+	Action     string `gorm:"not null"`
+	EntityType string `gorm:"not null"`
+	EntityID   uint   `gorm:"not null"`
+	OldValue   string `gorm:"type:text"`
+	NewValue   string `gorm:"type:text"`
+	UserNote   string
+	CreatedAt  time.Time `gorm:"not null;index"`
+}
+
+func (v1AuditLog) TableName() string { return "audit_logs" }
+
+type v1PortfolioSnapshot struct {
+	ID            uint            `gorm:"primaryKey"`
+	TotalValueUSD decimal.Decimal `gorm:"type:text;not null"`
+	Currency      string
+	TotalValue    decimal.Decimal        `gorm:"type:text"`
+	Details       map[string]interface{} `gorm:"serializer:json"`
+	Timestamp     time.Time              `gorm:"not null;index"`
+}
+
+func (v1PortfolioSnapshot) TableName() string { return "portfolio_snapshots" }
+
+type v1PriceCache struct {
+	ID        uint            `gorm:"primaryKey"`
+	AssetID   uint            `gorm:"uniqueIndex;not null"`
+	Asset     v1Asset         `gorm:"foreignKey:AssetID"`
+	PriceUSD  decimal.Decimal `gorm:"type:text;not null"`
+	Manual    bool            `gorm:"not null;default:false"`
+	PriceDate time.Time
+	UpdatedAt time.Time `gorm:"not null;index"`
+}
+
+func (v1PriceCache) TableName() string { return "price_caches" }
+
+type v1PriceHistory struct {
+	ID        uint            `gorm:"primaryKey"`
+	AssetID   uint            `gorm:"not null;uniqueIndex:idx_price_history_point,priority:1"`
+	PriceUSD  decimal.Decimal `gorm:"type:text;not null"`
+	Source    string          `gorm:"not null;uniqueIndex:idx_price_history_point,priority:3"`
+	Manual    bool            `gorm:"not null;default:false"`
+	Timestamp time.Time       `gorm:"not null;uniqueIndex:idx_price_history_point,priority:2"`
+}
+
+func (v1PriceHistory) TableName() string { return "price_histories" }
+
+type v1CoinListing struct {
+	ID        uint   `gorm:"primaryKey"`
+	CoinID    string `gorm:"uniqueIndex;not null"`
+	Symbol    string `gorm:"index;not null"`
+	Name      string
+	UpdatedAt time.Time
+}
+
+func (v1CoinListing) TableName() string { return "coin_listings" }
+
+type v1Setting struct {
+	Key       string `gorm:"primaryKey"`
+	Value     string `gorm:"not null"`
+	UpdatedAt time.Time
+}
+
+func (v1Setting) TableName() string { return "settings" }
+
+// v1Tables lists the models of migration 1 in the order they are created
+var v1Tables = []interface{}{
+	&v1Account{},
+	&v1Asset{},
+	&v1Holding{},
+	&v1Transaction{},
+	&v1AuditLog{},
+	&v1PortfolioSnapshot{},
+	&v1PriceCache{},
+	&v1PriceHistory{},
+	&v1CoinListing{},
+	&v1Setting{},
+}
@@ -0,0 +1,70 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"time"
+)
+
+// WriteCSV writes the holdings, accounts, assets and audit log as separate
+// tables, each with its own header and a blank line in between. Holdings
+// come first, so a spreadsheet opens on the table it is usually fed.
+func WriteCSV(w io.Writer, p *Portfolio) error {
+	cw := csv.NewWriter(w)
+	currency := p.Currency
+
+	tables := [][][]string{holdingRecords(p, currency), accountRecords(p, currency), assetRecords(p, currency)}
+	if len(p.AuditLog) > 0 {
+		tables = append(tables, auditRecords(p))
+	}
+
+	for i, records := range tables {
+		if i > 0 {
+			if err := cw.Write(nil); err != nil {
+				return err
+			}
+		}
+		if err := cw.WriteAll(records); err != nil {
+			return err
+		}
+	}
+	return cw.Error()
+}
+
+func holdingRecords(p *Portfolio, currency string) [][]string {
+	records := [][]string{{"holding_id", "account", "asset", "amount", "purchase_price_usd",
+		fmt.Sprintf("price_%s", currency), fmt.Sprintf("value_%s", currency)}}
+	for _, h := range p.Holdings {
+		records = append(records, []string{fmt.Sprint(h.ID), h.Account, h.Asset, h.Amount.String(),
+			h.PurchasePriceUSD.String(), h.Price.String(), h.Value.String()})
+	}
+	return records
+}
+
+func accountRecords(p *Portfolio, currency string) [][]string {
+	records := [][]string{{"account_id", "account", "type", "color", fmt.Sprintf("value_%s", currency)}}
+	for _, a := range p.Accounts {
+		records = append(records, []string{fmt.Sprint(a.ID), a.Name, a.Type, a.Color, a.Value.String()})
+	}
+	return records
+}
+
+func assetRecords(p *Portfolio, currency string) [][]string {
+	records := [][]string{{"asset_id", "symbol", "name", "type", "amount",
+		fmt.Sprintf("price_%s", currency), "manual_price", fmt.Sprintf("value_%s", currency)}}
+	for _, a := range p.Assets {
+		records = append(records, []string{fmt.Sprint(a.ID), a.Symbol, a.Name, a.Type, a.Amount.String(),
+			a.Price.String(), fmt.Sprint(a.ManualPrice), a.Value.String()})
+	}
+	return records
+}
+
+func auditRecords(p *Portfolio) [][]string {
+	records := [][]string{{"audit_id", "time", "action", "entity_type", "entity_id", "old_value", "new_value", "note"}}
+	for _, e := range p.AuditLog {
+		records = append(records, []string{fmt.Sprint(e.ID), e.Time.Format(time.RFC3339), e.Action,
+			e.EntityType, fmt.Sprint(e.EntityID), string(e.OldValue), string(e.NewValue), e.Note})
+	}
+	return records
+}
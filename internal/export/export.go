@@ -0,0 +1,107 @@
+// Package export writes a valued portfolio as CSV, JSON or Markdown.
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Format is an export file format
+type Format string
+
+const (
+	FormatCSV      Format = "csv"
+	FormatJSON     Format = "json"
+	FormatMarkdown Format = "md"
+)
+
+// Formats lists the supported export formats
+var Formats = []Format{FormatCSV, FormatJSON, FormatMarkdown}
+
+// ParseFormat finds a format by name in any case
+func ParseFormat(name string) (Format, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "markdown" {
+		return FormatMarkdown, nil
+	}
+	for _, f := range Formats {
+		if string(f) == name {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported export format %q (csv, json, md)", name)
+}
+
+// Portfolio is everything an export holds. Prices and values are in
+// Currency; purchase prices stay in USD, the currency they were paid in.
+type Portfolio struct {
+	GeneratedAt     time.Time       `json:"generated_at"`
+	Currency        string          `json:"currency"`
+	PricesUpdatedAt *time.Time      `json:"prices_updated_at,omitempty"`
+	Total           decimal.Decimal `json:"total"`
+	Accounts        []Account       `json:"accounts"`
+	Assets          []Asset         `json:"assets"`
+	Holdings        []Holding       `json:"holdings"`
+	AuditLog        []AuditEntry    `json:"audit_log,omitempty"`
+}
+
+type Account struct {
+	ID    uint            `json:"id"`
+	Name  string          `json:"name"`
+	Type  string          `json:"type"`
+	Color string          `json:"color,omitempty"`
+	Value decimal.Decimal `json:"value"`
+}
+
+type Asset struct {
+	ID          uint            `json:"id"`
+	Symbol      string          `json:"symbol"`
+	Name        string          `json:"name"`
+	Type        string          `json:"type"`
+	Amount      decimal.Decimal `json:"amount"`
+	Price       decimal.Decimal `json:"price"`
+	ManualPrice bool            `json:"manual_price,omitempty"`
+	Value       decimal.Decimal `json:"value"`
+}
+
+type Holding struct {
+	ID               uint            `json:"id"`
+	Account          string          `json:"account"`
+	Asset            string          `json:"asset"`
+	Amount           decimal.Decimal `json:"amount"`
+	PurchasePriceUSD decimal.Decimal `json:"purchase_price_usd"`
+	Price            decimal.Decimal `json:"price"`
+	Value            decimal.Decimal `json:"value"`
+}
+
+// AuditEntry is one audit log record; OldValue and NewValue are the JSON
+// documents the audit log stores
+type AuditEntry struct {
+	ID         uint            `json:"id"`
+	Time       time.Time       `json:"time"`
+	Action     string          `json:"action"`
+	EntityType string          `json:"entity_type"`
+	EntityID   uint            `json:"entity_id"`
+	OldValue   json.RawMessage `json:"old_value,omitempty"`
+	NewValue   json.RawMessage `json:"new_value,omitempty"`
+	Note       string          `json:"note,omitempty"`
+}
+
+// Write writes p to w in format
+func Write(w io.Writer, format Format, p *Portfolio) error {
+	switch format {
+	case FormatCSV:
+		return WriteCSV(w, p)
+	case FormatJSON:
+		return WriteJSON(w, p)
+	case FormatMarkdown:
+		return WriteMarkdown(w, p)
+	default:
+		return fmt.Errorf("unsupported export format %q", format)
+	}
+}
@@ -0,0 +1,94 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func samplePortfolio() *Portfolio {
+	d := decimal.RequireFromString
+	return &Portfolio{
+		GeneratedAt: time.Date(2025, 1, 11, 14, 22, 0, 0, time.UTC),
+		Currency:    "USD",
+		Total:       d("30500.00"),
+		Accounts:    []Account{{ID: 1, Name: "Hardware | Cold", Type: "wallet", Value: d("30000")}, {ID: 2, Name: "Bank", Type: "bank", Value: d("500")}},
+		Assets:      []Asset{{ID: 1, Symbol: "BTC", Name: "Bitcoin", Type: "crypto", Amount: d("0.50000001"), Price: d("60000"), Value: d("30000")}},
+		Holdings: []Holding{{ID: 7, Account: "Hardware | Cold", Asset: "BTC", Amount: d("0.50000001"),
+			PurchasePriceUSD: d("40000"), Price: d("60000"), Value: d("30000")}},
+		AuditLog: []AuditEntry{{ID: 3, Time: time.Date(2025, 1, 10, 9, 0, 0, 0, time.UTC), Action: "CREATE",
+			EntityType: "HOLDING", EntityID: 7, NewValue: json.RawMessage(`{"amount":"0.5"}`), Note: "Imported"}},
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for name, want := range map[string]Format{"csv": FormatCSV, "JSON": FormatJSON, "md": FormatMarkdown, "markdown": FormatMarkdown} {
+		got, err := ParseFormat(name)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+	_, err := ParseFormat("xlsx")
+	assert.ErrorContains(t, err, "unsupported export format")
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, FormatCSV, samplePortfolio()))
+
+	tables := strings.Split(strings.TrimSpace(buf.String()), "\n\n")
+	require.Len(t, tables, 4)
+
+	holdings, err := csv.NewReader(strings.NewReader(tables[0])).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"holding_id", "account", "asset", "amount", "purchase_price_usd", "price_USD", "value_USD"}, holdings[0])
+	assert.Equal(t, []string{"7", "Hardware | Cold", "BTC", "0.50000001", "40000", "60000", "30000"}, holdings[1])
+
+	assert.True(t, strings.HasPrefix(tables[1], "account_id,"))
+	assert.True(t, strings.HasPrefix(tables[2], "asset_id,"))
+	assert.Contains(t, tables[3], `"{""amount"":""0.5""}",Imported`)
+
+	// Without audit entries there is no audit table
+	p := samplePortfolio()
+	p.AuditLog = nil
+	buf.Reset()
+	require.NoError(t, WriteCSV(&buf, p))
+	assert.Len(t, strings.Split(strings.TrimSpace(buf.String()), "\n\n"), 3)
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, FormatJSON, samplePortfolio()))
+
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, "USD", decoded["currency"])
+	assert.Equal(t, "30500", decoded["total"])
+
+	holding := decoded["holdings"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "0.50000001", holding["amount"])
+
+	// Audit values are embedded as JSON, not as strings
+	entry := decoded["audit_log"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"amount": "0.5"}, entry["new_value"])
+	assert.NotContains(t, entry, "old_value")
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, FormatMarkdown, samplePortfolio()))
+	out := buf.String()
+
+	assert.Contains(t, out, "# Portfolio")
+	assert.Contains(t, out, "**Total: $30,500.00**")
+	assert.Contains(t, out, "| Hardware \\| Cold | BTC | 0.50000001 | $40,000.00 | $60,000.00 | $30,000.00 |")
+	assert.Contains(t, out, "|---|---|--:|--:|--:|--:|")
+	assert.Contains(t, out, "## Audit Log")
+	assert.Contains(t, out, "| 2025-01-10 09:00 | CREATE | HOLDING #7 | `{\"amount\":\"0.5\"}` | Imported |")
+}
@@ -0,0 +1,14 @@
+package export
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// WriteJSON writes p as one indented JSON document. Amounts, prices and
+// values are strings so they keep every decimal.
+func WriteJSON(w io.Writer, p *Portfolio) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(p)
+}
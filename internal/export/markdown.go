@@ -0,0 +1,90 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/bioharz/budget/internal/currency"
+	"github.com/shopspring/decimal"
+)
+
+const markdownTime = "2006-01-02 15:04"
+
+// WriteMarkdown writes p as a report with one table per section, values
+// formatted in the portfolio's currency
+func WriteMarkdown(w io.Writer, p *Portfolio) error {
+	c, err := currency.Lookup(p.Currency)
+	if err != nil {
+		return err
+	}
+	money := func(d decimal.Decimal) string { return c.Format(d) }
+
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, "# Portfolio\n\n")
+	fmt.Fprintf(b, "Generated %s", p.GeneratedAt.Format(markdownTime))
+	if p.PricesUpdatedAt != nil {
+		fmt.Fprintf(b, ", prices as of %s", p.PricesUpdatedAt.Format(markdownTime))
+	}
+	fmt.Fprintf(b, "\n\n**Total: %s**\n", money(p.Total))
+
+	fmt.Fprintf(b, "\n## Holdings\n\n")
+	writeTable(b, []string{"Account", "Asset", "Amount", "Purchase Price (USD)", "Price", "Value"}, 2)
+	for _, h := range p.Holdings {
+		writeRow(b, h.Account, h.Asset, h.Amount.String(), currency.USD.Format(h.PurchasePriceUSD),
+			money(h.Price), money(h.Value))
+	}
+
+	fmt.Fprintf(b, "\n## Accounts\n\n")
+	writeTable(b, []string{"Account", "Type", "Value"}, 2)
+	for _, a := range p.Accounts {
+		writeRow(b, a.Name, a.Type, money(a.Value))
+	}
+
+	fmt.Fprintf(b, "\n## Assets\n\n")
+	writeTable(b, []string{"Asset", "Name", "Type", "Amount", "Price", "Value"}, 3)
+	for _, a := range p.Assets {
+		price := money(a.Price)
+		if a.ManualPrice {
+			price += " (manual)"
+		}
+		writeRow(b, a.Symbol, a.Name, a.Type, a.Amount.String(), price, money(a.Value))
+	}
+
+	if len(p.AuditLog) > 0 {
+		fmt.Fprintf(b, "\n## Audit Log\n\n")
+		writeTable(b, []string{"Time", "Action", "Entity", "Details", "Note"}, 5)
+		for _, e := range p.AuditLog {
+			details := e.NewValue
+			if len(details) == 0 {
+				details = e.OldValue
+			}
+			writeRow(b, e.Time.Format(markdownTime), e.Action, fmt.Sprintf("%s #%d", e.EntityType, e.EntityID),
+				"`"+string(details)+"`", e.Note)
+		}
+	}
+	return b.Flush()
+}
+
+// writeTable writes a table header; columns from rightFrom on are numbers
+// and aligned right
+func writeTable(w io.Writer, header []string, rightFrom int) {
+	writeRow(w, header...)
+	align := make([]string, len(header))
+	for i := range header {
+		align[i] = "---"
+		if i >= rightFrom {
+			align[i] = "--:"
+		}
+	}
+	fmt.Fprintf(w, "|%s|\n", strings.Join(align, "|"))
+}
+
+func writeRow(w io.Writer, cells ...string) {
+	escaped := make([]string, len(cells))
+	for i, cell := range cells {
+		escaped[i] = strings.ReplaceAll(cell, "|", `\|`)
+	}
+	fmt.Fprintf(w, "| %s |\n", strings.Join(escaped, " | "))
+}
@@ -0,0 +1,117 @@
+package importer
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/bioharz/budget/internal/models"
+)
+
+// binanceTradeOperations are the statement operations that make up trades;
+// all of them booked at the same time belong to one trade
+var binanceTradeOperations = []string{
+	"buy", "sell", "fee", "transaction buy", "transaction spend", "transaction fee",
+	"transaction sold", "transaction revenue", "transaction related",
+	"binance convert", "small assets exchange bnb", "large otc trading",
+}
+
+// binanceIncome marks operations that pay out rewards
+var binanceIncome = []string{"interest", "reward", "distribution", "airdrop", "cashback", "rebate", "dividend"}
+
+// binanceInternal marks operations that move funds between Binance wallets
+var binanceInternal = []string{"subscription", "redemption", "transfer between", "savings purchase", "main and funding"}
+
+// binanceGroup is the statement entries of one trade
+type binanceGroup struct {
+	line int
+	date time.Time
+	legs []leg
+	fees []leg
+}
+
+// parseBinance reads a Binance transaction history statement. Balances of
+// all Binance wallets are booked to one account, so moves between them are
+// skipped.
+func parseBinance(r io.Reader, account string) ([]Row, error) {
+	t, err := readTable(r, "UTC_Time", "Operation", "Coin", "Change")
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []Row
+	var order []string
+	groups := make(map[string]*binanceGroup)
+
+	for i, record := range t.records {
+		line := t.lines[i]
+		timestamp := t.get(record, "UTC_Time")
+		date, err := parseUTC(timestamp, "2006-01-02 15:04:05")
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		change, err := parseAmount(t.get(record, "Change"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		coin := strings.ToUpper(t.get(record, "Coin"))
+		operation := t.get(record, "Operation")
+		lower := strings.ToLower(operation)
+
+		var row Row
+		switch {
+		case contains(binanceTradeOperations, lower):
+			key := timestamp + "\x00" + t.get(record, "Account")
+			group, ok := groups[key]
+			if !ok {
+				group = &binanceGroup{line: line, date: date}
+				groups[key] = group
+				order = append(order, key)
+			}
+			if strings.HasSuffix(lower, "fee") {
+				group.fees = append(group.fees, leg{asset: coin, amount: change})
+			} else {
+				group.legs = append(group.legs, leg{asset: coin, amount: change})
+			}
+			continue
+		case lower == "deposit":
+			row = movementRow(account, date, models.TransactionDeposit, coin, change, "Binance deposit")
+		case lower == "withdraw" || lower == "withdrawal":
+			row = movementRow(account, date, models.TransactionWithdrawal, coin, change, "Binance withdrawal")
+		case containsAny(lower, binanceInternal):
+			continue
+		case containsAny(lower, binanceIncome):
+			if !change.IsPositive() {
+				continue
+			}
+			row = movementRow(account, date, models.TransactionIncome, coin, change, "Binance "+operation)
+		default:
+			return nil, fmt.Errorf("line %d: unsupported operation %q", line, operation)
+		}
+
+		if row.Amount.IsPositive() {
+			row.Line = line
+			rows = append(rows, row)
+		}
+	}
+
+	for _, key := range order {
+		group := groups[key]
+		note := "Binance trade " + group.date.Format("2006-01-02 15:04:05")
+		for _, row := range swapRows(account, group.date, group.legs, group.fees, note) {
+			row.Line = group.line
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+func containsAny(s string, parts []string) bool {
+	for _, part := range parts {
+		if strings.Contains(s, part) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBinance(t *testing.T) {
+	rows := parseFixture(t, ExchangeBinance, "binance.csv")
+
+	// Fills booked at the same time form one trade; stablecoin quotes count
+	// as dollars and moves into Earn are skipped
+	assert.Equal(t, []string{
+		"deposit USDT 1000",
+		"deposit BNB 0.5",
+		"buy BTC 0.01 @62000",
+		"withdrawal USDT 620",
+		"fee BNB 0.0012",
+		"income USDT 0.05",
+		"sell BTC 0.004 @68000 fee 0.272",
+		"deposit USDT 271.728",
+		"withdrawal BTC 0.005",
+	}, summarize(rows))
+
+	assert.Equal(t, "Binance", rows[0].Account)
+	assert.Equal(t, 2, rows[2].Line)
+}
+
+func TestParseBinance_UnsupportedOperation(t *testing.T) {
+	input := "User_ID,UTC_Time,Account,Operation,Coin,Change,Remark\n" +
+		"1,2024-01-01 00:00:00,Spot,Futures Fee,USDT,-1,\n"
+	_, err := ParseExchange(ExchangeBinance, strings.NewReader(input), "")
+	assert.ErrorContains(t, err, `line 2: unsupported operation "Futures Fee"`)
+}
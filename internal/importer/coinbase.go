@@ -0,0 +1,133 @@
+package importer
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bioharz/budget/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// coinbaseConvert reads the note Coinbase writes for conversions, e.g.
+// "Converted 0.5 ETH to 0.0251 BTC"
+var coinbaseConvert = regexp.MustCompile(`(?i)converted\s+([\d.,]+)\s+(\S+)\s+to\s+([\d.,]+)\s+(\S+)`)
+
+// parseCoinbase reads a Coinbase transaction history export. Both the
+// current layout, which starts with a preamble, and the older one with
+// "Spot Price" columns are accepted. Prices only carry over when the
+// account's price currency is USD; trades priced in another currency are
+// marked unpriced. Fiat wallet movements are skipped.
+func parseCoinbase(r io.Reader, account string) ([]Row, error) {
+	t, err := readTable(r, "Timestamp", "Transaction Type", "Asset", "Quantity Transacted")
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []Row
+	for i, record := range t.records {
+		parsed, err := coinbaseRows(t, record, account)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", t.lines[i], err)
+		}
+		for _, row := range parsed {
+			row.Line = t.lines[i]
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+func coinbaseRows(t *table, record []string, account string) ([]Row, error) {
+	date, err := parseUTC(t.get(record, "Timestamp"), time.RFC3339, "2006-01-02 15:04:05")
+	if err != nil {
+		return nil, err
+	}
+	kind := t.get(record, "Transaction Type")
+	asset := strings.ToUpper(t.get(record, "Asset"))
+	currency := strings.ToUpper(t.get(record, "Price Currency", "Spot Price Currency"))
+
+	if asset == "" {
+		return nil, fmt.Errorf("missing asset")
+	}
+	if asset == currency {
+		return nil, nil
+	}
+
+	quantity, err := parseAmount(t.get(record, "Quantity Transacted"))
+	if err != nil {
+		return nil, err
+	}
+	quantity = quantity.Abs()
+	if quantity.IsZero() {
+		return nil, nil
+	}
+
+	var price, subtotal, fee decimal.Decimal
+	if currency == "USD" {
+		if price, err = parseAmount(t.get(record, "Price at Transaction", "Spot Price at Transaction")); err != nil {
+			return nil, err
+		}
+		if subtotal, err = parseAmount(t.get(record, "Subtotal")); err != nil {
+			return nil, err
+		}
+		if fee, err = parseAmount(t.get(record, "Fees and/or Spread", "Fees")); err != nil {
+			return nil, err
+		}
+		price, subtotal, fee = price.Abs(), subtotal.Abs(), fee.Abs()
+	}
+
+	note := t.get(record, "Notes")
+	if note == "" {
+		note = "Coinbase " + kind
+	}
+	row := Row{Account: account, Asset: asset, Amount: quantity, Date: date, Note: note}
+
+	unpriced := currency != "" && currency != "USD"
+	switch lower := strings.ToLower(kind); {
+	case lower == "buy" || lower == "advanced trade buy":
+		row.Type, row.Price, row.Fee, row.Unpriced = models.TransactionBuy, price, fee, unpriced
+	case lower == "sell" || lower == "advanced trade sell":
+		row.Type, row.Price, row.Fee, row.Unpriced = models.TransactionSell, price, fee, unpriced
+	case lower == "receive" || lower == "deposit":
+		row.Type = models.TransactionDeposit
+	case lower == "send" || lower == "withdrawal":
+		row.Type = models.TransactionWithdrawal
+	case strings.Contains(lower, "income") || strings.Contains(lower, "reward"):
+		row.Type, row.Price, row.Unpriced = models.TransactionIncome, price, unpriced
+	case lower == "convert":
+		row.Unpriced = unpriced
+		return coinbaseConversion(row, subtotal, fee)
+	default:
+		return nil, fmt.Errorf("unsupported transaction type %q", kind)
+	}
+	return []Row{row}, nil
+}
+
+// coinbaseConversion splits a conversion into a sale of the source asset and
+// a purchase of the target, both valued at the conversion's USD subtotal
+func coinbaseConversion(row Row, subtotal, fee decimal.Decimal) ([]Row, error) {
+	match := coinbaseConvert.FindStringSubmatch(row.Note)
+	if match == nil {
+		return nil, fmt.Errorf("cannot read conversion from note %q", row.Note)
+	}
+	received, err := parseAmount(match[3])
+	if err != nil {
+		return nil, err
+	}
+	if !received.IsPositive() {
+		return nil, fmt.Errorf("conversion received nothing")
+	}
+
+	sell := row
+	sell.Type, sell.Fee = models.TransactionSell, fee
+	buy := row
+	buy.Type, buy.Asset, buy.Amount = models.TransactionBuy, strings.ToUpper(match[4]), received
+	if subtotal.IsPositive() {
+		sell.Price = subtotal.Div(row.Amount)
+		buy.Price = subtotal.Div(received)
+	}
+	return []Row{sell, buy}, nil
+}
@@ -0,0 +1,47 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCoinbase(t *testing.T) {
+	rows := parseFixture(t, ExchangeCoinbase, "coinbase.csv")
+
+	assert.Equal(t, []string{
+		"buy BTC 0.02 @44000 fee 13.2",
+		"deposit ETH 1.5",
+		"income ETH 0.003 @2300",
+		"sell ETH 0.5 @2500 fee 18.75",
+		"buy BTC 0.0267 @46816.4794007490636704",
+		"sell BTC 0.01 @62000 fee 9.3",
+		"withdrawal ETH 0.2",
+	}, summarize(rows))
+
+	assert.Equal(t, "Coinbase", rows[0].Account)
+	assert.Equal(t, 7, rows[0].Line)
+	assert.Equal(t, time.Date(2024, 1, 5, 10, 0, 0, 0, time.UTC), rows[0].Date)
+	assert.Equal(t, "Bought 0.02 BTC for $893.20 USD", rows[0].Note)
+	assert.Equal(t, "Coinbase Staking Income", rows[2].Note)
+}
+
+func TestParseCoinbase_LegacyLayout(t *testing.T) {
+	input := "Timestamp,Transaction Type,Asset,Quantity Transacted,Spot Price Currency,Spot Price at Transaction,Subtotal,Total (inclusive of fees),Fees,Notes\n" +
+		"2021-04-01T12:00:00Z,Buy,ETH,2,USD,1900.00,3800.00,3857.00,57.00,\n" +
+		"2021-04-02T12:00:00Z,Buy,BTC,0.1,EUR,50000.00,5000.00,5075.00,75.00,\n"
+
+	rows, err := ParseExchange(ExchangeCoinbase, strings.NewReader(input), "Savings")
+	require.NoError(t, err)
+
+	// Prices in other currencies are not carried over, the row is unpriced
+	assert.Equal(t, []string{"buy ETH 2 @1900 fee 57", "buy BTC 0.1 unpriced"}, summarize(rows))
+	assert.Equal(t, "Savings", rows[0].Account)
+
+	input = "Timestamp,Transaction Type,Asset,Quantity Transacted\n2021-04-01T12:00:00Z,Stake,ETH,2\n"
+	_, err = ParseExchange(ExchangeCoinbase, strings.NewReader(input), "")
+	assert.ErrorContains(t, err, `line 2: unsupported transaction type "Stake"`)
+}
@@ -0,0 +1,282 @@
+// Package importer reads holdings and transactions from CSV files into rows
+// the import service can plan and apply
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bioharz/budget/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// Field names a mapping spec assigns columns to
+const (
+	FieldAccount = "account"
+	FieldAsset   = "asset"
+	FieldAmount  = "amount"
+	FieldPrice   = "price"
+	FieldDate    = "date"
+	FieldType    = "type"
+	FieldFee     = "fee"
+	FieldNote    = "note"
+)
+
+var fields = []string{FieldAccount, FieldAsset, FieldAmount, FieldPrice, FieldDate, FieldType, FieldFee, FieldNote}
+
+var requiredFields = []string{FieldAccount, FieldAsset, FieldAmount}
+
+// dateLayouts are tried in order when reading the date column
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// Row is one record of an import. Without a type it states a holding's
+// balance, with a type it is a ledger transaction.
+type Row struct {
+	Line    int // Line in the source file, for error messages
+	Account string
+	Asset   string
+	Amount  decimal.Decimal
+	Price   decimal.Decimal // USD per unit, zero if unknown
+	Fee     decimal.Decimal
+	Date    time.Time // Zero if the file has no date
+	Type    models.TransactionType
+	Note    string
+
+	// Unpriced marks a trade against a fiat currency other than USD, whose
+	// price is missing rather than zero
+	Unpriced bool
+}
+
+// IsTransaction reports whether the row is a ledger entry rather than a
+// holding balance
+func (r Row) IsTransaction() bool {
+	return r.Type != ""
+}
+
+// Mapping assigns CSV header names to fields. Fields left out of a spec use
+// a column named like the field, if the file has one.
+type Mapping struct {
+	columns  map[string]string
+	explicit map[string]bool
+}
+
+// DefaultMapping expects columns named account, asset, amount and so on
+func DefaultMapping() Mapping {
+	m := Mapping{columns: make(map[string]string), explicit: make(map[string]bool)}
+	for _, field := range fields {
+		m.columns[field] = field
+	}
+	return m
+}
+
+// ParseMapping reads a spec like "account=Wallet,asset=Coin,amount=Qty"
+func ParseMapping(spec string) (Mapping, error) {
+	m := DefaultMapping()
+	if strings.TrimSpace(spec) == "" {
+		return m, nil
+	}
+	for _, pair := range strings.Split(spec, ",") {
+		field, column, ok := strings.Cut(pair, "=")
+		field = strings.ToLower(strings.TrimSpace(field))
+		column = strings.TrimSpace(column)
+		if !ok || column == "" {
+			return Mapping{}, fmt.Errorf("expected FIELD=COLUMN, got %q", pair)
+		}
+		if _, known := m.columns[field]; !known {
+			return Mapping{}, fmt.Errorf("unknown field %q (use %s)", field, strings.Join(fields, ", "))
+		}
+		m.columns[field] = column
+		m.explicit[field] = true
+	}
+	return m, nil
+}
+
+// ParseCSV reads rows from a CSV file with a header line
+func ParseCSV(r io.Reader, mapping Mapping) ([]Row, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("file is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := mapping.resolve(header)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []Row
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		if blank(record) {
+			continue
+		}
+
+		row, err := parseRecord(record, index)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		row.Line = line
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// resolve finds the column index of every mapped field in header
+func (m Mapping) resolve(header []string) (map[string]int, error) {
+	positions := columnIndex(header)
+
+	index := make(map[string]int)
+	var missing []string
+	for field, column := range m.columns {
+		i, ok := positions[strings.ToLower(column)]
+		if ok {
+			index[field] = i
+			continue
+		}
+		if m.explicit[field] || contains(requiredFields, field) {
+			missing = append(missing, fmt.Sprintf("%s (%s)", column, field))
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("missing columns: %s", strings.Join(missing, ", "))
+	}
+	return index, nil
+}
+
+func parseRecord(record []string, index map[string]int) (Row, error) {
+	value := func(field string) string {
+		i, ok := index[field]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	row := Row{
+		Account: value(FieldAccount),
+		Asset:   strings.ToUpper(value(FieldAsset)),
+		Note:    value(FieldNote),
+	}
+	if row.Account == "" || row.Asset == "" {
+		return Row{}, fmt.Errorf("account and asset are required")
+	}
+
+	var err error
+	if row.Amount, err = parseNumber(value(FieldAmount)); err != nil {
+		return Row{}, fmt.Errorf("invalid amount: %w", err)
+	}
+	if !row.Amount.IsPositive() {
+		return Row{}, fmt.Errorf("amount must be positive")
+	}
+	if row.Price, err = parseNumber(value(FieldPrice)); err != nil {
+		return Row{}, fmt.Errorf("invalid price: %w", err)
+	}
+	if row.Fee, err = parseNumber(value(FieldFee)); err != nil {
+		return Row{}, fmt.Errorf("invalid fee: %w", err)
+	}
+	if row.Price.IsNegative() || row.Fee.IsNegative() {
+		return Row{}, fmt.Errorf("price and fee cannot be negative")
+	}
+	if row.Date, err = parseDate(value(FieldDate)); err != nil {
+		return Row{}, err
+	}
+
+	if txType := strings.ToLower(value(FieldType)); txType != "" {
+		row.Type = models.TransactionType(txType)
+		if !validType(row.Type) {
+			return Row{}, fmt.Errorf("unknown transaction type %q", txType)
+		}
+		if row.Type == models.TransactionTransfer {
+			return Row{}, fmt.Errorf("transfers cannot be imported, record them with 'budget transactions add'")
+		}
+	}
+	return row, nil
+}
+
+// parseNumber reads an optional number; an empty cell is zero
+func parseNumber(s string) (decimal.Decimal, error) {
+	s = strings.TrimPrefix(s, "$")
+	if s == "" {
+		return decimal.Zero, nil
+	}
+	d, err := decimal.NewFromString(s)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("%q is not a number", s)
+	}
+	return d, nil
+}
+
+// parseDate reads an optional date; dates without a zone are local
+func parseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", s)
+}
+
+func validType(txType models.TransactionType) bool {
+	for _, t := range models.TransactionTypes {
+		if txType == t {
+			return true
+		}
+	}
+	return false
+}
+
+// columnIndex maps lower-cased column names to their position
+func columnIndex(header []string) map[string]int {
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		// Spreadsheets like to start files with a byte order mark
+		name = strings.TrimPrefix(strings.TrimSpace(name), "\ufeff")
+		columns[strings.ToLower(name)] = i
+	}
+	return columns
+}
+
+func blank(record []string) bool {
+	for _, v := range record {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bioharz/budget/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCSV_DefaultColumns(t *testing.T) {
+	input := "Account,Asset,Amount,Price,Date\n" +
+		"Ledger,btc,0.00000001,40000,2024-03-01\n" +
+		"\n" +
+		"Bank, EUR ,1000,,\n"
+
+	rows, err := ParseCSV(strings.NewReader(input), DefaultMapping())
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+
+	assert.Equal(t, 2, rows[0].Line)
+	assert.Equal(t, "Ledger", rows[0].Account)
+	assert.Equal(t, "BTC", rows[0].Asset)
+	assert.Equal(t, "0.00000001", rows[0].Amount.String())
+	assert.Equal(t, "40000", rows[0].Price.String())
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local), rows[0].Date)
+	assert.False(t, rows[0].IsTransaction())
+
+	// Blank lines are skipped, empty optional cells are zero
+	assert.Equal(t, 4, rows[1].Line)
+	assert.Equal(t, "EUR", rows[1].Asset)
+	assert.True(t, rows[1].Price.IsZero())
+	assert.True(t, rows[1].Date.IsZero())
+}
+
+func TestParseCSV_Mapping(t *testing.T) {
+	mapping, err := ParseMapping("account=Wallet, asset=Coin, amount=Qty, type=Side")
+	require.NoError(t, err)
+
+	input := "Wallet;Coin;Qty;Side\n"
+	_, err = ParseCSV(strings.NewReader(input), mapping)
+	assert.ErrorContains(t, err, "missing columns")
+
+	input = "Wallet,Coin,Qty,Side,Fee\nKraken,ETH,2,BUY,1.5\n"
+	rows, err := ParseCSV(strings.NewReader(input), mapping)
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, models.TransactionBuy, rows[0].Type)
+	assert.Equal(t, "1.5", rows[0].Fee.String())
+	assert.True(t, rows[0].IsTransaction())
+
+	_, err = ParseMapping("wallet=Account")
+	assert.ErrorContains(t, err, "unknown field")
+	_, err = ParseMapping("account")
+	assert.Error(t, err)
+}
+
+func TestParseCSV_InvalidRows(t *testing.T) {
+	tests := map[string]string{
+		"invalid amount":     "Ledger,BTC,lots",
+		"must be positive":   "Ledger,BTC,0",
+		"are required":       ",BTC,1",
+		"invalid date":       "Ledger,BTC,1,,01/03/2024",
+		"unknown":            "Ledger,BTC,1,,,swap",
+		"cannot be imported": "Ledger,BTC,1,,,transfer",
+		"cannot be negative": "Ledger,BTC,1,-5",
+	}
+
+	for message, line := range tests {
+		t.Run(message, func(t *testing.T) {
+			input := "account,asset,amount,price,date,type\n" + line + "\n"
+			_, err := ParseCSV(strings.NewReader(input), DefaultMapping())
+			assert.ErrorContains(t, err, "line 2")
+			assert.ErrorContains(t, err, message)
+		})
+	}
+
+	_, err := ParseCSV(strings.NewReader(""), DefaultMapping())
+	assert.Error(t, err)
+}
@@ -0,0 +1,268 @@
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bioharz/budget/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// Exchange names an exchange whose transaction export can be imported
+type Exchange string
+
+const (
+	ExchangeCoinbase Exchange = "coinbase"
+	ExchangeKraken   Exchange = "kraken"
+	ExchangeBinance  Exchange = "binance"
+)
+
+// Exchanges lists the supported export formats
+var Exchanges = []Exchange{ExchangeCoinbase, ExchangeKraken, ExchangeBinance}
+
+// usdQuotes are valued at one dollar when they pay for a trade, which is
+// how most exchanges quote their markets
+var usdQuotes = []string{"USD", "USDT", "USDC", "BUSD", "FDUSD"}
+
+// fiatQuotes are the other currencies exchanges quote markets in. Trades
+// against them have a price, but not one in USD.
+var fiatQuotes = []string{"EUR", "GBP", "CHF", "CAD", "AUD", "JPY", "TRY", "BRL"}
+
+// ParseExchange reads an exchange export into transaction rows, oldest
+// first. Rows are booked to account, or to an account named after the
+// exchange if it is empty.
+func ParseExchange(exchange Exchange, r io.Reader, account string) ([]Row, error) {
+	if account == "" {
+		account = exchange.DisplayName()
+	}
+
+	var rows []Row
+	var err error
+	switch exchange {
+	case ExchangeCoinbase:
+		rows, err = parseCoinbase(r, account)
+	case ExchangeKraken:
+		rows, err = parseKraken(r, account)
+	case ExchangeBinance:
+		rows, err = parseBinance(r, account)
+	default:
+		return nil, fmt.Errorf("unknown exchange %q", exchange)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The ledger needs deposits before the withdrawals they fund
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].Date.Before(rows[j].Date)
+	})
+	return rows, nil
+}
+
+// DisplayName is the exchange's name as written by the exchange
+func (e Exchange) DisplayName() string {
+	if e == "" {
+		return ""
+	}
+	return strings.ToUpper(string(e[:1])) + string(e[1:])
+}
+
+// table is a CSV export addressed by column name
+type table struct {
+	columns map[string]int
+	records [][]string
+	lines   []int
+}
+
+// readTable reads an export whose header holds all required columns.
+// Exports may start with a preamble; everything before the header is skipped.
+func readTable(r io.Reader, required ...string) (*table, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+
+	t := &table{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+
+		if t.columns == nil {
+			if columns := columnIndex(record); hasColumns(columns, required) {
+				t.columns = columns
+			}
+			continue
+		}
+		if blank(record) {
+			continue
+		}
+		t.records = append(t.records, record)
+		t.lines = append(t.lines, line)
+	}
+
+	if t.columns == nil {
+		return nil, fmt.Errorf("not a recognized export, expected columns %s", strings.Join(required, ", "))
+	}
+	return t, nil
+}
+
+func hasColumns(columns map[string]int, required []string) bool {
+	for _, name := range required {
+		if _, ok := columns[strings.ToLower(name)]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// get returns the first of the named columns the export has
+func (t *table) get(record []string, names ...string) string {
+	for _, name := range names {
+		if i, ok := t.columns[strings.ToLower(name)]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+	}
+	return ""
+}
+
+// parseAmount reads an exchange number, which may carry a currency sign and
+// thousands separators
+func parseAmount(s string) (decimal.Decimal, error) {
+	s = strings.NewReplacer("$", "", ",", "", " ", "").Replace(s)
+	return parseNumber(s)
+}
+
+// parseUTC reads an exchange timestamp; exchanges write them in UTC
+func parseUTC(s string, layouts ...string) (time.Time, error) {
+	s = strings.TrimSuffix(strings.TrimSpace(s), " UTC")
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, s, time.UTC); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", s)
+}
+
+func isUSDQuote(symbol string) bool {
+	return contains(usdQuotes, symbol)
+}
+
+func isFiatQuote(symbol string) bool {
+	return contains(fiatQuotes, symbol)
+}
+
+// leg is an asset balance change; positive amounts are received
+type leg struct {
+	asset  string
+	amount decimal.Decimal
+}
+
+// movementRow books a balance change that is not a trade
+func movementRow(account string, date time.Time, txType models.TransactionType, asset string, quantity decimal.Decimal, note string) Row {
+	return Row{Account: account, Asset: asset, Amount: quantity.Abs(), Date: date, Type: txType, Note: note}
+}
+
+// swapRows turns the legs of one trade into rows. Against a USD quote the
+// traded asset is bought or sold at the implied USD price, with the quote
+// paid out or received alongside. Trades without a USD side have no price,
+// so both sides move as a withdrawal and a deposit; against another fiat
+// currency they are marked unpriced, since the asset had a cost that is not
+// recorded. Fees are paid in any asset; those not in the USD quote become
+// fee rows.
+func swapRows(account string, date time.Time, legs, fees []leg, note string) []Row {
+	net := netLegs(legs)
+	feeByAsset := make(map[string]decimal.Decimal)
+	for _, fee := range netLegs(fees) {
+		feeByAsset[fee.asset] = fee.amount.Abs()
+	}
+
+	var rows []Row
+	var in, out []leg
+	for _, l := range net {
+		if l.amount.IsPositive() {
+			in = append(in, l)
+		} else {
+			out = append(out, l)
+		}
+	}
+
+	switch {
+	case len(in) == 1 && len(out) == 1 && isUSDQuote(out[0].asset) && !isUSDQuote(in[0].asset):
+		bought, paid := in[0], out[0].amount.Abs()
+		fee := feeByAsset[out[0].asset]
+		delete(feeByAsset, out[0].asset)
+		rows = append(rows,
+			Row{Account: account, Asset: bought.asset, Amount: bought.amount, Price: paid.Div(bought.amount),
+				Fee: fee, Date: date, Type: models.TransactionBuy, Note: note},
+			movementRow(account, date, models.TransactionWithdrawal, out[0].asset, paid.Add(fee), "Paid for "+bought.asset))
+
+	case len(in) == 1 && len(out) == 1 && isUSDQuote(in[0].asset) && !isUSDQuote(out[0].asset):
+		sold, received := out[0], in[0].amount
+		fee := feeByAsset[in[0].asset]
+		delete(feeByAsset, in[0].asset)
+		rows = append(rows,
+			Row{Account: account, Asset: sold.asset, Amount: sold.amount.Abs(), Price: received.Div(sold.amount.Abs()),
+				Fee: fee, Date: date, Type: models.TransactionSell, Note: note})
+		if net := received.Sub(fee); net.IsPositive() {
+			rows = append(rows, movementRow(account, date, models.TransactionDeposit, in[0].asset, net, "Proceeds of "+sold.asset))
+		}
+
+	default:
+		for _, l := range out {
+			rows = append(rows, movementRow(account, date, models.TransactionWithdrawal, l.asset, l.amount, note))
+		}
+		for _, l := range in {
+			rows = append(rows, movementRow(account, date, models.TransactionDeposit, l.asset, l.amount, note))
+		}
+		for _, l := range net {
+			if !isFiatQuote(l.asset) {
+				continue
+			}
+			for i := range rows {
+				rows[i].Unpriced = true
+			}
+			break
+		}
+	}
+
+	for _, fee := range netLegs(fees) {
+		amount, ok := feeByAsset[fee.asset]
+		if !ok || !amount.IsPositive() {
+			continue
+		}
+		rows = append(rows, movementRow(account, date, models.TransactionFee, fee.asset, amount, "Fee: "+note))
+	}
+	return rows
+}
+
+// netLegs sums legs per asset in order of first appearance and drops those
+// that cancel out
+func netLegs(legs []leg) []leg {
+	var order []string
+	sums := make(map[string]decimal.Decimal)
+	for _, l := range legs {
+		if _, ok := sums[l.asset]; !ok {
+			order = append(order, l.asset)
+		}
+		sums[l.asset] = sums[l.asset].Add(l.amount)
+	}
+
+	var net []leg
+	for _, asset := range order {
+		if !sums[asset].IsZero() {
+			net = append(net, leg{asset: asset, amount: sums[asset]})
+		}
+	}
+	return net
+}
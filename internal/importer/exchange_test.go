@@ -0,0 +1,49 @@
+package importer
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// parseFixture parses an export from testdata
+func parseFixture(t *testing.T, exchange Exchange, name string) []Row {
+	t.Helper()
+	file, err := os.Open("testdata/" + name)
+	require.NoError(t, err)
+	defer file.Close()
+
+	rows, err := ParseExchange(exchange, file, "")
+	require.NoError(t, err)
+	return rows
+}
+
+// summarize renders rows compactly, e.g. "buy BTC 0.5 @40000 fee 1"
+func summarize(rows []Row) []string {
+	lines := make([]string, len(rows))
+	for i, row := range rows {
+		line := fmt.Sprintf("%s %s %s", row.Type, row.Asset, row.Amount)
+		if !row.Price.IsZero() {
+			line += " @" + row.Price.String()
+		}
+		if !row.Fee.IsZero() {
+			line += " fee " + row.Fee.String()
+		}
+		if row.Unpriced {
+			line += " unpriced"
+		}
+		lines[i] = line
+	}
+	return lines
+}
+
+func TestParseExchange_Unrecognized(t *testing.T) {
+	_, err := ParseExchange(ExchangeKraken, strings.NewReader("Account,Asset,Amount\nLedger,BTC,1\n"), "")
+	require.ErrorContains(t, err, "not a recognized export")
+
+	_, err = ParseExchange(Exchange("gemini"), strings.NewReader(""), "")
+	require.ErrorContains(t, err, "unknown exchange")
+}
@@ -0,0 +1,129 @@
+package importer
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/bioharz/budget/internal/models"
+)
+
+// krakenAssets maps Kraken's legacy asset codes to common symbols
+var krakenAssets = map[string]string{
+	"XXBT": "BTC", "XBT": "BTC", "XETH": "ETH", "XLTC": "LTC", "XXRP": "XRP",
+	"XXLM": "XLM", "XXMR": "XMR", "XZEC": "ZEC", "XETC": "ETC", "XXDG": "DOGE",
+	"XDG": "DOGE", "XREP": "REP", "XMLN": "MLN", "ZUSD": "USD", "ZEUR": "EUR",
+	"ZGBP": "GBP", "ZCAD": "CAD", "ZJPY": "JPY", "ZCHF": "CHF", "ZAUD": "AUD",
+	"ETH2": "ETH",
+}
+
+// krakenGroup is the ledger entries of one trade, which share a reference
+type krakenGroup struct {
+	line int
+	date time.Time
+	legs []leg
+	fees []leg
+}
+
+// parseKraken reads a Kraken ledgers export. Trade entries are paired by
+// their reference id; staked balances count as the underlying asset, so
+// moves between spot and staking are skipped.
+func parseKraken(r io.Reader, account string) ([]Row, error) {
+	t, err := readTable(r, "refid", "time", "type", "asset", "amount", "fee")
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []Row
+	var order []string
+	groups := make(map[string]*krakenGroup)
+
+	for i, record := range t.records {
+		line := t.lines[i]
+		date, err := parseUTC(t.get(record, "time"), "2006-01-02 15:04:05")
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		amount, err := parseAmount(t.get(record, "amount"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		fee, err := parseAmount(t.get(record, "fee"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		asset := krakenAsset(t.get(record, "asset"))
+		kind := strings.ToLower(t.get(record, "type"))
+		subtype := strings.ToLower(t.get(record, "subtype"))
+		refid := t.get(record, "refid")
+
+		var parsed []Row
+		switch kind {
+		case "trade", "spend", "receive":
+			group, ok := groups[refid]
+			if !ok {
+				group = &krakenGroup{line: line, date: date}
+				groups[refid] = group
+				order = append(order, refid)
+			}
+			group.legs = append(group.legs, leg{asset: asset, amount: amount})
+			group.fees = append(group.fees, leg{asset: asset, amount: fee})
+			continue
+		case "deposit":
+			parsed = append(parsed, movementRow(account, date, models.TransactionDeposit, asset, amount, "Kraken deposit "+refid))
+		case "withdrawal":
+			parsed = append(parsed, movementRow(account, date, models.TransactionWithdrawal, asset, amount, "Kraken withdrawal "+refid))
+		case "staking", "reward", "dividend", "earn":
+			if kind == "earn" && subtype != "reward" {
+				continue
+			}
+			if amount.IsPositive() {
+				parsed = append(parsed, movementRow(account, date, models.TransactionIncome, asset, amount, "Kraken "+kind+" "+refid))
+			}
+		case "transfer":
+			// Subtyped transfers move funds between spot and staking
+			if subtype != "" {
+				continue
+			}
+			txType := models.TransactionDeposit
+			if amount.IsNegative() {
+				txType = models.TransactionWithdrawal
+			}
+			parsed = append(parsed, movementRow(account, date, txType, asset, amount, "Kraken transfer "+refid))
+		default:
+			return nil, fmt.Errorf("line %d: unsupported ledger type %q", line, kind)
+		}
+
+		if fee.IsPositive() {
+			parsed = append(parsed, movementRow(account, date, models.TransactionFee, asset, fee, "Fee: Kraken "+kind+" "+refid))
+		}
+		for _, row := range parsed {
+			if row.Amount.IsPositive() {
+				row.Line = line
+				rows = append(rows, row)
+			}
+		}
+	}
+
+	for _, refid := range order {
+		group := groups[refid]
+		for _, row := range swapRows(account, group.date, group.legs, group.fees, "Kraken trade "+refid) {
+			row.Line = group.line
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+// krakenAsset normalizes a Kraken asset code, e.g. XXBT to BTC and DOT.S to DOT
+func krakenAsset(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if i := strings.IndexByte(code, '.'); i > 0 {
+		code = code[:i]
+	}
+	if symbol, ok := krakenAssets[code]; ok {
+		return symbol
+	}
+	return code
+}
@@ -0,0 +1,68 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseKraken(t *testing.T) {
+	rows := parseFixture(t, ExchangeKraken, "kraken.csv")
+
+	// Trades against USD are priced, crypto pairs only move balances, and
+	// moves between spot and staking are skipped
+	assert.Equal(t, []string{
+		"deposit USD 1500",
+		"buy BTC 0.01 @44000 fee 0.704",
+		"withdrawal USD 440.704",
+		"withdrawal BTC 0.004",
+		"deposit ETH 0.08",
+		"fee ETH 0.0001",
+		"income ETH 0.0001",
+		"sell BTC 0.003 @62000 fee 0.2976",
+		"deposit USD 185.7024",
+		"withdrawal BTC 0.002",
+		"fee BTC 0.00005",
+	}, summarize(rows))
+
+	assert.Equal(t, "Kraken", rows[0].Account)
+	assert.Equal(t, 3, rows[1].Line)
+	assert.Equal(t, "Kraken trade TTRD01-DDDDD-EEEEEE", rows[1].Note)
+}
+
+func TestParseKraken_FiatPair(t *testing.T) {
+	input := "txid,refid,time,type,subtype,aclass,asset,amount,fee,balance\n" +
+		"L1,T1,2024-01-05 10:00:00,trade,,currency,ZEUR,-400.0000,0.6400,599.3600\n" +
+		"L2,T1,2024-01-05 10:00:00,trade,,currency,XXBT,0.0100000000,0,0.0100000000\n" +
+		"L3,T2,2024-01-06 10:00:00,trade,,currency,XXBT,-0.0010000000,0,0.0090000000\n" +
+		"L4,T2,2024-01-06 10:00:00,trade,,currency,XETH,0.0150000000,0,0.0150000000\n"
+	rows, err := ParseExchange(ExchangeKraken, strings.NewReader(input), "")
+	require.NoError(t, err)
+
+	// A euro trade has no USD price, so it is marked rather than booked
+	// without a cost; crypto pairs only move balances
+	assert.Equal(t, []string{
+		"withdrawal EUR 400 unpriced",
+		"deposit BTC 0.01 unpriced",
+		"fee EUR 0.64",
+		"withdrawal BTC 0.001",
+		"deposit ETH 0.015",
+	}, summarize(rows))
+}
+
+func TestParseKraken_UnsupportedType(t *testing.T) {
+	input := "txid,refid,time,type,subtype,aclass,asset,amount,fee,balance\n" +
+		"L1,R1,2024-01-01 00:00:00,margin,,currency,XXBT,0.1,0,0.1\n"
+	_, err := ParseExchange(ExchangeKraken, strings.NewReader(input), "")
+	assert.ErrorContains(t, err, `line 2: unsupported ledger type "margin"`)
+}
+
+func TestKrakenAsset(t *testing.T) {
+	assert.Equal(t, "BTC", krakenAsset("XXBT"))
+	assert.Equal(t, "USD", krakenAsset("ZUSD"))
+	assert.Equal(t, "DOT", krakenAsset("DOT.S"))
+	assert.Equal(t, "ETH", krakenAsset("ETH2.S"))
+	assert.Equal(t, "SOL", krakenAsset("sol"))
+}
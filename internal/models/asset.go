@@ -1,8 +1,10 @@
 package models
 
 import (
-	"gorm.io/gorm"
 	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
 )
 
 type AssetType string
@@ -14,6 +16,9 @@ const (
 	AssetTypeOther  AssetType = "other"
 )
 
+// AssetTypes lists the types an asset can be given
+var AssetTypes = []AssetType{AssetTypeCrypto, AssetTypeStock, AssetTypeFiat, AssetTypeOther}
+
 type Account struct {
 	ID        uint   `gorm:"primaryKey"`
 	Name      string `gorm:"not null"`
@@ -24,44 +29,123 @@ type Account struct {
 	DeletedAt gorm.DeletedAt `gorm:"index"`
 }
 
+// Account types. Accounts created on the fly, by adding a holding or an
+// import, start out as AccountTypeUnknown.
+const (
+	AccountTypeExchange       = "exchange"
+	AccountTypeHardwareWallet = "hardware_wallet"
+	AccountTypeBank           = "bank"
+	AccountTypeBrokerage      = "brokerage"
+	AccountTypeCash           = "cash"
+	AccountTypeUnknown        = "unknown"
+)
+
+// AccountTypes lists the types an account can be given
+var AccountTypes = []string{
+	AccountTypeExchange,
+	AccountTypeHardwareWallet,
+	AccountTypeBank,
+	AccountTypeBrokerage,
+	AccountTypeCash,
+}
+
 type Asset struct {
-	ID        uint      `gorm:"primaryKey"`
-	Symbol    string    `gorm:"uniqueIndex;not null"`
-	Name      string    `gorm:"not null"`
-	Type      AssetType `gorm:"not null"`
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	DeletedAt gorm.DeletedAt `gorm:"index"`
+	ID         uint      `gorm:"primaryKey"`
+	Symbol     string    `gorm:"uniqueIndex;not null"`
+	Name       string    `gorm:"not null"`
+	Type       AssetType `gorm:"not null"`
+	ProviderID string    // Explicit price provider ID (e.g. CoinGecko coin ID), overrides symbol lookup
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	DeletedAt  gorm.DeletedAt `gorm:"index"`
 }
 
+// Amounts and prices are exact decimals, stored as text so 18-decimal tokens
+// and satoshi amounts survive the round trip
 type Holding struct {
-	ID            uint    `gorm:"primaryKey"`
-	AccountID     uint    `gorm:"not null"`
-	Account       Account `gorm:"foreignKey:AccountID"`
-	AssetID       uint    `gorm:"not null"`
-	Asset         Asset   `gorm:"foreignKey:AssetID"`
-	Amount        float64 `gorm:"not null"`
-	PurchasePrice float64
+	ID            uint            `gorm:"primaryKey"`
+	AccountID     uint            `gorm:"not null"`
+	Account       Account         `gorm:"foreignKey:AccountID"`
+	AssetID       uint            `gorm:"not null"`
+	Asset         Asset           `gorm:"foreignKey:AssetID"`
+	Amount        decimal.Decimal `gorm:"type:text;not null"`
+	PurchasePrice decimal.Decimal `gorm:"type:text"`
 	PurchaseDate  time.Time
 	CreatedAt     time.Time
 	UpdatedAt     time.Time
 	DeletedAt     gorm.DeletedAt `gorm:"index"`
 }
 
+type TransactionType string
+
+const (
+	TransactionBuy        TransactionType = "buy"
+	TransactionSell       TransactionType = "sell"
+	TransactionDeposit    TransactionType = "deposit"
+	TransactionWithdrawal TransactionType = "withdrawal"
+	TransactionTransfer   TransactionType = "transfer"
+	TransactionFee        TransactionType = "fee"
+	TransactionIncome     TransactionType = "income"
+	TransactionAdjustment TransactionType = "adjustment"
+)
+
+// TransactionTypes lists every ledger transaction type
+var TransactionTypes = []TransactionType{
+	TransactionBuy,
+	TransactionSell,
+	TransactionDeposit,
+	TransactionWithdrawal,
+	TransactionTransfer,
+	TransactionFee,
+	TransactionIncome,
+	TransactionAdjustment,
+}
+
+// Transaction is one ledger entry. Holding balances are derived from the
+// ledger: buys, deposits and income add to the account, sells, withdrawals
+// and fees take from it, and transfers move the quantity to ToAccountID.
+// Adjustments move nothing; they correct the cost of Quantity units to
+// PriceUSD.
+type Transaction struct {
+	ID          uint            `gorm:"primaryKey"`
+	Type        TransactionType `gorm:"not null;index"`
+	AccountID   uint            `gorm:"not null;index"`
+	Account     Account         `gorm:"foreignKey:AccountID"`
+	AssetID     uint            `gorm:"not null;index"`
+	Asset       Asset           `gorm:"foreignKey:AssetID"`
+	ToAccountID *uint           `gorm:"index"` // Destination of a transfer
+	ToAccount   *Account        `gorm:"foreignKey:ToAccountID"`
+	Quantity    decimal.Decimal `gorm:"type:text;not null"` // Always positive, Type gives the direction
+	PriceUSD    decimal.Decimal `gorm:"type:text"`          // Price per unit, 0 if unknown
+	Fee         decimal.Decimal `gorm:"type:text"`          // Fee paid in USD
+	Note        string
+	Timestamp   time.Time `gorm:"not null;index"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   gorm.DeletedAt `gorm:"index"`
+}
+
+// IsInflow reports whether the transaction adds to AccountID's balance
+func (t TransactionType) IsInflow() bool {
+	return t == TransactionBuy || t == TransactionDeposit || t == TransactionIncome
+}
+
 type AuditLogAction string
 
 const (
 	AuditActionCreate AuditLogAction = "CREATE"
 	AuditActionUpdate AuditLogAction = "UPDATE"
 	AuditActionDelete AuditLogAction = "DELETE"
+	AuditActionPurge  AuditLogAction = "PURGE" // Removed from the trash for good
 )
 
 type AuditLogEntityType string
 
 const (
-	AuditEntityHolding AuditLogEntityType = "HOLDING"
-	AuditEntityAsset   AuditLogEntityType = "ASSET"
-	AuditEntityAccount AuditLogEntityType = "ACCOUNT"
+	AuditEntityHolding     AuditLogEntityType = "HOLDING"
+	AuditEntityAsset       AuditLogEntityType = "ASSET"
+	AuditEntityAccount     AuditLogEntityType = "ACCOUNT"
+	AuditEntityTransaction AuditLogEntityType = "TRANSACTION" // Only purges are audited
 )
 
 type AuditLog struct {
@@ -72,20 +156,53 @@ type AuditLog struct {
 	OldValue   string             `gorm:"type:text"` // JSON representation
 	NewValue   string             `gorm:"type:text"` // JSON representation
 	UserNote   string
+	Undone     bool      `gorm:"not null;default:false"` // Reverted by an undo, until a redo applies it again
 	CreatedAt  time.Time `gorm:"not null;index"`
 }
 
 type PortfolioSnapshot struct {
 	ID            uint                   `gorm:"primaryKey"`
-	TotalValueUSD float64                `gorm:"not null"`
+	TotalValueUSD decimal.Decimal        `gorm:"type:text;not null"`
+	Currency      string                 // Base currency at the time of the snapshot
+	TotalValue    decimal.Decimal        `gorm:"type:text"` // Total in Currency, at that day's rate
 	Details       map[string]interface{} `gorm:"serializer:json"`
 	Timestamp     time.Time              `gorm:"not null;index"`
 }
 
 type PriceCache struct {
-	ID        uint      `gorm:"primaryKey"`
-	AssetID   uint      `gorm:"uniqueIndex;not null"`
-	Asset     Asset     `gorm:"foreignKey:AssetID"`
-	PriceUSD  float64   `gorm:"not null"`
-	UpdatedAt time.Time `gorm:"not null;index"`
+	ID        uint            `gorm:"primaryKey"`
+	AssetID   uint            `gorm:"uniqueIndex;not null"`
+	Asset     Asset           `gorm:"foreignKey:AssetID"`
+	PriceUSD  decimal.Decimal `gorm:"type:text;not null"`
+	Manual    bool            `gorm:"not null;default:false"` // Set by the user, never overwritten by fetched prices
+	PriceDate time.Time       // Date a manual price was valued at
+	UpdatedAt time.Time       `gorm:"not null;index"`
+}
+
+// PriceHistory is one recorded price of an asset. Fetched prices are appended
+// on every refresh and thinned out by age; manual prices are kept forever.
+type PriceHistory struct {
+	ID        uint            `gorm:"primaryKey"`
+	AssetID   uint            `gorm:"not null;uniqueIndex:idx_price_history_point,priority:1"`
+	PriceUSD  decimal.Decimal `gorm:"type:text;not null"`
+	Source    string          `gorm:"not null;uniqueIndex:idx_price_history_point,priority:3"`
+	Manual    bool            `gorm:"not null;default:false"`
+	Timestamp time.Time       `gorm:"not null;uniqueIndex:idx_price_history_point,priority:2"`
+}
+
+// CoinListing is one entry of the CoinGecko coin list, used to resolve
+// ticker symbols to coin IDs
+type CoinListing struct {
+	ID        uint   `gorm:"primaryKey"`
+	CoinID    string `gorm:"uniqueIndex;not null"`
+	Symbol    string `gorm:"index;not null"`
+	Name      string
+	UpdatedAt time.Time
+}
+
+// Setting is a user preference stored as a key/value pair
+type Setting struct {
+	Key       string `gorm:"primaryKey"`
+	Value     string `gorm:"not null"`
+	UpdatedAt time.Time
 }
@@ -0,0 +1,266 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Holdings, accounts and assets write their own audit log through GORM
+// hooks. The hooks run inside the transaction GORM opens for every create,
+// update and delete, so an entry is stored together with its change and a
+// failed audit write rolls the change back. Only changes that name a single
+// row by its ID are allowed; raw SQL bypasses auditing. Ledger transactions
+// change holdings, which audit the effect, so only their purges are audited.
+
+// ErrUnauditedChange is returned for a change the audit log cannot attribute
+// to a row, such as an update of every holding matching a condition
+var ErrUnauditedChange = errors.New("audited changes must name the row by ID")
+
+type auditNoteKey struct{}
+
+// WithAuditNote returns a session that attaches note to the audit entries of
+// the changes made through it
+func WithAuditNote(db *gorm.DB, note string) *gorm.DB {
+	ctx := db.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return db.WithContext(context.WithValue(ctx, auditNoteKey{}, note))
+}
+
+// audited is a model whose changes are written to the audit log
+type audited interface {
+	auditEntity() AuditLogEntityType
+	auditKey() uint
+	auditValue() map[string]interface{}
+	auditDeleted() bool
+}
+
+func (h *Holding) auditEntity() AuditLogEntityType { return AuditEntityHolding }
+func (h *Holding) auditKey() uint                  { return h.ID }
+func (h *Holding) auditDeleted() bool              { return h.DeletedAt.Valid }
+func (h *Holding) auditValue() map[string]interface{} {
+	return map[string]interface{}{
+		"account_id":     h.AccountID,
+		"asset_id":       h.AssetID,
+		"amount":         h.Amount,
+		"purchase_price": h.PurchasePrice,
+		"purchase_date":  h.PurchaseDate,
+	}
+}
+
+func (a *Account) auditEntity() AuditLogEntityType { return AuditEntityAccount }
+func (a *Account) auditKey() uint                  { return a.ID }
+func (a *Account) auditDeleted() bool              { return a.DeletedAt.Valid }
+func (a *Account) auditValue() map[string]interface{} {
+	return map[string]interface{}{
+		"name":  a.Name,
+		"type":  a.Type,
+		"color": a.Color,
+	}
+}
+
+func (a *Asset) auditEntity() AuditLogEntityType { return AuditEntityAsset }
+func (a *Asset) auditKey() uint                  { return a.ID }
+func (a *Asset) auditDeleted() bool              { return a.DeletedAt.Valid }
+func (a *Asset) auditValue() map[string]interface{} {
+	return map[string]interface{}{
+		"symbol":      a.Symbol,
+		"name":        a.Name,
+		"type":        a.Type,
+		"provider_id": a.ProviderID,
+	}
+}
+
+func (t *Transaction) auditEntity() AuditLogEntityType { return AuditEntityTransaction }
+func (t *Transaction) auditKey() uint                  { return t.ID }
+func (t *Transaction) auditDeleted() bool              { return t.DeletedAt.Valid }
+func (t *Transaction) auditValue() map[string]interface{} {
+	return map[string]interface{}{
+		"type":          t.Type,
+		"account_id":    t.AccountID,
+		"asset_id":      t.AssetID,
+		"to_account_id": t.ToAccountID,
+		"quantity":      t.Quantity,
+		"price_usd":     t.PriceUSD,
+		"fee":           t.Fee,
+		"note":          t.Note,
+		"timestamp":     t.Timestamp,
+	}
+}
+
+func (h *Holding) BeforeCreate(tx *gorm.DB) error { return auditBeforeCreate(tx, h, &Holding{}) }
+func (h *Holding) AfterCreate(tx *gorm.DB) error  { return auditCreate(tx, h, &Holding{}) }
+func (h *Holding) BeforeUpdate(tx *gorm.DB) error { return auditBefore(tx, h, &Holding{}) }
+func (h *Holding) AfterUpdate(tx *gorm.DB) error  { return auditUpdate(tx, h, &Holding{}) }
+func (h *Holding) BeforeDelete(tx *gorm.DB) error { return auditBefore(tx, h, &Holding{}) }
+func (h *Holding) AfterDelete(tx *gorm.DB) error  { return auditDelete(tx, h) }
+
+func (a *Account) BeforeCreate(tx *gorm.DB) error { return auditBeforeCreate(tx, a, &Account{}) }
+func (a *Account) AfterCreate(tx *gorm.DB) error  { return auditCreate(tx, a, &Account{}) }
+func (a *Account) BeforeUpdate(tx *gorm.DB) error { return auditBefore(tx, a, &Account{}) }
+func (a *Account) AfterUpdate(tx *gorm.DB) error  { return auditUpdate(tx, a, &Account{}) }
+func (a *Account) BeforeDelete(tx *gorm.DB) error { return auditBefore(tx, a, &Account{}) }
+func (a *Account) AfterDelete(tx *gorm.DB) error  { return auditDelete(tx, a) }
+
+func (a *Asset) BeforeCreate(tx *gorm.DB) error { return auditBeforeCreate(tx, a, &Asset{}) }
+func (a *Asset) AfterCreate(tx *gorm.DB) error  { return auditCreate(tx, a, &Asset{}) }
+func (a *Asset) BeforeUpdate(tx *gorm.DB) error { return auditBefore(tx, a, &Asset{}) }
+func (a *Asset) AfterUpdate(tx *gorm.DB) error  { return auditUpdate(tx, a, &Asset{}) }
+func (a *Asset) BeforeDelete(tx *gorm.DB) error { return auditBefore(tx, a, &Asset{}) }
+func (a *Asset) AfterDelete(tx *gorm.DB) error  { return auditDelete(tx, a) }
+
+func (t *Transaction) BeforeDelete(tx *gorm.DB) error {
+	if !tx.Statement.Unscoped {
+		return nil
+	}
+	return auditBefore(tx, t, &Transaction{})
+}
+
+func (t *Transaction) AfterDelete(tx *gorm.DB) error {
+	if !tx.Statement.Unscoped {
+		return nil
+	}
+	return auditDelete(tx, t)
+}
+
+// auditLoad reads the stored state of row into into, deleted or not
+func auditLoad(tx *gorm.DB, row, into audited) error {
+	if row.auditKey() == 0 {
+		return ErrUnauditedChange
+	}
+	return tx.Session(&gorm.Session{NewDB: true}).Unscoped().First(into, row.auditKey()).Error
+}
+
+// oldKey is where the before hook leaves the stored state for the after hook;
+// both run on the same statement
+func oldKey(row audited) string {
+	return fmt.Sprintf("audit:old:%p", row)
+}
+
+// auditBeforeCreate notes rows that already exist. Saving a holding with its
+// account and asset loaded upserts them, which runs their create hooks
+// without creating anything.
+func auditBeforeCreate(tx *gorm.DB, row, existing audited) error {
+	if row.auditKey() == 0 {
+		return nil
+	}
+	err := auditLoad(tx, row, existing)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	tx.Statement.Settings.Store(oldKey(row), existing)
+	return nil
+}
+
+func auditCreate(tx *gorm.DB, row, stored audited) error {
+	if _, exists := tx.Statement.Settings.LoadAndDelete(oldKey(row)); exists {
+		return nil
+	}
+	if err := auditLoad(tx, row, stored); err != nil {
+		return err
+	}
+	return writeAudit(tx, AuditActionCreate, stored, nil, stored)
+}
+
+func auditBefore(tx *gorm.DB, row, old audited) error {
+	if err := auditLoad(tx, row, old); err != nil {
+		return err
+	}
+	tx.Statement.Settings.Store(oldKey(row), old)
+	return nil
+}
+
+// auditUpdate records an update. Clearing the deletion of a row brings it
+// back and is recorded as its creation; an update that changes nothing
+// recorded is left out.
+func auditUpdate(tx *gorm.DB, row, stored audited) error {
+	value, ok := tx.Statement.Settings.LoadAndDelete(oldKey(row))
+	if !ok {
+		return ErrUnauditedChange
+	}
+	old := value.(audited)
+	if err := auditLoad(tx, row, stored); err != nil {
+		return err
+	}
+
+	switch {
+	case old.auditDeleted() && !stored.auditDeleted():
+		return writeAudit(tx, AuditActionCreate, stored, nil, stored)
+	case !old.auditDeleted() && stored.auditDeleted():
+		return writeAudit(tx, AuditActionDelete, stored, old, nil)
+	}
+	oldJSON, err := json.Marshal(old.auditValue())
+	if err != nil {
+		return err
+	}
+	newJSON, err := json.Marshal(stored.auditValue())
+	if err != nil {
+		return err
+	}
+	if string(oldJSON) == string(newJSON) {
+		return nil
+	}
+	return writeAudit(tx, AuditActionUpdate, stored, old, stored)
+}
+
+// auditDelete records the deletion of a live row, and the purge of a row
+// that is removed for good
+func auditDelete(tx *gorm.DB, row audited) error {
+	value, ok := tx.Statement.Settings.LoadAndDelete(oldKey(row))
+	if !ok {
+		return ErrUnauditedChange
+	}
+	old := value.(audited)
+	switch {
+	case tx.Statement.Unscoped:
+		return writeAudit(tx, AuditActionPurge, old, old, nil)
+	case !old.auditDeleted():
+		return writeAudit(tx, AuditActionDelete, old, old, nil)
+	}
+	return nil
+}
+
+func writeAudit(tx *gorm.DB, action AuditLogAction, row, oldRow, newRow audited) error {
+	encode := func(value audited) (string, error) {
+		if value == nil {
+			return "", nil
+		}
+		data, err := json.Marshal(value.auditValue())
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal %s for the audit log: %w", row.auditEntity(), err)
+		}
+		return string(data), nil
+	}
+	oldValue, err := encode(oldRow)
+	if err != nil {
+		return err
+	}
+	newValue, err := encode(newRow)
+	if err != nil {
+		return err
+	}
+
+	note, _ := tx.Statement.Context.Value(auditNoteKey{}).(string)
+	err = tx.Session(&gorm.Session{NewDB: true}).Create(&AuditLog{
+		Action:     action,
+		EntityType: row.auditEntity(),
+		EntityID:   row.auditKey(),
+		OldValue:   oldValue,
+		NewValue:   newValue,
+		UserNote:   note,
+		CreatedAt:  time.Now(),
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
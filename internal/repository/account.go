@@ -40,10 +40,28 @@ func (r *AccountRepository) GetByName(name string) (models.Account, error) {
 	return account, err
 }
 
+// Update saves an account as it is; clearing its DeletedAt brings it back
 func (r *AccountRepository) Update(account *models.Account) error {
-	return r.db.Save(account).Error
+	return r.db.Unscoped().Save(account).Error
 }
 
 func (r *AccountRepository) Delete(id uint) error {
-	return r.db.Delete(&models.Account{}, id).Error
+	return r.db.Delete(&models.Account{ID: id}).Error
+}
+
+// Restore brings back a deleted account
+func (r *AccountRepository) Restore(id uint) error {
+	return r.db.Unscoped().Model(&models.Account{ID: id}).Update("deleted_at", nil).Error
+}
+
+// GetDeleted returns the soft-deleted accounts, oldest first
+func (r *AccountRepository) GetDeleted() ([]models.Account, error) {
+	var accounts []models.Account
+	err := r.db.Unscoped().Where("deleted_at IS NOT NULL").Order("id asc").Find(&accounts).Error
+	return accounts, err
+}
+
+// Purge removes an account for good
+func (r *AccountRepository) Purge(id uint) error {
+	return r.db.Unscoped().Delete(&models.Account{ID: id}).Error
 }
@@ -40,10 +40,35 @@ func (r *AssetRepository) GetBySymbol(symbol string) (models.Asset, error) {
 	return asset, err
 }
 
+// Update saves an asset as it is; clearing its DeletedAt brings it back
 func (r *AssetRepository) Update(asset *models.Asset) error {
-	return r.db.Save(asset).Error
+	return r.db.Unscoped().Save(asset).Error
 }
 
 func (r *AssetRepository) Delete(id uint) error {
-	return r.db.Delete(&models.Asset{}, id).Error
+	return r.db.Delete(&models.Asset{ID: id}).Error
+}
+
+// GetDeletedBySymbol returns a deleted asset, which still holds its symbol
+func (r *AssetRepository) GetDeletedBySymbol(symbol string) (models.Asset, error) {
+	var asset models.Asset
+	err := r.db.Unscoped().Where("symbol = ? AND deleted_at IS NOT NULL", symbol).First(&asset).Error
+	return asset, err
+}
+
+// Restore brings back a deleted asset
+func (r *AssetRepository) Restore(id uint) error {
+	return r.db.Unscoped().Model(&models.Asset{ID: id}).Update("deleted_at", nil).Error
+}
+
+// GetDeleted returns the soft-deleted assets, oldest first
+func (r *AssetRepository) GetDeleted() ([]models.Asset, error) {
+	var assets []models.Asset
+	err := r.db.Unscoped().Where("deleted_at IS NOT NULL").Order("id asc").Find(&assets).Error
+	return assets, err
+}
+
+// Purge removes an asset for good
+func (r *AssetRepository) Purge(id uint) error {
+	return r.db.Unscoped().Delete(&models.Asset{ID: id}).Error
 }
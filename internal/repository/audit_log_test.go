@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/bioharz/budget/internal/models"
+	"github.com/bioharz/budget/test/fixtures"
+	"github.com/bioharz/budget/test/helpers"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func actions(t *testing.T, db *gorm.DB, entity models.AuditLogEntityType, id uint) []models.AuditLogAction {
+	t.Helper()
+	logs, err := NewAuditLogRepository(db).GetByEntity(entity, id)
+	require.NoError(t, err)
+	var result []models.AuditLogAction
+	for i := len(logs) - 1; i >= 0; i-- {
+		result = append(result, logs[i].Action)
+	}
+	return result
+}
+
+func TestAuditHooks_RecordChanges(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	repo := NewHoldingRepositoryWithDB(db)
+	account := fixtures.NewAccount().Create(t, db)
+	asset := fixtures.NewAsset().Create(t, db)
+
+	holding := &models.Holding{AccountID: account.ID, AssetID: asset.ID, Amount: decimal.NewFromInt(1)}
+	require.NoError(t, repo.Create(holding))
+	holding.Amount = decimal.NewFromInt(2)
+	require.NoError(t, repo.Update(holding))
+	require.NoError(t, repo.Update(holding), "saving without a change")
+	require.NoError(t, repo.Delete(holding.ID))
+	require.NoError(t, repo.Restore(holding.ID))
+
+	assert.Equal(t, []models.AuditLogAction{
+		models.AuditActionCreate,
+		models.AuditActionUpdate,
+		models.AuditActionDelete,
+		models.AuditActionCreate,
+	}, actions(t, db, models.AuditEntityHolding, holding.ID))
+
+	logs, err := NewAuditLogRepository(db).GetByEntity(models.AuditEntityHolding, holding.ID)
+	require.NoError(t, err)
+	assert.Contains(t, logs[2].OldValue, `"amount":"1"`)
+	assert.Contains(t, logs[2].NewValue, `"amount":"2"`)
+	assert.Contains(t, logs[1].OldValue, `"amount":"2"`)
+	assert.Empty(t, logs[1].NewValue)
+
+	assert.Equal(t, []models.AuditLogAction{models.AuditActionCreate},
+		actions(t, db, models.AuditEntityAccount, account.ID), "the holding's account is not created again")
+}
+
+func TestAuditHooks_Note(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+
+	err := models.WithAuditNote(db, "Imported from kraken.csv line 2").Transaction(func(tx *gorm.DB) error {
+		return NewAccountRepositoryWithDB(tx).Create(&models.Account{Name: "Kraken", Type: models.AccountTypeExchange})
+	})
+	require.NoError(t, err)
+	require.NoError(t, NewAccountRepositoryWithDB(db).Create(&models.Account{Name: "Ledger", Type: models.AccountTypeHardwareWallet}))
+
+	logs, err := NewAuditLogRepository(db).GetAll(0)
+	require.NoError(t, err)
+	require.Len(t, logs, 2)
+	assert.Empty(t, logs[0].UserNote)
+	assert.Equal(t, "Imported from kraken.csv line 2", logs[1].UserNote)
+}
+
+func TestAuditHooks_FailedAuditRollsBack(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	account := fixtures.NewAccount().WithName("Ledger").Create(t, db)
+	require.NoError(t, db.Migrator().DropTable(&models.AuditLog{}))
+
+	repo := NewAccountRepositoryWithDB(db)
+	assert.Error(t, repo.Create(&models.Account{Name: "Kraken", Type: models.AccountTypeExchange}))
+	account.Name = "Ledger Nano"
+	assert.Error(t, repo.Update(account))
+	assert.Error(t, repo.Delete(account.ID))
+
+	accounts, err := repo.GetAll()
+	require.NoError(t, err)
+	require.Len(t, accounts, 1)
+	assert.Equal(t, "Ledger", accounts[0].Name)
+}
+
+func TestAuditHooks_RefuseUnnamedRows(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	fixtures.NewAccount().Create(t, db)
+
+	err := db.Model(&models.Account{}).Where("type = ?", "wallet").Update("color", "#000000").Error
+	assert.ErrorIs(t, err, models.ErrUnauditedChange)
+	err = db.Where("type = ?", "wallet").Delete(&models.Account{}).Error
+	assert.ErrorIs(t, err, models.ErrUnauditedChange)
+}
+
+func TestAuditHooks_Purge(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	repo := NewAssetRepositoryWithDB(db)
+	asset := fixtures.NewAsset().Create(t, db)
+
+	require.NoError(t, repo.Delete(asset.ID))
+	require.NoError(t, repo.Purge(asset.ID))
+	assert.Equal(t, []models.AuditLogAction{
+		models.AuditActionCreate,
+		models.AuditActionDelete,
+		models.AuditActionPurge,
+	}, actions(t, db, models.AuditEntityAsset, asset.ID))
+
+	deleted, err := repo.GetDeleted()
+	require.NoError(t, err)
+	assert.Empty(t, deleted)
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"strings"
+	"time"
+
+	"github.com/bioharz/budget/internal/db"
+	"github.com/bioharz/budget/internal/models"
+	"gorm.io/gorm"
+)
+
+type CoinListingRepository struct {
+	db *gorm.DB
+}
+
+func NewCoinListingRepository() *CoinListingRepository {
+	return &CoinListingRepository{db: db.DB}
+}
+
+func NewCoinListingRepositoryWithDB(database *gorm.DB) *CoinListingRepository {
+	return &CoinListingRepository{db: database}
+}
+
+// ReplaceAll swaps the stored coin list for a freshly fetched one
+func (r *CoinListingRepository) ReplaceAll(listings []models.CoinListing) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&models.CoinListing{}).Error; err != nil {
+			return err
+		}
+		if len(listings) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(&listings, 500).Error
+	})
+}
+
+// GetBySymbol returns every coin sharing a ticker symbol
+func (r *CoinListingRepository) GetBySymbol(symbol string) ([]models.CoinListing, error) {
+	var listings []models.CoinListing
+	err := r.db.Where("symbol = ?", strings.ToUpper(symbol)).
+		Order("name asc").
+		Find(&listings).Error
+	return listings, err
+}
+
+// GetLastUpdateTime returns when the coin list was last stored
+func (r *CoinListingRepository) GetLastUpdateTime() (*time.Time, error) {
+	var listing models.CoinListing
+	err := r.db.Order("updated_at desc").First(&listing).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &listing.UpdatedAt, nil
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bioharz/budget/internal/models"
+	"github.com/bioharz/budget/test/helpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoinListingRepository_ReplaceAndLookup(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	repo := NewCoinListingRepositoryWithDB(db)
+
+	lastUpdate, err := repo.GetLastUpdateTime()
+	require.NoError(t, err)
+	assert.Nil(t, lastUpdate)
+
+	now := time.Now()
+	require.NoError(t, repo.ReplaceAll([]models.CoinListing{
+		{CoinID: "arbitrum", Symbol: "ARB", Name: "Arbitrum", UpdatedAt: now},
+		{CoinID: "arbi-token", Symbol: "ARB", Name: "Arbi Token", UpdatedAt: now},
+		{CoinID: "chainlink", Symbol: "LINK", Name: "Chainlink", UpdatedAt: now},
+	}))
+
+	listings, err := repo.GetBySymbol("arb")
+	require.NoError(t, err)
+	require.Len(t, listings, 2)
+	assert.Equal(t, "arbi-token", listings[0].CoinID)
+	assert.Equal(t, "arbitrum", listings[1].CoinID)
+
+	lastUpdate, err = repo.GetLastUpdateTime()
+	require.NoError(t, err)
+	require.NotNil(t, lastUpdate)
+	assert.WithinDuration(t, now, *lastUpdate, time.Second)
+
+	// A new list replaces the old one entirely
+	require.NoError(t, repo.ReplaceAll([]models.CoinListing{
+		{CoinID: "chainlink", Symbol: "LINK", Name: "Chainlink", UpdatedAt: now},
+	}))
+	listings, err = repo.GetBySymbol("ARB")
+	require.NoError(t, err)
+	assert.Empty(t, listings)
+}
@@ -40,10 +40,44 @@ func (r *HoldingRepository) GetByAccountID(accountID uint) ([]models.Holding, er
 	return holdings, err
 }
 
+// Update saves a holding as it is; clearing its DeletedAt brings it back
 func (r *HoldingRepository) Update(holding *models.Holding) error {
-	return r.db.Save(holding).Error
+	return r.db.Unscoped().Save(holding).Error
 }
 
 func (r *HoldingRepository) Delete(id uint) error {
-	return r.db.Delete(&models.Holding{}, id).Error
+	return r.db.Delete(&models.Holding{ID: id}).Error
+}
+
+// GetByPosition returns the holdings of an asset in an account, including
+// soft-deleted ones, oldest first
+func (r *HoldingRepository) GetByPosition(accountID, assetID uint) ([]models.Holding, error) {
+	var holdings []models.Holding
+	err := r.db.Unscoped().
+		Where("account_id = ? AND asset_id = ?", accountID, assetID).
+		Order("id asc").
+		Find(&holdings).Error
+	return holdings, err
+}
+
+// Restore brings back a soft-deleted holding
+func (r *HoldingRepository) Restore(id uint) error {
+	return r.db.Unscoped().Model(&models.Holding{ID: id}).Update("deleted_at", nil).Error
+}
+
+// GetDeleted returns the soft-deleted holdings with their accounts and
+// assets, deleted or not, oldest first
+func (r *HoldingRepository) GetDeleted() ([]models.Holding, error) {
+	var holdings []models.Holding
+	unscoped := func(tx *gorm.DB) *gorm.DB { return tx.Unscoped() }
+	err := r.db.Unscoped().Preload("Account", unscoped).Preload("Asset", unscoped).
+		Where("deleted_at IS NOT NULL").
+		Order("id asc").
+		Find(&holdings).Error
+	return holdings, err
+}
+
+// Purge removes a holding for good
+func (r *HoldingRepository) Purge(id uint) error {
+	return r.db.Unscoped().Delete(&models.Holding{ID: id}).Error
 }
@@ -7,6 +7,7 @@ import (
 	"github.com/bioharz/budget/internal/models"
 	"github.com/bioharz/budget/test/fixtures"
 	"github.com/bioharz/budget/test/helpers"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"gorm.io/gorm"
@@ -23,8 +24,8 @@ func TestHoldingRepository_Create(t *testing.T) {
 	holding := &models.Holding{
 		AccountID:     account.ID,
 		AssetID:       asset.ID,
-		Amount:        0.5,
-		PurchasePrice: 40000,
+		Amount:        decimal.NewFromFloat(0.5),
+		PurchasePrice: decimal.NewFromInt(40000),
 		PurchaseDate:  time.Now(),
 	}
 
@@ -38,8 +39,8 @@ func TestHoldingRepository_Create(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, account.ID, saved.AccountID)
 	assert.Equal(t, asset.ID, saved.AssetID)
-	assert.Equal(t, 0.5, saved.Amount)
-	assert.Equal(t, float64(40000), saved.PurchasePrice)
+	assert.Equal(t, "0.5", saved.Amount.String())
+	assert.Equal(t, "40000", saved.PurchasePrice.String())
 }
 
 func TestHoldingRepository_GetAll(t *testing.T) {
@@ -173,8 +174,8 @@ func TestHoldingRepository_Update(t *testing.T) {
 		Create(t, db)
 
 	// Update holding
-	holding.Amount = 1.5
-	holding.PurchasePrice = 35000
+	holding.Amount = decimal.NewFromFloat(1.5)
+	holding.PurchasePrice = decimal.NewFromInt(35000)
 	err := repo.Update(holding)
 	require.NoError(t, err)
 
@@ -182,8 +183,8 @@ func TestHoldingRepository_Update(t *testing.T) {
 	var updated models.Holding
 	err = db.First(&updated, holding.ID).Error
 	require.NoError(t, err)
-	assert.Equal(t, 1.5, updated.Amount)
-	assert.Equal(t, float64(35000), updated.PurchasePrice)
+	assert.Equal(t, "1.5", updated.Amount.String())
+	assert.Equal(t, "35000", updated.PurchasePrice.String())
 }
 
 func TestHoldingRepository_Delete(t *testing.T) {
@@ -226,7 +227,7 @@ func TestHoldingRepository_MultipleHoldingsSameAsset(t *testing.T) {
 	holding1 := &models.Holding{
 		AccountID: account1.ID,
 		AssetID:   btc.ID,
-		Amount:    0.5,
+		Amount:    decimal.NewFromFloat(0.5),
 	}
 	err := repo.Create(holding1)
 	require.NoError(t, err)
@@ -234,7 +235,7 @@ func TestHoldingRepository_MultipleHoldingsSameAsset(t *testing.T) {
 	holding2 := &models.Holding{
 		AccountID: account2.ID,
 		AssetID:   btc.ID,
-		Amount:    1.0,
+		Amount:    decimal.NewFromInt(1),
 	}
 	err = repo.Create(holding2)
 	require.NoError(t, err)
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/bioharz/budget/internal/db"
+	"github.com/bioharz/budget/internal/models"
+	"gorm.io/gorm"
+)
+
+type PortfolioSnapshotRepository struct {
+	db *gorm.DB
+}
+
+func NewPortfolioSnapshotRepository() *PortfolioSnapshotRepository {
+	return &PortfolioSnapshotRepository{db: db.DB}
+}
+
+func NewPortfolioSnapshotRepositoryWithDB(database *gorm.DB) *PortfolioSnapshotRepository {
+	return &PortfolioSnapshotRepository{db: database}
+}
+
+func (r *PortfolioSnapshotRepository) Create(snapshot *models.PortfolioSnapshot) error {
+	return r.db.Create(snapshot).Error
+}
+
+// GetByDateRange returns snapshots between start and end, oldest first
+func (r *PortfolioSnapshotRepository) GetByDateRange(start, end time.Time) ([]models.PortfolioSnapshot, error) {
+	var snapshots []models.PortfolioSnapshot
+	err := r.db.Where("timestamp BETWEEN ? AND ?", start, end).
+		Order("timestamp asc").
+		Find(&snapshots).Error
+	return snapshots, err
+}
+
+// GetLatest returns the most recent snapshot
+func (r *PortfolioSnapshotRepository) GetLatest() (*models.PortfolioSnapshot, error) {
+	var snapshot models.PortfolioSnapshot
+	err := r.db.Order("timestamp desc").First(&snapshot).Error
+	if err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
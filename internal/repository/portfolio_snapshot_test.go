@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bioharz/budget/internal/models"
+	"github.com/bioharz/budget/test/helpers"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPortfolioSnapshotRepository_CreateAndRange(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	repo := NewPortfolioSnapshotRepositoryWithDB(db)
+
+	now := time.Now()
+	for i, value := range []int64{1000, 1100, 1050} {
+		snapshot := &models.PortfolioSnapshot{
+			TotalValueUSD: decimal.NewFromInt(value),
+			Details: map[string]interface{}{
+				"assets": map[string]float64{"BTC": float64(value)},
+			},
+			Timestamp: now.Add(time.Duration(i-2) * time.Hour),
+		}
+		require.NoError(t, repo.Create(snapshot))
+		assert.NotZero(t, snapshot.ID)
+	}
+
+	// Only the last two fall inside a 90 minute window
+	snapshots, err := repo.GetByDateRange(now.Add(-90*time.Minute), now)
+	require.NoError(t, err)
+	require.Len(t, snapshots, 2)
+	assert.Equal(t, "1100", snapshots[0].TotalValueUSD.String())
+	assert.Equal(t, "1050", snapshots[1].TotalValueUSD.String())
+
+	// Details round-trip through the JSON serializer
+	assets, ok := snapshots[0].Details["assets"].(map[string]interface{})
+	require.True(t, ok)
+	assert.Equal(t, 1100.0, assets["BTC"])
+
+	latest, err := repo.GetLatest()
+	require.NoError(t, err)
+	assert.Equal(t, "1050", latest.TotalValueUSD.String())
+}
+
+func TestPortfolioSnapshotRepository_GetLatest_Empty(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	repo := NewPortfolioSnapshotRepositoryWithDB(db)
+
+	latest, err := repo.GetLatest()
+	assert.Error(t, err)
+	assert.Nil(t, latest)
+}
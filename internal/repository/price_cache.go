@@ -5,6 +5,7 @@ import (
 
 	"github.com/bioharz/budget/internal/db"
 	"github.com/bioharz/budget/internal/models"
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
@@ -43,9 +44,9 @@ func (r *PriceCacheRepository) GetAll() ([]models.PriceCache, error) {
 }
 
 // GetPricesMap returns a map of asset_id -> price
-func (r *PriceCacheRepository) GetPricesMap() (map[uint]float64, error) {
+func (r *PriceCacheRepository) GetPricesMap() (map[uint]decimal.Decimal, error) {
 	if r.db == nil {
-		return make(map[uint]float64), nil
+		return make(map[uint]decimal.Decimal), nil
 	}
 	var caches []models.PriceCache
 	err := r.db.Find(&caches).Error
@@ -53,7 +54,7 @@ func (r *PriceCacheRepository) GetPricesMap() (map[uint]float64, error) {
 		return nil, err
 	}
 
-	priceMap := make(map[uint]float64)
+	priceMap := make(map[uint]decimal.Decimal)
 	for _, cache := range caches {
 		priceMap[cache.AssetID] = cache.PriceUSD
 	}
@@ -61,7 +62,7 @@ func (r *PriceCacheRepository) GetPricesMap() (map[uint]float64, error) {
 }
 
 // Upsert creates or updates a price cache entry
-func (r *PriceCacheRepository) Upsert(assetID uint, priceUSD float64) error {
+func (r *PriceCacheRepository) Upsert(assetID uint, priceUSD decimal.Decimal) error {
 	cache := models.PriceCache{
 		AssetID:   assetID,
 		PriceUSD:  priceUSD,
@@ -69,14 +70,11 @@ func (r *PriceCacheRepository) Upsert(assetID uint, priceUSD float64) error {
 	}
 
 	// Use GORM's Upsert functionality
-	return r.db.Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "asset_id"}},
-		DoUpdates: clause.AssignmentColumns([]string{"price_usd", "updated_at"}),
-	}).Create(&cache).Error
+	return r.db.Clauses(fetchedPriceConflict()).Create(&cache).Error
 }
 
 // UpsertBatch creates or updates multiple price cache entries
-func (r *PriceCacheRepository) UpsertBatch(prices map[uint]float64) error {
+func (r *PriceCacheRepository) UpsertBatch(prices map[uint]decimal.Decimal) error {
 	if len(prices) == 0 {
 		return nil
 	}
@@ -91,10 +89,58 @@ func (r *PriceCacheRepository) UpsertBatch(prices map[uint]float64) error {
 		})
 	}
 
-	return r.db.Clauses(clause.OnConflict{
+	return r.db.Clauses(fetchedPriceConflict()).CreateInBatches(&caches, 100).Error
+}
+
+// fetchedPriceConflict updates existing entries with a fetched price unless
+// the user set that price manually
+func fetchedPriceConflict() clause.OnConflict {
+	return clause.OnConflict{
 		Columns:   []clause.Column{{Name: "asset_id"}},
+		Where:     clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "price_caches.manual = ?", Vars: []interface{}{false}}}},
 		DoUpdates: clause.AssignmentColumns([]string{"price_usd", "updated_at"}),
-	}).CreateInBatches(&caches, 100).Error
+	}
+}
+
+// SetManual stores a user-entered price, valued at date, that fetches will
+// not overwrite
+func (r *PriceCacheRepository) SetManual(assetID uint, priceUSD decimal.Decimal, date time.Time) error {
+	cache := models.PriceCache{
+		AssetID:   assetID,
+		PriceUSD:  priceUSD,
+		Manual:    true,
+		PriceDate: date,
+		UpdatedAt: time.Now(),
+	}
+
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "asset_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"price_usd", "manual", "price_date", "updated_at"}),
+	}).Create(&cache).Error
+}
+
+// ClearManual hands an asset back to the price feeds. The last price stays
+// cached until the next fetch replaces it.
+func (r *PriceCacheRepository) ClearManual(assetID uint) error {
+	return r.db.Model(&models.PriceCache{}).
+		Where("asset_id = ?", assetID).
+		Updates(map[string]interface{}{"manual": false, "price_date": time.Time{}}).Error
+}
+
+// GetManual returns the manually priced entries keyed by asset ID
+func (r *PriceCacheRepository) GetManual() (map[uint]models.PriceCache, error) {
+	manual := make(map[uint]models.PriceCache)
+	if r.db == nil {
+		return manual, nil
+	}
+	var caches []models.PriceCache
+	if err := r.db.Where("manual = ?", true).Find(&caches).Error; err != nil {
+		return nil, err
+	}
+	for _, cache := range caches {
+		manual[cache.AssetID] = cache
+	}
+	return manual, nil
 }
 
 // GetLastUpdateTime returns the most recent update time
@@ -6,6 +6,7 @@ import (
 
 	"github.com/bioharz/budget/internal/models"
 	"github.com/bioharz/budget/test/helpers"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -25,25 +26,25 @@ func TestPriceCacheRepository_Upsert(t *testing.T) {
 	require.NoError(t, err)
 
 	// Test insert
-	err = repo.Upsert(asset.ID, 50000.0)
+	err = repo.Upsert(asset.ID, decimal.NewFromInt(50000))
 	require.NoError(t, err)
 
 	// Verify insert
 	cache, err := repo.GetByAssetID(asset.ID)
 	require.NoError(t, err)
-	assert.Equal(t, 50000.0, cache.PriceUSD)
+	assert.Equal(t, "50000", cache.PriceUSD.String())
 	assert.Equal(t, asset.ID, cache.AssetID)
 	assert.WithinDuration(t, time.Now(), cache.UpdatedAt, 2*time.Second)
 
 	// Test update
 	time.Sleep(100 * time.Millisecond) // Ensure different timestamp
-	err = repo.Upsert(asset.ID, 51000.0)
+	err = repo.Upsert(asset.ID, decimal.NewFromInt(51000))
 	require.NoError(t, err)
 
 	// Verify update
 	cache, err = repo.GetByAssetID(asset.ID)
 	require.NoError(t, err)
-	assert.Equal(t, 51000.0, cache.PriceUSD)
+	assert.Equal(t, "51000", cache.PriceUSD.String())
 	assert.Equal(t, asset.ID, cache.AssetID)
 }
 
@@ -62,10 +63,10 @@ func TestPriceCacheRepository_UpsertBatch(t *testing.T) {
 	require.NoError(t, assetRepo.Create(&usd))
 
 	// Test batch upsert
-	prices := map[uint]float64{
-		btc.ID: 50000.0,
-		eth.ID: 3000.0,
-		usd.ID: 1.0,
+	prices := map[uint]decimal.Decimal{
+		btc.ID: decimal.NewFromInt(50000),
+		eth.ID: decimal.NewFromInt(3000),
+		usd.ID: decimal.NewFromInt(1),
 	}
 
 	err := repo.UpsertBatch(prices)
@@ -78,9 +79,9 @@ func TestPriceCacheRepository_UpsertBatch(t *testing.T) {
 
 	priceMap, err := repo.GetPricesMap()
 	require.NoError(t, err)
-	assert.Equal(t, 50000.0, priceMap[btc.ID])
-	assert.Equal(t, 3000.0, priceMap[eth.ID])
-	assert.Equal(t, 1.0, priceMap[usd.ID])
+	assert.Equal(t, "50000", priceMap[btc.ID].String())
+	assert.Equal(t, "3000", priceMap[eth.ID].String())
+	assert.Equal(t, "1", priceMap[usd.ID].String())
 }
 
 func TestPriceCacheRepository_GetLastUpdateTime(t *testing.T) {
@@ -100,12 +101,12 @@ func TestPriceCacheRepository_GetLastUpdateTime(t *testing.T) {
 	require.NoError(t, assetRepo.Create(&eth))
 
 	// Add prices with different times
-	err = repo.Upsert(btc.ID, 50000.0)
+	err = repo.Upsert(btc.ID, decimal.NewFromInt(50000))
 	require.NoError(t, err)
 
 	time.Sleep(100 * time.Millisecond)
 
-	err = repo.Upsert(eth.ID, 3000.0)
+	err = repo.Upsert(eth.ID, decimal.NewFromInt(3000))
 	require.NoError(t, err)
 
 	// Get last update time
@@ -135,3 +136,39 @@ func TestPriceCacheRepository_GetByAssetID_NotFound(t *testing.T) {
 	assert.Error(t, err)
 	assert.Nil(t, cache)
 }
+
+func TestPriceCacheRepository_ManualPrice(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	repo := NewPriceCacheRepositoryWithDB(db)
+	assetRepo := NewAssetRepositoryWithDB(db)
+
+	house := models.Asset{Symbol: "HOUSE", Name: "Flat in Vienna", Type: models.AssetTypeOther}
+	require.NoError(t, assetRepo.Create(&house))
+	btc := models.Asset{Symbol: "BTC", Name: "Bitcoin", Type: models.AssetTypeCrypto}
+	require.NoError(t, assetRepo.Create(&btc))
+
+	valuedAt := time.Date(2025, 3, 1, 0, 0, 0, 0, time.Local)
+	require.NoError(t, repo.SetManual(house.ID, decimal.NewFromInt(350000), valuedAt))
+
+	// Fetched prices update other assets but leave the manual one alone
+	require.NoError(t, repo.UpsertBatch(map[uint]decimal.Decimal{house.ID: decimal.NewFromInt(0), btc.ID: decimal.NewFromInt(50000)}))
+	require.NoError(t, repo.Upsert(house.ID, decimal.NewFromInt(1)))
+
+	prices, err := repo.GetPricesMap()
+	require.NoError(t, err)
+	assert.Equal(t, "350000", prices[house.ID].String())
+	assert.Equal(t, "50000", prices[btc.ID].String())
+
+	manual, err := repo.GetManual()
+	require.NoError(t, err)
+	require.Len(t, manual, 1)
+	assert.True(t, manual[house.ID].PriceDate.Equal(valuedAt))
+
+	// Once cleared, fetches take over again
+	require.NoError(t, repo.ClearManual(house.ID))
+	require.NoError(t, repo.Upsert(house.ID, decimal.NewFromInt(0)))
+	cache, err := repo.GetByAssetID(house.ID)
+	require.NoError(t, err)
+	assert.False(t, cache.Manual)
+	assert.Equal(t, "0", cache.PriceUSD.String())
+}
@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/bioharz/budget/internal/db"
+	"github.com/bioharz/budget/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RetentionRule thins out fetched prices older than OlderThan to one point
+// per Resolution
+type RetentionRule struct {
+	OlderThan  time.Duration
+	Resolution time.Duration
+}
+
+// DefaultRetention keeps every point for a week, hourly points for three
+// months and daily points after that
+var DefaultRetention = []RetentionRule{
+	{OlderThan: 7 * 24 * time.Hour, Resolution: time.Hour},
+	{OlderThan: 90 * 24 * time.Hour, Resolution: 24 * time.Hour},
+}
+
+type PriceHistoryRepository struct {
+	db *gorm.DB
+}
+
+func NewPriceHistoryRepository() *PriceHistoryRepository {
+	return &PriceHistoryRepository{db: db.DB}
+}
+
+func NewPriceHistoryRepositoryWithDB(database *gorm.DB) *PriceHistoryRepository {
+	return &PriceHistoryRepository{db: database}
+}
+
+// Append stores price points. A point already recorded for the same asset,
+// source and timestamp (e.g. a quote served from cache) is skipped.
+func (r *PriceHistoryRepository) Append(entries []models.PriceHistory) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&entries, 100).Error
+}
+
+// GetAt returns the last price of an asset recorded at or before t, or nil
+// if there is none
+func (r *PriceHistoryRepository) GetAt(assetID uint, t time.Time) (*models.PriceHistory, error) {
+	var entry models.PriceHistory
+	err := r.db.Where("asset_id = ? AND timestamp <= ?", assetID, t).
+		Order("timestamp desc").
+		First(&entry).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// GetRange returns the prices of an asset recorded between start and end,
+// oldest first
+func (r *PriceHistoryRepository) GetRange(assetID uint, start, end time.Time) ([]models.PriceHistory, error) {
+	var entries []models.PriceHistory
+	err := r.db.Where("asset_id = ? AND timestamp >= ? AND timestamp <= ?", assetID, start, end).
+		Order("timestamp asc").
+		Find(&entries).Error
+	return entries, err
+}
+
+// LatestSources returns the provider of the newest fetched price of each
+// asset
+func (r *PriceHistoryRepository) LatestSources() (map[uint]string, error) {
+	var entries []models.PriceHistory
+	err := r.db.Raw(`SELECT asset_id, source FROM price_histories AS p
+		WHERE manual = ? AND timestamp = (
+			SELECT MAX(timestamp) FROM price_histories WHERE asset_id = p.asset_id AND manual = ?)`, false, false).
+		Scan(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+
+	sources := make(map[uint]string, len(entries))
+	for _, entry := range entries {
+		sources[entry.AssetID] = entry.Source
+	}
+	return sources, nil
+}
+
+// Prune applies retention rules relative to now, keeping the latest fetched
+// point of each asset per resolution bucket. Manual prices are never pruned.
+// It returns the number of deleted points.
+func (r *PriceHistoryRepository) Prune(rules []RetentionRule, now time.Time) (int64, error) {
+	if len(rules) == 0 {
+		return 0, nil
+	}
+
+	youngest := rules[0].OlderThan
+	for _, rule := range rules {
+		if rule.OlderThan < youngest {
+			youngest = rule.OlderThan
+		}
+	}
+
+	var entries []models.PriceHistory
+	err := r.db.Select("id", "asset_id", "timestamp").
+		Where("manual = ? AND timestamp < ?", false, now.Add(-youngest)).
+		Order("timestamp desc").
+		Find(&entries).Error
+	if err != nil {
+		return 0, err
+	}
+
+	type bucket struct {
+		assetID uint
+		rule    int
+		start   int64
+	}
+	kept := make(map[bucket]bool)
+	var toDelete []uint
+	for _, entry := range entries {
+		rule := applicableRule(rules, now.Sub(entry.Timestamp))
+		if rule < 0 {
+			continue
+		}
+		// Entries are newest first, so the first one seen in a bucket is kept
+		key := bucket{
+			assetID: entry.AssetID,
+			rule:    rule,
+			start:   entry.Timestamp.Truncate(rules[rule].Resolution).Unix(),
+		}
+		if kept[key] {
+			toDelete = append(toDelete, entry.ID)
+			continue
+		}
+		kept[key] = true
+	}
+
+	var deleted int64
+	for start := 0; start < len(toDelete); start += 500 {
+		end := start + 500
+		if end > len(toDelete) {
+			end = len(toDelete)
+		}
+		result := r.db.Delete(&models.PriceHistory{}, toDelete[start:end])
+		if result.Error != nil {
+			return deleted, result.Error
+		}
+		deleted += result.RowsAffected
+	}
+	return deleted, nil
+}
+
+// applicableRule returns the index of the rule with the longest OlderThan
+// that age exceeds, or -1 if none applies
+func applicableRule(rules []RetentionRule, age time.Duration) int {
+	match := -1
+	for i, rule := range rules {
+		if age > rule.OlderThan && (match < 0 || rule.OlderThan > rules[match].OlderThan) {
+			match = i
+		}
+	}
+	return match
+}
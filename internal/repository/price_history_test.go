@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bioharz/budget/internal/models"
+	"github.com/bioharz/budget/test/helpers"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPriceHistoryRepository_AppendAndQuery(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	repo := NewPriceHistoryRepositoryWithDB(db)
+
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	entries := []models.PriceHistory{
+		{AssetID: 1, PriceUSD: decimal.NewFromInt(40000), Source: "coingecko", Timestamp: base},
+		{AssetID: 1, PriceUSD: decimal.NewFromInt(41000), Source: "coingecko", Timestamp: base.Add(time.Hour)},
+		{AssetID: 1, PriceUSD: decimal.NewFromInt(42000), Source: "cryptocompare", Timestamp: base.Add(2 * time.Hour)},
+		{AssetID: 2, PriceUSD: decimal.NewFromInt(2000), Source: "coingecko", Timestamp: base.Add(time.Hour)},
+	}
+	require.NoError(t, repo.Append(entries))
+
+	// The same quote served again from cache is not stored twice
+	require.NoError(t, repo.Append(entries[:1]))
+	var count int64
+	require.NoError(t, db.Model(&models.PriceHistory{}).Count(&count).Error)
+	assert.Equal(t, int64(4), count)
+
+	at, err := repo.GetAt(1, base.Add(90*time.Minute))
+	require.NoError(t, err)
+	require.NotNil(t, at)
+	assert.Equal(t, "41000", at.PriceUSD.String())
+
+	at, err = repo.GetAt(1, base.Add(-time.Minute))
+	require.NoError(t, err)
+	assert.Nil(t, at)
+
+	history, err := repo.GetRange(1, base.Add(30*time.Minute), base.Add(3*time.Hour))
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, "41000", history[0].PriceUSD.String())
+	assert.Equal(t, "cryptocompare", history[1].Source)
+}
+
+func TestPriceHistoryRepository_LatestSources(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	repo := NewPriceHistoryRepositoryWithDB(db)
+
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, repo.Append([]models.PriceHistory{
+		{AssetID: 1, PriceUSD: decimal.NewFromInt(40000), Source: "coingecko", Timestamp: base},
+		{AssetID: 1, PriceUSD: decimal.NewFromInt(42000), Source: "cryptocompare", Timestamp: base.Add(time.Hour)},
+		{AssetID: 1, PriceUSD: decimal.NewFromInt(45000), Source: "manual", Manual: true, Timestamp: base.Add(2 * time.Hour)},
+		{AssetID: 2, PriceUSD: decimal.NewFromInt(190), Source: "yahoo-finance", Timestamp: base},
+	}))
+
+	sources, err := repo.LatestSources()
+	require.NoError(t, err)
+	assert.Equal(t, map[uint]string{1: "cryptocompare", 2: "yahoo-finance"}, sources)
+}
+
+func TestPriceHistoryRepository_Prune(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	repo := NewPriceHistoryRepositoryWithDB(db)
+
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	var entries []models.PriceHistory
+	add := func(age time.Duration, price int64, manual bool) {
+		entries = append(entries, models.PriceHistory{
+			AssetID:   1,
+			PriceUSD:  decimal.NewFromInt(price),
+			Source:    "coingecko",
+			Manual:    manual,
+			Timestamp: now.Add(-age),
+		})
+	}
+
+	// Recent points are all kept
+	add(time.Hour, 1, false)
+	add(time.Hour+5*time.Minute, 2, false)
+	// Three points in the same hour ten days ago collapse to the newest
+	add(10*24*time.Hour+10*time.Minute, 3, false)
+	add(10*24*time.Hour+20*time.Minute, 4, false)
+	add(10*24*time.Hour+30*time.Minute, 5, false)
+	// Two points on the same day half a year ago collapse to one
+	add(180*24*time.Hour+time.Hour, 6, false)
+	add(180*24*time.Hour+2*time.Hour, 7, false)
+	// Manual valuations survive regardless of age
+	add(180*24*time.Hour+3*time.Hour, 8, true)
+	require.NoError(t, repo.Append(entries))
+
+	deleted, err := repo.Prune(DefaultRetention, now)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), deleted)
+
+	history, err := repo.GetRange(1, now.Add(-365*24*time.Hour), now)
+	require.NoError(t, err)
+	var prices []string
+	for _, entry := range history {
+		prices = append(prices, entry.PriceUSD.String())
+	}
+	assert.Equal(t, []string{"8", "6", "3", "2", "1"}, prices)
+}
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/bioharz/budget/internal/db"
+	"github.com/bioharz/budget/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SettingRepository struct {
+	db *gorm.DB
+}
+
+func NewSettingRepository() *SettingRepository {
+	return &SettingRepository{db: db.DB}
+}
+
+func NewSettingRepositoryWithDB(database *gorm.DB) *SettingRepository {
+	return &SettingRepository{db: database}
+}
+
+// Get returns the value stored under key, or "" if it was never set
+func (r *SettingRepository) Get(key string) (string, error) {
+	var setting models.Setting
+	err := r.db.Where("key = ?", key).First(&setting).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return "", nil
+		}
+		return "", err
+	}
+	return setting.Value, nil
+}
+
+// Set stores value under key, replacing any previous value
+func (r *SettingRepository) Set(key, value string) error {
+	setting := models.Setting{Key: key, Value: value, UpdatedAt: time.Now()}
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"}),
+	}).Create(&setting).Error
+}
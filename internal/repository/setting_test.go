@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/bioharz/budget/test/helpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSettingRepository_GetAndSet(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	repo := NewSettingRepositoryWithDB(db)
+
+	value, err := repo.Get("base_currency")
+	require.NoError(t, err)
+	assert.Empty(t, value)
+
+	require.NoError(t, repo.Set("base_currency", "EUR"))
+	require.NoError(t, repo.Set("base_currency", "CHF"))
+
+	value, err = repo.Get("base_currency")
+	require.NoError(t, err)
+	assert.Equal(t, "CHF", value)
+}
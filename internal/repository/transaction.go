@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"github.com/bioharz/budget/internal/db"
+	"github.com/bioharz/budget/internal/models"
+	"gorm.io/gorm"
+)
+
+type TransactionRepository struct {
+	db *gorm.DB
+}
+
+func NewTransactionRepository() *TransactionRepository {
+	return &TransactionRepository{db: db.DB}
+}
+
+func NewTransactionRepositoryWithDB(database *gorm.DB) *TransactionRepository {
+	return &TransactionRepository{db: database}
+}
+
+func (r *TransactionRepository) Create(transaction *models.Transaction) error {
+	return r.db.Create(transaction).Error
+}
+
+func (r *TransactionRepository) GetByID(id uint) (models.Transaction, error) {
+	var transaction models.Transaction
+	err := r.db.Preload("Account").Preload("Asset").Preload("ToAccount").First(&transaction, id).Error
+	return transaction, err
+}
+
+// GetAll returns the ledger oldest first
+func (r *TransactionRepository) GetAll() ([]models.Transaction, error) {
+	var transactions []models.Transaction
+	err := r.db.Preload("Account").Preload("Asset").Preload("ToAccount").
+		Order("timestamp asc, id asc").
+		Find(&transactions).Error
+	return transactions, err
+}
+
+// GetByPosition returns every transaction that moves assetID in or out of
+// accountID, including transfers into it, oldest first
+func (r *TransactionRepository) GetByPosition(accountID, assetID uint) ([]models.Transaction, error) {
+	var transactions []models.Transaction
+	err := r.db.Preload("Account").Preload("Asset").Preload("ToAccount").
+		Where("asset_id = ? AND (account_id = ? OR to_account_id = ?)", assetID, accountID, accountID).
+		Order("timestamp asc, id asc").
+		Find(&transactions).Error
+	return transactions, err
+}
+
+// GetByAsset returns every transaction of an asset across accounts, oldest first
+func (r *TransactionRepository) GetByAsset(assetID uint) ([]models.Transaction, error) {
+	var transactions []models.Transaction
+	err := r.db.Preload("Account").Preload("Asset").Preload("ToAccount").
+		Where("asset_id = ?", assetID).
+		Order("timestamp asc, id asc").
+		Find(&transactions).Error
+	return transactions, err
+}
+
+// CountByPosition returns how many transactions touch a position
+func (r *TransactionRepository) CountByPosition(accountID, assetID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Transaction{}).
+		Where("asset_id = ? AND (account_id = ? OR to_account_id = ?)", assetID, accountID, accountID).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *TransactionRepository) Update(transaction *models.Transaction) error {
+	return r.db.Save(transaction).Error
+}
+
+// Purge removes a transaction for good
+func (r *TransactionRepository) Purge(id uint) error {
+	return r.db.Unscoped().Delete(&models.Transaction{ID: id}).Error
+}
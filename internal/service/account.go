@@ -0,0 +1,166 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bioharz/budget/internal/db"
+	"github.com/bioharz/budget/internal/models"
+	"github.com/bioharz/budget/internal/repository"
+	"gorm.io/gorm"
+)
+
+// AccountService renames, types, colours and merges accounts
+type AccountService struct {
+	db     *gorm.DB
+	ledger *LedgerService
+}
+
+func NewAccountService() *AccountService {
+	return &AccountService{db: db.DB, ledger: NewLedgerService()}
+}
+
+func NewAccountServiceWithDB(database *gorm.DB) *AccountService {
+	return &AccountService{db: database, ledger: NewLedgerServiceWithDB(database)}
+}
+
+// GetAll returns the accounts ordered by name
+func (s *AccountService) GetAll() ([]models.Account, error) {
+	var accounts []models.Account
+	err := s.db.Order("name").Find(&accounts).Error
+	return accounts, err
+}
+
+// Rename gives an account a new name that no other account has
+func (s *AccountService) Rename(id uint, name string) (models.Account, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return models.Account{}, fmt.Errorf("account name cannot be empty")
+	}
+
+	accountRepo := repository.NewAccountRepositoryWithDB(s.db)
+	account, err := accountRepo.GetByID(id)
+	if err != nil {
+		return account, err
+	}
+	existing, err := accountRepo.GetByName(name)
+	if err == nil && existing.ID != id {
+		return account, fmt.Errorf("account %q already exists, merge the accounts instead", name)
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return account, err
+	}
+
+	if account.Name == name {
+		return account, nil
+	}
+	account.Name = name
+	return account, accountRepo.Update(&account)
+}
+
+// SetType sets the type of an account to one of models.AccountTypes
+func (s *AccountService) SetType(id uint, accountType string) (models.Account, error) {
+	valid := false
+	for _, t := range models.AccountTypes {
+		if accountType == t {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return models.Account{}, fmt.Errorf("unknown account type %q", accountType)
+	}
+	return s.update(id, func(account *models.Account) { account.Type = accountType })
+}
+
+// SetColor sets the colour an account is shown in, empty for none
+func (s *AccountService) SetColor(id uint, color string) (models.Account, error) {
+	return s.update(id, func(account *models.Account) { account.Color = color })
+}
+
+func (s *AccountService) update(id uint, change func(*models.Account)) (models.Account, error) {
+	accountRepo := repository.NewAccountRepositoryWithDB(s.db)
+	account, err := accountRepo.GetByID(id)
+	if err != nil {
+		return account, err
+	}
+	change(&account)
+	return account, accountRepo.Update(&account)
+}
+
+// Merge transfers every balance of account sourceID to targetID and deletes
+// the source. The source keeps its ledger, so the transfers and the deletion
+// are audited like any other change and undo reverses them one by one.
+func (s *AccountService) Merge(sourceID, targetID uint) error {
+	if sourceID == targetID {
+		return fmt.Errorf("cannot merge an account into itself")
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		accountRepo := repository.NewAccountRepositoryWithDB(tx)
+		if _, err := accountRepo.GetByID(sourceID); err != nil {
+			return fmt.Errorf("failed to find account to merge: %w", err)
+		}
+		target, err := accountRepo.GetByID(targetID)
+		if err != nil {
+			return fmt.Errorf("failed to find account to merge into: %w", err)
+		}
+
+		assetIDs, err := mergedAssets(tx, sourceID)
+		if err != nil {
+			return err
+		}
+		for _, assetID := range assetIDs {
+			// Holdings from before the ledger need their opening balances to move
+			if err := s.ledger.ensureOpeningBalance(tx, sourceID, assetID); err != nil {
+				return err
+			}
+			position, err := s.ledger.position(tx, sourceID, assetID)
+			if err != nil {
+				return err
+			}
+			if !position.Amount.IsPositive() {
+				continue
+			}
+			_, err = s.ledger.record(tx, &models.Transaction{
+				Type:        models.TransactionTransfer,
+				AccountID:   sourceID,
+				ToAccountID: &targetID,
+				AssetID:     assetID,
+				Quantity:    position.Amount,
+				Note:        "Merged into " + target.Name,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return accountRepo.Delete(sourceID)
+	})
+}
+
+// mergedAssets returns the assets the source account holds or has moved
+func mergedAssets(tx *gorm.DB, sourceID uint) ([]uint, error) {
+	var fromLedger, fromHoldings []uint
+	err := tx.Model(&models.Transaction{}).
+		Where("account_id = ? OR to_account_id = ?", sourceID, sourceID).
+		Distinct().Pluck("asset_id", &fromLedger).Error
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Model(&models.Holding{}).Where("account_id = ?", sourceID).
+		Distinct().Pluck("asset_id", &fromHoldings).Error
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[uint]bool)
+	var assetIDs []uint
+	for _, assetID := range append(fromLedger, fromHoldings...) {
+		if !seen[assetID] {
+			seen[assetID] = true
+			assetIDs = append(assetIDs, assetID)
+		}
+	}
+	return assetIDs, nil
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bioharz/budget/internal/models"
+	"github.com/bioharz/budget/internal/repository"
+	"github.com/bioharz/budget/test/fixtures"
+	"github.com/bioharz/budget/test/helpers"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccountService_Edit(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	service := NewAccountServiceWithDB(db)
+	ledger := fixtures.NewAccount().WithName("ledger").WithType(models.AccountTypeUnknown).Create(t, db)
+	fixtures.NewAccount().WithName("Kraken").Create(t, db)
+
+	account, err := service.Rename(ledger.ID, "  Ledger Nano  ")
+	require.NoError(t, err)
+	assert.Equal(t, "Ledger Nano", account.Name)
+	_, err = service.Rename(ledger.ID, "Kraken")
+	assert.ErrorContains(t, err, "already exists")
+	_, err = service.Rename(ledger.ID, " ")
+	assert.Error(t, err)
+
+	account, err = service.SetType(ledger.ID, models.AccountTypeHardwareWallet)
+	require.NoError(t, err)
+	assert.Equal(t, models.AccountTypeHardwareWallet, account.Type)
+	_, err = service.SetType(ledger.ID, "piggy bank")
+	assert.ErrorContains(t, err, "unknown account type")
+
+	_, err = service.SetColor(ledger.ID, "4")
+	require.NoError(t, err)
+
+	accounts, err := service.GetAll()
+	require.NoError(t, err)
+	require.Len(t, accounts, 2)
+	assert.Equal(t, "Kraken", accounts[0].Name)
+	assert.Equal(t, "Ledger Nano", accounts[1].Name)
+	assert.Equal(t, "4", accounts[1].Color)
+
+	// Rejected changes leave no trace, the three that went through do
+	logs, err := repository.NewAuditLogRepository(db).GetByEntity(models.AuditEntityAccount, ledger.ID)
+	require.NoError(t, err)
+	require.Len(t, logs, 4)
+	assert.Equal(t, models.AuditActionCreate, logs[3].Action)
+	for _, log := range logs[:3] {
+		assert.Equal(t, models.AuditActionUpdate, log.Action)
+	}
+	assert.Contains(t, logs[2].OldValue, `"name":"ledger"`)
+	assert.Contains(t, logs[2].NewValue, `"name":"Ledger Nano"`)
+}
+
+func TestAccountService_Merge(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	service := NewAccountServiceWithDB(db)
+	ledger := NewLedgerServiceWithDB(db)
+	duplicate := fixtures.NewAccount().WithName("coinbase").Create(t, db)
+	target := fixtures.NewAccount().WithName("Coinbase").Create(t, db)
+	btc := fixtures.NewAsset().WithSymbol("BTC").Create(t, db)
+	eth := fixtures.NewAsset().WithSymbol("ETH").WithName("Ethereum").Create(t, db)
+	// A holding from before the ledger, without transactions
+	fixtures.NewHolding().WithAccount(duplicate).WithAsset(eth).WithAmount(2).WithPurchasePrice(2000).Create(t, db)
+
+	day := func(n int) time.Time { return time.Date(2024, 1, n, 0, 0, 0, 0, time.UTC) }
+	for _, transaction := range []*models.Transaction{
+		{Type: models.TransactionBuy, AccountID: target.ID, AssetID: btc.ID, Quantity: decimal.NewFromInt(1), PriceUSD: decimal.NewFromInt(30000), Timestamp: day(1)},
+		{Type: models.TransactionBuy, AccountID: duplicate.ID, AssetID: btc.ID, Quantity: decimal.NewFromInt(1), PriceUSD: decimal.NewFromInt(40000), Timestamp: day(2)},
+		{Type: models.TransactionTransfer, AccountID: target.ID, ToAccountID: &duplicate.ID, AssetID: btc.ID, Quantity: decimal.NewFromFloat(0.5), Timestamp: day(3)},
+	} {
+		_, err := ledger.Record(transaction)
+		require.NoError(t, err)
+	}
+
+	require.Error(t, service.Merge(target.ID, target.ID))
+	require.NoError(t, service.Merge(duplicate.ID, target.ID))
+
+	_, err := repository.NewAccountRepositoryWithDB(db).GetByID(duplicate.ID)
+	assert.Error(t, err, "the merged account is deleted")
+	logs, err := repository.NewAuditLogRepository(db).GetByEntity(models.AuditEntityAccount, duplicate.ID)
+	require.NoError(t, err)
+	require.Len(t, logs, 2)
+	assert.Equal(t, models.AuditActionDelete, logs[0].Action)
+
+	holdings, err := repository.NewHoldingRepositoryWithDB(db).GetAll()
+	require.NoError(t, err)
+	require.Len(t, holdings, 2)
+	amounts := make(map[string]string)
+	for _, holding := range holdings {
+		assert.Equal(t, target.ID, holding.AccountID)
+		amounts[holding.Asset.Symbol] = holding.Amount.String()
+	}
+	assert.Equal(t, map[string]string{"BTC": "2", "ETH": "2"}, amounts)
+
+	// The balances moved over as transfers, the source keeps its ledger
+	transactions, err := ledger.GetTransactions(duplicate.ID, btc.ID)
+	require.NoError(t, err)
+	require.Len(t, transactions, 3)
+	merged := transactions[2]
+	assert.Equal(t, models.TransactionTransfer, merged.Type)
+	assert.Equal(t, target.ID, *merged.ToAccountID)
+	assert.Equal(t, "1.5", merged.Quantity.String())
+	assert.Equal(t, "Merged into Coinbase", merged.Note)
+
+	position, err := ledger.GetPosition(target.ID, btc.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "35000", position.AverageCost.String())
+}
+
+func TestAccountService_MergeUndo(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	service := NewAccountServiceWithDB(db)
+	ledger := NewLedgerServiceWithDB(db)
+	source := fixtures.NewAccount().WithName("Ledger").Create(t, db)
+	target := fixtures.NewAccount().WithName("Kraken").Create(t, db)
+	btc := fixtures.NewAsset().WithSymbol("BTC").Create(t, db)
+	for _, account := range []*models.Account{source, target} {
+		_, err := ledger.Record(&models.Transaction{
+			Type: models.TransactionBuy, AccountID: account.ID, AssetID: btc.ID, Quantity: decimal.NewFromInt(1), PriceUSD: decimal.NewFromInt(30000),
+		})
+		require.NoError(t, err)
+	}
+
+	var before int64
+	require.NoError(t, db.Model(&models.AuditLog{}).Count(&before).Error)
+	require.NoError(t, service.Merge(source.ID, target.ID))
+	var after int64
+	require.NoError(t, db.Model(&models.AuditLog{}).Count(&after).Error)
+
+	// Every audited step of the merge is undone, newest first
+	undo := NewUndoServiceWithDB(db)
+	for range after - before {
+		_, err := undo.Undo()
+		require.NoError(t, err)
+	}
+
+	_, err := repository.NewAccountRepositoryWithDB(db).GetByID(source.ID)
+	require.NoError(t, err)
+	for _, account := range []*models.Account{source, target} {
+		position, err := ledger.GetPosition(account.ID, btc.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "1", position.Amount.String(), account.Name)
+	}
+}
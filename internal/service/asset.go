@@ -0,0 +1,97 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bioharz/budget/internal/db"
+	"github.com/bioharz/budget/internal/models"
+	"github.com/bioharz/budget/internal/repository"
+	"gorm.io/gorm"
+)
+
+// AssetService edits and deletes assets
+type AssetService struct {
+	db *gorm.DB
+}
+
+func NewAssetService() *AssetService {
+	return &AssetService{db: db.DB}
+}
+
+func NewAssetServiceWithDB(database *gorm.DB) *AssetService {
+	return &AssetService{db: database}
+}
+
+// GetAll returns the assets ordered by symbol
+func (s *AssetService) GetAll() ([]models.Asset, error) {
+	var assets []models.Asset
+	err := s.db.Order("symbol").Find(&assets).Error
+	return assets, err
+}
+
+// GetByID returns the asset with id
+func (s *AssetService) GetByID(id uint) (models.Asset, error) {
+	return repository.NewAssetRepositoryWithDB(s.db).GetByID(id)
+}
+
+// Update sets the name and type of an asset. A fetched price was looked up
+// for the old type, so it is dropped and the next update prices the asset
+// again; a manual price stays.
+func (s *AssetService) Update(id uint, name string, assetType models.AssetType) (models.Asset, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return models.Asset{}, fmt.Errorf("asset name cannot be empty")
+	}
+	valid := false
+	for _, t := range models.AssetTypes {
+		if assetType == t {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return models.Asset{}, fmt.Errorf("unknown asset type %q", assetType)
+	}
+
+	var asset models.Asset
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		assetRepo := repository.NewAssetRepositoryWithDB(tx)
+		var err error
+		if asset, err = assetRepo.GetByID(id); err != nil {
+			return err
+		}
+
+		if asset.Type != assetType {
+			err := tx.Where("asset_id = ? AND manual = ?", id, false).Delete(&models.PriceCache{}).Error
+			if err != nil {
+				return err
+			}
+		}
+		asset.Name = name
+		asset.Type = assetType
+		return assetRepo.Update(&asset)
+	})
+	return asset, err
+}
+
+// Delete moves an asset that no open holding refers to into the trash. Its
+// ledger stays until the asset is purged.
+func (s *AssetService) Delete(id uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		assetRepo := repository.NewAssetRepositoryWithDB(tx)
+		asset, err := assetRepo.GetByID(id)
+		if err != nil {
+			return err
+		}
+
+		var holdings int64
+		if err := tx.Model(&models.Holding{}).Where("asset_id = ?", id).Count(&holdings).Error; err != nil {
+			return err
+		}
+		if holdings > 0 {
+			return fmt.Errorf("%s is still held in %d holding(s)", asset.Symbol, holdings)
+		}
+		return assetRepo.Delete(id)
+	})
+}
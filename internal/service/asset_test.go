@@ -0,0 +1,84 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/bioharz/budget/internal/models"
+	"github.com/bioharz/budget/internal/repository"
+	"github.com/bioharz/budget/test/fixtures"
+	"github.com/bioharz/budget/test/helpers"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssetService_Update(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	service := NewAssetServiceWithDB(db)
+	cacheRepo := repository.NewPriceCacheRepositoryWithDB(db)
+	// Guessed as crypto and priced as a coin sharing the ticker
+	asset := fixtures.NewAsset().WithSymbol("VWCE").WithType(models.AssetTypeCrypto).Create(t, db)
+	require.NoError(t, cacheRepo.Upsert(asset.ID, decimal.NewFromFloat(0.01)))
+
+	updated, err := service.Update(asset.ID, " Vanguard FTSE All-World ", models.AssetTypeStock)
+	require.NoError(t, err)
+	assert.Equal(t, "Vanguard FTSE All-World", updated.Name)
+	assert.Equal(t, models.AssetTypeStock, updated.Type)
+	_, err = cacheRepo.GetByAssetID(asset.ID)
+	assert.Error(t, err, "the price fetched for the wrong type is dropped")
+
+	// A manual price does not depend on the type
+	require.NoError(t, cacheRepo.SetManual(asset.ID, decimal.NewFromInt(120), updated.UpdatedAt))
+	_, err = service.Update(asset.ID, updated.Name, models.AssetTypeOther)
+	require.NoError(t, err)
+	_, err = cacheRepo.GetByAssetID(asset.ID)
+	assert.NoError(t, err)
+
+	_, err = service.Update(asset.ID, "", models.AssetTypeStock)
+	assert.Error(t, err)
+	_, err = service.Update(asset.ID, "Vanguard", "bond")
+	assert.ErrorContains(t, err, "unknown asset type")
+}
+
+func TestAssetService_Delete(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	service := NewAssetServiceWithDB(db)
+	account := fixtures.NewAccount().Create(t, db)
+	held := fixtures.NewAsset().WithSymbol("BTC").Create(t, db)
+	fixtures.NewHolding().WithAccount(account).WithAsset(held).WithAmount(1).Create(t, db)
+	unused := fixtures.NewAsset().WithSymbol("DOGE").WithName("Dogecoin").Create(t, db)
+
+	assert.ErrorContains(t, service.Delete(held.ID), "still held")
+	require.NoError(t, service.Delete(unused.ID))
+
+	// An asset that was sold off goes to the trash with its ledger
+	ledger := NewLedgerServiceWithDB(db)
+	sold := fixtures.NewAsset().WithSymbol("ETH").WithName("Ethereum").Create(t, db)
+	holding, err := ledger.Record(&models.Transaction{
+		Type: models.TransactionBuy, AccountID: account.ID, AssetID: sold.ID, Quantity: decimal.NewFromInt(1), PriceUSD: decimal.NewFromInt(2000),
+	})
+	require.NoError(t, err)
+	require.NoError(t, ledger.CloseHolding(*holding))
+	require.NoError(t, service.Delete(sold.ID))
+	transactions, err := ledger.GetTransactions(account.ID, sold.ID)
+	require.NoError(t, err)
+	assert.Len(t, transactions, 2)
+
+	assets, err := service.GetAll()
+	require.NoError(t, err)
+	require.Len(t, assets, 1)
+	assert.Equal(t, "BTC", assets[0].Symbol)
+
+	// Adding the symbol again brings the deleted asset back
+	restored, err := NewHoldingServiceWithDB(db).GetOrCreateAsset("doge")
+	require.NoError(t, err)
+	assert.Equal(t, unused.ID, restored.ID)
+	assert.Equal(t, "Dogecoin", restored.Name)
+
+	logs, err := repository.NewAuditLogRepository(db).GetByEntity(models.AuditEntityAsset, unused.ID)
+	require.NoError(t, err)
+	require.Len(t, logs, 3)
+	assert.Equal(t, models.AuditActionCreate, logs[0].Action, "the restore is audited as a creation")
+	assert.Equal(t, models.AuditActionDelete, logs[1].Action)
+	assert.Contains(t, logs[1].OldValue, `"symbol":"DOGE"`)
+}
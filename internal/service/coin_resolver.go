@@ -0,0 +1,186 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bioharz/budget/internal/api"
+	"github.com/bioharz/budget/internal/models"
+	"github.com/bioharz/budget/internal/repository"
+	"gorm.io/gorm"
+)
+
+// coinListMaxAge is how long a stored coin list is trusted before symbols
+// missing from it trigger a refresh
+const coinListMaxAge = 24 * time.Hour
+
+// CoinListFetcher downloads the full list of coins from the price provider
+type CoinListFetcher interface {
+	GetCoinList() ([]api.Coin, error)
+}
+
+// CoinResolver maps crypto symbols to CoinGecko coin IDs. An explicit
+// Asset.ProviderID always wins; otherwise the stored coin list is consulted
+// and symbols shared by several coins fall back to the built-in mapping or
+// stay unresolved until the user picks one.
+type CoinResolver struct {
+	listingRepo *repository.CoinListingRepository
+	assetRepo   *repository.AssetRepository
+	fetcher     CoinListFetcher
+
+	mu          sync.Mutex
+	lastAttempt time.Time
+}
+
+func NewCoinResolver(fetcher CoinListFetcher) *CoinResolver {
+	return &CoinResolver{
+		listingRepo: repository.NewCoinListingRepository(),
+		assetRepo:   repository.NewAssetRepository(),
+		fetcher:     fetcher,
+	}
+}
+
+func NewCoinResolverWithDB(database *gorm.DB, fetcher CoinListFetcher) *CoinResolver {
+	return &CoinResolver{
+		listingRepo: repository.NewCoinListingRepositoryWithDB(database),
+		assetRepo:   repository.NewAssetRepositoryWithDB(database),
+		fetcher:     fetcher,
+	}
+}
+
+// ResolveIDs implements api.CoinIDResolver
+func (r *CoinResolver) ResolveIDs(symbols []string) map[string]string {
+	ids := make(map[string]string)
+	for _, symbol := range symbols {
+		symbol = strings.ToUpper(symbol)
+		if id, ok := r.resolve(symbol); ok {
+			ids[symbol] = id
+		}
+	}
+	return ids
+}
+
+func (r *CoinResolver) resolve(symbol string) (string, bool) {
+	if asset, err := r.assetRepo.GetBySymbol(symbol); err == nil && asset.ProviderID != "" {
+		return asset.ProviderID, true
+	}
+
+	candidates, err := r.Candidates(symbol)
+	if err != nil {
+		return "", false
+	}
+	return pickCoinID(symbol, candidates)
+}
+
+// pickCoinID chooses among the coins sharing a symbol. A single match is
+// unambiguous; with several, only the built-in mapping can break the tie.
+func pickCoinID(symbol string, candidates []models.CoinListing) (string, bool) {
+	if len(candidates) == 1 {
+		return candidates[0].CoinID, true
+	}
+	if known, ok := api.KnownCoinID(symbol); ok {
+		for _, candidate := range candidates {
+			if candidate.CoinID == known {
+				return known, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Candidates returns every coin listed under symbol, refreshing the stored
+// coin list first when the symbol is unknown and the list is stale
+func (r *CoinResolver) Candidates(symbol string) ([]models.CoinListing, error) {
+	candidates, err := r.listingRepo.GetBySymbol(symbol)
+	if err != nil || len(candidates) > 0 {
+		return candidates, err
+	}
+
+	if !r.listIsStale() {
+		return candidates, nil
+	}
+	if err := r.RefreshList(); err != nil {
+		return candidates, err
+	}
+	return r.listingRepo.GetBySymbol(symbol)
+}
+
+func (r *CoinResolver) listIsStale() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Don't retry a failed download on every price refresh
+	if time.Since(r.lastAttempt) < coinListMaxAge && !r.lastAttempt.IsZero() {
+		return false
+	}
+
+	lastUpdate, err := r.listingRepo.GetLastUpdateTime()
+	if err != nil {
+		return false
+	}
+	return lastUpdate == nil || time.Since(*lastUpdate) > coinListMaxAge
+}
+
+// RefreshList downloads the coin list and replaces the stored copy
+func (r *CoinResolver) RefreshList() error {
+	if r.fetcher == nil {
+		return fmt.Errorf("no coin list source configured")
+	}
+
+	r.mu.Lock()
+	r.lastAttempt = time.Now()
+	r.mu.Unlock()
+
+	coins, err := r.fetcher.GetCoinList()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	listings := make([]models.CoinListing, 0, len(coins))
+	seen := make(map[string]bool)
+	for _, coin := range coins {
+		if coin.ID == "" || coin.Symbol == "" || seen[coin.ID] {
+			continue
+		}
+		seen[coin.ID] = true
+		listings = append(listings, models.CoinListing{
+			CoinID:    coin.ID,
+			Symbol:    strings.ToUpper(coin.Symbol),
+			Name:      coin.Name,
+			UpdatedAt: now,
+		})
+	}
+	return r.listingRepo.ReplaceAll(listings)
+}
+
+// Unresolved returns the crypto assets whose symbol matches several coins
+// and that have no explicit provider ID to pick one
+func (r *CoinResolver) Unresolved(assets []models.Asset) []models.Asset {
+	var unresolved []models.Asset
+	for _, asset := range assets {
+		if asset.Type != models.AssetTypeCrypto || asset.ProviderID != "" {
+			continue
+		}
+		candidates, err := r.listingRepo.GetBySymbol(asset.Symbol)
+		if err != nil || len(candidates) < 2 {
+			continue
+		}
+		if _, ok := pickCoinID(asset.Symbol, candidates); !ok {
+			unresolved = append(unresolved, asset)
+		}
+	}
+	return unresolved
+}
+
+// SetOverride pins an asset to a specific provider coin ID
+func (r *CoinResolver) SetOverride(assetID uint, coinID string) error {
+	asset, err := r.assetRepo.GetByID(assetID)
+	if err != nil {
+		return err
+	}
+	asset.ProviderID = coinID
+	return r.assetRepo.Update(&asset)
+}
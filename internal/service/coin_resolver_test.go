@@ -0,0 +1,84 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bioharz/budget/internal/api"
+	"github.com/bioharz/budget/internal/models"
+	"github.com/bioharz/budget/test/fixtures"
+	"github.com/bioharz/budget/test/helpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubCoinList serves a fixed coin list and counts downloads
+type stubCoinList struct {
+	coins []api.Coin
+	err   error
+	calls int
+}
+
+func (s *stubCoinList) GetCoinList() ([]api.Coin, error) {
+	s.calls++
+	return s.coins, s.err
+}
+
+func TestCoinResolver_ResolveIDs(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	fetcher := &stubCoinList{coins: []api.Coin{
+		{ID: "bitcoin", Symbol: "btc", Name: "Bitcoin"},
+		{ID: "batcat", Symbol: "btc", Name: "BatCat"},
+		{ID: "chainlink", Symbol: "link", Name: "Chainlink"},
+		{ID: "arbitrum", Symbol: "arb", Name: "Arbitrum"},
+		{ID: "arbi-token", Symbol: "arb", Name: "Arbi Token"},
+	}}
+	resolver := NewCoinResolverWithDB(db, fetcher)
+
+	ids := resolver.ResolveIDs([]string{"link", "BTC", "ARB", "NOPE"})
+
+	// Unique symbols resolve directly, known ones win ties, others stay open
+	assert.Equal(t, map[string]string{
+		"LINK": "chainlink",
+		"BTC":  "bitcoin",
+	}, ids)
+	// The list is downloaded once and then served from the database
+	assert.Equal(t, 1, fetcher.calls)
+}
+
+func TestCoinResolver_Override(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	fetcher := &stubCoinList{coins: []api.Coin{
+		{ID: "arbitrum", Symbol: "arb", Name: "Arbitrum"},
+		{ID: "arbi-token", Symbol: "arb", Name: "Arbi Token"},
+	}}
+	resolver := NewCoinResolverWithDB(db, fetcher)
+	asset := fixtures.NewAsset().WithSymbol("ARB").WithName("Arbitrum").Create(t, db)
+
+	require.NoError(t, resolver.RefreshList())
+	unresolved := resolver.Unresolved([]models.Asset{*asset})
+	require.Len(t, unresolved, 1)
+	assert.Equal(t, "ARB", unresolved[0].Symbol)
+
+	candidates, err := resolver.Candidates("ARB")
+	require.NoError(t, err)
+	assert.Len(t, candidates, 2)
+
+	require.NoError(t, resolver.SetOverride(asset.ID, "arbitrum"))
+	assert.Equal(t, map[string]string{"ARB": "arbitrum"}, resolver.ResolveIDs([]string{"ARB"}))
+
+	updated, err := resolver.assetRepo.GetByID(asset.ID)
+	require.NoError(t, err)
+	assert.Empty(t, resolver.Unresolved([]models.Asset{updated}))
+}
+
+func TestCoinResolver_FailedDownloadIsThrottled(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	fetcher := &stubCoinList{err: errors.New("rate limited")}
+	resolver := NewCoinResolverWithDB(db, fetcher)
+
+	// Nothing resolves without a list and the download is not retried per fetch
+	assert.Empty(t, resolver.ResolveIDs([]string{"LINK"}))
+	assert.Empty(t, resolver.ResolveIDs([]string{"LINK"}))
+	assert.Equal(t, 1, fetcher.calls)
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/bioharz/budget/internal/currency"
+	"github.com/shopspring/decimal"
+)
+
+// Settings keys of the base currency and its last known USD rate
+const (
+	SettingBaseCurrency = "base_currency"
+	settingBaseRate     = "base_currency_rate"
+)
+
+// BaseCurrency returns the currency values are shown in, USD by default
+func (s *PriceService) BaseCurrency() (currency.Currency, error) {
+	if s.settingRepo == nil {
+		return currency.USD, nil
+	}
+	code, err := s.settingRepo.Get(SettingBaseCurrency)
+	if err != nil || code == "" {
+		return currency.USD, err
+	}
+	return currency.Lookup(code)
+}
+
+// SetBaseCurrency stores the currency values are shown in
+func (s *PriceService) SetBaseCurrency(code string) (currency.Currency, error) {
+	c, err := currency.Lookup(code)
+	if err != nil {
+		return c, err
+	}
+	if s.settingRepo == nil {
+		return c, fmt.Errorf("no settings storage configured")
+	}
+	if err := s.settingRepo.Set(SettingBaseCurrency, c.Code); err != nil {
+		return c, err
+	}
+	// The stored rate belongs to the previous currency
+	return c, s.settingRepo.Set(settingBaseRate, "")
+}
+
+// Converter returns a converter into the base currency
+func (s *PriceService) Converter() (currency.Converter, error) {
+	c, err := s.BaseCurrency()
+	if err != nil {
+		return currency.Identity, err
+	}
+	return s.ConverterFor(c)
+}
+
+// ConverterFor returns a converter into c using the fiat rates, or the crypto
+// price for BTC. When the rate cannot be fetched the last one stored is used.
+func (s *PriceService) ConverterFor(c currency.Currency) (currency.Converter, error) {
+	if c.Code == currency.USD.Code {
+		return currency.Identity, nil
+	}
+
+	var rates map[string]float64
+	var err error
+	if c.Crypto {
+		rates, err = s.client.GetCryptoPrices([]string{c.Code})
+	} else {
+		rates, err = s.client.GetFiatRates([]string{c.Code})
+	}
+
+	if rate := decimal.NewFromFloat(rates[c.Code]); rate.IsPositive() {
+		if base, _ := s.BaseCurrency(); base.Code == c.Code && s.settingRepo != nil {
+			_ = s.settingRepo.Set(settingBaseRate, rate.String())
+		}
+		return currency.Converter{Currency: c, USDPerUnit: rate}, nil
+	}
+
+	if base, _ := s.BaseCurrency(); base.Code == c.Code && s.settingRepo != nil {
+		if stored, _ := s.settingRepo.Get(settingBaseRate); stored != "" {
+			if rate, parseErr := decimal.NewFromString(stored); parseErr == nil && rate.IsPositive() {
+				return currency.Converter{Currency: c, USDPerUnit: rate}, nil
+			}
+		}
+	}
+
+	if err == nil {
+		err = fmt.Errorf("no exchange rate available")
+	}
+	return currency.Identity, fmt.Errorf("failed to get %s rate: %w", c.Code, err)
+}
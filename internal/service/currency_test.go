@@ -0,0 +1,73 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bioharz/budget/internal/api"
+	"github.com/bioharz/budget/internal/currency"
+	"github.com/bioharz/budget/internal/models"
+	"github.com/bioharz/budget/internal/repository"
+	"github.com/bioharz/budget/test/fixtures"
+	"github.com/bioharz/budget/test/helpers"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPriceService_BaseCurrency(t *testing.T) {
+	online := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !online {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"rates":{"USD":1,"EUR":0.8}}`))
+	}))
+	defer server.Close()
+
+	db := helpers.SetupTestDB(t)
+	service := NewPriceServiceWithDB(db)
+	service.client = api.NewPriceClientWithConfig(api.Config{ExchangeRateURL: server.URL})
+
+	base, err := service.BaseCurrency()
+	require.NoError(t, err)
+	assert.Equal(t, currency.USD, base)
+
+	_, err = service.SetBaseCurrency("JPY")
+	assert.Error(t, err)
+	_, err = service.SetBaseCurrency("eur")
+	require.NoError(t, err)
+
+	converter, err := service.Converter()
+	require.NoError(t, err)
+	assert.Equal(t, "EUR", converter.Code)
+	assert.Equal(t, "1.25", converter.USDPerUnit.String())
+
+	// Offline, the last fetched rate is used
+	online = false
+	service.client = api.NewPriceClientWithConfig(api.Config{ExchangeRateURL: server.URL})
+	converter, err = service.Converter()
+	require.NoError(t, err)
+	assert.Equal(t, "1.25", converter.USDPerUnit.String())
+
+	// Snapshots keep the total in the base currency alongside USD
+	account := fixtures.NewAccount().Create(t, db)
+	house := fixtures.NewAsset().WithSymbol("HOUSE").WithType(models.AssetTypeOther).Create(t, db)
+	fixtures.NewHolding().WithAccount(account).WithAsset(house).WithAmount(1).Create(t, db)
+	require.NoError(t, repository.NewPriceCacheRepositoryWithDB(db).Upsert(house.ID, decimal.NewFromInt(1000)))
+	service.recordSnapshot(map[uint]decimal.Decimal{house.ID: decimal.NewFromInt(1000)})
+
+	latest, err := repository.NewPortfolioSnapshotRepositoryWithDB(db).GetLatest()
+	require.NoError(t, err)
+	assert.Equal(t, "1000", latest.TotalValueUSD.String())
+	assert.Equal(t, "EUR", latest.Currency)
+	assert.Equal(t, "800", latest.TotalValue.String())
+
+	// Switching currency forgets the old rate
+	_, err = service.SetBaseCurrency("GBP")
+	require.NoError(t, err)
+	_, err = service.Converter()
+	assert.Error(t, err)
+}
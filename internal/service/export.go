@@ -0,0 +1,171 @@
+package service
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/bioharz/budget/internal/currency"
+	"github.com/bioharz/budget/internal/db"
+	"github.com/bioharz/budget/internal/export"
+	"github.com/bioharz/budget/internal/repository"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// exportPricePlaces keeps sub-cent token prices and satoshi-level BTC
+// prices readable in exports
+const exportPricePlaces = 8
+
+type ExportService struct {
+	accountRepo *repository.AccountRepository
+	assetRepo   *repository.AssetRepository
+	holdingRepo *repository.HoldingRepository
+	cacheRepo   *repository.PriceCacheRepository
+	auditRepo   *repository.AuditLogRepository
+}
+
+func NewExportService() *ExportService {
+	return &ExportService{
+		accountRepo: repository.NewAccountRepository(),
+		assetRepo:   repository.NewAssetRepository(),
+		holdingRepo: repository.NewHoldingRepository(),
+		cacheRepo:   repository.NewPriceCacheRepository(),
+		auditRepo:   repository.NewAuditLogRepository(db.DB),
+	}
+}
+
+func NewExportServiceWithDB(database *gorm.DB) *ExportService {
+	return &ExportService{
+		accountRepo: repository.NewAccountRepositoryWithDB(database),
+		assetRepo:   repository.NewAssetRepositoryWithDB(database),
+		holdingRepo: repository.NewHoldingRepositoryWithDB(database),
+		cacheRepo:   repository.NewPriceCacheRepositoryWithDB(database),
+		auditRepo:   repository.NewAuditLogRepository(database),
+	}
+}
+
+// Portfolio values the holdings at the cached prices in the converter's
+// currency, optionally with the audit log oldest first
+func (s *ExportService) Portfolio(converter currency.Converter, withAudit bool) (*export.Portfolio, error) {
+	accounts, err := s.accountRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	assets, err := s.assetRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	holdings, err := s.holdingRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	prices, err := s.cacheRepo.GetPricesMap()
+	if err != nil {
+		return nil, err
+	}
+	manual, err := s.cacheRepo.GetManual()
+	if err != nil {
+		return nil, err
+	}
+	updated, err := s.cacheRepo.GetLastUpdateTime()
+	if err != nil {
+		return nil, err
+	}
+
+	places := int32(converter.Decimals)
+	price := func(usd decimal.Decimal) decimal.Decimal { return converter.FromUSD(usd).Round(exportPricePlaces) }
+	value := func(usd decimal.Decimal) decimal.Decimal { return converter.FromUSD(usd).Round(places) }
+
+	p := &export.Portfolio{
+		GeneratedAt:     time.Now(),
+		Currency:        converter.Code,
+		PricesUpdatedAt: updated,
+		Total:           value(CalculateTotal(holdings, prices)),
+		Accounts:        []export.Account{},
+		Assets:          []export.Asset{},
+		Holdings:        []export.Holding{},
+	}
+
+	accountValues := make(map[uint]decimal.Decimal)
+	assetAmounts := make(map[uint]decimal.Decimal)
+	for _, holding := range holdings {
+		usd := holding.Amount.Mul(prices[holding.AssetID])
+		accountValues[holding.AccountID] = accountValues[holding.AccountID].Add(usd)
+		assetAmounts[holding.AssetID] = assetAmounts[holding.AssetID].Add(holding.Amount)
+
+		p.Holdings = append(p.Holdings, export.Holding{
+			ID:               holding.ID,
+			Account:          holding.Account.Name,
+			Asset:            holding.Asset.Symbol,
+			Amount:           holding.Amount,
+			PurchasePriceUSD: holding.PurchasePrice,
+			Price:            price(prices[holding.AssetID]),
+			Value:            value(usd),
+		})
+	}
+	sort.SliceStable(p.Holdings, func(i, j int) bool {
+		if p.Holdings[i].Account != p.Holdings[j].Account {
+			return p.Holdings[i].Account < p.Holdings[j].Account
+		}
+		return p.Holdings[i].Asset < p.Holdings[j].Asset
+	})
+
+	for _, account := range accounts {
+		p.Accounts = append(p.Accounts, export.Account{
+			ID:    account.ID,
+			Name:  account.Name,
+			Type:  account.Type,
+			Color: account.Color,
+			Value: value(accountValues[account.ID]),
+		})
+	}
+
+	for _, asset := range assets {
+		_, isManual := manual[asset.ID]
+		p.Assets = append(p.Assets, export.Asset{
+			ID:          asset.ID,
+			Symbol:      asset.Symbol,
+			Name:        asset.Name,
+			Type:        string(asset.Type),
+			Amount:      assetAmounts[asset.ID],
+			Price:       price(prices[asset.ID]),
+			ManualPrice: isManual,
+			Value:       value(assetAmounts[asset.ID].Mul(prices[asset.ID])),
+		})
+	}
+
+	if withAudit {
+		logs, err := s.auditRepo.GetAll(0)
+		if err != nil {
+			return nil, err
+		}
+		for i := len(logs) - 1; i >= 0; i-- {
+			log := logs[i]
+			p.AuditLog = append(p.AuditLog, export.AuditEntry{
+				ID:         log.ID,
+				Time:       log.CreatedAt,
+				Action:     string(log.Action),
+				EntityType: string(log.EntityType),
+				EntityID:   log.EntityID,
+				OldValue:   rawJSON(log.OldValue),
+				NewValue:   rawJSON(log.NewValue),
+				Note:       log.UserNote,
+			})
+		}
+	}
+	return p, nil
+}
+
+// rawJSON embeds a stored JSON document as is, or as a string if it is not
+// valid JSON
+func rawJSON(s string) json.RawMessage {
+	if s == "" {
+		return nil
+	}
+	if json.Valid([]byte(s)) {
+		return json.RawMessage(s)
+	}
+	quoted, _ := json.Marshal(s)
+	return quoted
+}
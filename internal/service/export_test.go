@@ -0,0 +1,88 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bioharz/budget/internal/currency"
+	"github.com/bioharz/budget/internal/repository"
+	"github.com/bioharz/budget/test/fixtures"
+	"github.com/bioharz/budget/test/helpers"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportService_Portfolio(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	holdingService := NewHoldingServiceWithDB(db)
+	_, err := holdingService.AddHolding("Ledger", "BTC", decimal.RequireFromString("0.5"), decimal.NewFromInt(40000))
+	require.NoError(t, err)
+	_, err = holdingService.AddHolding("Exchange", "BTC", decimal.RequireFromString("0.25"), decimal.Zero)
+	require.NoError(t, err)
+	_, err = holdingService.AddHolding("Exchange", "HOUSE", decimal.NewFromInt(1), decimal.Zero)
+	require.NoError(t, err)
+	fixtures.NewAccount().WithName("Empty").Create(t, db)
+
+	assets := repository.NewAssetRepositoryWithDB(db)
+	btc, err := assets.GetBySymbol("BTC")
+	require.NoError(t, err)
+	house, err := assets.GetBySymbol("HOUSE")
+	require.NoError(t, err)
+	cache := repository.NewPriceCacheRepositoryWithDB(db)
+	require.NoError(t, cache.Upsert(btc.ID, decimal.NewFromInt(60000)))
+	require.NoError(t, cache.SetManual(house.ID, decimal.NewFromInt(350000), time.Now()))
+
+	service := NewExportServiceWithDB(db)
+	eur := currency.Converter{Currency: currency.EUR, USDPerUnit: decimal.RequireFromString("1.2")}
+	p, err := service.Portfolio(eur, false)
+	require.NoError(t, err)
+
+	assert.Equal(t, "EUR", p.Currency)
+	assert.Equal(t, "329166.67", p.Total.String())
+	assert.NotNil(t, p.PricesUpdatedAt)
+	assert.Nil(t, p.AuditLog)
+
+	// Holdings are sorted by account, then asset
+	require.Len(t, p.Holdings, 3)
+	assert.Equal(t, "Exchange", p.Holdings[0].Account)
+	assert.Equal(t, "BTC", p.Holdings[0].Asset)
+	assert.Equal(t, "50000", p.Holdings[0].Price.String())
+	assert.Equal(t, "12500", p.Holdings[0].Value.String())
+	assert.Equal(t, "Ledger", p.Holdings[2].Account)
+	assert.Equal(t, "40000", p.Holdings[2].PurchasePriceUSD.String())
+
+	values := make(map[string]string)
+	for _, account := range p.Accounts {
+		values[account.Name] = account.Value.String()
+	}
+	assert.Equal(t, map[string]string{"Ledger": "25000", "Exchange": "304166.67", "Empty": "0"}, values)
+
+	for _, asset := range p.Assets {
+		switch asset.Symbol {
+		case "BTC":
+			assert.Equal(t, "0.75", asset.Amount.String())
+			assert.False(t, asset.ManualPrice)
+		case "HOUSE":
+			assert.True(t, asset.ManualPrice)
+		}
+	}
+
+	// The audit log comes oldest first
+	p, err = service.Portfolio(currency.Identity, true)
+	require.NoError(t, err)
+	require.NotEmpty(t, p.AuditLog)
+	assert.Equal(t, "CREATE", p.AuditLog[0].Action)
+	assert.Equal(t, "ACCOUNT", p.AuditLog[0].EntityType)
+	var holdingEntries []string
+	for _, entry := range p.AuditLog {
+		if entry.EntityType == "HOLDING" {
+			holdingEntries = append(holdingEntries, string(entry.NewValue))
+		}
+	}
+	require.NotEmpty(t, holdingEntries)
+	assert.Contains(t, holdingEntries[0], `"amount":"0.5"`)
+	for i := 1; i < len(p.AuditLog); i++ {
+		assert.False(t, p.AuditLog[i].Time.Before(p.AuditLog[i-1].Time))
+	}
+}
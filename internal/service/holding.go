@@ -0,0 +1,176 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bioharz/budget/internal/models"
+	"github.com/bioharz/budget/internal/repository"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+type HoldingService struct {
+	accountRepo *repository.AccountRepository
+	assetRepo   *repository.AssetRepository
+	holdingRepo *repository.HoldingRepository
+	ledger      *LedgerService
+}
+
+func NewHoldingService() *HoldingService {
+	return &HoldingService{
+		accountRepo: repository.NewAccountRepository(),
+		assetRepo:   repository.NewAssetRepository(),
+		holdingRepo: repository.NewHoldingRepository(),
+		ledger:      NewLedgerService(),
+	}
+}
+
+func NewHoldingServiceWithDB(database *gorm.DB) *HoldingService {
+	return &HoldingService{
+		accountRepo: repository.NewAccountRepositoryWithDB(database),
+		assetRepo:   repository.NewAssetRepositoryWithDB(database),
+		holdingRepo: repository.NewHoldingRepositoryWithDB(database),
+		ledger:      NewLedgerServiceWithDB(database),
+	}
+}
+
+// AddHolding adds amount of an asset to an account through the ledger,
+// creating the account and asset on first use. With a purchase price it is
+// recorded as a buy, otherwise as a deposit.
+func (s *HoldingService) AddHolding(accountName, symbol string, amount, purchasePrice decimal.Decimal) (*models.Holding, error) {
+	accountName = strings.TrimSpace(accountName)
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+
+	if accountName == "" || symbol == "" {
+		return nil, fmt.Errorf("account and asset are required")
+	}
+	if !amount.IsPositive() {
+		return nil, fmt.Errorf("amount must be positive")
+	}
+	if purchasePrice.IsNegative() {
+		return nil, fmt.Errorf("purchase price cannot be negative")
+	}
+
+	account, err := s.getOrCreateAccount(accountName)
+	if err != nil {
+		return nil, err
+	}
+
+	asset, err := s.getOrCreateAsset(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	transaction := &models.Transaction{
+		Type:      models.TransactionDeposit,
+		AccountID: account.ID,
+		AssetID:   asset.ID,
+		Quantity:  amount,
+		PriceUSD:  purchasePrice,
+		Timestamp: time.Now(),
+	}
+	if purchasePrice.IsPositive() {
+		transaction.Type = models.TransactionBuy
+	}
+
+	holding, err := s.ledger.Record(transaction)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record holding: %w", err)
+	}
+	return holding, nil
+}
+
+// GetOrCreateAccount returns the account with name, creating it on first use
+func (s *HoldingService) GetOrCreateAccount(name string) (models.Account, error) {
+	return s.getOrCreateAccount(strings.TrimSpace(name))
+}
+
+// GetOrCreateAsset returns the asset with symbol, creating it on first use
+func (s *HoldingService) GetOrCreateAsset(symbol string) (models.Asset, error) {
+	return s.getOrCreateAsset(strings.ToUpper(strings.TrimSpace(symbol)))
+}
+
+// GetHoldings returns all holdings with their account and asset loaded
+func (s *HoldingService) GetHoldings() ([]models.Holding, error) {
+	return s.holdingRepo.GetAll()
+}
+
+func (s *HoldingService) getOrCreateAccount(name string) (models.Account, error) {
+	account, err := s.accountRepo.GetByName(name)
+	if err == gorm.ErrRecordNotFound {
+		account = models.Account{
+			Name: name,
+			Type: models.AccountTypeUnknown,
+		}
+		if err := s.accountRepo.Create(&account); err != nil {
+			return account, fmt.Errorf("failed to create account: %w", err)
+		}
+		return account, nil
+	}
+	return account, err
+}
+
+func (s *HoldingService) getOrCreateAsset(symbol string) (models.Asset, error) {
+	asset, err := s.assetRepo.GetBySymbol(symbol)
+	if err == gorm.ErrRecordNotFound {
+		// A deleted asset keeps its symbol, so it comes back rather than
+		// being created again
+		if deleted, err := s.assetRepo.GetDeletedBySymbol(symbol); err == nil {
+			if err := s.assetRepo.Restore(deleted.ID); err != nil {
+				return deleted, fmt.Errorf("failed to restore asset: %w", err)
+			}
+			deleted.DeletedAt = gorm.DeletedAt{}
+			return deleted, nil
+		}
+		asset = models.Asset{
+			Symbol: symbol,
+			Name:   symbol,
+			Type:   GuessAssetType(symbol),
+		}
+		if err := s.assetRepo.Create(&asset); err != nil {
+			return asset, fmt.Errorf("failed to create asset: %w", err)
+		}
+		return asset, nil
+	}
+	return asset, err
+}
+
+// GuessAssetType infers the asset type from a ticker symbol
+func GuessAssetType(symbol string) models.AssetType {
+	symbol = strings.ToUpper(symbol)
+
+	// Common fiat currencies
+	fiatSymbols := []string{"USD", "EUR", "GBP", "JPY", "CHF", "CAD", "AUD", "NZD", "AED"}
+	for _, fiat := range fiatSymbols {
+		if symbol == fiat {
+			return models.AssetTypeFiat
+		}
+	}
+
+	// Common crypto currencies
+	cryptoSymbols := []string{"BTC", "ETH", "USDT", "USDC", "BNB", "XRP", "SOL", "ADA"}
+	for _, crypto := range cryptoSymbols {
+		if symbol == crypto {
+			return models.AssetTypeCrypto
+		}
+	}
+
+	// Exchange suffixes such as VOD.L only appear on stock tickers
+	if strings.Contains(symbol, ".") {
+		return models.AssetTypeStock
+	}
+
+	// Default to crypto for unknown symbols
+	return models.AssetTypeCrypto
+}
+
+// CalculateTotal sums the USD value of holdings at the given prices
+func CalculateTotal(holdings []models.Holding, prices map[uint]decimal.Decimal) decimal.Decimal {
+	total := decimal.Zero
+	for _, holding := range holdings {
+		total = total.Add(holding.Amount.Mul(prices[holding.AssetID]))
+	}
+	return total
+}
@@ -0,0 +1,275 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bioharz/budget/internal/db"
+	"github.com/bioharz/budget/internal/importer"
+	"github.com/bioharz/budget/internal/models"
+	"github.com/bioharz/budget/internal/repository"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// ImportAction is what applying an import row does to the holdings
+type ImportAction string
+
+const (
+	ImportCreate    ImportAction = "create"    // Opens a new holding
+	ImportUpdate    ImportAction = "update"    // Changes an existing holding
+	ImportDuplicate ImportAction = "duplicate" // Already recorded, skipped
+)
+
+// PlannedRow is an import row with the action applying it would take
+type PlannedRow struct {
+	importer.Row
+	Action ImportAction
+}
+
+// ErrUnpricedRows refuses an import with rows whose price is missing until
+// the plan allows them
+var ErrUnpricedRows = errors.New("rows traded against a currency other than USD have no price")
+
+// ImportPlan is the preview of an import, applied as a whole or not at all
+type ImportPlan struct {
+	Source string // File name, recorded in the audit log
+	Rows   []PlannedRow
+
+	// AllowUnpriced confirms that unpriced rows are recorded without a cost
+	AllowUnpriced bool
+}
+
+// Count returns how many rows take action
+func (p *ImportPlan) Count(action ImportAction) int {
+	count := 0
+	for _, row := range p.Rows {
+		if row.Action == action {
+			count++
+		}
+	}
+	return count
+}
+
+// Unpriced returns how many rows to record have no known price
+func (p *ImportPlan) Unpriced() int {
+	count := 0
+	for _, row := range p.Rows {
+		if row.Unpriced && row.Action != ImportDuplicate {
+			count++
+		}
+	}
+	return count
+}
+
+// ImportService turns imported rows into ledger entries. Balance rows set a
+// holding to the imported amount, transaction rows are recorded as they are.
+type ImportService struct {
+	db *gorm.DB
+}
+
+func NewImportService() *ImportService {
+	return &ImportService{db: db.DB}
+}
+
+func NewImportServiceWithDB(database *gorm.DB) *ImportService {
+	return &ImportService{db: database}
+}
+
+// plannedPosition tracks a holding while rows are planned, so later rows of
+// the same position see the effect of earlier ones
+type plannedPosition struct {
+	open   bool
+	amount decimal.Decimal
+	price  decimal.Decimal
+}
+
+// Plan decides for every row whether it creates or updates a holding, or
+// duplicates what is already recorded. Nothing is written.
+func (s *ImportService) Plan(source string, rows []importer.Row) (*ImportPlan, error) {
+	plan := &ImportPlan{Source: source}
+	positions := make(map[string]*plannedPosition)
+
+	for _, row := range rows {
+		key := row.Account + "\x00" + row.Asset
+		position, ok := positions[key]
+		if !ok {
+			holding, err := s.findHolding(s.db, row.Account, row.Asset)
+			if err != nil {
+				return nil, err
+			}
+			position = &plannedPosition{}
+			if holding != nil {
+				position = &plannedPosition{open: true, amount: holding.Amount, price: holding.PurchasePrice}
+			}
+			positions[key] = position
+		}
+
+		action := ImportCreate
+		if position.open {
+			action = ImportUpdate
+		}
+
+		if row.IsTransaction() {
+			duplicate, err := s.isRecorded(row)
+			if err != nil {
+				return nil, err
+			}
+			if duplicate {
+				action = ImportDuplicate
+			} else if row.Type.IsInflow() {
+				position.open = true
+			}
+		} else {
+			samePrice := row.Price.IsZero() ||
+				position.price.Round(costPlaces).Equal(row.Price.Round(costPlaces))
+			if position.open && position.amount.Equal(row.Amount) && samePrice {
+				action = ImportDuplicate
+			}
+			position.open = true
+			position.amount = row.Amount
+			if !row.Price.IsZero() {
+				position.price = row.Price
+			}
+		}
+
+		plan.Rows = append(plan.Rows, PlannedRow{Row: row, Action: action})
+	}
+	return plan, nil
+}
+
+// Apply records every row of the plan that is not a duplicate, creating
+// accounts and assets on first use. Each change is audited with the file and
+// line it came from. A failing row rolls back the whole import. Unpriced
+// rows are refused unless the plan allows them.
+func (s *ImportService) Apply(plan *ImportPlan) error {
+	if unpriced := plan.Unpriced(); unpriced > 0 && !plan.AllowUnpriced {
+		return fmt.Errorf("%w: %d row(s)", ErrUnpricedRows, unpriced)
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		for _, row := range plan.Rows {
+			if row.Action == ImportDuplicate {
+				continue
+			}
+			noted := models.WithAuditNote(tx, fmt.Sprintf("Imported from %s line %d", plan.Source, row.Line))
+			if err := s.applyRow(noted, NewHoldingServiceWithDB(noted), NewLedgerServiceWithDB(noted), row.Row); err != nil {
+				return fmt.Errorf("line %d: %w", row.Line, err)
+			}
+		}
+		return nil
+	})
+}
+
+func (s *ImportService) applyRow(tx *gorm.DB, holdingService *HoldingService, ledger *LedgerService, row importer.Row) error {
+	account, err := holdingService.GetOrCreateAccount(row.Account)
+	if err != nil {
+		return err
+	}
+	asset, err := holdingService.GetOrCreateAsset(row.Asset)
+	if err != nil {
+		return err
+	}
+
+	note := row.Note
+	if note == "" {
+		note = "Imported"
+	}
+	transaction := &models.Transaction{
+		Type:      row.Type,
+		AccountID: account.ID,
+		AssetID:   asset.ID,
+		Quantity:  row.Amount,
+		PriceUSD:  row.Price,
+		Fee:       row.Fee,
+		Note:      note,
+		Timestamp: row.Date,
+	}
+
+	if !row.IsTransaction() {
+		holding, err := s.findHolding(tx, row.Account, row.Asset)
+		if err != nil {
+			return err
+		}
+		if holding != nil {
+			price := row.Price
+			if price.IsZero() {
+				price = holding.PurchasePrice
+			}
+			_, err := ledger.AdjustHolding(*holding, account.ID, asset.ID, row.Amount, price)
+			return err
+		}
+
+		transaction.Type = models.TransactionDeposit
+		if row.Price.IsPositive() {
+			transaction.Type = models.TransactionBuy
+		}
+	}
+
+	_, err = ledger.Record(transaction)
+	return err
+}
+
+// findHolding returns the open holding of an asset in an account, nil if
+// there is none
+func (s *ImportService) findHolding(database *gorm.DB, accountName, symbol string) (*models.Holding, error) {
+	account, asset, err := s.findPosition(database, accountName, symbol)
+	if err != nil || account == nil || asset == nil {
+		return nil, err
+	}
+
+	holdings, err := repository.NewHoldingRepositoryWithDB(database).GetByPosition(account.ID, asset.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, holding := range holdings {
+		if !holding.DeletedAt.Valid {
+			return &holding, nil
+		}
+	}
+	return nil, nil
+}
+
+// isRecorded reports whether the ledger already has a transaction like row.
+// Rows without a date match regardless of when the transaction happened.
+func (s *ImportService) isRecorded(row importer.Row) (bool, error) {
+	account, asset, err := s.findPosition(s.db, row.Account, row.Asset)
+	if err != nil || account == nil || asset == nil {
+		return false, err
+	}
+
+	transactions, err := repository.NewTransactionRepositoryWithDB(s.db).GetByPosition(account.ID, asset.ID)
+	if err != nil {
+		return false, err
+	}
+	for _, t := range transactions {
+		if t.Type != row.Type || t.AccountID != account.ID ||
+			!t.Quantity.Equal(row.Amount) || !t.PriceUSD.Equal(row.Price) || !t.Fee.Equal(row.Fee) {
+			continue
+		}
+		if row.Date.IsZero() || t.Timestamp.Equal(row.Date) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// findPosition looks up an account and asset without creating them; either
+// is nil when it does not exist yet
+func (s *ImportService) findPosition(database *gorm.DB, accountName, symbol string) (*models.Account, *models.Asset, error) {
+	account, err := repository.NewAccountRepositoryWithDB(database).GetByName(strings.TrimSpace(accountName))
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	asset, err := repository.NewAssetRepositoryWithDB(database).GetBySymbol(strings.ToUpper(strings.TrimSpace(symbol)))
+	if err == gorm.ErrRecordNotFound {
+		return &account, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return &account, &asset, nil
+}
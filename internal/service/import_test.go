@@ -0,0 +1,159 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bioharz/budget/internal/importer"
+	"github.com/bioharz/budget/internal/models"
+	"github.com/bioharz/budget/internal/repository"
+	"github.com/bioharz/budget/test/fixtures"
+	"github.com/bioharz/budget/test/helpers"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func importRow(line int, account, asset, amount, price string) importer.Row {
+	row := importer.Row{Line: line, Account: account, Asset: asset, Amount: decimal.RequireFromString(amount)}
+	if price != "" {
+		row.Price = decimal.RequireFromString(price)
+	}
+	return row
+}
+
+func TestImportService_Balances(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	service := NewImportServiceWithDB(db)
+	ledger := fixtures.NewAccount().WithName("Ledger").Create(t, db)
+	btc := fixtures.NewAsset().WithSymbol("BTC").Create(t, db)
+	sol := fixtures.NewAsset().WithSymbol("SOL").Create(t, db)
+	fixtures.NewHolding().WithAccount(ledger).WithAsset(btc).WithAmount(0.5).WithPurchasePrice(40000).Create(t, db)
+	fixtures.NewHolding().WithAccount(ledger).WithAsset(sol).WithAmount(10).WithPurchasePrice(100).Create(t, db)
+
+	rows := []importer.Row{
+		importRow(2, "Ledger", "BTC", "0.5", ""),
+		importRow(3, "Ledger", "SOL", "12", "150"),
+		importRow(4, "Bank", "EUR", "1000", ""),
+		importRow(5, "Bank", "EUR", "1000", ""),
+	}
+
+	plan, err := service.Plan("portfolio.csv", rows)
+	require.NoError(t, err)
+	actions := make([]ImportAction, len(plan.Rows))
+	for i, row := range plan.Rows {
+		actions[i] = row.Action
+	}
+	assert.Equal(t, []ImportAction{ImportDuplicate, ImportUpdate, ImportCreate, ImportDuplicate}, actions)
+	assert.Equal(t, 1, plan.Count(ImportCreate))
+
+	// Planning writes nothing
+	_, err = repository.NewAccountRepositoryWithDB(db).GetByName("Bank")
+	assert.Error(t, err)
+
+	require.NoError(t, service.Apply(plan))
+
+	holdings, err := repository.NewHoldingRepositoryWithDB(db).GetAll()
+	require.NoError(t, err)
+	require.Len(t, holdings, 3)
+	for _, holding := range holdings {
+		switch holding.Asset.Symbol {
+		case "BTC":
+			assert.Equal(t, "0.5", holding.Amount.String())
+		case "SOL":
+			assert.Equal(t, "12", holding.Amount.String())
+			assert.Equal(t, "150", holding.PurchasePrice.String())
+		case "EUR":
+			assert.Equal(t, "Bank", holding.Account.Name)
+			assert.Equal(t, "1000", holding.Amount.String())
+		}
+	}
+
+	// Every imported row is audited with where it came from
+	logs, err := repository.NewAuditLogRepository(db).GetAll(0)
+	require.NoError(t, err)
+	notes := make(map[string]bool)
+	for _, log := range logs {
+		notes[log.UserNote] = true
+	}
+	assert.True(t, notes["Imported from portfolio.csv line 3"])
+	assert.True(t, notes["Imported from portfolio.csv line 4"])
+
+	// Importing the same file again changes nothing
+	plan, err = service.Plan("portfolio.csv", rows)
+	require.NoError(t, err)
+	assert.Equal(t, len(rows), plan.Count(ImportDuplicate))
+}
+
+func TestImportService_Transactions(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	service := NewImportServiceWithDB(db)
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.Local)
+
+	buy := importRow(2, "Kraken", "ETH", "2", "3000")
+	buy.Type = models.TransactionBuy
+	buy.Date = day
+	sell := importRow(3, "Kraken", "ETH", "0.5", "3500")
+	sell.Type = models.TransactionSell
+	sell.Fee = decimal.NewFromInt(2)
+	sell.Date = day.AddDate(0, 0, 1)
+
+	plan, err := service.Plan("kraken.csv", []importer.Row{buy, sell})
+	require.NoError(t, err)
+	assert.Equal(t, ImportCreate, plan.Rows[0].Action)
+	assert.Equal(t, ImportUpdate, plan.Rows[1].Action)
+	require.NoError(t, service.Apply(plan))
+
+	transactions, err := repository.NewTransactionRepositoryWithDB(db).GetAll()
+	require.NoError(t, err)
+	require.Len(t, transactions, 2)
+	assert.Equal(t, "2", transactions[1].Fee.String())
+
+	plan, err = service.Plan("kraken.csv", []importer.Row{buy, sell})
+	require.NoError(t, err)
+	assert.Equal(t, 2, plan.Count(ImportDuplicate))
+
+	// A failing row rolls back the rows before it
+	deposit := importRow(2, "Kraken", "SOL", "10", "")
+	deposit.Type = models.TransactionDeposit
+	oversell := importRow(3, "Kraken", "ETH", "5", "3500")
+	oversell.Type = models.TransactionSell
+	plan, err = service.Plan("kraken.csv", []importer.Row{deposit, oversell})
+	require.NoError(t, err)
+	err = service.Apply(plan)
+	assert.ErrorContains(t, err, "line 3")
+	_, err = repository.NewAssetRepositoryWithDB(db).GetBySymbol("SOL")
+	assert.Error(t, err)
+}
+
+func TestImportService_Unpriced(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	service := NewImportServiceWithDB(db)
+
+	paid := importRow(2, "Kraken", "EUR", "400", "")
+	paid.Type, paid.Unpriced = models.TransactionWithdrawal, true
+	funded := importRow(1, "Kraken", "EUR", "1000", "")
+	funded.Type = models.TransactionDeposit
+	bought := importRow(2, "Kraken", "BTC", "0.01", "")
+	bought.Type, bought.Unpriced = models.TransactionDeposit, true
+
+	plan, err := service.Plan("kraken.csv", []importer.Row{funded, paid, bought})
+	require.NoError(t, err)
+	assert.Equal(t, 2, plan.Unpriced())
+
+	// Unpriced rows are refused until the plan allows them
+	assert.ErrorIs(t, service.Apply(plan), ErrUnpricedRows)
+	_, err = repository.NewAssetRepositoryWithDB(db).GetBySymbol("BTC")
+	assert.Error(t, err)
+
+	plan.AllowUnpriced = true
+	require.NoError(t, service.Apply(plan))
+	transactions, err := repository.NewTransactionRepositoryWithDB(db).GetAll()
+	require.NoError(t, err)
+	assert.Len(t, transactions, 3)
+
+	// Duplicates are skipped, so they need no confirmation
+	plan, err = service.Plan("kraken.csv", []importer.Row{funded, paid, bought})
+	require.NoError(t, err)
+	assert.Equal(t, 0, plan.Unpriced())
+}
@@ -0,0 +1,407 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bioharz/budget/internal/db"
+	"github.com/bioharz/budget/internal/models"
+	"github.com/bioharz/budget/internal/repository"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// costPlaces is the precision average costs are compared at; dividing the
+// cost by the quantity leaves digits beyond it
+const costPlaces = 8
+
+// openingBalanceNote marks the deposits created for holdings that predate
+// the ledger
+const openingBalanceNote = "Opening balance"
+
+// LedgerService records transactions and keeps holdings in sync with them.
+// A holding is the derived balance of one asset in one account.
+type LedgerService struct {
+	db *gorm.DB
+}
+
+func NewLedgerService() *LedgerService {
+	return &LedgerService{db: db.DB}
+}
+
+func NewLedgerServiceWithDB(database *gorm.DB) *LedgerService {
+	return &LedgerService{db: database}
+}
+
+// WithAuditNote returns a copy of the service that attaches note to the
+// audit entries of the holdings it changes
+func (s *LedgerService) WithAuditNote(note string) *LedgerService {
+	return &LedgerService{db: models.WithAuditNote(s.db, note)}
+}
+
+// Position is a balance derived from the ledger
+type Position struct {
+	Amount       decimal.Decimal
+	AverageCost  decimal.Decimal // Average USD cost per unit, 0 if unknown
+	PurchaseDate time.Time       // First acquisition of the current position
+}
+
+// Record validates and stores a transaction, then updates the affected
+// holdings. It returns the holding of the transaction's account.
+func (s *LedgerService) Record(transaction *models.Transaction) (*models.Holding, error) {
+	var holding *models.Holding
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		holding, err = s.record(tx, transaction)
+		return err
+	})
+	return holding, err
+}
+
+func (s *LedgerService) record(tx *gorm.DB, transaction *models.Transaction) (*models.Holding, error) {
+	if err := validateTransaction(transaction); err != nil {
+		return nil, err
+	}
+	if transaction.Timestamp.IsZero() {
+		transaction.Timestamp = time.Now()
+	}
+
+	if err := s.ensureOpeningBalance(tx, transaction.AccountID, transaction.AssetID); err != nil {
+		return nil, err
+	}
+
+	if transaction.Type == models.TransactionTransfer {
+		if err := s.ensureOpeningBalance(tx, *transaction.ToAccountID, transaction.AssetID); err != nil {
+			return nil, err
+		}
+		// Units keep their cost basis when they move between accounts
+		if transaction.PriceUSD.IsZero() {
+			position, err := s.position(tx, transaction.AccountID, transaction.AssetID)
+			if err != nil {
+				return nil, err
+			}
+			transaction.PriceUSD = position.AverageCost
+		}
+	}
+
+	if err := repository.NewTransactionRepositoryWithDB(tx).Create(transaction); err != nil {
+		return nil, fmt.Errorf("failed to create transaction: %w", err)
+	}
+
+	holding, err := s.syncHolding(tx, transaction.AccountID, transaction.AssetID)
+	if err != nil {
+		return nil, err
+	}
+	if transaction.Type == models.TransactionTransfer {
+		if _, err := s.syncHolding(tx, *transaction.ToAccountID, transaction.AssetID); err != nil {
+			return nil, err
+		}
+	}
+	return holding, nil
+}
+
+func validateTransaction(transaction *models.Transaction) error {
+	valid := false
+	for _, t := range models.TransactionTypes {
+		if transaction.Type == t {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return fmt.Errorf("unknown transaction type %q", transaction.Type)
+	}
+	if transaction.AccountID == 0 || transaction.AssetID == 0 {
+		return fmt.Errorf("account and asset are required")
+	}
+	if !transaction.Quantity.IsPositive() {
+		return fmt.Errorf("quantity must be positive")
+	}
+	if transaction.PriceUSD.IsNegative() || transaction.Fee.IsNegative() {
+		return fmt.Errorf("price and fee cannot be negative")
+	}
+	if transaction.Type == models.TransactionAdjustment && !transaction.Fee.IsZero() {
+		return fmt.Errorf("adjustments cannot have a fee")
+	}
+	if transaction.Type == models.TransactionTransfer {
+		if transaction.ToAccountID == nil || *transaction.ToAccountID == 0 {
+			return fmt.Errorf("transfer needs a destination account")
+		}
+		if *transaction.ToAccountID == transaction.AccountID {
+			return fmt.Errorf("cannot transfer to the same account")
+		}
+	} else if transaction.ToAccountID != nil {
+		return fmt.Errorf("only transfers have a destination account")
+	}
+	return nil
+}
+
+// GetPosition derives the balance of an asset in an account from the ledger
+func (s *LedgerService) GetPosition(accountID, assetID uint) (Position, error) {
+	return s.position(s.db, accountID, assetID)
+}
+
+// GetTransactions returns the ledger of one position, oldest first
+func (s *LedgerService) GetTransactions(accountID, assetID uint) ([]models.Transaction, error) {
+	return repository.NewTransactionRepositoryWithDB(s.db).GetByPosition(accountID, assetID)
+}
+
+func (s *LedgerService) position(tx *gorm.DB, accountID, assetID uint) (Position, error) {
+	transactions, err := repository.NewTransactionRepositoryWithDB(tx).GetByPosition(accountID, assetID)
+	if err != nil {
+		return Position{}, err
+	}
+	return derivePosition(transactions, accountID), nil
+}
+
+// derivePosition replays a position's transactions. Cost basis is tracked as
+// a running average over units with a known price; outflows reduce it
+// proportionally and adjustments replace the cost of the units they cover.
+func derivePosition(transactions []models.Transaction, accountID uint) Position {
+	var position Position
+	var costedQty, cost decimal.Decimal
+
+	for _, transaction := range transactions {
+		inflow := transaction.Type.IsInflow() ||
+			(transaction.Type == models.TransactionTransfer && transaction.AccountID != accountID)
+
+		if inflow {
+			if !position.Amount.IsPositive() {
+				position.PurchaseDate = transaction.Timestamp
+			}
+			position.Amount = position.Amount.Add(transaction.Quantity)
+			if transaction.PriceUSD.IsPositive() {
+				costedQty = costedQty.Add(transaction.Quantity)
+				cost = cost.Add(transaction.Quantity.Mul(transaction.PriceUSD))
+				if transaction.Type == models.TransactionBuy {
+					cost = cost.Add(transaction.Fee)
+				}
+			}
+			continue
+		}
+
+		if transaction.Type == models.TransactionAdjustment {
+			if position.Amount.IsPositive() {
+				adjusted := decimal.Min(transaction.Quantity, position.Amount)
+				remaining := position.Amount.Sub(adjusted)
+				costedQty = costedQty.Mul(remaining).Div(position.Amount)
+				cost = cost.Mul(remaining).Div(position.Amount)
+				if transaction.PriceUSD.IsPositive() {
+					costedQty = costedQty.Add(adjusted)
+					cost = cost.Add(adjusted.Mul(transaction.PriceUSD))
+				}
+			}
+			continue
+		}
+
+		if position.Amount.IsPositive() {
+			remaining := decimal.Max(decimal.Zero, position.Amount.Sub(transaction.Quantity))
+			costedQty = costedQty.Mul(remaining).Div(position.Amount)
+			cost = cost.Mul(remaining).Div(position.Amount)
+		}
+		position.Amount = position.Amount.Sub(transaction.Quantity)
+	}
+
+	if costedQty.IsPositive() {
+		position.AverageCost = cost.Div(costedQty)
+	}
+	return position
+}
+
+// syncHolding writes the ledger balance of a position to its holding,
+// creating, restoring or soft-deleting it as needed. Duplicate holdings of
+// the same position are folded into the oldest one.
+func (s *LedgerService) syncHolding(tx *gorm.DB, accountID, assetID uint) (*models.Holding, error) {
+	position, err := s.position(tx, accountID, assetID)
+	if err != nil {
+		return nil, err
+	}
+	if position.Amount.IsNegative() {
+		return nil, fmt.Errorf("insufficient balance: transaction would leave %s", position.Amount)
+	}
+
+	holdingRepo := repository.NewHoldingRepositoryWithDB(tx)
+
+	holdings, err := holdingRepo.GetByPosition(accountID, assetID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(holdings) > 1 {
+		for _, duplicate := range holdings[1:] {
+			if duplicate.DeletedAt.Valid {
+				continue
+			}
+			if err := holdingRepo.Delete(duplicate.ID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(holdings) == 0 {
+		if position.Amount.IsZero() {
+			return nil, nil
+		}
+		holding := &models.Holding{
+			AccountID:     accountID,
+			AssetID:       assetID,
+			Amount:        position.Amount,
+			PurchasePrice: position.AverageCost,
+			PurchaseDate:  position.PurchaseDate,
+		}
+		if err := holdingRepo.Create(holding); err != nil {
+			return nil, fmt.Errorf("failed to create holding: %w", err)
+		}
+		return loadHolding(holdingRepo, holding.ID)
+	}
+
+	holding := holdings[0]
+
+	if position.Amount.IsZero() {
+		if !holding.DeletedAt.Valid {
+			if err := holdingRepo.Delete(holding.ID); err != nil {
+				return nil, err
+			}
+		}
+		return &holding, nil
+	}
+
+	// A position that was closed and reopened brings its holding back
+	holding.DeletedAt = gorm.DeletedAt{}
+	holding.Amount = position.Amount
+	holding.PurchasePrice = position.AverageCost
+	holding.PurchaseDate = position.PurchaseDate
+	if err := holdingRepo.Update(&holding); err != nil {
+		return nil, fmt.Errorf("failed to update holding: %w", err)
+	}
+	return loadHolding(holdingRepo, holding.ID)
+}
+
+func loadHolding(holdingRepo *repository.HoldingRepository, id uint) (*models.Holding, error) {
+	holding, err := holdingRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	return &holding, nil
+}
+
+// ensureOpeningBalance seeds the ledger of a position that only exists as
+// holdings, with one deposit per holding so each keeps its price and date
+func (s *LedgerService) ensureOpeningBalance(tx *gorm.DB, accountID, assetID uint) error {
+	transactionRepo := repository.NewTransactionRepositoryWithDB(tx)
+	count, err := transactionRepo.CountByPosition(accountID, assetID)
+	if err != nil || count > 0 {
+		return err
+	}
+
+	holdings, err := repository.NewHoldingRepositoryWithDB(tx).GetByPosition(accountID, assetID)
+	if err != nil {
+		return err
+	}
+	for _, holding := range holdings {
+		if holding.DeletedAt.Valid || !holding.Amount.IsPositive() {
+			continue
+		}
+		timestamp := holding.PurchaseDate
+		if timestamp.IsZero() {
+			timestamp = holding.CreatedAt
+		}
+		opening := &models.Transaction{
+			Type:      models.TransactionDeposit,
+			AccountID: accountID,
+			AssetID:   assetID,
+			Quantity:  holding.Amount,
+			PriceUSD:  holding.PurchasePrice,
+			Note:      openingBalanceNote,
+			Timestamp: timestamp,
+		}
+		if err := transactionRepo.Create(opening); err != nil {
+			return fmt.Errorf("failed to create opening balance: %w", err)
+		}
+	}
+	return nil
+}
+
+// AdjustHolding turns an edit of a holding into ledger entries: a transfer
+// when the account changes, a withdrawal and deposit when the asset changes,
+// and a deposit or withdrawal for the change in amount. A different
+// purchase price is recorded as a cost adjustment of the whole balance.
+func (s *LedgerService) AdjustHolding(holding models.Holding, accountID, assetID uint, amount, purchasePrice decimal.Decimal) (*models.Holding, error) {
+	if !amount.IsPositive() {
+		return nil, fmt.Errorf("amount must be positive")
+	}
+	if purchasePrice.IsNegative() {
+		return nil, fmt.Errorf("purchase price cannot be negative")
+	}
+
+	var result *models.Holding
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		delta := amount.Sub(holding.Amount)
+
+		switch {
+		case assetID != holding.AssetID:
+			if _, err := s.record(tx, &models.Transaction{
+				Type: models.TransactionWithdrawal, AccountID: holding.AccountID, AssetID: holding.AssetID,
+				Quantity: holding.Amount, Note: "Edited holding", Timestamp: now,
+			}); err != nil {
+				return err
+			}
+			// The new asset starts from scratch
+			delta = amount
+		case accountID != holding.AccountID:
+			to := accountID
+			if _, err := s.record(tx, &models.Transaction{
+				Type: models.TransactionTransfer, AccountID: holding.AccountID, AssetID: assetID,
+				ToAccountID: &to, Quantity: holding.Amount, Note: "Edited holding", Timestamp: now,
+			}); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		if delta.IsPositive() {
+			result, err = s.record(tx, &models.Transaction{
+				Type: models.TransactionDeposit, AccountID: accountID, AssetID: assetID,
+				Quantity: delta, PriceUSD: purchasePrice, Note: "Edited holding", Timestamp: now,
+			})
+		} else if delta.IsNegative() {
+			result, err = s.record(tx, &models.Transaction{
+				Type: models.TransactionWithdrawal, AccountID: accountID, AssetID: assetID,
+				Quantity: delta.Neg(), Note: "Edited holding", Timestamp: now,
+			})
+		} else {
+			result, err = s.syncHolding(tx, accountID, assetID)
+		}
+		if err != nil {
+			return err
+		}
+
+		// Past acquisitions keep their recorded price; an adjustment corrects
+		// the cost of the balance and of its tax lots
+		if result != nil && !result.PurchasePrice.Round(costPlaces).Equal(purchasePrice.Round(costPlaces)) {
+			result, err = s.record(tx, &models.Transaction{
+				Type: models.TransactionAdjustment, AccountID: accountID, AssetID: assetID,
+				Quantity: result.Amount, PriceUSD: purchasePrice, Note: "Edited holding", Timestamp: now,
+			})
+		}
+		return err
+	})
+	return result, err
+}
+
+// CloseHolding withdraws a holding's full balance, which removes it
+func (s *LedgerService) CloseHolding(holding models.Holding) error {
+	if !holding.Amount.IsPositive() {
+		return nil
+	}
+	_, err := s.Record(&models.Transaction{
+		Type:      models.TransactionWithdrawal,
+		AccountID: holding.AccountID,
+		AssetID:   holding.AssetID,
+		Quantity:  holding.Amount,
+		Note:      "Deleted holding",
+		Timestamp: time.Now(),
+	})
+	return err
+}
@@ -0,0 +1,223 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bioharz/budget/internal/models"
+	"github.com/bioharz/budget/internal/repository"
+	"github.com/bioharz/budget/test/fixtures"
+	"github.com/bioharz/budget/test/helpers"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLedgerService_DerivesHoldings(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	ledger := NewLedgerServiceWithDB(db)
+	account := fixtures.NewAccount().WithName("Exchange").Create(t, db)
+	asset := fixtures.NewAsset().WithSymbol("BTC").Create(t, db)
+
+	day := func(n int) time.Time { return time.Date(2024, 1, n, 0, 0, 0, 0, time.UTC) }
+	record := func(txType models.TransactionType, quantity, price, fee float64, ts time.Time) *models.Holding {
+		holding, err := ledger.Record(&models.Transaction{
+			Type: txType, AccountID: account.ID, AssetID: asset.ID,
+			Quantity: decimal.NewFromFloat(quantity), PriceUSD: decimal.NewFromFloat(price), Fee: decimal.NewFromFloat(fee), Timestamp: ts,
+		})
+		require.NoError(t, err)
+		return holding
+	}
+
+	holding := record(models.TransactionBuy, 1, 30000, 100, day(1))
+	assert.Equal(t, "1", holding.Amount.String())
+	assert.Equal(t, "30100", holding.PurchasePrice.String())
+
+	holding = record(models.TransactionBuy, 1, 40000, 0, day(2))
+	assert.Equal(t, "2", holding.Amount.String())
+	assert.Equal(t, "35050", holding.PurchasePrice.String())
+
+	// Selling keeps the average cost of the remaining units
+	holding = record(models.TransactionSell, 0.5, 50000, 10, day(3))
+	assert.Equal(t, "1.5", holding.Amount.String())
+	assert.Equal(t, "35050", holding.PurchasePrice.String())
+
+	holding = record(models.TransactionFee, 0.001, 0, 0, day(4))
+	assert.Equal(t, "1.499", holding.Amount.String())
+
+	// Overdrawing is rejected and leaves the ledger untouched
+	_, err := ledger.Record(&models.Transaction{
+		Type: models.TransactionWithdrawal, AccountID: account.ID, AssetID: asset.ID, Quantity: decimal.NewFromInt(5),
+	})
+	assert.ErrorContains(t, err, "insufficient balance")
+
+	transactions, err := ledger.GetTransactions(account.ID, asset.ID)
+	require.NoError(t, err)
+	assert.Len(t, transactions, 4)
+
+	// Closing the position soft-deletes the holding, reopening restores it
+	holding = record(models.TransactionWithdrawal, 1.499, 0, 0, day(5))
+	_, err = repository.NewHoldingRepositoryWithDB(db).GetByID(holding.ID)
+	assert.Error(t, err)
+
+	reopened := record(models.TransactionIncome, 0.01, 60000, 0, day(6))
+	assert.Equal(t, holding.ID, reopened.ID)
+	assert.Equal(t, "0.01", reopened.Amount.String())
+	assert.Equal(t, day(6), reopened.PurchaseDate.UTC())
+}
+
+func TestLedgerService_Transfer(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	ledger := NewLedgerServiceWithDB(db)
+	exchange := fixtures.NewAccount().WithName("Exchange").Create(t, db)
+	wallet := fixtures.NewAccount().WithName("Wallet").Create(t, db)
+	asset := fixtures.NewAsset().WithSymbol("ETH").Create(t, db)
+
+	_, err := ledger.Record(&models.Transaction{
+		Type: models.TransactionBuy, AccountID: exchange.ID, AssetID: asset.ID, Quantity: decimal.NewFromInt(10), PriceUSD: decimal.NewFromInt(2000),
+	})
+	require.NoError(t, err)
+
+	_, err = ledger.Record(&models.Transaction{
+		Type: models.TransactionTransfer, AccountID: exchange.ID, AssetID: asset.ID, ToAccountID: &exchange.ID, Quantity: decimal.NewFromInt(1),
+	})
+	assert.Error(t, err)
+
+	_, err = ledger.Record(&models.Transaction{
+		Type: models.TransactionTransfer, AccountID: exchange.ID, AssetID: asset.ID, ToAccountID: &wallet.ID, Quantity: decimal.NewFromInt(4),
+	})
+	require.NoError(t, err)
+
+	source, err := ledger.GetPosition(exchange.ID, asset.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "6", source.Amount.String())
+
+	// Transferred units keep their cost basis
+	destination, err := ledger.GetPosition(wallet.ID, asset.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "4", destination.Amount.String())
+	assert.Equal(t, "2000", destination.AverageCost.String())
+
+	holdings, err := repository.NewHoldingRepositoryWithDB(db).GetAll()
+	require.NoError(t, err)
+	assert.Len(t, holdings, 2)
+}
+
+func TestLedgerService_OpeningBalances(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	ledger := NewLedgerServiceWithDB(db)
+	account := fixtures.NewAccount().Create(t, db)
+	asset := fixtures.NewAsset().Create(t, db)
+	purchased := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	// Two holdings of the same position from before the ledger existed
+	first := fixtures.NewHolding().WithAccount(account).WithAsset(asset).WithAmount(1).
+		WithPurchasePrice(20000).WithPurchaseDate(purchased).Create(t, db)
+	fixtures.NewHolding().WithAccount(account).WithAsset(asset).WithAmount(1).
+		WithPurchasePrice(30000).WithPurchaseDate(purchased.AddDate(0, 1, 0)).Create(t, db)
+
+	// The first entry seeds the ledger and folds the duplicates into the
+	// oldest holding
+	holding, err := ledger.Record(&models.Transaction{
+		Type: models.TransactionSell, AccountID: account.ID, AssetID: asset.ID, Quantity: decimal.NewFromFloat(0.5), PriceUSD: decimal.NewFromInt(40000),
+	})
+	require.NoError(t, err)
+
+	transactions, err := ledger.GetTransactions(account.ID, asset.ID)
+	require.NoError(t, err)
+	require.Len(t, transactions, 3)
+	for _, opening := range transactions[:2] {
+		assert.Equal(t, models.TransactionDeposit, opening.Type)
+		assert.Equal(t, openingBalanceNote, opening.Note)
+	}
+	assert.Equal(t, first.ID, holding.ID)
+	assert.Equal(t, "1.5", holding.Amount.String())
+	assert.Equal(t, "25000", holding.PurchasePrice.String())
+	assert.Equal(t, purchased, holding.PurchaseDate.UTC())
+
+	holdings, err := repository.NewHoldingRepositoryWithDB(db).GetAll()
+	require.NoError(t, err)
+	assert.Len(t, holdings, 1)
+}
+
+func TestLedgerService_AdjustHolding(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	ledger := NewLedgerServiceWithDB(db)
+	bank := fixtures.NewAccount().WithName("Bank").Create(t, db)
+	broker := fixtures.NewAccount().WithName("Broker").Create(t, db)
+	asset := fixtures.NewAsset().WithSymbol("AAPL").WithType(models.AssetTypeStock).Create(t, db)
+
+	holding, err := ledger.Record(&models.Transaction{
+		Type: models.TransactionBuy, AccountID: bank.ID, AssetID: asset.ID, Quantity: decimal.NewFromInt(10), PriceUSD: decimal.NewFromInt(150),
+	})
+	require.NoError(t, err)
+
+	// Moving and growing the holding is a transfer plus a deposit
+	adjusted, err := ledger.AdjustHolding(*holding, broker.ID, asset.ID, decimal.NewFromInt(12), decimal.NewFromInt(150))
+	require.NoError(t, err)
+	assert.Equal(t, broker.ID, adjusted.AccountID)
+	assert.Equal(t, "12", adjusted.Amount.String())
+	assert.Equal(t, "150", adjusted.PurchasePrice.String())
+
+	transactions, err := ledger.GetTransactions(broker.ID, asset.ID)
+	require.NoError(t, err)
+	require.Len(t, transactions, 2)
+	assert.Equal(t, models.TransactionTransfer, transactions[0].Type)
+	assert.Equal(t, models.TransactionDeposit, transactions[1].Type)
+	assert.Equal(t, "2", transactions[1].Quantity.String())
+
+	// Correcting only the purchase price adds an adjustment and leaves the
+	// recorded acquisitions alone
+	adjusted, err = ledger.AdjustHolding(*adjusted, broker.ID, asset.ID, decimal.NewFromInt(12), decimal.NewFromInt(140))
+	require.NoError(t, err)
+	assert.Equal(t, "12", adjusted.Amount.String())
+	assert.Equal(t, "140", adjusted.PurchasePrice.String())
+
+	transactions, err = ledger.GetTransactions(broker.ID, asset.ID)
+	require.NoError(t, err)
+	require.Len(t, transactions, 3)
+	assert.Equal(t, "150", transactions[0].PriceUSD.String())
+	assert.Equal(t, "150", transactions[1].PriceUSD.String())
+	assert.Equal(t, models.TransactionAdjustment, transactions[2].Type)
+	assert.Equal(t, "12", transactions[2].Quantity.String())
+
+	// Later acquisitions average in with the corrected cost
+	adjusted, err = ledger.Record(&models.Transaction{
+		Type: models.TransactionBuy, AccountID: broker.ID, AssetID: asset.ID, Quantity: decimal.NewFromInt(8), PriceUSD: decimal.NewFromInt(165),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "150", adjusted.PurchasePrice.String())
+
+	require.NoError(t, ledger.CloseHolding(*adjusted))
+	holdings, err := repository.NewHoldingRepositoryWithDB(db).GetAll()
+	require.NoError(t, err)
+	assert.Empty(t, holdings)
+}
+
+func TestLedgerService_ExactAmounts(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	ledger := NewLedgerServiceWithDB(db)
+	account := fixtures.NewAccount().Create(t, db)
+	asset := fixtures.NewAsset().WithSymbol("ETH").Create(t, db)
+
+	// Amounts float64 cannot hold: a single wei and sums like 0.1 + 0.2
+	for _, quantity := range []string{"0.1", "0.2", "0.000000000000000001"} {
+		_, err := ledger.Record(&models.Transaction{
+			Type: models.TransactionDeposit, AccountID: account.ID, AssetID: asset.ID,
+			Quantity: decimal.RequireFromString(quantity),
+		})
+		require.NoError(t, err)
+	}
+
+	holdings, err := repository.NewHoldingRepositoryWithDB(db).GetAll()
+	require.NoError(t, err)
+	require.Len(t, holdings, 1)
+	assert.Equal(t, "0.300000000000000001", holdings[0].Amount.String())
+
+	holding, err := ledger.Record(&models.Transaction{
+		Type: models.TransactionWithdrawal, AccountID: account.ID, AssetID: asset.ID,
+		Quantity: decimal.RequireFromString("0.3"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "0.000000000000000001", holding.Amount.String())
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"time"
+
+	"github.com/bioharz/budget/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// Performance is the cost basis and unrealized profit/loss of holdings at a
+// given price. Holdings without a purchase price have no known cost basis
+// and are left out of the profit/loss figures.
+type Performance struct {
+	CostBasis     decimal.Decimal
+	Value         decimal.Decimal // Current value of the holdings with a cost basis
+	ProfitLoss    decimal.Decimal
+	ProfitLossPct decimal.Decimal
+	HasCostBasis  bool
+	HeldSince     time.Time // Earliest purchase date, zero if unknown
+}
+
+// CalculatePerformance aggregates the cost basis and unrealized profit/loss
+// of holdings priced at price
+func CalculatePerformance(holdings []models.Holding, price decimal.Decimal) Performance {
+	var perf Performance
+	for _, holding := range holdings {
+		if !holding.PurchaseDate.IsZero() && (perf.HeldSince.IsZero() || holding.PurchaseDate.Before(perf.HeldSince)) {
+			perf.HeldSince = holding.PurchaseDate
+		}
+		if !holding.PurchasePrice.IsPositive() {
+			continue
+		}
+		perf.HasCostBasis = true
+		perf.CostBasis = perf.CostBasis.Add(holding.Amount.Mul(holding.PurchasePrice))
+		perf.Value = perf.Value.Add(holding.Amount.Mul(price))
+	}
+
+	perf.ProfitLoss = perf.Value.Sub(perf.CostBasis)
+	if perf.CostBasis.IsPositive() {
+		perf.ProfitLossPct = perf.ProfitLoss.Div(perf.CostBasis).Mul(decimal.NewFromInt(100))
+	}
+	return perf
+}
@@ -0,0 +1,38 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bioharz/budget/internal/models"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalculatePerformance(t *testing.T) {
+	early := time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC)
+	late := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	holdings := []models.Holding{
+		{Amount: decimal.NewFromInt(1), PurchasePrice: decimal.NewFromInt(30000), PurchaseDate: late},
+		{Amount: decimal.NewFromFloat(0.5), PurchasePrice: decimal.NewFromInt(60000), PurchaseDate: early},
+		// Unknown cost basis: counts for the holding period only
+		{Amount: decimal.NewFromInt(2), PurchaseDate: late},
+	}
+
+	perf := CalculatePerformance(holdings, decimal.NewFromInt(50000))
+	assert.True(t, perf.HasCostBasis)
+	assert.Equal(t, "60000", perf.CostBasis.String())
+	assert.Equal(t, "75000", perf.Value.String())
+	assert.Equal(t, "15000", perf.ProfitLoss.String())
+	assert.Equal(t, "25", perf.ProfitLossPct.String())
+	assert.Equal(t, early, perf.HeldSince)
+
+	loss := CalculatePerformance(holdings[1:2], decimal.NewFromInt(45000))
+	assert.Equal(t, "-7500", loss.ProfitLoss.String())
+	assert.Equal(t, "-25", loss.ProfitLossPct.String())
+
+	unknown := CalculatePerformance(holdings[2:], decimal.NewFromInt(50000))
+	assert.False(t, unknown.HasCostBasis)
+	assert.True(t, unknown.ProfitLoss.IsZero())
+}
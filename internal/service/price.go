@@ -1,103 +1,389 @@
 package service
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/bioharz/budget/internal/api"
+	"github.com/bioharz/budget/internal/currency"
 	"github.com/bioharz/budget/internal/models"
 	"github.com/bioharz/budget/internal/repository"
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
 type PriceService struct {
-	client    *api.PriceClient
-	assetRepo *repository.AssetRepository
-	cacheRepo *repository.PriceCacheRepository
+	client          *api.PriceClient
+	assetRepo       *repository.AssetRepository
+	cacheRepo       *repository.PriceCacheRepository
+	historyRepo     *repository.PriceHistoryRepository
+	snapshotService *SnapshotService
+	settingRepo     *repository.SettingRepository
+	resolver        *CoinResolver
+	lastPrune       time.Time
 }
 
+// ManualPriceSource marks user-entered prices in the price history
+const ManualPriceSource = "manual"
+
+// USDQuote is a fetched price converted to USD
+type USDQuote struct {
+	Price     decimal.Decimal
+	Timestamp time.Time
+	Source    string
+}
+
+// pruneInterval is how often old price history is thinned out
+const pruneInterval = 24 * time.Hour
+
 func NewPriceService() *PriceService {
+	cfg := api.ConfigFromEnv()
+	resolver := NewCoinResolver(api.NewCoinGeckoProvider(cfg.CoinGeckoURL, cfg.HTTPClient))
+	cfg.CoinIDResolver = resolver
+
 	return &PriceService{
-		client:    api.NewPriceClient(),
-		assetRepo: repository.NewAssetRepository(),
-		cacheRepo: repository.NewPriceCacheRepository(),
+		client:          api.NewPriceClientWithConfig(cfg),
+		assetRepo:       repository.NewAssetRepository(),
+		cacheRepo:       repository.NewPriceCacheRepository(),
+		historyRepo:     repository.NewPriceHistoryRepository(),
+		snapshotService: NewSnapshotService(),
+		settingRepo:     repository.NewSettingRepository(),
+		resolver:        resolver,
 	}
 }
 
 func NewPriceServiceWithDB(database *gorm.DB) *PriceService {
+	cfg := api.ConfigFromEnv()
+	resolver := NewCoinResolverWithDB(database, api.NewCoinGeckoProvider(cfg.CoinGeckoURL, cfg.HTTPClient))
+	cfg.CoinIDResolver = resolver
+
 	return &PriceService{
-		client:    api.NewPriceClient(),
-		assetRepo: repository.NewAssetRepositoryWithDB(database),
-		cacheRepo: repository.NewPriceCacheRepositoryWithDB(database),
+		client:          api.NewPriceClientWithConfig(cfg),
+		assetRepo:       repository.NewAssetRepositoryWithDB(database),
+		cacheRepo:       repository.NewPriceCacheRepositoryWithDB(database),
+		historyRepo:     repository.NewPriceHistoryRepositoryWithDB(database),
+		snapshotService: NewSnapshotServiceWithDB(database),
+		settingRepo:     repository.NewSettingRepositoryWithDB(database),
+		resolver:        resolver,
 	}
 }
 
-func (s *PriceService) FetchPrices(assets []models.Asset) (map[uint]float64, error) {
-	prices := make(map[uint]float64)
+// Resolver returns the coin ID resolver used for crypto prices
+func (s *PriceService) Resolver() *CoinResolver {
+	return s.resolver
+}
 
-	// Separate crypto and fiat assets
-	var cryptoSymbols []string
-	var fiatSymbols []string
-	cryptoAssetMap := make(map[string]uint)
-	fiatAssetMap := make(map[string]uint)
+// SetProviderID pins a crypto asset to a CoinGecko coin ID and drops its
+// cached quote so the next fetch uses the new coin
+func (s *PriceService) SetProviderID(asset models.Asset, coinID string) error {
+	if s.resolver == nil {
+		return fmt.Errorf("no coin resolver configured")
+	}
+	if err := s.resolver.SetOverride(asset.ID, coinID); err != nil {
+		return err
+	}
+	s.client.Invalidate(asset.Type, asset.Symbol)
+	return nil
+}
 
+// FetchPrices fetches USD prices for the given assets and stores them in the
+// price cache. Manually priced assets keep their price and assets without a
+// provider default to 0.
+func (s *PriceService) FetchPrices(assets []models.Asset) (map[uint]decimal.Decimal, error) {
+	prices := make(map[uint]decimal.Decimal)
+	fetched := make(map[uint]decimal.Decimal)
+
+	manual, err := s.GetManualPrices()
+	if err != nil {
+		return prices, err
+	}
+
+	var toFetch []models.Asset
 	for _, asset := range assets {
-		switch asset.Type {
-		case models.AssetTypeCrypto:
-			cryptoSymbols = append(cryptoSymbols, asset.Symbol)
-			cryptoAssetMap[asset.Symbol] = asset.ID
-		case models.AssetTypeFiat:
-			fiatSymbols = append(fiatSymbols, asset.Symbol)
-			fiatAssetMap[asset.Symbol] = asset.ID
-		default:
-			// For stocks and others, default to 0 for now
-			prices[asset.ID] = 0
-		}
-	}
-
-	// Fetch crypto prices
-	if len(cryptoSymbols) > 0 {
-		cryptoPrices, err := s.client.GetCryptoPrices(cryptoSymbols)
-		if err != nil {
-			// Continue with partial results
-		} else {
-			for symbol, price := range cryptoPrices {
-				if assetID, ok := cryptoAssetMap[symbol]; ok {
-					prices[assetID] = price
-				}
-			}
+		if cache, ok := manual[asset.ID]; ok {
+			prices[asset.ID] = cache.PriceUSD
+			continue
 		}
+		toFetch = append(toFetch, asset)
 	}
 
-	// Fetch fiat rates
-	if len(fiatSymbols) > 0 {
-		fiatRates, err := s.client.GetFiatRates(fiatSymbols)
-		if err != nil {
-			// Continue with partial results
-		} else {
-			for symbol, rate := range fiatRates {
-				if assetID, ok := fiatAssetMap[symbol]; ok {
-					prices[assetID] = rate
-				}
-			}
+	quotes, err := s.FetchQuotes(toFetch)
+	if err != nil {
+		return prices, err
+	}
+
+	for _, asset := range toFetch {
+		if len(s.client.Registry().Providers(asset.Type)) == 0 {
+			// Other assets have no price feed, default to 0 until priced manually
+			fetched[asset.ID] = decimal.Zero
 		}
 	}
+	for assetID, quote := range quotes {
+		fetched[assetID] = quote.Price
+	}
 
 	// Save prices to cache
 	if s.cacheRepo != nil {
-		if err := s.cacheRepo.UpsertBatch(prices); err != nil {
+		if err := s.cacheRepo.UpsertBatch(fetched); err != nil {
 			// Log error but don't fail the operation
 			_ = err
 			// Log error but don't fail the operation
 		}
 	}
 
+	for assetID, price := range fetched {
+		prices[assetID] = price
+	}
+
+	s.recordSnapshot(prices)
+	if err := s.recordHistory(quotes); err != nil {
+		return prices, fmt.Errorf("failed to record price history: %w", err)
+	}
+
 	return prices, nil
 }
 
+// SetManualPrice values an asset at priceUSD as of date. Fetches leave the
+// price alone until it is cleared again.
+func (s *PriceService) SetManualPrice(assetID uint, priceUSD decimal.Decimal, date time.Time) error {
+	if priceUSD.IsNegative() {
+		return fmt.Errorf("price cannot be negative")
+	}
+	if s.cacheRepo == nil {
+		return fmt.Errorf("no price cache configured")
+	}
+	if _, err := s.assetRepo.GetByID(assetID); err != nil {
+		return fmt.Errorf("asset not found: %w", err)
+	}
+	if err := s.cacheRepo.SetManual(assetID, priceUSD, date); err != nil {
+		return err
+	}
+
+	if s.historyRepo == nil {
+		return nil
+	}
+	return s.historyRepo.Append([]models.PriceHistory{{
+		AssetID:   assetID,
+		PriceUSD:  priceUSD,
+		Source:    ManualPriceSource,
+		Manual:    true,
+		Timestamp: date,
+	}})
+}
+
+// ClearManualPrice returns an asset to automatic pricing
+func (s *PriceService) ClearManualPrice(assetID uint) error {
+	if s.cacheRepo == nil {
+		return nil
+	}
+	return s.cacheRepo.ClearManual(assetID)
+}
+
+// GetManualPrices returns the manually set cache entries keyed by asset ID
+func (s *PriceService) GetManualPrices() (map[uint]models.PriceCache, error) {
+	if s.cacheRepo == nil {
+		return make(map[uint]models.PriceCache), nil
+	}
+	return s.cacheRepo.GetManual()
+}
+
+// FetchQuotes asks the provider chain of each asset type for current quotes
+// and converts them to USD. Assets no provider could price are left out.
+func (s *PriceService) FetchQuotes(assets []models.Asset) (map[uint]USDQuote, error) {
+	// Group symbols by asset type so each provider chain gets one batch
+	symbolsByType := make(map[models.AssetType][]string)
+	assetIDs := make(map[models.AssetType]map[string]uint)
+	for _, asset := range assets {
+		if len(s.client.Registry().Providers(asset.Type)) == 0 {
+			continue
+		}
+		symbol := strings.ToUpper(asset.Symbol)
+		if assetIDs[asset.Type] == nil {
+			assetIDs[asset.Type] = make(map[string]uint)
+		}
+		symbolsByType[asset.Type] = append(symbolsByType[asset.Type], symbol)
+		assetIDs[asset.Type][symbol] = asset.ID
+	}
+
+	quotes := make(map[uint]api.Quote)
+	for assetType, symbols := range symbolsByType {
+		result, err := s.client.GetQuotes(assetType, symbols)
+		if err != nil {
+			// Continue with partial results
+			continue
+		}
+		for symbol, quote := range result {
+			if assetID, ok := assetIDs[assetType][symbol]; ok {
+				quotes[assetID] = quote
+			}
+		}
+	}
+
+	return s.convertQuotesToUSD(quotes), nil
+}
+
+// convertQuotesToUSD converts quotes in other currencies (e.g. stocks listed
+// abroad) to USD using the fiat rates. Quotes in a currency without a known
+// rate are left out rather than mispriced.
+func (s *PriceService) convertQuotesToUSD(quotes map[uint]api.Quote) map[uint]USDQuote {
+	var currencies []string
+	seen := make(map[string]bool)
+	for _, quote := range quotes {
+		if quote.Currency != "USD" && !seen[quote.Currency] {
+			seen[quote.Currency] = true
+			currencies = append(currencies, quote.Currency)
+		}
+	}
+
+	rates := map[string]decimal.Decimal{"USD": decimal.NewFromInt(1)}
+	if len(currencies) > 0 {
+		fiatRates, err := s.client.GetFiatRates(currencies)
+		if err == nil {
+			for currency, rate := range fiatRates {
+				rates[currency] = decimal.NewFromFloat(rate)
+			}
+		}
+	}
+
+	return quotesToUSD(quotes, rates)
+}
+
+// quotesToUSD converts provider quotes to exact USD prices. Providers
+// report floats, which are taken at their shortest decimal representation.
+func quotesToUSD(quotes map[uint]api.Quote, rates map[string]decimal.Decimal) map[uint]USDQuote {
+	converted := make(map[uint]USDQuote)
+	for assetID, quote := range quotes {
+		rate, ok := rates[quote.Currency]
+		if !ok {
+			continue
+		}
+		converted[assetID] = USDQuote{
+			Price:     decimal.NewFromFloat(quote.Price).Mul(rate),
+			Timestamp: quote.Timestamp,
+			Source:    quote.Source,
+		}
+	}
+	return converted
+}
+
+// recordHistory appends fetched quotes to the price history and thins out
+// old points once a day
+func (s *PriceService) recordHistory(quotes map[uint]USDQuote) error {
+	if s.historyRepo == nil {
+		return nil
+	}
+
+	entries := make([]models.PriceHistory, 0, len(quotes))
+	for assetID, quote := range quotes {
+		timestamp := quote.Timestamp
+		if timestamp.IsZero() {
+			timestamp = time.Now()
+		}
+		entries = append(entries, models.PriceHistory{
+			AssetID:   assetID,
+			PriceUSD:  quote.Price,
+			Source:    quote.Source,
+			Timestamp: timestamp,
+		})
+	}
+	if err := s.historyRepo.Append(entries); err != nil {
+		return err
+	}
+
+	if time.Since(s.lastPrune) > pruneInterval {
+		if _, err := s.historyRepo.Prune(repository.DefaultRetention, time.Now()); err != nil {
+			return err
+		}
+		s.lastPrune = time.Now()
+	}
+	return nil
+}
+
+// GetPriceAt returns the USD price of an asset at time t from the price
+// history, or nil if nothing was recorded before t
+func (s *PriceService) GetPriceAt(assetID uint, t time.Time) (*models.PriceHistory, error) {
+	if s.historyRepo == nil {
+		return nil, nil
+	}
+	return s.historyRepo.GetAt(assetID, t)
+}
+
+// GetPriceHistory returns the recorded prices of an asset between start and end
+func (s *PriceService) GetPriceHistory(assetID uint, start, end time.Time) ([]models.PriceHistory, error) {
+	if s.historyRepo == nil {
+		return nil, nil
+	}
+	return s.historyRepo.GetRange(assetID, start, end)
+}
+
+// recordSnapshot stores the portfolio value after a fetch. Assets missing from
+// this fetch are valued at their last cached price so a partial API failure
+// does not show up as a dip in the history.
+func (s *PriceService) recordSnapshot(prices map[uint]decimal.Decimal) {
+	if s.snapshotService == nil {
+		return
+	}
+
+	valuation := prices
+	if s.cacheRepo != nil {
+		if cached, err := s.cacheRepo.GetPricesMap(); err == nil {
+			for assetID, price := range prices {
+				cached[assetID] = price
+			}
+			valuation = cached
+		}
+	}
+
+	// Without a rate the snapshot is still recorded in USD
+	converter, err := s.Converter()
+	if err != nil {
+		converter = currency.Identity
+	}
+
+	// A missing snapshot must not fail the price update
+	_, _ = s.snapshotService.RecordIn(valuation, converter)
+}
+
+// PriceSource says where the cached price of an asset came from and when
+type PriceSource struct {
+	Source    string // ManualPriceSource, a provider name, or empty without a feed
+	UpdatedAt time.Time
+}
+
+// GetPriceSources returns the source of each cached price by asset ID
+func (s *PriceService) GetPriceSources() (map[uint]PriceSource, error) {
+	sources := make(map[uint]PriceSource)
+	if s.cacheRepo == nil {
+		return sources, nil
+	}
+	caches, err := s.cacheRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	// Without a history only manual prices have a known source
+	var providers map[uint]string
+	if s.historyRepo != nil {
+		providers, err = s.historyRepo.LatestSources()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, cache := range caches {
+		source := providers[cache.AssetID]
+		if cache.Manual {
+			source = ManualPriceSource
+		}
+		sources[cache.AssetID] = PriceSource{Source: source, UpdatedAt: cache.UpdatedAt}
+	}
+	return sources, nil
+}
+
 // GetCachedPrices returns prices from the cache
-func (s *PriceService) GetCachedPrices() (map[uint]float64, error) {
+func (s *PriceService) GetCachedPrices() (map[uint]decimal.Decimal, error) {
 	if s.cacheRepo == nil {
-		return make(map[uint]float64), nil
+		return make(map[uint]decimal.Decimal), nil
 	}
 	return s.cacheRepo.GetPricesMap()
 }
@@ -1,10 +1,16 @@
 package service
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/bioharz/budget/internal/api"
 	"github.com/bioharz/budget/internal/models"
+	"github.com/bioharz/budget/test/fixtures"
 	"github.com/bioharz/budget/test/helpers"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -25,33 +31,37 @@ func TestPriceService_FetchPrices(t *testing.T) {
 			{ID: 2, Symbol: "ETH", Type: models.AssetTypeCrypto},
 			{ID: 3, Symbol: "USD", Type: models.AssetTypeFiat},
 			{ID: 4, Symbol: "EUR", Type: models.AssetTypeFiat},
-			{ID: 5, Symbol: "AAPL", Type: models.AssetTypeStock}, // Should return 0
+			{ID: 5, Symbol: "AAPL", Type: models.AssetTypeStock},
 		}
 
 		prices, err := service.FetchPrices(assets)
 		require.NoError(t, err)
 
 		// Check crypto prices (might not be available due to rate limiting)
-		if price, ok := prices[1]; ok && price > 0 {
-			helpers.AssertReasonablePrice(t, "BTC", price)
+		if price, ok := prices[1]; ok && price.IsPositive() {
+			helpers.AssertReasonablePrice(t, "BTC", price.InexactFloat64())
 		} else {
 			t.Log("BTC price not available")
 		}
-		if price, ok := prices[2]; ok && price > 0 {
-			helpers.AssertReasonablePrice(t, "ETH", price)
+		if price, ok := prices[2]; ok && price.IsPositive() {
+			helpers.AssertReasonablePrice(t, "ETH", price.InexactFloat64())
 		} else {
 			t.Log("ETH price not available")
 		}
 
 		// Check fiat prices
-		assert.Equal(t, 1.0, prices[3])   // USD should be 1
-		assert.Greater(t, prices[4], 0.5) // EUR should be > 0.5
+		assert.Equal(t, "1", prices[3].String())                         // USD should be 1
+		assert.True(t, prices[4].GreaterThan(decimal.NewFromFloat(0.5))) // EUR should be > 0.5
 
-		// Stock should return 0 (not implemented)
-		assert.Equal(t, 0.0, prices[5])
+		// Stock quotes might not be available due to rate limiting
+		if price, ok := prices[5]; ok {
+			assert.True(t, price.IsPositive())
+		} else {
+			t.Log("AAPL price not available")
+		}
 
-		t.Logf("Prices fetched: BTC=$%.2f, ETH=$%.2f, USD=$%.2f, EUR=$%.2f",
-			prices[1], prices[2], prices[3], prices[4])
+		t.Logf("Prices fetched: BTC=$%s, ETH=$%s, USD=$%s, EUR=$%s",
+			prices[1].StringFixed(2), prices[2].StringFixed(2), prices[3].StringFixed(2), prices[4].StringFixed(2))
 	})
 
 	t.Run("handle empty assets", func(t *testing.T) {
@@ -87,7 +97,7 @@ func TestPriceService_FetchPrices(t *testing.T) {
 
 		// Should have at least USD
 		assert.Contains(t, prices, uint(3))
-		assert.Equal(t, 1.0, prices[3])
+		assert.Equal(t, "1", prices[3].String())
 	})
 }
 
@@ -121,27 +131,27 @@ func TestPriceService_RealPortfolio(t *testing.T) {
 	t.Log("Portfolio Prices:")
 	for _, asset := range portfolio {
 		if price, ok := prices[asset.ID]; ok {
-			t.Logf("  %s (%s): $%.2f", asset.Name, asset.Symbol, price)
+			t.Logf("  %s (%s): $%s", asset.Name, asset.Symbol, price.StringFixed(2))
 		} else {
 			t.Logf("  %s (%s): No price available", asset.Name, asset.Symbol)
 		}
 	}
 
 	// Calculate example portfolio value
-	holdings := map[uint]float64{
-		1: 0.5,    // 0.5 BTC
-		2: 10.0,   // 10 ETH
-		3: 100.0,  // 100 SOL
-		4: 1000.0, // 1000 USDT
-		5: 5000.0, // 5000 USD
-		6: 2000.0, // 2000 EUR
+	holdings := map[uint]decimal.Decimal{
+		1: decimal.NewFromFloat(0.5), // 0.5 BTC
+		2: decimal.NewFromInt(10),    // 10 ETH
+		3: decimal.NewFromInt(100),   // 100 SOL
+		4: decimal.NewFromInt(1000),  // 1000 USDT
+		5: decimal.NewFromInt(5000),  // 5000 USD
+		6: decimal.NewFromInt(2000),  // 2000 EUR
 	}
 
-	totalValue := 0.0
+	totalValue := decimal.Zero
 	for assetID, amount := range holdings {
 		if price, ok := prices[assetID]; ok {
-			value := amount * price
-			totalValue += value
+			value := amount.Mul(price)
+			totalValue = totalValue.Add(value)
 
 			// Find asset name
 			assetName := ""
@@ -151,10 +161,136 @@ func TestPriceService_RealPortfolio(t *testing.T) {
 					break
 				}
 			}
-			t.Logf("  %s: %.2f × $%.2f = $%.2f", assetName, amount, price, value)
+			t.Logf("  %s: %s × $%s = $%s", assetName, amount, price.StringFixed(2), value.StringFixed(2))
 		}
 	}
 
-	t.Logf("Total Portfolio Value: $%.2f", totalValue)
-	assert.Greater(t, totalValue, 5000.0, "Portfolio should be worth > $5k with fiat alone")
+	t.Logf("Total Portfolio Value: $%s", totalValue.StringFixed(2))
+	assert.True(t, totalValue.GreaterThan(decimal.NewFromInt(5000)), "Portfolio should be worth > $5k with fiat alone")
+}
+
+func TestPriceService_FetchPrices_Stocks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prices := map[string]string{"/v8/finance/chart/AAPL": "190.5", "/v8/finance/chart/SPY": "500.25"}
+		price, ok := prices[r.URL.Path]
+		require.True(t, ok, r.URL.Path)
+		_, _ = w.Write([]byte(`{"chart":{"result":[{"meta":{"currency":"USD","regularMarketPrice":` + price + `}}]}}`))
+	}))
+	defer server.Close()
+
+	testDB := helpers.SetupTestDB(t)
+	service := NewPriceServiceWithDB(testDB)
+	service.client = api.NewPriceClientWithConfig(api.Config{StockQuoteURL: server.URL})
+
+	prices, err := service.FetchPrices([]models.Asset{
+		{ID: 1, Symbol: "AAPL", Type: models.AssetTypeStock},
+		{ID: 2, Symbol: "spy", Type: models.AssetTypeStock},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "190.5", prices[1].String())
+	assert.Equal(t, "500.25", prices[2].String())
+}
+
+func TestPriceService_FetchPrices_Manual(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Only the automatically priced stock reaches the API
+		assert.Equal(t, "/v8/finance/chart/AAPL", r.URL.Path)
+		_, _ = w.Write([]byte(`{"chart":{"result":[{"meta":{"currency":"USD","regularMarketPrice":190.5}}]}}`))
+	}))
+	defer server.Close()
+
+	testDB := helpers.SetupTestDB(t)
+	service := NewPriceServiceWithDB(testDB)
+	service.client = api.NewPriceClientWithConfig(api.Config{StockQuoteURL: server.URL})
+
+	house := fixtures.NewAsset().WithSymbol("HOUSE").WithType(models.AssetTypeOther).Create(t, testDB)
+	private := fixtures.NewAsset().WithSymbol("PRIV").WithType(models.AssetTypeStock).Create(t, testDB)
+	apple := fixtures.NewAsset().WithSymbol("AAPL").WithType(models.AssetTypeStock).Create(t, testDB)
+
+	valuedAt := time.Date(2025, 1, 31, 0, 0, 0, 0, time.Local)
+	require.NoError(t, service.SetManualPrice(house.ID, decimal.NewFromInt(420000), valuedAt))
+	require.NoError(t, service.SetManualPrice(private.ID, decimal.NewFromFloat(12.5), valuedAt))
+	assert.Error(t, service.SetManualPrice(apple.ID, decimal.NewFromInt(-1), valuedAt))
+
+	prices, err := service.FetchPrices([]models.Asset{*house, *private, *apple})
+	require.NoError(t, err)
+	assert.Equal(t, "420000", prices[house.ID].String())
+	assert.Equal(t, "12.5", prices[private.ID].String())
+	assert.Equal(t, "190.5", prices[apple.ID].String())
+
+	cached, err := service.GetCachedPrices()
+	require.NoError(t, err)
+	assert.Equal(t, "12.5", cached[private.ID].String())
+
+	manual, err := service.GetManualPrices()
+	require.NoError(t, err)
+	assert.Len(t, manual, 2)
+
+	// Both manual and fetched prices land in the history with their source
+	at, err := service.GetPriceAt(house.ID, valuedAt.Add(time.Hour))
+	require.NoError(t, err)
+	require.NotNil(t, at)
+	assert.Equal(t, "420000", at.PriceUSD.String())
+	assert.Equal(t, ManualPriceSource, at.Source)
+	assert.True(t, at.Manual)
+
+	history, err := service.GetPriceHistory(apple.ID, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, "yahoo-finance", history[0].Source)
+	assert.Equal(t, "190.5", history[0].PriceUSD.String())
+
+	sources, err := service.GetPriceSources()
+	require.NoError(t, err)
+	assert.Equal(t, ManualPriceSource, sources[house.ID].Source)
+	assert.Equal(t, "yahoo-finance", sources[apple.ID].Source)
+	assert.False(t, sources[apple.ID].UpdatedAt.IsZero())
+}
+
+func TestPriceService_GetPriceSources_WithoutHistory(t *testing.T) {
+	testDB := helpers.SetupTestDB(t)
+	service := NewPriceServiceWithDB(testDB)
+	service.historyRepo = nil
+	house := fixtures.NewAsset().WithSymbol("HOUSE").WithType(models.AssetTypeOther).Create(t, testDB)
+	require.NoError(t, service.SetManualPrice(house.ID, decimal.NewFromInt(420000), time.Now()))
+
+	sources, err := service.GetPriceSources()
+	require.NoError(t, err)
+	assert.Equal(t, ManualPriceSource, sources[house.ID].Source)
+}
+
+func TestPriceService_FetchPrices_HistoryError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"chart":{"result":[{"meta":{"currency":"USD","regularMarketPrice":190.5}}]}}`))
+	}))
+	defer server.Close()
+
+	testDB := helpers.SetupTestDB(t)
+	service := NewPriceServiceWithDB(testDB)
+	service.client = api.NewPriceClientWithConfig(api.Config{StockQuoteURL: server.URL})
+	apple := fixtures.NewAsset().WithSymbol("AAPL").WithType(models.AssetTypeStock).Create(t, testDB)
+	require.NoError(t, testDB.Migrator().DropTable(&models.PriceHistory{}))
+
+	// The fetched prices are still returned and cached
+	prices, err := service.FetchPrices([]models.Asset{*apple})
+	assert.ErrorContains(t, err, "failed to record price history")
+	assert.Equal(t, "190.5", prices[apple.ID].String())
+	cached, err := service.GetCachedPrices()
+	require.NoError(t, err)
+	assert.Equal(t, "190.5", cached[apple.ID].String())
+}
+
+func TestQuotesToUSD(t *testing.T) {
+	quotes := map[uint]api.Quote{
+		1: {Symbol: "AAPL", Price: 200, Currency: "USD"},
+		2: {Symbol: "VOD.L", Price: 0.70, Currency: "GBP"},
+		3: {Symbol: "SAP.DE", Price: 120, Currency: "EUR"},
+	}
+	rates := map[string]decimal.Decimal{"USD": decimal.NewFromInt(1), "GBP": decimal.NewFromFloat(1.25)}
+
+	converted := quotesToUSD(quotes, rates)
+	assert.Equal(t, "200", converted[1].Price.String())
+	assert.Equal(t, "0.875", converted[2].Price.String())
+	// No EUR rate available, so SAP stays unpriced
+	assert.NotContains(t, converted, uint(3))
 }
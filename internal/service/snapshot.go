@@ -0,0 +1,83 @@
+package service
+
+import (
+	"time"
+
+	"github.com/bioharz/budget/internal/currency"
+	"github.com/bioharz/budget/internal/models"
+	"github.com/bioharz/budget/internal/repository"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+type SnapshotService struct {
+	holdingRepo  *repository.HoldingRepository
+	snapshotRepo *repository.PortfolioSnapshotRepository
+}
+
+func NewSnapshotService() *SnapshotService {
+	return &SnapshotService{
+		holdingRepo:  repository.NewHoldingRepository(),
+		snapshotRepo: repository.NewPortfolioSnapshotRepository(),
+	}
+}
+
+func NewSnapshotServiceWithDB(database *gorm.DB) *SnapshotService {
+	return &SnapshotService{
+		holdingRepo:  repository.NewHoldingRepositoryWithDB(database),
+		snapshotRepo: repository.NewPortfolioSnapshotRepositoryWithDB(database),
+	}
+}
+
+// Record values the current holdings at the given prices and stores the
+// result with a per-asset and per-account breakdown
+func (s *SnapshotService) Record(prices map[uint]decimal.Decimal) (*models.PortfolioSnapshot, error) {
+	return s.RecordIn(prices, currency.Identity)
+}
+
+// RecordIn is Record with the total also stored in the converter's base
+// currency, so the history keeps the exchange rate of the day
+func (s *SnapshotService) RecordIn(prices map[uint]decimal.Decimal, converter currency.Converter) (*models.PortfolioSnapshot, error) {
+	holdings, err := s.holdingRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	assetValues := make(map[string]decimal.Decimal)
+	accountValues := make(map[string]decimal.Decimal)
+	for _, holding := range holdings {
+		value := holding.Amount.Mul(prices[holding.AssetID])
+		assetValues[holding.Asset.Symbol] = assetValues[holding.Asset.Symbol].Add(value)
+		accountValues[holding.Account.Name] = accountValues[holding.Account.Name].Add(value)
+	}
+
+	total := CalculateTotal(holdings, prices)
+	snapshot := &models.PortfolioSnapshot{
+		TotalValueUSD: total,
+		Currency:      converter.Code,
+		TotalValue:    converter.FromUSD(total),
+		Details: map[string]interface{}{
+			"assets":   breakdown(assetValues),
+			"accounts": breakdown(accountValues),
+		},
+		Timestamp: time.Now(),
+	}
+	if err := s.snapshotRepo.Create(snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// breakdown rounds values to cents for the informational JSON details
+func breakdown(values map[string]decimal.Decimal) map[string]float64 {
+	rounded := make(map[string]float64, len(values))
+	for name, value := range values {
+		rounded[name] = value.Round(2).InexactFloat64()
+	}
+	return rounded
+}
+
+// GetSince returns all snapshots taken after start, oldest first
+func (s *SnapshotService) GetSince(start time.Time) ([]models.PortfolioSnapshot, error) {
+	return s.snapshotRepo.GetByDateRange(start, time.Now())
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bioharz/budget/internal/models"
+	"github.com/bioharz/budget/internal/repository"
+	"github.com/bioharz/budget/test/fixtures"
+	"github.com/bioharz/budget/test/helpers"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotService_Record(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	service := NewSnapshotServiceWithDB(db)
+
+	wallet := fixtures.NewAccount().WithName("hardware wallet").Create(t, db)
+	bank := fixtures.NewAccount().WithName("NeoBank").Create(t, db)
+	btc := fixtures.NewAsset().WithSymbol("BTC").Create(t, db)
+	usd := fixtures.NewAsset().WithSymbol("USD").WithType(models.AssetTypeFiat).Create(t, db)
+
+	fixtures.NewHolding().WithAccount(wallet).WithAsset(btc).WithAmount(0.5).Create(t, db)
+	fixtures.NewHolding().WithAccount(bank).WithAsset(btc).WithAmount(0.25).Create(t, db)
+	fixtures.NewHolding().WithAccount(bank).WithAsset(usd).WithAmount(1000).Create(t, db)
+
+	snapshot, err := service.Record(map[uint]decimal.Decimal{btc.ID: decimal.NewFromInt(40000), usd.ID: decimal.NewFromInt(1)})
+	require.NoError(t, err)
+	assert.Equal(t, "31000", snapshot.TotalValueUSD.String())
+	assert.WithinDuration(t, time.Now(), snapshot.Timestamp, 2*time.Second)
+
+	snapshots, err := service.GetSince(time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	require.Len(t, snapshots, 1)
+
+	assets := snapshots[0].Details["assets"].(map[string]interface{})
+	assert.Equal(t, 30000.0, assets["BTC"])
+	assert.Equal(t, 1000.0, assets["USD"])
+
+	accounts := snapshots[0].Details["accounts"].(map[string]interface{})
+	assert.Equal(t, 20000.0, accounts["hardware wallet"])
+	assert.Equal(t, 11000.0, accounts["NeoBank"])
+}
+
+func TestPriceService_FetchPrices_RecordsSnapshot(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	service := NewPriceServiceWithDB(db)
+
+	account := fixtures.NewAccount().Create(t, db)
+	btc := fixtures.NewAsset().WithSymbol("BTC").Create(t, db)
+	house := fixtures.NewAsset().WithSymbol("HOUSE").WithType(models.AssetTypeOther).Create(t, db)
+	fixtures.NewHolding().WithAccount(account).WithAsset(btc).WithAmount(2).Create(t, db)
+
+	// BTC is not part of this fetch, so its cached price must still count
+	require.NoError(t, repository.NewPriceCacheRepositoryWithDB(db).Upsert(btc.ID, decimal.NewFromInt(30000)))
+
+	_, err := service.FetchPrices([]models.Asset{*house})
+	require.NoError(t, err)
+
+	latest, err := repository.NewPortfolioSnapshotRepositoryWithDB(db).GetLatest()
+	require.NoError(t, err)
+	assert.Equal(t, "60000", latest.TotalValueUSD.String())
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"github.com/bioharz/budget/internal/repository"
+	"github.com/bioharz/budget/internal/tax"
+	"gorm.io/gorm"
+)
+
+// TaxService computes realized gains from the transaction ledger. Holdings
+// from before the ledger enter as opening deposits, so their purchase price
+// and date become lots too.
+type TaxService struct {
+	transactionRepo *repository.TransactionRepository
+}
+
+func NewTaxService() *TaxService {
+	return &TaxService{
+		transactionRepo: repository.NewTransactionRepository(),
+	}
+}
+
+func NewTaxServiceWithDB(database *gorm.DB) *TaxService {
+	return &TaxService{
+		transactionRepo: repository.NewTransactionRepositoryWithDB(database),
+	}
+}
+
+// Report matches every sell in the ledger against its lots using method.
+// selections picks lots for specific-ID sells, keyed by sell transaction ID.
+func (s *TaxService) Report(method tax.Method, selections map[uint][]tax.Selection) (*tax.Report, error) {
+	transactions, err := s.transactionRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	return tax.Compute(transactions, method, selections)
+}
+
+// OpenLots returns the lots still held after replaying the ledger with
+// method, optionally limited to one asset
+func (s *TaxService) OpenLots(method tax.Method, assetID uint) ([]tax.Lot, error) {
+	report, err := s.Report(method, nil)
+	if err != nil {
+		return nil, err
+	}
+	if assetID == 0 {
+		return report.OpenLots, nil
+	}
+
+	var lots []tax.Lot
+	for _, lot := range report.OpenLots {
+		if lot.AssetID == assetID {
+			lots = append(lots, lot)
+		}
+	}
+	return lots, nil
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/bioharz/budget/internal/models"
+	"github.com/bioharz/budget/internal/tax"
+	"github.com/bioharz/budget/test/fixtures"
+	"github.com/bioharz/budget/test/helpers"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaxService_EditedPurchasePrice(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	ledger := NewLedgerServiceWithDB(db)
+	service := NewTaxServiceWithDB(db)
+	account := fixtures.NewAccount().Create(t, db)
+	asset := fixtures.NewAsset().WithSymbol("AAPL").WithType(models.AssetTypeStock).Create(t, db)
+
+	holding, err := ledger.Record(&models.Transaction{
+		Type: models.TransactionBuy, AccountID: account.ID, AssetID: asset.ID, Quantity: decimal.NewFromInt(10), PriceUSD: decimal.NewFromInt(150),
+	})
+	require.NoError(t, err)
+
+	// The purchase price was entered wrong and is corrected in the holding
+	holding, err = ledger.AdjustHolding(*holding, account.ID, asset.ID, decimal.NewFromInt(10), decimal.NewFromInt(140))
+	require.NoError(t, err)
+
+	_, err = ledger.Record(&models.Transaction{
+		Type: models.TransactionSell, AccountID: account.ID, AssetID: asset.ID, Quantity: decimal.NewFromInt(4), PriceUSD: decimal.NewFromInt(160),
+	})
+	require.NoError(t, err)
+
+	report, err := service.Report(tax.MethodFIFO, nil)
+	require.NoError(t, err)
+	require.Len(t, report.Disposals, 1)
+	assert.Equal(t, "560", report.Disposals[0].CostBasis.String())
+	assert.Equal(t, "80", report.Disposals[0].Gain.String())
+
+	lots, err := service.OpenLots(tax.MethodFIFO, asset.ID)
+	require.NoError(t, err)
+	require.Len(t, lots, 1)
+	assert.Equal(t, "6", lots[0].Quantity.String())
+	assert.Equal(t, "140", lots[0].UnitCost.String())
+}
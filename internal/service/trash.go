@@ -0,0 +1,346 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/bioharz/budget/internal/db"
+	"github.com/bioharz/budget/internal/models"
+	"github.com/bioharz/budget/internal/repository"
+	"gorm.io/gorm"
+)
+
+// SettingTrashDays is the settings key holding after how many days deleted
+// holdings, accounts and assets are purged
+const SettingTrashDays = "trash_days"
+
+// DefaultTrashDays is how long deleted rows are kept when it was never set
+const DefaultTrashDays = 30
+
+// TrashItem is a deleted holding, account or asset
+type TrashItem struct {
+	Entity    models.AuditLogEntityType
+	ID        uint
+	Name      string // e.g. "0.5 BTC in Ledger"
+	DeletedAt time.Time
+}
+
+// TrashService lists, restores and purges the holdings, accounts and assets
+// that were soft-deleted. A holding is restored by reopening its position in
+// the ledger. Purges are audited and cannot be undone; an account or asset
+// takes its ledger transactions with it.
+type TrashService struct {
+	db          *gorm.DB
+	settingRepo *repository.SettingRepository
+}
+
+func NewTrashService() *TrashService {
+	return &TrashService{db: db.DB, settingRepo: repository.NewSettingRepository()}
+}
+
+func NewTrashServiceWithDB(database *gorm.DB) *TrashService {
+	return &TrashService{db: database, settingRepo: repository.NewSettingRepositoryWithDB(database)}
+}
+
+// GetAll returns what is in the trash, most recently deleted first
+func (s *TrashService) GetAll() ([]TrashItem, error) {
+	return trashItems(s.db)
+}
+
+func trashItems(tx *gorm.DB) ([]TrashItem, error) {
+	var items []TrashItem
+
+	holdings, err := repository.NewHoldingRepositoryWithDB(tx).GetDeleted()
+	if err != nil {
+		return nil, err
+	}
+	for _, holding := range holdings {
+		items = append(items, TrashItem{
+			Entity:    models.AuditEntityHolding,
+			ID:        holding.ID,
+			Name:      fmt.Sprintf("%s %s in %s", holding.Amount, holding.Asset.Symbol, holding.Account.Name),
+			DeletedAt: holding.DeletedAt.Time,
+		})
+	}
+
+	accounts, err := repository.NewAccountRepositoryWithDB(tx).GetDeleted()
+	if err != nil {
+		return nil, err
+	}
+	for _, account := range accounts {
+		items = append(items, TrashItem{
+			Entity:    models.AuditEntityAccount,
+			ID:        account.ID,
+			Name:      account.Name,
+			DeletedAt: account.DeletedAt.Time,
+		})
+	}
+
+	assets, err := repository.NewAssetRepositoryWithDB(tx).GetDeleted()
+	if err != nil {
+		return nil, err
+	}
+	for _, asset := range assets {
+		items = append(items, TrashItem{
+			Entity:    models.AuditEntityAsset,
+			ID:        asset.ID,
+			Name:      fmt.Sprintf("%s (%s)", asset.Symbol, asset.Name),
+			DeletedAt: asset.DeletedAt.Time,
+		})
+	}
+
+	sort.SliceStable(items, func(i, j int) bool { return items[i].DeletedAt.After(items[j].DeletedAt) })
+	return items, nil
+}
+
+// Restore takes a holding, account or asset out of the trash. A holding
+// comes back with the amount and price it had, through a deposit, once its
+// account and asset are restored.
+func (s *TrashService) Restore(entity models.AuditLogEntityType, id uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		name, deleted, err := trashRow(tx, entity, id)
+		if err != nil {
+			return err
+		}
+		if !deleted {
+			return fmt.Errorf("%s is not in the trash", name)
+		}
+
+		switch entity {
+		case models.AuditEntityHolding:
+			return reopenDeletedHolding(tx, id)
+		case models.AuditEntityAccount:
+			accountRepo := repository.NewAccountRepositoryWithDB(tx)
+			var account models.Account
+			if err := tx.Unscoped().First(&account, id).Error; err != nil {
+				return err
+			}
+			_, err := accountRepo.GetByName(account.Name)
+			if err == nil {
+				return fmt.Errorf("account %q already exists, rename it first", account.Name)
+			}
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			return accountRepo.Restore(id)
+		default:
+			return repository.NewAssetRepositoryWithDB(tx).Restore(id)
+		}
+	})
+}
+
+func reopenDeletedHolding(tx *gorm.DB, id uint) error {
+	var holding models.Holding
+	unscoped := func(tx *gorm.DB) *gorm.DB { return tx.Unscoped() }
+	err := tx.Unscoped().Preload("Account", unscoped).Preload("Asset", unscoped).First(&holding, id).Error
+	if err != nil {
+		return err
+	}
+	if holding.Account.DeletedAt.Valid {
+		return fmt.Errorf("restore account %s first", holding.Account.Name)
+	}
+	if holding.Asset.DeletedAt.Valid {
+		return fmt.Errorf("restore asset %s first", holding.Asset.Symbol)
+	}
+
+	var held int64
+	err = tx.Model(&models.Holding{}).
+		Where("account_id = ? AND asset_id = ?", holding.AccountID, holding.AssetID).
+		Count(&held).Error
+	if err != nil {
+		return err
+	}
+	if held > 0 {
+		return fmt.Errorf("%s is held in %s again, edit that holding instead", holding.Asset.Symbol, holding.Account.Name)
+	}
+	return reopenHolding(NewLedgerServiceWithDB(tx), holding.AccountID, holding.AssetID, holding.Amount, holding.PurchasePrice)
+}
+
+// Purge removes a holding, account or asset in the trash for good
+func (s *TrashService) Purge(entity models.AuditLogEntityType, id uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return purge(tx, entity, id)
+	})
+}
+
+func purge(tx *gorm.DB, entity models.AuditLogEntityType, id uint) error {
+	name, deleted, err := trashRow(tx, entity, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return fmt.Errorf("%s is not in the trash", name)
+	}
+	reason, err := purgeRefused(tx, entity, id)
+	if err != nil {
+		return err
+	}
+	if reason != "" {
+		return fmt.Errorf("cannot purge %s: %s", name, reason)
+	}
+
+	if entity == models.AuditEntityHolding {
+		return repository.NewHoldingRepositoryWithDB(tx).Purge(id)
+	}
+	if err := purgeLedger(tx, entity, id, name); err != nil {
+		return err
+	}
+	if entity == models.AuditEntityAccount {
+		return repository.NewAccountRepositoryWithDB(tx).Purge(id)
+	}
+	return repository.NewAssetRepositoryWithDB(tx).Purge(id)
+}
+
+// purgeLedger purges the transactions of an account or asset about to be
+// purged, each audited with a note naming it. A transfer between a purged
+// account and one that stays becomes a deposit or withdrawal of the other
+// account, so its balance and cost basis do not change.
+func purgeLedger(tx *gorm.DB, entity models.AuditLogEntityType, id uint, name string) error {
+	where := "asset_id = @id"
+	if entity == models.AuditEntityAccount {
+		where = "account_id = @id OR to_account_id = @id"
+	}
+	var transactions []models.Transaction
+	err := tx.Unscoped().Where(where, map[string]interface{}{"id": id}).Order("id asc").Find(&transactions).Error
+	if err != nil {
+		return err
+	}
+
+	transactionRepo := repository.NewTransactionRepositoryWithDB(models.WithAuditNote(tx, "Purged with "+name))
+	for _, transaction := range transactions {
+		if err := transactionRepo.Purge(transaction.ID); err != nil {
+			return err
+		}
+		transfer := transaction.Type == models.TransactionTransfer && transaction.ToAccountID != nil
+		if entity != models.AuditEntityAccount || !transfer || transaction.DeletedAt.Valid ||
+			transaction.AccountID == *transaction.ToAccountID {
+			continue
+		}
+
+		kept := models.Transaction{
+			Type:      models.TransactionDeposit,
+			AccountID: *transaction.ToAccountID,
+			AssetID:   transaction.AssetID,
+			Quantity:  transaction.Quantity,
+			PriceUSD:  transaction.PriceUSD,
+			Fee:       transaction.Fee,
+			Note:      "Transfer from " + name,
+			Timestamp: transaction.Timestamp,
+		}
+		if transaction.AccountID != id {
+			kept.Type, kept.AccountID, kept.Note = models.TransactionWithdrawal, transaction.AccountID, "Transfer to "+name
+		}
+		if err := transactionRepo.Create(&kept); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// trashRow names a holding, account or asset and tells whether it is deleted
+func trashRow(tx *gorm.DB, entity models.AuditLogEntityType, id uint) (string, bool, error) {
+	switch entity {
+	case models.AuditEntityHolding:
+		var holding models.Holding
+		err := tx.Unscoped().First(&holding, id).Error
+		return fmt.Sprintf("holding #%d", id), holding.DeletedAt.Valid, err
+	case models.AuditEntityAccount:
+		var account models.Account
+		err := tx.Unscoped().First(&account, id).Error
+		return "account " + account.Name, account.DeletedAt.Valid, err
+	case models.AuditEntityAsset:
+		var asset models.Asset
+		err := tx.Unscoped().First(&asset, id).Error
+		return "asset " + asset.Symbol, asset.DeletedAt.Valid, err
+	}
+	return "", false, fmt.Errorf("unknown trash item %s", entity)
+}
+
+// purgeRefused explains why a deleted account or asset has to stay: deleted
+// holdings still refer to it and go first. It is empty when none do.
+func purgeRefused(tx *gorm.DB, entity models.AuditLogEntityType, id uint) (string, error) {
+	var column string
+	switch entity {
+	case models.AuditEntityAccount:
+		column = "account_id"
+	case models.AuditEntityAsset:
+		column = "asset_id"
+	default:
+		return "", nil
+	}
+
+	var holdings int64
+	err := tx.Unscoped().Model(&models.Holding{}).Where(column+" = ?", id).Count(&holdings).Error
+	if err != nil || holdings == 0 {
+		return "", err
+	}
+	return fmt.Sprintf("still used by %d holding(s) in the trash", holdings), nil
+}
+
+// PurgeExpired purges what was deleted more than the configured number of
+// days ago and returns how much it purged. Accounts and assets that deleted
+// holdings still use stay until those holdings are gone.
+func (s *TrashService) PurgeExpired() (int, error) {
+	days, err := s.Days()
+	if err != nil || days == 0 {
+		return 0, err
+	}
+	cutoff := time.Now().AddDate(0, 0, -days)
+
+	purged := 0
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		items, err := trashItems(tx)
+		if err != nil {
+			return err
+		}
+		// Holdings go first so the accounts and assets they used can follow
+		sort.SliceStable(items, func(i, j int) bool {
+			return items[i].Entity == models.AuditEntityHolding && items[j].Entity != models.AuditEntityHolding
+		})
+		for _, item := range items {
+			if item.DeletedAt.After(cutoff) {
+				continue
+			}
+			reason, err := purgeRefused(tx, item.Entity, item.ID)
+			if err != nil {
+				return err
+			}
+			if reason != "" {
+				continue
+			}
+			if err := purge(tx, item.Entity, item.ID); err != nil {
+				return err
+			}
+			purged++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return purged, nil
+}
+
+// Days returns after how many days deleted rows are purged, 0 for never
+func (s *TrashService) Days() (int, error) {
+	value, err := s.settingRepo.Get(SettingTrashDays)
+	if err != nil || value == "" {
+		return DefaultTrashDays, err
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 0 {
+		return DefaultTrashDays, nil
+	}
+	return days, nil
+}
+
+// SetDays sets after how many days deleted rows are purged, 0 for never
+func (s *TrashService) SetDays(days int) error {
+	if days < 0 {
+		return fmt.Errorf("days cannot be negative")
+	}
+	return s.settingRepo.Set(SettingTrashDays, strconv.Itoa(days))
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bioharz/budget/internal/models"
+	"github.com/bioharz/budget/internal/repository"
+	"github.com/bioharz/budget/test/fixtures"
+	"github.com/bioharz/budget/test/helpers"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestTrashService_RestoreAndPurge(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	service := NewTrashServiceWithDB(db)
+	ledger := NewLedgerServiceWithDB(db)
+	account := fixtures.NewAccount().WithName("Ledger").Create(t, db)
+	asset := fixtures.NewAsset().WithSymbol("BTC").Create(t, db)
+
+	holding, err := ledger.Record(&models.Transaction{
+		Type: models.TransactionBuy, AccountID: account.ID, AssetID: asset.ID,
+		Quantity: decimal.NewFromInt(2), PriceUSD: decimal.NewFromInt(30000),
+	})
+	require.NoError(t, err)
+	require.NoError(t, ledger.CloseHolding(*holding))
+
+	items, err := service.GetAll()
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, models.AuditEntityHolding, items[0].Entity)
+	assert.Equal(t, "2 BTC in Ledger", items[0].Name)
+	assert.WithinDuration(t, time.Now(), items[0].DeletedAt, time.Minute)
+
+	require.NoError(t, service.Restore(models.AuditEntityHolding, holding.ID))
+	restored, err := repository.NewHoldingRepositoryWithDB(db).GetByID(holding.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "2", restored.Amount.String())
+	assert.Equal(t, "30000", restored.PurchasePrice.String())
+	assert.ErrorContains(t, service.Restore(models.AuditEntityHolding, holding.ID), "not in the trash")
+	assert.ErrorContains(t, service.Purge(models.AuditEntityHolding, holding.ID), "not in the trash")
+
+	require.NoError(t, ledger.CloseHolding(restored))
+	require.NoError(t, service.Purge(models.AuditEntityHolding, holding.ID))
+	err = db.Unscoped().First(&models.Holding{}, holding.ID).Error
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	items, err = service.GetAll()
+	require.NoError(t, err)
+	assert.Empty(t, items)
+
+	logs, err := repository.NewAuditLogRepository(db).GetByEntity(models.AuditEntityHolding, holding.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.AuditActionPurge, logs[0].Action)
+	assert.Contains(t, logs[0].OldValue, `"amount":"2"`)
+
+	// A purged holding is gone, undo moves on to what came before it
+	entry, err := NewUndoServiceWithDB(db).Undo()
+	require.NoError(t, err)
+	assert.Equal(t, "create of asset #1", DescribeChange(entry))
+}
+
+func TestTrashService_PurgeExpired(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	service := NewTrashServiceWithDB(db)
+	ledger := NewLedgerServiceWithDB(db)
+	wallet := fixtures.NewAccount().WithName("Wallet").Create(t, db)
+	old := fixtures.NewAccount().WithName("Old").Create(t, db)
+	recent := fixtures.NewAccount().WithName("Recent").Create(t, db)
+	asset := fixtures.NewAsset().WithSymbol("BTC").Create(t, db)
+
+	holding, err := ledger.Record(&models.Transaction{
+		Type: models.TransactionDeposit, AccountID: wallet.ID, AssetID: asset.ID, Quantity: decimal.NewFromInt(1),
+	})
+	require.NoError(t, err)
+	require.NoError(t, ledger.CloseHolding(*holding))
+	require.NoError(t, repository.NewAssetRepositoryWithDB(db).Delete(asset.ID))
+	require.NoError(t, repository.NewAccountRepositoryWithDB(db).Delete(old.ID))
+	require.NoError(t, repository.NewAccountRepositoryWithDB(db).Delete(recent.ID))
+
+	assert.EqualError(t, service.Purge(models.AuditEntityAsset, asset.ID),
+		"cannot purge asset BTC: still used by 1 holding(s) in the trash")
+
+	longAgo := time.Now().AddDate(0, 0, -40)
+	for _, table := range []string{"holdings", "assets"} {
+		require.NoError(t, db.Exec("UPDATE "+table+" SET deleted_at = ? WHERE deleted_at IS NOT NULL", longAgo).Error)
+	}
+	require.NoError(t, db.Exec("UPDATE accounts SET deleted_at = ? WHERE id = ?", longAgo, old.ID).Error)
+
+	days, err := service.Days()
+	require.NoError(t, err)
+	assert.Equal(t, DefaultTrashDays, days)
+
+	purged, err := service.PurgeExpired()
+	require.NoError(t, err)
+	assert.Equal(t, 3, purged, "the holding, then the asset with its ledger, and the old account")
+
+	items, err := service.GetAll()
+	require.NoError(t, err)
+	var names []string
+	for _, item := range items {
+		names = append(names, item.Name)
+	}
+	assert.Equal(t, []string{"Recent"}, names)
+
+	transactions, err := repository.NewTransactionRepositoryWithDB(db).GetAll()
+	require.NoError(t, err)
+	assert.Empty(t, transactions)
+	purges, err := repository.NewAuditLogRepository(db).GetByAction(models.AuditActionPurge, 10)
+	require.NoError(t, err)
+	assert.Len(t, purges, 5, "the holding, the asset, the account and the deposit and withdrawal")
+
+	require.NoError(t, service.SetDays(0))
+	require.NoError(t, db.Exec("UPDATE accounts SET deleted_at = ? WHERE id = ?", longAgo, recent.ID).Error)
+	purged, err = service.PurgeExpired()
+	require.NoError(t, err)
+	assert.Zero(t, purged, "0 days keeps the trash")
+	assert.Error(t, service.SetDays(-1))
+}
+
+func TestTrashService_PurgeAccountWithTransfers(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	service := NewTrashServiceWithDB(db)
+	ledger := NewLedgerServiceWithDB(db)
+	exchange := fixtures.NewAccount().WithName("Exchange").Create(t, db)
+	wallet := fixtures.NewAccount().WithName("Wallet").Create(t, db)
+	asset := fixtures.NewAsset().WithSymbol("BTC").Create(t, db)
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)
+
+	_, err := ledger.Record(&models.Transaction{
+		Type: models.TransactionBuy, AccountID: exchange.ID, AssetID: asset.ID,
+		Quantity: decimal.NewFromInt(2), PriceUSD: decimal.NewFromInt(30000), Timestamp: day,
+	})
+	require.NoError(t, err)
+	to := wallet.ID
+	_, err = ledger.Record(&models.Transaction{
+		Type: models.TransactionTransfer, AccountID: exchange.ID, ToAccountID: &to, AssetID: asset.ID,
+		Quantity: decimal.NewFromInt(2), Timestamp: day.AddDate(0, 0, 1),
+	})
+	require.NoError(t, err)
+	require.NoError(t, repository.NewAccountRepositoryWithDB(db).Delete(exchange.ID))
+	items, err := service.GetAll()
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+	assert.Equal(t, "2 BTC in Exchange", items[1].Name)
+	require.NoError(t, service.Purge(models.AuditEntityHolding, items[1].ID))
+
+	// The wallet keeps what was moved in, at the price it came with
+	require.NoError(t, service.Purge(models.AuditEntityAccount, exchange.ID))
+	transactions, err := ledger.GetTransactions(wallet.ID, asset.ID)
+	require.NoError(t, err)
+	require.Len(t, transactions, 1)
+	assert.Equal(t, models.TransactionDeposit, transactions[0].Type)
+	assert.Equal(t, "30000", transactions[0].PriceUSD.String())
+	assert.Equal(t, "Transfer from account Exchange", transactions[0].Note)
+
+	position, err := ledger.GetPosition(wallet.ID, asset.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "2", position.Amount.String())
+	assert.Equal(t, "30000", position.AverageCost.String())
+	assert.True(t, day.AddDate(0, 0, 1).Equal(position.PurchaseDate))
+
+	logs, err := repository.NewAuditLogRepository(db).GetByAction(models.AuditActionPurge, 10)
+	require.NoError(t, err)
+	require.Len(t, logs, 4)
+	var entities []models.AuditLogEntityType
+	for _, log := range logs {
+		entities = append(entities, log.EntityType)
+		if log.EntityType == models.AuditEntityTransaction {
+			assert.Equal(t, "Purged with account Exchange", log.UserNote)
+		}
+	}
+	assert.ElementsMatch(t, []models.AuditLogEntityType{
+		models.AuditEntityHolding, models.AuditEntityTransaction, models.AuditEntityTransaction, models.AuditEntityAccount,
+	}, entities)
+}
+
+func TestTrashService_RestoreOrder(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	service := NewTrashServiceWithDB(db)
+	account := fixtures.NewAccount().WithName("Ledger").Create(t, db)
+	asset := fixtures.NewAsset().Create(t, db)
+	holding := fixtures.NewHolding().WithAccount(account).WithAsset(asset).WithAmount(1).Create(t, db)
+	require.NoError(t, repository.NewHoldingRepositoryWithDB(db).Delete(holding.ID))
+	require.NoError(t, repository.NewAccountRepositoryWithDB(db).Delete(account.ID))
+
+	assert.EqualError(t, service.Restore(models.AuditEntityHolding, holding.ID), "restore account Ledger first")
+	fixtures.NewAccount().WithName("Ledger").Create(t, db)
+	assert.ErrorContains(t, service.Restore(models.AuditEntityAccount, account.ID), "already exists")
+}
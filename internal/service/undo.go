@@ -0,0 +1,247 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bioharz/budget/internal/db"
+	"github.com/bioharz/budget/internal/models"
+	"github.com/bioharz/budget/internal/repository"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNothingToUndo = errors.New("nothing to undo")
+	ErrNothingToRedo = errors.New("nothing to redo")
+)
+
+// Notes of the audit entries an undo or redo writes. Those entries are not
+// changes of their own and are never undone.
+const (
+	undoNotePrefix = "Undo of #"
+	redoNotePrefix = "Redo of #"
+)
+
+// UndoService reverts the changes in the audit log, newest first, and
+// applies reverted changes again. Holdings are derived from the ledger, so
+// their old values are replayed as transactions; accounts and assets are
+// written back directly. Both are audited like any other change.
+type UndoService struct {
+	db *gorm.DB
+}
+
+func NewUndoService() *UndoService {
+	return &UndoService{db: db.DB}
+}
+
+func NewUndoServiceWithDB(database *gorm.DB) *UndoService {
+	return &UndoService{db: database}
+}
+
+// Undo reverts the newest change that is not undone yet and returns its
+// audit entry
+func (s *UndoService) Undo() (models.AuditLog, error) {
+	var entry models.AuditLog
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		err := changes(tx).Where("undone = ?", false).Order("id desc").First(&entry).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNothingToUndo
+		}
+		if err != nil {
+			return err
+		}
+
+		noted := models.WithAuditNote(tx, fmt.Sprintf("%s%d", undoNotePrefix, entry.ID))
+		if err := restoreValue(noted, entry.EntityType, entry.EntityID, entry.OldValue); err != nil {
+			return fmt.Errorf("failed to undo %s: %w", DescribeChange(entry), err)
+		}
+		return tx.Model(&models.AuditLog{ID: entry.ID}).Update("undone", true).Error
+	})
+	return entry, err
+}
+
+// Redo applies the change undone last again. A change made after an undo
+// ends what can be redone.
+func (s *UndoService) Redo() (models.AuditLog, error) {
+	var entry models.AuditLog
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var latest uint
+		err := changes(tx).Model(&models.AuditLog{}).Where("undone = ?", false).
+			Select("COALESCE(MAX(id), 0)").Scan(&latest).Error
+		if err != nil {
+			return err
+		}
+		err = changes(tx).Where("undone = ? AND id > ?", true, latest).Order("id asc").First(&entry).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNothingToRedo
+		}
+		if err != nil {
+			return err
+		}
+
+		noted := models.WithAuditNote(tx, fmt.Sprintf("%s%d", redoNotePrefix, entry.ID))
+		if err := restoreValue(noted, entry.EntityType, entry.EntityID, entry.NewValue); err != nil {
+			return fmt.Errorf("failed to redo %s: %w", DescribeChange(entry), err)
+		}
+		return tx.Model(&models.AuditLog{ID: entry.ID}).Update("undone", false).Error
+	})
+	return entry, err
+}
+
+// changes selects the audit entries that undo and redo step through. Rows
+// purged from the trash are gone, so their entries are left out.
+func changes(tx *gorm.DB) *gorm.DB {
+	return tx.Session(&gorm.Session{NewDB: true}).
+		Where("COALESCE(user_note, '') NOT LIKE ? AND COALESCE(user_note, '') NOT LIKE ?", undoNotePrefix+"%", redoNotePrefix+"%").
+		Where(`NOT EXISTS (SELECT 1 FROM audit_logs purged WHERE purged.action = ?
+			AND purged.entity_type = audit_logs.entity_type AND purged.entity_id = audit_logs.entity_id)`, models.AuditActionPurge)
+}
+
+// DescribeChange names the change an audit entry records, e.g.
+// "update of holding #3"
+func DescribeChange(entry models.AuditLog) string {
+	return fmt.Sprintf("%s of %s #%d",
+		strings.ToLower(string(entry.Action)), strings.ToLower(string(entry.EntityType)), entry.EntityID)
+}
+
+// restoreValue brings a row to the state an audit value records, an empty
+// value meaning deleted
+func restoreValue(tx *gorm.DB, entity models.AuditLogEntityType, id uint, value string) error {
+	switch entity {
+	case models.AuditEntityHolding:
+		return restoreHolding(tx, id, value)
+	case models.AuditEntityAccount:
+		return restoreAccount(tx, id, value)
+	case models.AuditEntityAsset:
+		return restoreAsset(tx, id, value)
+	default:
+		return fmt.Errorf("cannot revert changes of %s", entity)
+	}
+}
+
+// restoreHolding replays a holding's value through the ledger: a deposit
+// brings a deleted holding back, a withdrawal of its balance deletes it and
+// an adjustment moves it to the old account, asset, amount and price
+func restoreHolding(tx *gorm.DB, id uint, value string) error {
+	ledger := NewLedgerServiceWithDB(tx)
+	var current models.Holding
+	err := tx.Unscoped().First(&current, id).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	live := err == nil && !current.DeletedAt.Valid
+
+	if value == "" {
+		if !live {
+			return nil
+		}
+		return ledger.CloseHolding(current)
+	}
+
+	var old struct {
+		AccountID     uint            `json:"account_id"`
+		AssetID       uint            `json:"asset_id"`
+		Amount        decimal.Decimal `json:"amount"`
+		PurchasePrice decimal.Decimal `json:"purchase_price"`
+	}
+	if err := json.Unmarshal([]byte(value), &old); err != nil {
+		return fmt.Errorf("failed to read holding from the audit log: %w", err)
+	}
+	if live {
+		_, err = ledger.AdjustHolding(current, old.AccountID, old.AssetID, old.Amount, old.PurchasePrice)
+		return err
+	}
+	return reopenHolding(ledger, old.AccountID, old.AssetID, old.Amount, old.PurchasePrice)
+}
+
+// reopenHolding deposits the amount a deleted holding had at its price,
+// which brings the holding back
+func reopenHolding(ledger *LedgerService, accountID, assetID uint, amount, price decimal.Decimal) error {
+	_, err := ledger.Record(&models.Transaction{
+		Type:      models.TransactionDeposit,
+		AccountID: accountID,
+		AssetID:   assetID,
+		Quantity:  amount,
+		PriceUSD:  price,
+		Note:      "Restored holding",
+		Timestamp: time.Now(),
+	})
+	return err
+}
+
+func restoreAccount(tx *gorm.DB, id uint, value string) error {
+	accountRepo := repository.NewAccountRepositoryWithDB(tx)
+	var account models.Account
+	if err := tx.Unscoped().First(&account, id).Error; err != nil {
+		return err
+	}
+
+	if value == "" {
+		if account.DeletedAt.Valid {
+			return nil
+		}
+		if err := refuseHeld(tx, "account_id", id, account.Name); err != nil {
+			return err
+		}
+		return accountRepo.Delete(id)
+	}
+
+	var old struct {
+		Name  string `json:"name"`
+		Type  string `json:"type"`
+		Color string `json:"color"`
+	}
+	if err := json.Unmarshal([]byte(value), &old); err != nil {
+		return fmt.Errorf("failed to read account from the audit log: %w", err)
+	}
+	account.Name, account.Type, account.Color = old.Name, old.Type, old.Color
+	account.DeletedAt = gorm.DeletedAt{}
+	return accountRepo.Update(&account)
+}
+
+func restoreAsset(tx *gorm.DB, id uint, value string) error {
+	assetRepo := repository.NewAssetRepositoryWithDB(tx)
+	var asset models.Asset
+	if err := tx.Unscoped().First(&asset, id).Error; err != nil {
+		return err
+	}
+
+	if value == "" {
+		if asset.DeletedAt.Valid {
+			return nil
+		}
+		if err := refuseHeld(tx, "asset_id", id, asset.Symbol); err != nil {
+			return err
+		}
+		return assetRepo.Delete(id)
+	}
+
+	var old struct {
+		Symbol     string           `json:"symbol"`
+		Name       string           `json:"name"`
+		Type       models.AssetType `json:"type"`
+		ProviderID string           `json:"provider_id"`
+	}
+	if err := json.Unmarshal([]byte(value), &old); err != nil {
+		return fmt.Errorf("failed to read asset from the audit log: %w", err)
+	}
+	asset.Symbol, asset.Name, asset.Type, asset.ProviderID = old.Symbol, old.Name, old.Type, old.ProviderID
+	asset.DeletedAt = gorm.DeletedAt{}
+	return assetRepo.Update(&asset)
+}
+
+// refuseHeld keeps an account or asset that holdings still refer to
+func refuseHeld(tx *gorm.DB, column string, id uint, name string) error {
+	var holdings int64
+	if err := tx.Model(&models.Holding{}).Where(column+" = ?", id).Count(&holdings).Error; err != nil {
+		return err
+	}
+	if holdings > 0 {
+		return fmt.Errorf("%s is still held in %d holding(s)", name, holdings)
+	}
+	return nil
+}
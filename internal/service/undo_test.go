@@ -0,0 +1,131 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/bioharz/budget/internal/models"
+	"github.com/bioharz/budget/internal/repository"
+	"github.com/bioharz/budget/test/fixtures"
+	"github.com/bioharz/budget/test/helpers"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestUndoService_Holding(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	service := NewUndoServiceWithDB(db)
+	ledger := NewLedgerServiceWithDB(db)
+	account := fixtures.NewAccount().Create(t, db)
+	asset := fixtures.NewAsset().Create(t, db)
+
+	holding, err := ledger.Record(&models.Transaction{
+		Type: models.TransactionBuy, AccountID: account.ID, AssetID: asset.ID,
+		Quantity: decimal.NewFromInt(1), PriceUSD: decimal.NewFromInt(30000),
+	})
+	require.NoError(t, err)
+	holding, err = ledger.AdjustHolding(*holding, account.ID, asset.ID, decimal.NewFromInt(3), decimal.NewFromInt(30000))
+	require.NoError(t, err)
+	require.NoError(t, ledger.CloseHolding(*holding))
+
+	amount := func() string {
+		t.Helper()
+		var holdings []models.Holding
+		require.NoError(t, db.Where("account_id = ? AND asset_id = ?", account.ID, asset.ID).Find(&holdings).Error)
+		if len(holdings) == 0 {
+			return "none"
+		}
+		require.Len(t, holdings, 1)
+		return holdings[0].Amount.String()
+	}
+
+	entry, err := service.Undo()
+	require.NoError(t, err)
+	assert.Equal(t, models.AuditActionDelete, entry.Action)
+	assert.Equal(t, "3", amount(), "the deleted holding is back")
+
+	entry, err = service.Undo()
+	require.NoError(t, err)
+	assert.Equal(t, "update of holding #1", DescribeChange(entry))
+	assert.Equal(t, "1", amount())
+
+	_, err = service.Redo()
+	require.NoError(t, err)
+	assert.Equal(t, "3", amount())
+	entry, err = service.Redo()
+	require.NoError(t, err)
+	assert.Equal(t, models.AuditActionDelete, entry.Action)
+	assert.Equal(t, "none", amount())
+	_, err = service.Redo()
+	assert.ErrorIs(t, err, ErrNothingToRedo)
+
+	// Reverts are audited but are not changes to undo themselves
+	logs, err := repository.NewAuditLogRepository(db).GetByEntity(models.AuditEntityHolding, holding.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Redo of #5", logs[0].UserNote)
+	assert.Equal(t, "Undo of #5", logs[3].UserNote)
+	_, err = service.Undo()
+	require.NoError(t, err)
+	assert.Equal(t, "3", amount())
+
+	// A new change ends what can be redone
+	_, err = NewAccountServiceWithDB(db).Rename(account.ID, "Ledger")
+	require.NoError(t, err)
+	_, err = service.Redo()
+	assert.ErrorIs(t, err, ErrNothingToRedo)
+}
+
+func TestUndoService_AccountsAndAssets(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	service := NewUndoServiceWithDB(db)
+	account := fixtures.NewAccount().WithName("ledger").Create(t, db)
+	asset := fixtures.NewAsset().WithSymbol("BTC").Create(t, db)
+
+	_, err := NewAccountServiceWithDB(db).Rename(account.ID, "Ledger Nano")
+	require.NoError(t, err)
+	require.NoError(t, NewAssetServiceWithDB(db).Delete(asset.ID))
+
+	_, err = service.Undo()
+	require.NoError(t, err)
+	restored, err := repository.NewAssetRepositoryWithDB(db).GetBySymbol("BTC")
+	require.NoError(t, err, "the deleted asset is back")
+	assert.Equal(t, asset.ID, restored.ID)
+
+	_, err = service.Undo()
+	require.NoError(t, err)
+	renamed, err := repository.NewAccountRepositoryWithDB(db).GetByID(account.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "ledger", renamed.Name)
+
+	for range 2 {
+		_, err = service.Undo()
+		require.NoError(t, err)
+	}
+	_, err = repository.NewAccountRepositoryWithDB(db).GetByID(account.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound, "undoing its creation deletes the account")
+	_, err = service.Undo()
+	assert.ErrorIs(t, err, ErrNothingToUndo)
+
+	entry, err := service.Redo()
+	require.NoError(t, err)
+	assert.Equal(t, "create of account #1", DescribeChange(entry))
+	_, err = repository.NewAccountRepositoryWithDB(db).GetByID(account.ID)
+	assert.NoError(t, err)
+}
+
+func TestUndoService_RefusesHeldAccount(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	account := fixtures.NewAccount().Create(t, db)
+	asset := fixtures.NewAsset().Create(t, db)
+	// Raw SQL is not audited, so the newest change is the asset's creation
+	require.NoError(t, db.Exec("INSERT INTO holdings (account_id, asset_id, amount) VALUES (?, ?, '1')", account.ID, asset.ID).Error)
+
+	_, err := NewUndoServiceWithDB(db).Undo()
+	assert.ErrorContains(t, err, "still held")
+	logs, err := repository.NewAuditLogRepository(db).GetAll(0)
+	require.NoError(t, err)
+	for _, log := range logs {
+		assert.False(t, log.Undone)
+	}
+}
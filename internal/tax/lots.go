@@ -0,0 +1,310 @@
+// Package tax matches sells against purchase lots to compute realized gains.
+package tax
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bioharz/budget/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// Method selects which lots a sell consumes first
+type Method string
+
+const (
+	MethodFIFO     Method = "fifo"     // Oldest lots first
+	MethodLIFO     Method = "lifo"     // Newest lots first
+	MethodHIFO     Method = "hifo"     // Highest unit cost first
+	MethodSpecific Method = "specific" // Lots chosen per sell, FIFO for the rest
+)
+
+// ParseMethod accepts a method name in any case
+func ParseMethod(name string) (Method, error) {
+	method := Method(strings.ToLower(strings.TrimSpace(name)))
+	switch method {
+	case MethodFIFO, MethodLIFO, MethodHIFO, MethodSpecific:
+		return method, nil
+	default:
+		return "", fmt.Errorf("unknown lot method %q (fifo, lifo, hifo or specific)", name)
+	}
+}
+
+// Lot is what is left of one acquisition. Its ID is the acquiring
+// transaction's ID.
+type Lot struct {
+	ID       uint
+	AssetID  uint
+	Symbol   string
+	Acquired time.Time
+	Quantity decimal.Decimal
+	UnitCost decimal.Decimal // USD per unit including the purchase fee
+}
+
+// Selection picks Quantity units from a lot for a specific-ID sell
+type Selection struct {
+	LotID    uint
+	Quantity decimal.Decimal
+}
+
+// Disposal is the part of a sell matched against one lot
+type Disposal struct {
+	SaleID    uint
+	LotID     uint
+	AssetID   uint
+	Symbol    string
+	Acquired  time.Time
+	Sold      time.Time
+	Quantity  decimal.Decimal
+	Proceeds  decimal.Decimal
+	CostBasis decimal.Decimal
+	Gain      decimal.Decimal
+	LongTerm  bool
+}
+
+// YearSummary totals the realized gains of one tax year
+type YearSummary struct {
+	Year      int
+	Proceeds  decimal.Decimal
+	CostBasis decimal.Decimal
+	ShortTerm decimal.Decimal
+	LongTerm  decimal.Decimal
+}
+
+// Total is the net realized gain of the year
+func (y YearSummary) Total() decimal.Decimal {
+	return y.ShortTerm.Add(y.LongTerm)
+}
+
+// Report is the result of matching a ledger against its lots
+type Report struct {
+	Method    Method
+	Disposals []Disposal
+	Years     []YearSummary
+	OpenLots  []Lot
+}
+
+// Year returns the summary of one tax year, zero if nothing was sold in it
+func (r *Report) Year(year int) YearSummary {
+	for _, summary := range r.Years {
+		if summary.Year == year {
+			return summary
+		}
+	}
+	return YearSummary{Year: year}
+}
+
+// IsLongTerm reports whether a lot held from acquired to sold was held for
+// more than a year
+func IsLongTerm(acquired, sold time.Time) bool {
+	return sold.After(acquired.AddDate(1, 0, 0))
+}
+
+// Compute replays transactions in time order. Buys, deposits and income
+// open lots; sells realize gains against them using method. Withdrawals and
+// fees take units out without realizing a gain, and transfers between
+// accounts leave lots untouched since lots are pooled per asset. A cost
+// adjustment reprices as many units of the asset's open lots as it covers,
+// oldest first, and keeps their acquisition dates.
+// selections maps a sell's transaction ID to its chosen lots.
+func Compute(transactions []models.Transaction, method Method, selections map[uint][]Selection) (*Report, error) {
+	sorted := make([]models.Transaction, len(transactions))
+	copy(sorted, transactions)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Timestamp.Equal(sorted[j].Timestamp) {
+			return sorted[i].ID < sorted[j].ID
+		}
+		return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+	})
+
+	report := &Report{Method: method}
+	lots := make(map[uint][]*Lot) // open lots per asset, oldest first
+	years := make(map[int]*YearSummary)
+
+	for _, t := range sorted {
+		switch {
+		case t.Type.IsInflow():
+			unitCost := t.PriceUSD
+			if t.Type == models.TransactionBuy && t.Quantity.IsPositive() {
+				unitCost = unitCost.Add(t.Fee.Div(t.Quantity))
+			}
+			lots[t.AssetID] = append(lots[t.AssetID], &Lot{
+				ID:       t.ID,
+				AssetID:  t.AssetID,
+				Symbol:   t.Asset.Symbol,
+				Acquired: t.Timestamp,
+				Quantity: t.Quantity,
+				UnitCost: unitCost,
+			})
+
+		case t.Type == models.TransactionSell:
+			matches, err := match(lots[t.AssetID], t, method, selections[t.ID])
+			if err != nil {
+				return nil, err
+			}
+			// The sell fee is shared by the matched lots in proportion
+			proceeds := t.Quantity.Mul(t.PriceUSD).Sub(t.Fee)
+			for _, m := range matches {
+				d := Disposal{
+					SaleID:    t.ID,
+					LotID:     m.lot.ID,
+					AssetID:   t.AssetID,
+					Symbol:    t.Asset.Symbol,
+					Acquired:  m.lot.Acquired,
+					Sold:      t.Timestamp,
+					Quantity:  m.quantity,
+					Proceeds:  proceeds.Mul(m.quantity).Div(t.Quantity),
+					CostBasis: m.quantity.Mul(m.lot.UnitCost),
+					LongTerm:  IsLongTerm(m.lot.Acquired, t.Timestamp),
+				}
+				d.Gain = d.Proceeds.Sub(d.CostBasis)
+				report.Disposals = append(report.Disposals, d)
+
+				summary := years[t.Timestamp.Year()]
+				if summary == nil {
+					summary = &YearSummary{Year: t.Timestamp.Year()}
+					years[t.Timestamp.Year()] = summary
+				}
+				summary.Proceeds = summary.Proceeds.Add(d.Proceeds)
+				summary.CostBasis = summary.CostBasis.Add(d.CostBasis)
+				if d.LongTerm {
+					summary.LongTerm = summary.LongTerm.Add(d.Gain)
+				} else {
+					summary.ShortTerm = summary.ShortTerm.Add(d.Gain)
+				}
+			}
+			lots[t.AssetID] = openLots(lots[t.AssetID])
+
+		case t.Type == models.TransactionWithdrawal || t.Type == models.TransactionFee:
+			if _, err := match(lots[t.AssetID], t, method, nil); err != nil {
+				return nil, err
+			}
+			lots[t.AssetID] = openLots(lots[t.AssetID])
+
+		case t.Type == models.TransactionAdjustment:
+			reprice(lots[t.AssetID], t.Quantity, t.PriceUSD)
+		}
+	}
+
+	for _, summary := range years {
+		report.Years = append(report.Years, *summary)
+	}
+	sort.Slice(report.Years, func(i, j int) bool {
+		return report.Years[i].Year < report.Years[j].Year
+	})
+
+	for _, assetLots := range lots {
+		for _, lot := range assetLots {
+			report.OpenLots = append(report.OpenLots, *lot)
+		}
+	}
+	sort.Slice(report.OpenLots, func(i, j int) bool {
+		if report.OpenLots[i].Acquired.Equal(report.OpenLots[j].Acquired) {
+			return report.OpenLots[i].ID < report.OpenLots[j].ID
+		}
+		return report.OpenLots[i].Acquired.Before(report.OpenLots[j].Acquired)
+	})
+
+	return report, nil
+}
+
+type lotMatch struct {
+	lot      *Lot
+	quantity decimal.Decimal
+}
+
+// match takes t.Quantity units out of lots and returns what came from where
+func match(lots []*Lot, t models.Transaction, method Method, selected []Selection) ([]lotMatch, error) {
+	remaining := t.Quantity
+	var matches []lotMatch
+
+	take := func(lot *Lot, quantity decimal.Decimal) {
+		quantity = decimal.Min(quantity, lot.Quantity)
+		if !quantity.IsPositive() {
+			return
+		}
+		lot.Quantity = lot.Quantity.Sub(quantity)
+		remaining = remaining.Sub(quantity)
+		matches = append(matches, lotMatch{lot: lot, quantity: quantity})
+	}
+
+	if method == MethodSpecific {
+		for _, selection := range selected {
+			lot := findLot(lots, selection.LotID)
+			if lot == nil {
+				return nil, fmt.Errorf("sell %d: lot %d is not an open lot of this asset", t.ID, selection.LotID)
+			}
+			take(lot, decimal.Min(selection.Quantity, remaining))
+		}
+	}
+
+	for _, lot := range orderLots(lots, method) {
+		if !remaining.IsPositive() {
+			break
+		}
+		take(lot, remaining)
+	}
+
+	if remaining.IsPositive() {
+		return nil, fmt.Errorf("transaction %d takes %s %s more than its open lots hold", t.ID, remaining, t.Asset.Symbol)
+	}
+	return matches, nil
+}
+
+// reprice gives up to quantity units of lots, oldest first, the unit cost
+// price. A lot only partly covered gets the average cost of both parts.
+func reprice(lots []*Lot, quantity, price decimal.Decimal) {
+	for _, lot := range orderLots(lots, MethodFIFO) {
+		if !quantity.IsPositive() {
+			break
+		}
+		covered := decimal.Min(quantity, lot.Quantity)
+		rest := lot.Quantity.Sub(covered)
+		lot.UnitCost = covered.Mul(price).Add(rest.Mul(lot.UnitCost)).Div(lot.Quantity)
+		quantity = quantity.Sub(covered)
+	}
+}
+
+// orderLots returns lots in the order method consumes them
+func orderLots(lots []*Lot, method Method) []*Lot {
+	ordered := make([]*Lot, len(lots))
+	copy(ordered, lots)
+
+	switch method {
+	case MethodLIFO:
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return ordered[i].Acquired.After(ordered[j].Acquired)
+		})
+	case MethodHIFO:
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return ordered[i].UnitCost.GreaterThan(ordered[j].UnitCost)
+		})
+	default:
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return ordered[i].Acquired.Before(ordered[j].Acquired)
+		})
+	}
+	return ordered
+}
+
+func findLot(lots []*Lot, id uint) *Lot {
+	for _, lot := range lots {
+		if lot.ID == id {
+			return lot
+		}
+	}
+	return nil
+}
+
+// openLots drops lots that have been used up
+func openLots(lots []*Lot) []*Lot {
+	open := lots[:0]
+	for _, lot := range lots {
+		if lot.Quantity.IsPositive() {
+			open = append(open, lot)
+		}
+	}
+	return open
+}
@@ -0,0 +1,175 @@
+package tax
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bioharz/budget/internal/models"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var btc = models.Asset{ID: 1, Symbol: "BTC"}
+
+func tx(id uint, txType models.TransactionType, quantity, price float64, timestamp time.Time) models.Transaction {
+	return models.Transaction{
+		ID:        id,
+		Type:      txType,
+		AssetID:   btc.ID,
+		Asset:     btc,
+		Quantity:  decimal.NewFromFloat(quantity),
+		PriceUSD:  decimal.NewFromFloat(price),
+		Timestamp: timestamp,
+	}
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 12, 0, 0, 0, time.UTC)
+}
+
+// Three buys at rising then falling prices, one sell of 1.5 BTC
+func ledger() []models.Transaction {
+	return []models.Transaction{
+		tx(1, models.TransactionBuy, 1, 10000, date(2023, 1, 10)),
+		tx(2, models.TransactionBuy, 1, 30000, date(2023, 6, 10)),
+		tx(3, models.TransactionBuy, 1, 20000, date(2024, 3, 10)),
+		tx(4, models.TransactionSell, 1.5, 40000, date(2024, 4, 1)),
+	}
+}
+
+func TestCompute_Methods(t *testing.T) {
+	tests := []struct {
+		method    Method
+		costBasis int64
+		lots      []uint
+	}{
+		{MethodFIFO, 10000 + 15000, []uint{1, 2}},
+		{MethodLIFO, 20000 + 15000, []uint{3, 2}},
+		{MethodHIFO, 30000 + 10000, []uint{2, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.method), func(t *testing.T) {
+			report, err := Compute(ledger(), tt.method, nil)
+			require.NoError(t, err)
+			require.Len(t, report.Disposals, 2)
+
+			costBasis := decimal.Zero
+			var lots []uint
+			for _, d := range report.Disposals {
+				costBasis = costBasis.Add(d.CostBasis)
+				lots = append(lots, d.LotID)
+			}
+			assert.Equal(t, tt.lots, lots)
+			assert.Equal(t, decimal.NewFromInt(tt.costBasis).String(), costBasis.String())
+
+			year := report.Year(2024)
+			assert.Equal(t, "60000", year.Proceeds.String())
+			assert.Equal(t, decimal.NewFromInt(60000-tt.costBasis).String(), year.Total().String())
+
+			open := decimal.Zero
+			for _, lot := range report.OpenLots {
+				open = open.Add(lot.Quantity)
+			}
+			assert.Equal(t, "1.5", open.String())
+		})
+	}
+}
+
+func TestCompute_LongAndShortTerm(t *testing.T) {
+	report, err := Compute(ledger(), MethodFIFO, nil)
+	require.NoError(t, err)
+
+	// Lot 1 was held more than a year, lot 2 less
+	assert.True(t, report.Disposals[0].LongTerm)
+	assert.False(t, report.Disposals[1].LongTerm)
+
+	year := report.Year(2024)
+	assert.Equal(t, "30000", year.LongTerm.String())
+	assert.Equal(t, "5000", year.ShortTerm.String())
+
+	empty := report.Year(2023)
+	assert.Equal(t, 2023, empty.Year)
+	assert.True(t, empty.Total().IsZero())
+	assert.True(t, IsLongTerm(date(2023, 1, 1), date(2024, 1, 2)))
+	assert.False(t, IsLongTerm(date(2023, 1, 1), date(2024, 1, 1)))
+}
+
+func TestCompute_SpecificID(t *testing.T) {
+	selections := map[uint][]Selection{
+		4: {{LotID: 3, Quantity: decimal.NewFromInt(1)}, {LotID: 1, Quantity: decimal.RequireFromString("0.25")}},
+	}
+	report, err := Compute(ledger(), MethodSpecific, selections)
+	require.NoError(t, err)
+	require.Len(t, report.Disposals, 3)
+
+	assert.Equal(t, uint(3), report.Disposals[0].LotID)
+	assert.Equal(t, uint(1), report.Disposals[1].LotID)
+	assert.Equal(t, "0.25", report.Disposals[1].Quantity.String())
+	// Whatever the selection leaves open is taken FIFO
+	assert.Equal(t, uint(1), report.Disposals[2].LotID)
+	assert.Equal(t, "0.25", report.Disposals[2].Quantity.String())
+
+	_, err = Compute(ledger(), MethodSpecific, map[uint][]Selection{4: {{LotID: 99, Quantity: decimal.NewFromInt(1)}}})
+	assert.Error(t, err)
+}
+
+func TestCompute_FeesAndOutflows(t *testing.T) {
+	buy := tx(1, models.TransactionBuy, 2, 100, date(2024, 1, 1))
+	buy.Fee = decimal.NewFromInt(10)
+	withdrawal := tx(2, models.TransactionWithdrawal, 0.5, 0, date(2024, 2, 1))
+	sell := tx(3, models.TransactionSell, 1, 200, date(2024, 3, 1))
+	sell.Fee = decimal.NewFromInt(4)
+
+	report, err := Compute([]models.Transaction{sell, withdrawal, buy}, MethodFIFO, nil)
+	require.NoError(t, err)
+	require.Len(t, report.Disposals, 1)
+
+	d := report.Disposals[0]
+	assert.Equal(t, "196", d.Proceeds.String())
+	assert.Equal(t, "105", d.CostBasis.String())
+	assert.Equal(t, "91", d.Gain.String())
+
+	// The withdrawal took units without realizing anything
+	require.Len(t, report.OpenLots, 1)
+	assert.Equal(t, "0.5", report.OpenLots[0].Quantity.String())
+
+	// Selling more than was ever acquired is an error
+	oversell := tx(4, models.TransactionSell, 1, 200, date(2024, 4, 1))
+	_, err = Compute([]models.Transaction{buy, sell, withdrawal, oversell}, MethodFIFO, nil)
+	assert.Error(t, err)
+}
+
+func TestCompute_CostAdjustment(t *testing.T) {
+	// The first buy was entered at the wrong price and corrected for 1.5 of
+	// the 2 units held
+	adjustment := tx(3, models.TransactionAdjustment, 1.5, 12000, date(2023, 7, 1))
+	transactions := []models.Transaction{
+		tx(1, models.TransactionBuy, 1, 10000, date(2023, 1, 10)),
+		tx(2, models.TransactionBuy, 1, 30000, date(2023, 6, 10)),
+		adjustment,
+		tx(4, models.TransactionSell, 1.5, 40000, date(2024, 4, 1)),
+	}
+
+	report, err := Compute(transactions, MethodFIFO, nil)
+	require.NoError(t, err)
+	require.Len(t, report.Disposals, 2)
+
+	// The oldest lot is repriced in full, the next one for half its units
+	assert.Equal(t, "12000", report.Disposals[0].CostBasis.String())
+	assert.Equal(t, date(2023, 1, 10), report.Disposals[0].Acquired)
+	assert.Equal(t, "10500", report.Disposals[1].CostBasis.String())
+
+	require.Len(t, report.OpenLots, 1)
+	assert.Equal(t, "21000", report.OpenLots[0].UnitCost.String())
+}
+
+func TestParseMethod(t *testing.T) {
+	method, err := ParseMethod(" HIFO ")
+	require.NoError(t, err)
+	assert.Equal(t, MethodHIFO, method)
+
+	_, err = ParseMethod("average")
+	assert.Error(t, err)
+}
@@ -0,0 +1,337 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bioharz/budget/internal/models"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/shopspring/decimal"
+)
+
+// accountColors are the colours an account can be shown in. Basic ANSI
+// colours keep the escape codes in table cells short.
+var accountColors = []struct {
+	name  string
+	color string
+}{
+	{"none", ""},
+	{"red", "1"},
+	{"green", "2"},
+	{"yellow", "3"},
+	{"blue", "4"},
+	{"magenta", "5"},
+	{"cyan", "6"},
+	{"grey", "8"},
+}
+
+var accountTypeLabels = map[string]string{
+	models.AccountTypeExchange:       "Exchange",
+	models.AccountTypeHardwareWallet: "Hardware wallet",
+	models.AccountTypeBank:           "Bank",
+	models.AccountTypeBrokerage:      "Brokerage",
+	models.AccountTypeCash:           "Cash",
+	models.AccountTypeUnknown:        "-",
+}
+
+func accountTypeLabel(accountType string) string {
+	if label, ok := accountTypeLabels[accountType]; ok {
+		return label
+	}
+	return accountType
+}
+
+func accountColorName(color string) string {
+	for _, c := range accountColors {
+		if c.color == color {
+			return c.name
+		}
+	}
+	return color
+}
+
+// accountStyle renders an account name in the account's colour
+func accountStyle(account models.Account) lipgloss.Style {
+	if account.Color == "" {
+		return lipgloss.NewStyle()
+	}
+	return lipgloss.NewStyle().Foreground(lipgloss.Color(account.Color))
+}
+
+// openAccounts shows the accounts, keeping the selection if it is still there
+func (m *Model) openAccounts() {
+	m.view = ViewAccounts
+	m.mergeSourceID = 0
+	m.mergeTargetID = 0
+	m.err = nil
+	if m.accountCursor >= len(m.accounts) {
+		m.accountCursor = 0
+	}
+}
+
+// sortedAccounts returns the accounts in the order the accounts view lists
+// them
+func (m *Model) sortedAccounts() []models.Account {
+	accounts := append([]models.Account(nil), m.accounts...)
+	sort.SliceStable(accounts, func(i, j int) bool {
+		return strings.ToLower(accounts[i].Name) < strings.ToLower(accounts[j].Name)
+	})
+	return accounts
+}
+
+func (m *Model) selectedAccount() (models.Account, bool) {
+	accounts := m.sortedAccounts()
+	if m.accountCursor < 0 || m.accountCursor >= len(accounts) {
+		return models.Account{}, false
+	}
+	return accounts[m.accountCursor], true
+}
+
+func (m *Model) handleAccountsKey(key string) tea.Cmd {
+	// Confirming a merge
+	if m.mergeTargetID != 0 {
+		switch key {
+		case "y", "Y":
+			m.mergeAccounts()
+		case "n", "N", "esc":
+			m.mergeSourceID = 0
+			m.mergeTargetID = 0
+		}
+		return nil
+	}
+
+	switch key {
+	case "up", "k":
+		if m.accountCursor > 0 {
+			m.accountCursor--
+		}
+	case "down", "j":
+		if m.accountCursor < len(m.accounts)-1 {
+			m.accountCursor++
+		}
+	case "enter":
+		account, ok := m.selectedAccount()
+		if m.mergeSourceID != 0 && ok && account.ID != m.mergeSourceID {
+			m.mergeTargetID = account.ID
+		}
+	case "r":
+		if m.mergeSourceID == 0 {
+			m.openRenameAccount()
+		}
+	case "t":
+		if m.mergeSourceID == 0 {
+			m.cycleAccountType()
+		}
+	case "c":
+		if m.mergeSourceID == 0 {
+			m.cycleAccountColor()
+		}
+	case "m":
+		if account, ok := m.selectedAccount(); ok && m.mergeSourceID == 0 && len(m.accounts) > 1 {
+			m.mergeSourceID = account.ID
+			m.err = nil
+		}
+	case "esc":
+		if m.mergeSourceID != 0 {
+			m.mergeSourceID = 0
+			return nil
+		}
+		m.view = ViewMain
+		m.err = nil
+	case "ctrl+c", "q":
+		return tea.Quit
+	}
+	return nil
+}
+
+func (m *Model) cycleAccountType() {
+	account, ok := m.selectedAccount()
+	if !ok {
+		return
+	}
+	next := models.AccountTypes[0]
+	for i, t := range models.AccountTypes {
+		if t == account.Type {
+			next = models.AccountTypes[(i+1)%len(models.AccountTypes)]
+			break
+		}
+	}
+	if _, err := m.accountService.SetType(account.ID, next); err != nil {
+		m.err = err
+		return
+	}
+	m.reloadAccounts()
+}
+
+func (m *Model) cycleAccountColor() {
+	account, ok := m.selectedAccount()
+	if !ok {
+		return
+	}
+	next := accountColors[1].color
+	for i, c := range accountColors {
+		if c.color == account.Color {
+			next = accountColors[(i+1)%len(accountColors)].color
+			break
+		}
+	}
+	if _, err := m.accountService.SetColor(account.ID, next); err != nil {
+		m.err = err
+		return
+	}
+	m.reloadAccounts()
+}
+
+func (m *Model) openRenameAccount() {
+	account, ok := m.selectedAccount()
+	if !ok {
+		return
+	}
+	m.modalState = ModalState{
+		Fields: []InputField{
+			{Label: "Name", Value: account.Name, Placeholder: "e.g., Ledger, Kraken"},
+		},
+		EditingAccountID: account.ID,
+	}
+	m.view = ViewRenameAccount
+	m.inputMode = true
+}
+
+func (m *Model) saveAccountName() {
+	account, err := m.accountService.Rename(m.modalState.EditingAccountID, m.modalState.Fields[0].Value)
+	if err != nil {
+		m.modalState.ShowError = true
+		m.modalState.ErrorMessage = err.Error()
+		return
+	}
+
+	m.reloadAccounts()
+	for i, a := range m.sortedAccounts() {
+		if a.ID == account.ID {
+			m.accountCursor = i
+		}
+	}
+	m.closeModal()
+}
+
+func (m *Model) renderRenameAccountModal() string {
+	return m.renderModal("Rename Account")
+}
+
+func (m *Model) mergeAccounts() {
+	err := m.accountService.Merge(m.mergeSourceID, m.mergeTargetID)
+	target := m.mergeTargetID
+	m.mergeSourceID = 0
+	m.mergeTargetID = 0
+	if err != nil {
+		m.err = err
+		return
+	}
+
+	m.reloadAccounts()
+	for i, a := range m.sortedAccounts() {
+		if a.ID == target {
+			m.accountCursor = i
+		}
+	}
+}
+
+// reloadAccounts reads accounts and holdings again after they changed
+func (m *Model) reloadAccounts() {
+	m.err = nil
+	accounts, err := m.accountService.GetAll()
+	if err != nil {
+		m.err = err
+		return
+	}
+	m.accounts = accounts
+	holdings, err := m.holdingService.GetHoldings()
+	if err != nil {
+		m.err = err
+		return
+	}
+	m.holdings = holdings
+	if m.accountCursor >= len(m.accounts) {
+		m.accountCursor = len(m.accounts) - 1
+	}
+	m.updateTableData()
+}
+
+func (m Model) accountsView() string {
+	var b strings.Builder
+
+	source := m.getAccountByID(m.mergeSourceID)
+	switch {
+	case m.mergeSourceID != 0:
+		b.WriteString(titleStyle.Render("🔀 Merge "+source.Name+" into…") + "\n")
+	default:
+		b.WriteString(titleStyle.Render("👛 Accounts") + "\n")
+	}
+
+	accounts := m.sortedAccounts()
+	if len(accounts) == 0 {
+		b.WriteString("No accounts yet. Adding a holding creates its account.\n\n[ESC] back")
+		return b.String()
+	}
+
+	values := make(map[uint]decimal.Decimal)
+	counts := make(map[uint]int)
+	for _, holding := range m.holdings {
+		values[holding.AccountID] = values[holding.AccountID].Add(holding.Amount.Mul(m.prices[holding.AssetID]))
+		counts[holding.AccountID]++
+	}
+
+	nameWidth := len("Account")
+	for _, account := range accounts {
+		if w := lipgloss.Width(account.Name); w > nameWidth {
+			nameWidth = w
+		}
+	}
+	row := func(name, accountType, color, holdings, value string) string {
+		return fmt.Sprintf("%s  %-15s  %-7s  %8s  %14s", padRight(name, nameWidth), accountType, color, holdings, value)
+	}
+
+	b.WriteString("  " + labelStyle.Render(row("Account", "Type", "Colour", "Holdings", "Value")) + "\n")
+	for i, account := range accounts {
+		cells := func(name string) string {
+			return row(name, accountTypeLabel(account.Type), accountColorName(account.Color),
+				fmt.Sprint(counts[account.ID]), m.converter.FormatUSD(values[account.ID]))
+		}
+		switch {
+		case i == m.accountCursor:
+			b.WriteString(selectedStyle.Render("> "+cells(account.Name)) + "\n")
+		case account.ID == m.mergeSourceID:
+			b.WriteString("  " + labelStyle.Render(cells(account.Name)) + "\n")
+		default:
+			b.WriteString("  " + cells(accountStyle(account).Render(account.Name)) + "\n")
+		}
+	}
+	b.WriteString("\n")
+
+	if m.err != nil {
+		b.WriteString(errorStyle.Render(m.err.Error()) + "\n\n")
+	}
+
+	switch {
+	case m.mergeTargetID != 0:
+		target := m.getAccountByID(m.mergeTargetID)
+		b.WriteString(warningStyle.Render(fmt.Sprintf(
+			"Move all holdings and transactions of %s to %s and delete %s?", source.Name, target.Name, source.Name)) + "\n")
+		b.WriteString("[Y]es     [N]o / [ESC]")
+	case m.mergeSourceID != 0:
+		b.WriteString("[↑/↓] select  [enter] merge into this account  [ESC] cancel")
+	default:
+		b.WriteString("[↑/↓] select  [r]ename  [t]ype  [c]olour  [m]erge  [ESC] back")
+	}
+	return b.String()
+}
+
+// padRight pads s with spaces to width terminal cells
+func padRight(s string, width int) string {
+	if w := lipgloss.Width(s); w < width {
+		return s + strings.Repeat(" ", width-w)
+	}
+	return s
+}
@@ -0,0 +1,217 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bioharz/budget/internal/models"
+	"github.com/bioharz/budget/internal/service"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// openAssets shows the assets with where their prices come from
+func (m *Model) openAssets() {
+	m.view = ViewAssets
+	m.deletingAssetID = 0
+	m.reloadAssets()
+}
+
+// reloadAssets reads the assets and their price sources again after they
+// changed
+func (m *Model) reloadAssets() {
+	m.err = nil
+	assets, err := m.assetService.GetAll()
+	if err != nil {
+		m.err = err
+		return
+	}
+	m.assets = assets
+	if m.priceService != nil {
+		sources, err := m.priceService.GetPriceSources()
+		if err != nil {
+			m.err = err
+			return
+		}
+		m.priceSources = sources
+	}
+	if m.assetCursor >= len(m.assets) {
+		m.assetCursor = len(m.assets) - 1
+	}
+	if m.assetCursor < 0 {
+		m.assetCursor = 0
+	}
+	m.updateTableData()
+}
+
+func (m *Model) selectedAsset() (models.Asset, bool) {
+	if m.assetCursor < 0 || m.assetCursor >= len(m.assets) {
+		return models.Asset{}, false
+	}
+	return m.assets[m.assetCursor], true
+}
+
+func (m *Model) handleAssetsKey(key string) tea.Cmd {
+	// Confirming a deletion
+	if m.deletingAssetID != 0 {
+		switch key {
+		case "y", "Y":
+			if err := m.assetService.Delete(m.deletingAssetID); err != nil {
+				m.deletingAssetID = 0
+				m.err = err
+				return nil
+			}
+			m.deletingAssetID = 0
+			m.reloadAssets()
+		case "n", "N", "esc":
+			m.deletingAssetID = 0
+		}
+		return nil
+	}
+
+	switch key {
+	case "up", "k":
+		if m.assetCursor > 0 {
+			m.assetCursor--
+		}
+	case "down", "j":
+		if m.assetCursor < len(m.assets)-1 {
+			m.assetCursor++
+		}
+	case "e":
+		m.openEditAsset()
+	case "t":
+		m.cycleAssetType()
+	case "d":
+		if asset, ok := m.selectedAsset(); ok {
+			m.deletingAssetID = asset.ID
+			m.err = nil
+		}
+	case "esc":
+		m.view = ViewMain
+		m.err = nil
+	case "ctrl+c", "q":
+		return tea.Quit
+	}
+	return nil
+}
+
+// cycleAssetType corrects the type guessed from the symbol
+func (m *Model) cycleAssetType() {
+	asset, ok := m.selectedAsset()
+	if !ok {
+		return
+	}
+	next := models.AssetTypes[0]
+	for i, t := range models.AssetTypes {
+		if t == asset.Type {
+			next = models.AssetTypes[(i+1)%len(models.AssetTypes)]
+			break
+		}
+	}
+	if _, err := m.assetService.Update(asset.ID, asset.Name, next); err != nil {
+		m.err = err
+		return
+	}
+	m.reloadAssets()
+}
+
+func (m *Model) openEditAsset() {
+	asset, ok := m.selectedAsset()
+	if !ok {
+		return
+	}
+	m.modalState = ModalState{
+		Fields: []InputField{
+			{Label: "Name", Value: asset.Name, Placeholder: "e.g., Bitcoin"},
+		},
+		EditingAssetID: asset.ID,
+	}
+	m.view = ViewEditAsset
+	m.inputMode = true
+}
+
+func (m *Model) saveAssetName() {
+	asset, err := m.assetService.GetByID(m.modalState.EditingAssetID)
+	if err == nil {
+		_, err = m.assetService.Update(asset.ID, m.modalState.Fields[0].Value, asset.Type)
+	}
+	if err != nil {
+		m.modalState.ShowError = true
+		m.modalState.ErrorMessage = err.Error()
+		return
+	}
+
+	m.closeModal()
+	m.reloadAssets()
+}
+
+func (m *Model) renderEditAssetModal() string {
+	asset := m.getAssetByID(m.modalState.EditingAssetID)
+	return m.renderModal("Edit Asset - " + asset.Symbol)
+}
+
+// priceSourceLabel names where an asset's price comes from
+func priceSourceLabel(asset models.Asset, source service.PriceSource, cached bool) string {
+	switch {
+	case !cached:
+		return "-"
+	case source.Source != "":
+		return source.Source
+	case asset.Type == models.AssetTypeOther:
+		return "none"
+	default:
+		return "cache"
+	}
+}
+
+func (m Model) assetsView() string {
+	var b strings.Builder
+	b.WriteString(titleStyle.Render("📊 Your Assets") + "\n")
+
+	if len(m.assets) == 0 {
+		b.WriteString("No assets yet. Press n on the main screen to add a holding.\n\n[ESC] back")
+		return b.String()
+	}
+
+	symbolWidth, nameWidth := len("Symbol"), len("Name")
+	for _, asset := range m.assets {
+		symbolWidth = max(symbolWidth, lipgloss.Width(asset.Symbol))
+		nameWidth = max(nameWidth, lipgloss.Width(asset.Name))
+	}
+	row := func(symbol, name, assetType, price, source, updated string) string {
+		return fmt.Sprintf("%s  %s  %-6s  %14s  %-13s  %s",
+			padRight(symbol, symbolWidth), padRight(name, nameWidth), assetType, price, source, updated)
+	}
+
+	b.WriteString("  " + labelStyle.Render(row("Symbol", "Name", "Type", "Price", "Source", "Updated")) + "\n")
+	for i, asset := range m.assets {
+		source, cached := m.priceSources[asset.ID]
+		price, updated := "-", "-"
+		if cached {
+			price = m.converter.FormatUSD(m.prices[asset.ID])
+			updated = source.UpdatedAt.Format("2006-01-02 15:04")
+		}
+		line := row(asset.Symbol, asset.Name, string(asset.Type), price,
+			priceSourceLabel(asset, source, cached), updated)
+		if i == m.assetCursor {
+			b.WriteString(selectedStyle.Render("> "+line) + "\n")
+		} else {
+			b.WriteString("  " + line + "\n")
+		}
+	}
+	b.WriteString("\n")
+
+	if m.err != nil {
+		b.WriteString(errorStyle.Render(m.err.Error()) + "\n\n")
+	}
+
+	if m.deletingAssetID != 0 {
+		asset := m.getAssetByID(m.deletingAssetID)
+		b.WriteString(warningStyle.Render(fmt.Sprintf("Delete %s (%s)?", asset.Symbol, asset.Name)) + "\n")
+		b.WriteString("[Y]es     [N]o / [ESC]")
+		return b.String()
+	}
+	b.WriteString("[↑/↓] select  [e]dit name  [t]ype  [d]elete unused  [ESC] back")
+	return b.String()
+}
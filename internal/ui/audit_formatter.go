@@ -6,8 +6,26 @@ import (
 	"strings"
 
 	"github.com/bioharz/budget/internal/models"
+	"github.com/shopspring/decimal"
 )
 
+// auditDecimal reads an amount from audit JSON. Entries written before the
+// switch to decimals hold numbers, newer ones hold strings.
+func auditDecimal(v interface{}) decimal.Decimal {
+	switch value := v.(type) {
+	case string:
+		d, err := decimal.NewFromString(value)
+		if err != nil {
+			return decimal.Zero
+		}
+		return d
+	case float64:
+		return decimal.NewFromFloat(value)
+	default:
+		return decimal.Zero
+	}
+}
+
 func (m Model) formatHoldingChange(log models.AuditLog) string {
 	var result strings.Builder
 
@@ -18,14 +36,14 @@ func (m Model) formatHoldingChange(log models.AuditLog) string {
 		if err := json.Unmarshal([]byte(log.NewValue), &data); err == nil {
 			accountID := uint(data["account_id"].(float64))
 			assetID := uint(data["asset_id"].(float64))
-			amount := data["amount"].(float64)
+			amount := auditDecimal(data["amount"])
 
 			account := m.getAccountByID(accountID)
 			asset := m.getAssetByID(assetID)
 
-			result.WriteString(fmt.Sprintf("  Added %.4f %s to %s\n", amount, asset.Symbol, account.Name))
-			if purchasePrice, ok := data["purchase_price"].(float64); ok && purchasePrice > 0 {
-				result.WriteString(fmt.Sprintf("  Purchase price: $%.2f\n", purchasePrice))
+			result.WriteString(fmt.Sprintf("  Added %s %s to %s\n", amount.StringFixed(4), asset.Symbol, account.Name))
+			if purchasePrice := auditDecimal(data["purchase_price"]); purchasePrice.IsPositive() {
+				result.WriteString(fmt.Sprintf("  Purchase price: $%s\n", purchasePrice.StringFixed(2)))
 			}
 		}
 
@@ -43,10 +61,10 @@ func (m Model) formatHoldingChange(log models.AuditLog) string {
 				result.WriteString(fmt.Sprintf("  Updated %s in %s:\n", asset.Symbol, account.Name))
 
 				// Check what changed
-				oldAmount := oldData["amount"].(float64)
-				newAmount := newData["amount"].(float64)
-				if oldAmount != newAmount {
-					result.WriteString(fmt.Sprintf("  Amount: %.4f → %.4f\n", oldAmount, newAmount))
+				oldAmount := auditDecimal(oldData["amount"])
+				newAmount := auditDecimal(newData["amount"])
+				if !oldAmount.Equal(newAmount) {
+					result.WriteString(fmt.Sprintf("  Amount: %s → %s\n", oldAmount.StringFixed(4), newAmount.StringFixed(4)))
 				}
 
 				// Check if account changed
@@ -58,20 +76,113 @@ func (m Model) formatHoldingChange(log models.AuditLog) string {
 			}
 		}
 
-	case models.AuditActionDelete:
+	case models.AuditActionDelete, models.AuditActionPurge:
 		// Parse old value
 		var data map[string]interface{}
 		if err := json.Unmarshal([]byte(log.OldValue), &data); err == nil {
 			accountID := uint(data["account_id"].(float64))
 			assetID := uint(data["asset_id"].(float64))
-			amount := data["amount"].(float64)
+			amount := auditDecimal(data["amount"])
 
 			account := m.getAccountByID(accountID)
 			asset := m.getAssetByID(assetID)
 
-			result.WriteString(fmt.Sprintf("  Removed %.4f %s from %s\n", amount, asset.Symbol, account.Name))
+			verb := "Removed"
+			if log.Action == models.AuditActionPurge {
+				verb = "Purged"
+			}
+			result.WriteString(fmt.Sprintf("  %s %s %s from %s\n", verb, amount.StringFixed(4), asset.Symbol, account.Name))
 		}
 	}
 
 	return result.String()
 }
+
+// auditFields reads the old and new JSON of an entry; either is nil when
+// empty or unreadable
+func auditFields(log models.AuditLog) (oldData, newData map[string]interface{}) {
+	_ = json.Unmarshal([]byte(log.OldValue), &oldData)
+	_ = json.Unmarshal([]byte(log.NewValue), &newData)
+	return oldData, newData
+}
+
+// auditString reads a text field from audit JSON
+func auditString(data map[string]interface{}, key string) string {
+	s, _ := data[key].(string)
+	return s
+}
+
+// auditChanges lists the fields that differ between old and new
+func auditChanges(oldData, newData map[string]interface{}, fields []struct{ key, label string }) string {
+	var result strings.Builder
+	for _, field := range fields {
+		oldValue, newValue := auditString(oldData, field.key), auditString(newData, field.key)
+		if oldValue == newValue {
+			continue
+		}
+		if oldValue == "" {
+			oldValue = "-"
+		}
+		if newValue == "" {
+			newValue = "-"
+		}
+		result.WriteString(fmt.Sprintf("  %s: %s → %s\n", field.label, oldValue, newValue))
+	}
+	return result.String()
+}
+
+func (m Model) formatAccountChange(log models.AuditLog) string {
+	oldData, newData := auditFields(log)
+
+	switch log.Action {
+	case models.AuditActionCreate:
+		return fmt.Sprintf("  Created account %s\n", auditString(newData, "name"))
+	case models.AuditActionUpdate:
+		return fmt.Sprintf("  Updated account %s:\n", auditString(newData, "name")) +
+			auditChanges(oldData, newData, []struct{ key, label string }{
+				{"name", "Name"},
+				{"type", "Type"},
+				{"color", "Colour"},
+			})
+	case models.AuditActionDelete:
+		return fmt.Sprintf("  Deleted account %s\n", auditString(oldData, "name"))
+	case models.AuditActionPurge:
+		return fmt.Sprintf("  Purged account %s\n", auditString(oldData, "name"))
+	}
+	return ""
+}
+
+func (m Model) formatAssetChange(log models.AuditLog) string {
+	oldData, newData := auditFields(log)
+
+	switch log.Action {
+	case models.AuditActionCreate:
+		return fmt.Sprintf("  Created asset %s (%s, %s)\n",
+			auditString(newData, "symbol"), auditString(newData, "name"), auditString(newData, "type"))
+	case models.AuditActionUpdate:
+		return fmt.Sprintf("  Updated asset %s:\n", auditString(newData, "symbol")) +
+			auditChanges(oldData, newData, []struct{ key, label string }{
+				{"name", "Name"},
+				{"type", "Type"},
+				{"provider_id", "Coin"},
+			})
+	case models.AuditActionDelete:
+		return fmt.Sprintf("  Deleted asset %s (%s)\n", auditString(oldData, "symbol"), auditString(oldData, "name"))
+	case models.AuditActionPurge:
+		return fmt.Sprintf("  Purged asset %s (%s)\n", auditString(oldData, "symbol"), auditString(oldData, "name"))
+	}
+	return ""
+}
+
+// formatTransactionChange describes a ledger transaction purged along with
+// its account or asset, the only change of a transaction that is audited
+func (m Model) formatTransactionChange(log models.AuditLog) string {
+	if log.Action != models.AuditActionPurge {
+		return ""
+	}
+	oldData, _ := auditFields(log)
+	accountID, _ := oldData["account_id"].(float64)
+	assetID, _ := oldData["asset_id"].(float64)
+	return fmt.Sprintf("  Purged %s of %s %s in %s\n", auditString(oldData, "type"),
+		auditDecimal(oldData["quantity"]).StringFixed(4), m.getAssetByID(uint(assetID)).Symbol, m.getAccountByID(uint(accountID)).Name)
+}
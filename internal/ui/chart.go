@@ -0,0 +1,254 @@
+package ui
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/bioharz/budget/internal/models"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mattn/go-runewidth"
+	"github.com/shopspring/decimal"
+)
+
+type ChartRange string
+
+const (
+	ChartRangeDay   ChartRange = "day"
+	ChartRangeWeek  ChartRange = "week"
+	ChartRangeMonth ChartRange = "month"
+	ChartRangeYear  ChartRange = "year"
+)
+
+var (
+	chartUpStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("42"))
+
+	chartDownStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("196"))
+
+	axisStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("241"))
+)
+
+// chartBlocks are the eighth-height bars used to draw one chart cell
+var chartBlocks = []rune{' ', '▁', '▂', '▃', '▄', '▅', '▆', '▇', '█'}
+
+func (r ChartRange) Duration() time.Duration {
+	switch r {
+	case ChartRangeDay:
+		return 24 * time.Hour
+	case ChartRangeMonth:
+		return 30 * 24 * time.Hour
+	case ChartRangeYear:
+		return 365 * 24 * time.Hour
+	default:
+		return 7 * 24 * time.Hour
+	}
+}
+
+func (r ChartRange) dateLayout() string {
+	if r == ChartRangeDay {
+		return "01-02 15:04"
+	}
+	return "2006-01-02"
+}
+
+func (m *Model) openChart() {
+	if m.chartRange == "" {
+		m.chartRange = ChartRangeWeek
+	}
+	m.view = ViewChart
+	m.loadSnapshots()
+}
+
+func (m *Model) setChartRange(r ChartRange) {
+	m.chartRange = r
+	m.loadSnapshots()
+}
+
+func (m *Model) loadSnapshots() {
+	if m.snapshotService == nil {
+		return
+	}
+	snapshots, err := m.snapshotService.GetSince(time.Now().Add(-m.chartRange.Duration()))
+	if err != nil {
+		m.err = err
+		return
+	}
+	m.snapshots = snapshots
+}
+
+func (m Model) chartView() string {
+	var b strings.Builder
+
+	title := strings.ToUpper(string(m.chartRange[:1])) + string(m.chartRange[1:])
+	b.WriteString(titleStyle.Render("📈 Net Worth - "+title) + "\n")
+
+	if len(m.snapshots) == 0 {
+		b.WriteString("No snapshots in this range yet.\n")
+		b.WriteString("A snapshot is recorded every time prices are updated.\n\n")
+		b.WriteString("[d]ay  [w]eek  [m]onth  [y]ear  [ESC] back")
+		return b.String()
+	}
+
+	first := m.snapshotValue(m.snapshots[0])
+	last := m.snapshotValue(m.snapshots[len(m.snapshots)-1])
+	style := chartUpStyle
+	if last.LessThan(first) {
+		style = chartDownStyle
+	}
+
+	change := last.Sub(first)
+	changeText := "Change: " + m.converter.FormatSigned(change)
+	if !first.IsZero() {
+		changeText += " (" + formatPercent(change.Div(first).Mul(decimal.NewFromInt(100))) + ")"
+	}
+	b.WriteString(fmt.Sprintf("Current: %s   %s\n\n", m.converter.Format(last), style.Render(changeText)))
+
+	height := m.height - 10
+	if height < 4 {
+		height = 4
+	}
+	if height > 20 {
+		height = 20
+	}
+
+	minValue, maxValue := snapshotBounds(m.snapshots, m.snapshotValue)
+	topLabel := m.converter.Format(maxValue)
+	bottomLabel := m.converter.Format(minValue)
+	labelWidth := runewidth.StringWidth(topLabel)
+	if w := runewidth.StringWidth(bottomLabel); w > labelWidth {
+		labelWidth = w
+	}
+
+	width := m.width - labelWidth - 4
+	if width < 10 {
+		width = 10
+	}
+
+	end := time.Now()
+	start := end.Add(-m.chartRange.Duration())
+	values := bucketSnapshots(m.snapshots, m.snapshotValue, start, end, width)
+	rows := renderChart(values, height)
+
+	for i, row := range rows {
+		label := ""
+		axis := "│"
+		switch i {
+		case 0:
+			label = topLabel
+			axis = "┤"
+		case len(rows) - 1:
+			label = bottomLabel
+			axis = "┤"
+		}
+		padding := strings.Repeat(" ", labelWidth-runewidth.StringWidth(label))
+		b.WriteString(axisStyle.Render(padding + label + " " + axis))
+		b.WriteString(style.Render(row) + "\n")
+	}
+
+	startLabel := start.Format(m.chartRange.dateLayout())
+	endLabel := end.Format(m.chartRange.dateLayout())
+	gap := width - len(startLabel) - len(endLabel)
+	if gap < 1 {
+		gap = 1
+	}
+	b.WriteString(axisStyle.Render(strings.Repeat(" ", labelWidth+2) + startLabel + strings.Repeat(" ", gap) + endLabel))
+	b.WriteString("\n\n[d]ay  [w]eek  [m]onth  [y]ear  [ESC] back")
+
+	return b.String()
+}
+
+// snapshotValue is a snapshot's total in the base currency. Snapshots taken
+// in that currency keep the rate of their day, others use today's rate.
+func (m Model) snapshotValue(snapshot models.PortfolioSnapshot) decimal.Decimal {
+	if snapshot.Currency != "" && snapshot.Currency == m.converter.Code {
+		return snapshot.TotalValue
+	}
+	return m.converter.FromUSD(snapshot.TotalValueUSD)
+}
+
+func snapshotBounds(snapshots []models.PortfolioSnapshot, valueOf func(models.PortfolioSnapshot) decimal.Decimal) (decimal.Decimal, decimal.Decimal) {
+	if len(snapshots) == 0 {
+		return decimal.Zero, decimal.Zero
+	}
+	minValue := valueOf(snapshots[0])
+	maxValue := minValue
+	for _, snapshot := range snapshots[1:] {
+		minValue = decimal.Min(minValue, valueOf(snapshot))
+		maxValue = decimal.Max(maxValue, valueOf(snapshot))
+	}
+	return minValue, maxValue
+}
+
+// bucketSnapshots spreads snapshots over width columns between start and end.
+// Each column shows the last value seen up to that point; columns before the
+// first snapshot are NaN so they render empty. Plotting only needs float
+// precision.
+func bucketSnapshots(snapshots []models.PortfolioSnapshot, valueOf func(models.PortfolioSnapshot) decimal.Decimal, start, end time.Time, width int) []float64 {
+	values := make([]float64, width)
+	step := end.Sub(start) / time.Duration(width)
+	if step <= 0 {
+		step = 1
+	}
+
+	current := math.NaN()
+	next := 0
+	for i := range values {
+		bucketEnd := start.Add(step * time.Duration(i+1))
+		for next < len(snapshots) && !snapshots[next].Timestamp.After(bucketEnd) {
+			current = valueOf(snapshots[next]).InexactFloat64()
+			next++
+		}
+		values[i] = current
+	}
+	return values
+}
+
+// renderChart draws values as a bar chart of the given height, top row first
+func renderChart(values []float64, height int) []string {
+	minValue := math.Inf(1)
+	maxValue := math.Inf(-1)
+	for _, v := range values {
+		if math.IsNaN(v) {
+			continue
+		}
+		minValue = math.Min(minValue, v)
+		maxValue = math.Max(maxValue, v)
+	}
+
+	levels := height * 8
+	filled := make([]int, len(values))
+	for i, v := range values {
+		switch {
+		case math.IsNaN(v):
+			filled[i] = 0
+		case maxValue == minValue:
+			// A flat line sits in the middle of the chart
+			filled[i] = levels / 2
+		default:
+			// Keep at least one eighth so the lowest point stays visible
+			filled[i] = 1 + int((v-minValue)/(maxValue-minValue)*float64(levels-1))
+		}
+	}
+
+	rows := make([]string, height)
+	for r := 0; r < height; r++ {
+		base := (height - 1 - r) * 8
+		var row strings.Builder
+		for _, f := range filled {
+			level := f - base
+			if level < 0 {
+				level = 0
+			}
+			if level > 8 {
+				level = 8
+			}
+			row.WriteRune(chartBlocks[level])
+		}
+		rows[r] = row.String()
+	}
+	return rows
+}
@@ -0,0 +1,54 @@
+package ui
+
+import (
+	"github.com/bioharz/budget/internal/currency"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type converterMsg struct {
+	converter currency.Converter
+	err       error
+}
+
+// loadConverter fetches the base currency rate
+func (m Model) loadConverter() *converterMsg {
+	if m.priceService == nil {
+		return nil
+	}
+	converter, err := m.priceService.Converter()
+	return &converterMsg{converter: converter, err: err}
+}
+
+// cycleBaseCurrency switches to the next supported base currency and loads
+// its rate in the background
+func (m *Model) cycleBaseCurrency() tea.Cmd {
+	if m.priceService == nil {
+		return nil
+	}
+	base, err := m.priceService.BaseCurrency()
+	if err != nil {
+		m.err = err
+		return nil
+	}
+	next, err := m.priceService.SetBaseCurrency(currency.Next(base).Code)
+	if err != nil {
+		m.err = err
+		return nil
+	}
+
+	priceService := m.priceService
+	return func() tea.Msg {
+		converter, err := priceService.ConverterFor(next)
+		return converterMsg{converter: converter, err: err}
+	}
+}
+
+// applyConverter switches displayed values to a new base currency. Without
+// a rate they stay in USD and the table shows why.
+func (m *Model) applyConverter(msg converterMsg) {
+	m.converterErr = msg.err
+	m.converter = msg.converter
+	if msg.err != nil {
+		m.converter = currency.Identity
+	}
+}
@@ -0,0 +1,131 @@
+package ui
+
+import (
+	"strings"
+	"time"
+
+	"github.com/bioharz/budget/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// manualDateLayout is the date format of the manual price modal
+const manualDateLayout = "2006-01-02"
+
+// inModal reports whether keys go to the modal form instead of the table
+func (m Model) inModal() bool {
+	return m.view == ViewAddAsset || m.view == ViewManualPrice || m.view == ViewRenameAccount ||
+		m.view == ViewEditAsset
+}
+
+// closeModal leaves a modal for the view it was opened from
+func (m *Model) closeModal() {
+	switch m.view {
+	case ViewRenameAccount:
+		m.view = ViewAccounts
+	case ViewEditAsset:
+		m.view = ViewAssets
+	default:
+		m.view = ViewMain
+	}
+	m.inputMode = false
+	m.inputBuffer = ""
+	m.modalState = ModalState{}
+}
+
+// openManualPrice opens the manual price form for the selected asset,
+// prefilled with its current manual price if it has one
+func (m *Model) openManualPrice() {
+	cursor := m.table.Cursor()
+	if m.priceService == nil || cursor < 0 || cursor >= len(m.rowAssetIDs) {
+		return
+	}
+	asset := m.getAssetByID(m.rowAssetIDs[cursor])
+
+	price := ""
+	date := time.Now().Format(manualDateLayout)
+	if cache, ok := m.manualPrices[asset.ID]; ok {
+		price = cache.PriceUSD.String()
+		date = cache.PriceDate.Format(manualDateLayout)
+	}
+
+	m.modalState = ModalState{
+		Fields: []InputField{
+			{Label: "Price (USD)", Value: price, Placeholder: "empty = automatic"},
+			{Label: "Date", Value: date, Placeholder: "YYYY-MM-DD"},
+		},
+		ManualAssetID: asset.ID,
+	}
+	m.view = ViewManualPrice
+	m.inputMode = true
+}
+
+func (m *Model) saveManualPrice() {
+	priceStr := strings.TrimSpace(m.modalState.Fields[0].Value)
+	dateStr := strings.TrimSpace(m.modalState.Fields[1].Value)
+	assetID := m.modalState.ManualAssetID
+
+	if priceStr == "" {
+		// An empty price hands the asset back to the price feeds
+		if err := m.priceService.ClearManualPrice(assetID); err != nil {
+			m.modalState.ShowError = true
+			m.modalState.ErrorMessage = "Failed to clear manual price"
+			return
+		}
+	} else {
+		price, err := decimal.NewFromString(priceStr)
+		if err != nil || price.IsNegative() {
+			m.modalState.ShowError = true
+			m.modalState.ErrorMessage = "Invalid price"
+			return
+		}
+
+		date, err := time.ParseInLocation(manualDateLayout, dateStr, time.Local)
+		if err != nil {
+			m.modalState.ShowError = true
+			m.modalState.ErrorMessage = "Invalid date, use YYYY-MM-DD"
+			return
+		}
+
+		if err := m.priceService.SetManualPrice(assetID, price, date); err != nil {
+			m.modalState.ShowError = true
+			m.modalState.ErrorMessage = "Failed to save price"
+			return
+		}
+		m.prices[assetID] = price
+	}
+
+	m.loadManualPrices()
+	m.updateUnresolved()
+	m.updateTableData()
+	m.view = ViewMain
+	m.inputMode = false
+	m.modalState = ModalState{}
+}
+
+// loadManualPrices refreshes which assets are priced by hand
+func (m *Model) loadManualPrices() {
+	if m.priceService == nil {
+		return
+	}
+	manual, err := m.priceService.GetManualPrices()
+	if err != nil {
+		return
+	}
+	m.manualPrices = manual
+}
+
+func (m *Model) renderManualPriceModal() string {
+	asset := m.getAssetByID(m.modalState.ManualAssetID)
+	return m.renderModal("Manual Price - " + asset.Symbol)
+}
+
+// pricedAssets drops manually priced assets, which never hit the price feeds
+func (m *Model) pricedAssets() []models.Asset {
+	var assets []models.Asset
+	for _, asset := range m.assets {
+		if _, ok := m.manualPrices[asset.ID]; !ok {
+			assets = append(assets, asset)
+		}
+	}
+	return assets
+}
@@ -2,14 +2,12 @@ package ui
 
 import (
 	"fmt"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/bioharz/budget/internal/models"
-	"github.com/bioharz/budget/internal/repository"
 	"github.com/charmbracelet/lipgloss"
-	"gorm.io/gorm"
+	"github.com/shopspring/decimal"
 )
 
 type InputField struct {
@@ -26,6 +24,9 @@ type ModalState struct {
 	ErrorMessage     string
 	IsEdit           bool
 	EditingHoldingID uint
+	ManualAssetID    uint
+	EditingAccountID uint
+	EditingAssetID   uint
 }
 
 var (
@@ -79,15 +80,15 @@ func (m *Model) initAddAssetModal() {
 
 func (m *Model) initEditAssetModal(holding models.Holding, account models.Account, asset models.Asset) {
 	purchasePrice := ""
-	if holding.PurchasePrice > 0 {
-		purchasePrice = fmt.Sprintf("%.2f", holding.PurchasePrice)
+	if holding.PurchasePrice.IsPositive() {
+		purchasePrice = holding.PurchasePrice.StringFixed(2)
 	}
 
 	m.modalState = ModalState{
 		Fields: []InputField{
 			{Label: "Account", Value: account.Name, Placeholder: "e.g., hardware wallet, NeoBank"},
 			{Label: "Asset", Value: asset.Symbol, Placeholder: "e.g., BTC, ETH, USD"},
-			{Label: "Amount", Value: fmt.Sprintf("%.6f", holding.Amount), Placeholder: "e.g., 0.5"},
+			{Label: "Amount", Value: holding.Amount.String(), Placeholder: "e.g., 0.5"},
 			{Label: "Purchase Price", Value: purchasePrice, Placeholder: "e.g., 40000 (optional)"},
 		},
 		ActiveField:      0,
@@ -107,12 +108,18 @@ func (m *Model) handleModalInput(key string) {
 		}
 	case "enter":
 		if m.modalState.ActiveField == len(m.modalState.Fields) { // Save button
-			m.saveAsset()
+			switch m.view {
+			case ViewManualPrice:
+				m.saveManualPrice()
+			case ViewRenameAccount:
+				m.saveAccountName()
+			case ViewEditAsset:
+				m.saveAssetName()
+			default:
+				m.saveAsset()
+			}
 		} else if m.modalState.ActiveField == len(m.modalState.Fields)+1 { // Cancel button
-			m.view = ViewMain
-			m.inputMode = false
-			m.inputBuffer = ""
-			m.modalState = ModalState{}
+			m.closeModal()
 		}
 	case "backspace":
 		if m.modalState.ActiveField < len(m.modalState.Fields) {
@@ -141,68 +148,42 @@ func (m *Model) saveAsset() {
 		return
 	}
 
-	amount, err := strconv.ParseFloat(amountStr, 64)
-	if err != nil || amount <= 0 {
+	amount, err := decimal.NewFromString(amountStr)
+	if err != nil || !amount.IsPositive() {
 		m.modalState.ShowError = true
 		m.modalState.ErrorMessage = "Invalid amount"
 		return
 	}
 
-	var purchasePrice float64
+	purchasePrice := decimal.Zero
 	if priceStr != "" {
-		purchasePrice, err = strconv.ParseFloat(priceStr, 64)
-		if err != nil || purchasePrice < 0 {
+		purchasePrice, err = decimal.NewFromString(priceStr)
+		if err != nil || purchasePrice.IsNegative() {
 			m.modalState.ShowError = true
 			m.modalState.ErrorMessage = "Invalid purchase price"
 			return
 		}
 	}
 
-	// Get or create account
-	accountRepo := repository.NewAccountRepository()
-	account, err := accountRepo.GetByName(accountName)
-	if err == gorm.ErrRecordNotFound {
-		account = models.Account{
-			Name: accountName,
-			Type: "unknown", // TODO: Add account type selection
-		}
-		if err := accountRepo.Create(&account); err != nil {
-			m.modalState.ShowError = true
-			m.modalState.ErrorMessage = "Failed to create account"
-			return
-		}
-	} else if err != nil {
+	// Get or create account; its type and colour are set in the accounts
+	// view
+	account, err := m.holdingService.GetOrCreateAccount(accountName)
+	if err != nil {
 		m.modalState.ShowError = true
-		m.modalState.ErrorMessage = "Database error"
+		m.modalState.ErrorMessage = "Failed to create account"
 		return
 	}
 
-	// Get or create asset
-	assetRepo := repository.NewAssetRepository()
-	asset, err := assetRepo.GetBySymbol(strings.ToUpper(assetSymbol))
-	if err == gorm.ErrRecordNotFound {
-		// Determine asset type based on symbol
-		assetType := m.guessAssetType(assetSymbol)
-		asset = models.Asset{
-			Symbol: strings.ToUpper(assetSymbol),
-			Name:   assetSymbol, // TODO: Fetch proper name from API
-			Type:   assetType,
-		}
-		if err := assetRepo.Create(&asset); err != nil {
-			m.modalState.ShowError = true
-			m.modalState.ErrorMessage = "Failed to create asset"
-			return
-		}
-	} else if err != nil {
+	// Get or create asset; the type is guessed from the symbol and can be
+	// corrected in the assets view
+	asset, err := m.holdingService.GetOrCreateAsset(assetSymbol)
+	if err != nil {
 		m.modalState.ShowError = true
-		m.modalState.ErrorMessage = "Database error"
+		m.modalState.ErrorMessage = "Failed to create asset"
 		return
 	}
 
-	holdingRepo := repository.NewHoldingRepository()
-
 	if m.modalState.IsEdit {
-		// Get old holding for audit log
 		var oldHolding models.Holding
 		for _, h := range m.holdings {
 			if h.ID == m.modalState.EditingHoldingID {
@@ -211,45 +192,29 @@ func (m *Model) saveAsset() {
 			}
 		}
 
-		// Update existing holding
-		holding := models.Holding{
-			ID:            m.modalState.EditingHoldingID,
-			AccountID:     account.ID,
-			AssetID:       asset.ID,
-			Amount:        amount,
-			PurchasePrice: purchasePrice,
-			// Keep original purchase date for edits
-		}
-		if err := holdingRepo.Update(&holding); err != nil {
+		// Edits become ledger entries for the difference
+		if _, err := m.ledgerService.AdjustHolding(oldHolding, account.ID, asset.ID, amount, purchasePrice); err != nil {
 			m.modalState.ShowError = true
 			m.modalState.ErrorMessage = "Failed to update holding"
 			return
 		}
-
-		// Log the update to audit trail
-		if m.auditService != nil && oldHolding.ID != 0 {
-			holding.PurchaseDate = oldHolding.PurchaseDate // Preserve purchase date for audit
-			_ = m.auditService.LogHoldingUpdate(&oldHolding, &holding)
-		}
 	} else {
-		// Create new holding
-		holding := models.Holding{
-			AccountID:     account.ID,
-			AssetID:       asset.ID,
-			Amount:        amount,
-			PurchasePrice: purchasePrice,
-			PurchaseDate:  time.Now(),
+		transaction := &models.Transaction{
+			Type:      models.TransactionDeposit,
+			AccountID: account.ID,
+			AssetID:   asset.ID,
+			Quantity:  amount,
+			PriceUSD:  purchasePrice,
+			Timestamp: time.Now(),
+		}
+		if purchasePrice.IsPositive() {
+			transaction.Type = models.TransactionBuy
 		}
-		if err := holdingRepo.Create(&holding); err != nil {
+		if _, err := m.ledgerService.Record(transaction); err != nil {
 			m.modalState.ShowError = true
 			m.modalState.ErrorMessage = "Failed to create holding"
 			return
 		}
-
-		// Log the creation to audit trail
-		if m.auditService != nil {
-			_ = m.auditService.LogHoldingCreate(&holding)
-		}
 	}
 
 	// Success - reload data and close modal
@@ -260,12 +225,17 @@ func (m *Model) saveAsset() {
 }
 
 func (m *Model) renderAddAssetModal() string {
-	var b strings.Builder
-
 	title := "Add New Asset"
 	if m.modalState.IsEdit {
 		title = "Edit Asset"
 	}
+	return m.renderModal(title)
+}
+
+// renderModal draws the active modal's fields, error and Save/Cancel buttons
+func (m *Model) renderModal(title string) string {
+	var b strings.Builder
+
 	b.WriteString(titleStyle.Render(title) + "\n\n")
 
 	// Render input fields
@@ -310,26 +280,3 @@ func (m *Model) renderAddAssetModal() string {
 
 	return modalStyle.Render(b.String())
 }
-
-func (m *Model) guessAssetType(symbol string) models.AssetType {
-	symbol = strings.ToUpper(symbol)
-
-	// Common fiat currencies
-	fiatSymbols := []string{"USD", "EUR", "GBP", "JPY", "CHF", "CAD", "AUD", "NZD", "AED"}
-	for _, fiat := range fiatSymbols {
-		if symbol == fiat {
-			return models.AssetTypeFiat
-		}
-	}
-
-	// Common crypto currencies
-	cryptoSymbols := []string{"BTC", "ETH", "USDT", "USDC", "BNB", "XRP", "SOL", "ADA"}
-	for _, crypto := range cryptoSymbols {
-		if symbol == crypto {
-			return models.AssetTypeCrypto
-		}
-	}
-
-	// Default to crypto for unknown symbols
-	return models.AssetTypeCrypto
-}
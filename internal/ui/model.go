@@ -4,11 +4,13 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/bioharz/budget/internal/currency"
 	"github.com/bioharz/budget/internal/models"
 	"github.com/bioharz/budget/internal/repository"
 	"github.com/bioharz/budget/internal/service"
 	"github.com/charmbracelet/bubbles/table"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
@@ -20,6 +22,13 @@ const (
 	ViewAddAsset      View = "add_asset"
 	ViewHistory       View = "history"
 	ViewDeleteConfirm View = "delete_confirm"
+	ViewChart         View = "chart"
+	ViewResolve       View = "resolve"
+	ViewManualPrice   View = "manual_price"
+	ViewAccounts      View = "accounts"
+	ViewRenameAccount View = "rename_account"
+	ViewEditAsset     View = "edit_asset"
+	ViewTrash         View = "trash"
 )
 
 type Model struct {
@@ -27,7 +36,7 @@ type Model struct {
 	accounts          []models.Account
 	assets            []models.Asset
 	holdings          []models.Holding
-	prices            map[uint]float64
+	prices            map[uint]decimal.Decimal
 	table             table.Model
 	width             int
 	height            int
@@ -37,21 +46,59 @@ type Model struct {
 	modalState        ModalState
 	priceService      *service.PriceService
 	auditService      *service.AuditService
+	snapshotService   *service.SnapshotService
+	ledgerService     *service.LedgerService
+	accountService    *service.AccountService
+	holdingService    *service.HoldingService
+	assetService      *service.AssetService
+	undoService       *service.UndoService
+	trashService      *service.TrashService
+	undoStatus        string
+	undoErr           error
 	deletingHoldingID uint
 	lastPriceUpdate   *time.Time
+	chartRange        ChartRange
+	snapshots         []models.PortfolioSnapshot
+	rowAssetIDs       []uint
+	rowHoldingIDs     []uint
+	unresolved        []models.Asset
+	resolveAsset      models.Asset
+	resolveCandidates []models.CoinListing
+	resolveCursor     int
+	manualPrices      map[uint]models.PriceCache
+	accountCursor     int
+	mergeSourceID     uint
+	mergeTargetID     uint
+	assetCursor       int
+	deletingAssetID   uint
+	priceSources      map[uint]service.PriceSource
+	trashItems        []service.TrashItem
+	trashCursor       int
+	purgingTrash      bool
+	converter         currency.Converter
+	converterErr      error
 }
 
 func InitialModel() Model {
 	m := Model{
-		view:         ViewMain,
-		prices:       make(map[uint]float64),
-		accounts:     []models.Account{},
-		assets:       []models.Asset{},
-		holdings:     []models.Holding{},
-		priceService: service.NewPriceService(),
-		auditService: service.NewAuditService(),
-		width:        120, // Default width
-		height:       30,  // Default height
+		view:            ViewMain,
+		prices:          make(map[uint]decimal.Decimal),
+		accounts:        []models.Account{},
+		assets:          []models.Asset{},
+		holdings:        []models.Holding{},
+		priceService:    service.NewPriceService(),
+		auditService:    service.NewAuditService(),
+		snapshotService: service.NewSnapshotService(),
+		ledgerService:   service.NewLedgerService(),
+		accountService:  service.NewAccountService(),
+		holdingService:  service.NewHoldingService(),
+		assetService:    service.NewAssetService(),
+		undoService:     service.NewUndoService(),
+		trashService:    service.NewTrashService(),
+		chartRange:      ChartRangeWeek,
+		converter:       currency.Identity,
+		width:           120, // Default width
+		height:          30,  // Default height
 	}
 	m.setupTable()
 	return m
@@ -59,15 +106,24 @@ func InitialModel() Model {
 
 func InitialModelWithDB(db *gorm.DB) Model {
 	m := Model{
-		view:         ViewMain,
-		prices:       make(map[uint]float64),
-		accounts:     []models.Account{},
-		assets:       []models.Asset{},
-		holdings:     []models.Holding{},
-		priceService: service.NewPriceServiceWithDB(db),
-		auditService: service.NewAuditServiceWithDB(db),
-		width:        120, // Default width
-		height:       30,  // Default height
+		view:            ViewMain,
+		prices:          make(map[uint]decimal.Decimal),
+		accounts:        []models.Account{},
+		assets:          []models.Asset{},
+		holdings:        []models.Holding{},
+		priceService:    service.NewPriceServiceWithDB(db),
+		auditService:    service.NewAuditServiceWithDB(db),
+		snapshotService: service.NewSnapshotServiceWithDB(db),
+		ledgerService:   service.NewLedgerServiceWithDB(db),
+		accountService:  service.NewAccountServiceWithDB(db),
+		holdingService:  service.NewHoldingServiceWithDB(db),
+		assetService:    service.NewAssetServiceWithDB(db),
+		undoService:     service.NewUndoServiceWithDB(db),
+		trashService:    service.NewTrashServiceWithDB(db),
+		chartRange:      ChartRangeWeek,
+		converter:       currency.Identity,
+		width:           120, // Default width
+		height:          30,  // Default height
 	}
 	m.setupTable()
 	return m
@@ -86,26 +142,24 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if m.inputMode {
 			switch msg.String() {
 			case "esc":
-				m.inputMode = false
-				m.inputBuffer = ""
-				m.view = ViewMain
+				m.closeModal()
 			case "enter":
-				if m.view == ViewAddAsset {
+				if m.inModal() {
 					m.handleModalInput("enter")
 					return m, nil
 				}
 			case "backspace":
-				if m.view == ViewAddAsset {
+				if m.inModal() {
 					m.handleModalInput("backspace")
 				} else if len(m.inputBuffer) > 0 {
 					m.inputBuffer = m.inputBuffer[:len(m.inputBuffer)-1]
 				}
 			case "tab":
-				if m.view == ViewAddAsset {
+				if m.inModal() {
 					m.handleModalInput("tab")
 				}
 			default:
-				if m.view == ViewAddAsset {
+				if m.inModal() {
 					m.handleModalInput(msg.String())
 				} else {
 					m.inputBuffer += msg.String()
@@ -126,7 +180,45 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, nil
 		}
 
-		// Main table view keyboard handling
+		// Handle chart view range selection
+		if m.view == ViewChart {
+			switch msg.String() {
+			case "d":
+				m.setChartRange(ChartRangeDay)
+			case "w":
+				m.setChartRange(ChartRangeWeek)
+			case "m":
+				m.setChartRange(ChartRangeMonth)
+			case "y":
+				m.setChartRange(ChartRangeYear)
+			case "esc":
+				m.view = ViewMain
+			case "ctrl+c", "q":
+				return m, tea.Quit
+			}
+			return m, nil
+		}
+
+		// Handle coin ID disambiguation
+		if m.view == ViewResolve {
+			return m, m.handleResolveKey(msg.String())
+		}
+
+		if m.view == ViewAccounts {
+			return m, m.handleAccountsKey(msg.String())
+		}
+
+		if m.view == ViewAssets {
+			return m, m.handleAssetsKey(msg.String())
+		}
+
+		if m.view == ViewTrash {
+			return m, m.handleTrashKey(msg.String())
+		}
+
+		// Main table view keyboard handling. The result of an undo shows
+		// until the next key.
+		m.undoStatus, m.undoErr = "", nil
 		switch msg.String() {
 		case "ctrl+c", "q":
 			return m, tea.Quit
@@ -142,6 +234,24 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, m.refreshPrices()
 		case "h":
 			m.view = ViewHistory
+		case "c":
+			m.openChart()
+		case "r":
+			m.openResolve()
+		case "m":
+			m.openManualPrice()
+		case "b":
+			return m, m.cycleBaseCurrency()
+		case "a":
+			m.openAccounts()
+		case "s":
+			m.openAssets()
+		case "u":
+			m.undoChange()
+		case "ctrl+r":
+			m.redoChange()
+		case "t":
+			m.openTrash()
 		case "esc":
 			if m.view == ViewDeleteConfirm {
 				m.deletingHoldingID = 0
@@ -164,16 +274,28 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.updateTableData()
 		}
 
+	case converterMsg:
+		m.applyConverter(msg)
+		m.updateTableData()
+
 	case priceUpdateMsg:
+		if msg.converter != nil {
+			m.applyConverter(*msg.converter)
+		}
 		if msg.err == nil && msg.prices != nil {
 			m.prices = msg.prices
 			// Update last price update time
 			lastUpdate, _ := m.priceService.GetLastUpdateTime()
 			m.lastPriceUpdate = lastUpdate
+			m.loadManualPrices()
+			m.updateUnresolved()
 			m.updateTableData()
 		}
 
 	case dataLoadedMsg:
+		if msg.converter != nil {
+			m.applyConverter(*msg.converter)
+		}
 		m.accounts = msg.accounts
 		m.assets = msg.assets
 		m.holdings = msg.holdings
@@ -189,6 +311,8 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 		// Now update table with prices available
+		m.loadManualPrices()
+		m.updateUnresolved()
 		m.updateTableData()
 	}
 
@@ -207,6 +331,20 @@ func (m Model) View() string {
 		return m.historyView()
 	case ViewDeleteConfirm:
 		return m.deleteConfirmView()
+	case ViewChart:
+		return m.chartView()
+	case ViewResolve:
+		return m.resolveView()
+	case ViewManualPrice:
+		return m.renderManualPriceModal()
+	case ViewAccounts:
+		return m.accountsView()
+	case ViewRenameAccount:
+		return m.renderRenameAccountModal()
+	case ViewEditAsset:
+		return m.renderEditAssetModal()
+	case ViewTrash:
+		return m.trashView()
 	default:
 		return "Unknown view"
 	}
@@ -216,13 +354,6 @@ func (m Model) mainView() string {
 	return m.tableView()
 }
 
-func (m Model) assetsView() string {
-	content := "📊 Your Assets\n\n"
-	content += "No assets yet. Press 2 from main menu to add assets.\n\n"
-	content += "Press ESC to go back"
-	return content
-}
-
 func (m Model) addAssetView() string {
 	return m.renderAddAssetModal()
 }
@@ -256,16 +387,35 @@ func (m Model) historyView() string {
 			actionIcon = "✏️"
 		case models.AuditActionDelete:
 			actionIcon = "🗑️"
+		case models.AuditActionPurge:
+			actionIcon = "🔥"
 		}
 
-		content += fmt.Sprintf("%s %s - %s\n",
+		// Entries are numbered so the notes of undos can refer to them
+		undone := ""
+		if log.Undone {
+			undone = " (undone)"
+		}
+		content += fmt.Sprintf("%s %s - %s #%d%s\n",
 			log.CreatedAt.Format("2006-01-02 15:04"),
 			actionIcon,
-			log.Action)
+			log.Action,
+			log.ID,
+			undone)
 
 		// Parse and display the changes
-		if log.EntityType == models.AuditEntityHolding {
+		switch log.EntityType {
+		case models.AuditEntityHolding:
 			content += m.formatHoldingChange(log)
+		case models.AuditEntityAccount:
+			content += m.formatAccountChange(log)
+		case models.AuditEntityAsset:
+			content += m.formatAssetChange(log)
+		case models.AuditEntityTransaction:
+			content += m.formatTransactionChange(log)
+		}
+		if log.UserNote != "" {
+			content += "  Note: " + log.UserNote + "\n"
 		}
 
 		content += "───────────────────────────────────\n"
@@ -287,7 +437,7 @@ func (m Model) deleteConfirmView() string {
 
 	account := m.getAccountByID(holding.AccountID)
 	asset := m.getAssetByID(holding.AssetID)
-	value := holding.Amount * m.prices[holding.AssetID]
+	value := holding.Amount.Mul(m.prices[holding.AssetID])
 
 	content := m.tableView() + "\n\n"
 	content += "┌─────────────────────────────────────────────┐\n"
@@ -295,8 +445,8 @@ func (m Model) deleteConfirmView() string {
 	content += "├─────────────────────────────────────────────┤\n"
 	content += fmt.Sprintf("│ Account: %-34s │\n", account.Name)
 	content += fmt.Sprintf("│ Asset:   %-34s │\n", asset.Symbol)
-	content += fmt.Sprintf("│ Amount:  %-34.4f │\n", holding.Amount)
-	content += fmt.Sprintf("│ Value:   $%-33.2f │\n", value)
+	content += fmt.Sprintf("│ Amount:  %-34s │\n", holding.Amount.StringFixed(4))
+	content += fmt.Sprintf("│ Value:   %-34s │\n", m.converter.FormatUSD(value))
 	content += "│                                             │\n"
 	content += "│ Are you sure you want to delete this?      │\n"
 	content += "│                                             │\n"
@@ -352,19 +502,21 @@ func (m Model) refreshPrices() tea.Cmd {
 			return priceUpdateMsg{err: err}
 		}
 
-		return priceUpdateMsg{prices: prices}
+		return priceUpdateMsg{prices: prices, converter: m.loadConverter()}
 	}
 }
 
 type priceUpdateMsg struct {
-	prices map[uint]float64
-	err    error
+	prices    map[uint]decimal.Decimal
+	converter *converterMsg // nil keeps the current one
+	err       error
 }
 
 type dataLoadedMsg struct {
-	accounts []models.Account
-	assets   []models.Asset
-	holdings []models.Holding
+	accounts  []models.Account
+	assets    []models.Asset
+	holdings  []models.Holding
+	converter *converterMsg // nil keeps the current one
 }
 
 func (m Model) loadDataCmd() tea.Cmd {
@@ -382,28 +534,24 @@ func (m Model) loadDataCmd() tea.Cmd {
 		holdings, _ := holdingRepo.GetAll()
 
 		return dataLoadedMsg{
-			accounts: accounts,
-			assets:   assets,
-			holdings: holdings,
+			accounts:  accounts,
+			assets:    assets,
+			holdings:  holdings,
+			converter: m.loadConverter(),
 		}
 	}
 }
 
 func (m *Model) deleteSelectedHolding() {
-	// Get selected row index
-	selectedRow := m.table.Cursor()
-	if selectedRow >= len(m.holdings) {
+	holding, ok := m.selectedHolding()
+	if !ok {
 		return
 	}
-
-	// Get the holding to delete
-	holding := m.holdings[selectedRow]
 	m.deletingHoldingID = holding.ID
 	m.view = ViewDeleteConfirm
 }
 
 func (m *Model) confirmDelete() {
-	// Find the holding before deletion for audit log
 	var holdingToDelete models.Holding
 	for _, h := range m.holdings {
 		if h.ID == m.deletingHoldingID {
@@ -412,18 +560,12 @@ func (m *Model) confirmDelete() {
 		}
 	}
 
-	// Delete from database
-	holdingRepo := repository.NewHoldingRepository()
-	if err := holdingRepo.Delete(m.deletingHoldingID); err != nil {
+	// Deleting withdraws the whole balance, the ledger removes the holding
+	if err := m.ledgerService.CloseHolding(holdingToDelete); err != nil {
 		m.err = err
 		return
 	}
 
-	// Log the deletion to audit trail
-	if m.auditService != nil && holdingToDelete.ID != 0 {
-		_ = m.auditService.LogHoldingDelete(&holdingToDelete)
-	}
-
 	// Reload data and return to main view
 	m.loadData()
 	m.view = ViewMain
@@ -431,14 +573,10 @@ func (m *Model) confirmDelete() {
 }
 
 func (m *Model) editSelectedHolding() {
-	// Get selected row index
-	selectedRow := m.table.Cursor()
-	if selectedRow >= len(m.holdings) {
+	holding, ok := m.selectedHolding()
+	if !ok {
 		return
 	}
-
-	// Get the holding to edit
-	holding := m.holdings[selectedRow]
 	account := m.getAccountByID(holding.AccountID)
 	asset := m.getAssetByID(holding.AssetID)
 
@@ -447,3 +585,33 @@ func (m *Model) editSelectedHolding() {
 	m.inputMode = true
 	m.initEditAssetModal(holding, account, asset)
 }
+
+// selectedHolding returns the holding under the cursor. On an asset row it
+// is the asset's only holding, if it has just one.
+func (m *Model) selectedHolding() (models.Holding, bool) {
+	cursor := m.table.Cursor()
+	if cursor < 0 || cursor >= len(m.rowHoldingIDs) {
+		return models.Holding{}, false
+	}
+
+	holdingID := m.rowHoldingIDs[cursor]
+	if holdingID == 0 {
+		var match []models.Holding
+		for _, h := range m.holdings {
+			if h.AssetID == m.rowAssetIDs[cursor] {
+				match = append(match, h)
+			}
+		}
+		if len(match) != 1 {
+			return models.Holding{}, false
+		}
+		return match[0], true
+	}
+
+	for _, h := range m.holdings {
+		if h.ID == holdingID {
+			return h, true
+		}
+	}
+	return models.Holding{}, false
+}
@@ -1,12 +1,21 @@
 package ui
 
 import (
+	"io"
+	"math"
 	"testing"
+	"time"
 
+	"github.com/bioharz/budget/internal/currency"
 	"github.com/bioharz/budget/internal/models"
+	"github.com/bioharz/budget/test/fixtures"
 	"github.com/bioharz/budget/test/helpers"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestInitialModel(t *testing.T) {
@@ -220,7 +229,7 @@ func TestModel_PriceUpdate(t *testing.T) {
 
 	// Add some holdings for the table
 	model.holdings = []models.Holding{
-		{ID: 1, AccountID: 1, AssetID: 1, Amount: 0.5},
+		{ID: 1, AccountID: 1, AssetID: 1, Amount: decimal.NewFromFloat(0.5)},
 	}
 	model.accounts = []models.Account{
 		{ID: 1, Name: "Test Account"},
@@ -230,15 +239,15 @@ func TestModel_PriceUpdate(t *testing.T) {
 	}
 
 	// Send price update message
-	prices := map[uint]float64{
-		1: 50000.0,
+	prices := map[uint]decimal.Decimal{
+		1: decimal.NewFromInt(50000),
 	}
 	msg := priceUpdateMsg{prices: prices}
 
 	newModel, _ := model.Update(msg)
 	m := newModel.(Model)
 
-	assert.Equal(t, 50000.0, m.prices[1])
+	assert.Equal(t, "50000", m.prices[1].String())
 }
 
 func TestModel_ViewRendering(t *testing.T) {
@@ -288,3 +297,466 @@ func TestModel_ViewRendering(t *testing.T) {
 		})
 	}
 }
+
+func TestModel_ChartView(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	model := InitialModelWithDB(db)
+
+	now := time.Now()
+	for i, value := range []int64{1000, 1200, 900} {
+		require.NoError(t, db.Create(&models.PortfolioSnapshot{
+			TotalValueUSD: decimal.NewFromInt(value),
+			Timestamp:     now.Add(time.Duration(i-3) * time.Hour),
+		}).Error)
+	}
+
+	msg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("c")}
+	newModel, _ := model.Update(msg)
+	m := newModel.(Model)
+
+	assert.Equal(t, ViewChart, m.view)
+	assert.Equal(t, ChartRangeWeek, m.chartRange)
+	assert.Len(t, m.snapshots, 3)
+
+	output := m.View()
+	assert.Contains(t, output, "Net Worth - Week")
+	assert.Contains(t, output, "$1,200.00")
+	assert.Contains(t, output, "$900.00")
+	assert.Contains(t, output, "-$100.00")
+
+	// 'd' switches range instead of deleting while the chart is open
+	msg = tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("d")}
+	newModel, _ = m.Update(msg)
+	m = newModel.(Model)
+	assert.Equal(t, ViewChart, m.view)
+	assert.Equal(t, ChartRangeDay, m.chartRange)
+
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyEscape})
+	m = newModel.(Model)
+	assert.Equal(t, ViewMain, m.view)
+}
+
+func TestModel_ResolveView(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	model := InitialModelWithDB(db)
+
+	now := time.Now()
+	require.NoError(t, db.Create(&[]models.CoinListing{
+		{CoinID: "arbitrum", Symbol: "ARB", Name: "Arbitrum", UpdatedAt: now},
+		{CoinID: "arbi-token", Symbol: "ARB", Name: "Arbi Token", UpdatedAt: now},
+	}).Error)
+	account := fixtures.NewAccount().Create(t, db)
+	asset := fixtures.NewAsset().WithSymbol("ARB").WithName("Arbitrum").Create(t, db)
+	holding := fixtures.NewHolding().WithAccount(account).WithAsset(asset).Create(t, db)
+
+	newModel, _ := model.Update(dataLoadedMsg{
+		accounts: []models.Account{*account},
+		assets:   []models.Asset{*asset},
+		holdings: []models.Holding{*holding},
+	})
+	m := newModel.(Model)
+	require.Len(t, m.unresolved, 1)
+	assert.Contains(t, m.View(), "Ambiguous symbols: ARB")
+
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("r")})
+	m = newModel.(Model)
+	assert.Equal(t, ViewResolve, m.view)
+	require.Len(t, m.resolveCandidates, 2)
+	assert.Contains(t, m.View(), "Arbi Token (arbi-token)")
+
+	// Candidates are sorted by name, so the second one is Arbitrum
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	m = newModel.(Model)
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = newModel.(Model)
+	assert.Equal(t, ViewMain, m.view)
+
+	var updated models.Asset
+	require.NoError(t, db.First(&updated, asset.ID).Error)
+	assert.Equal(t, "arbitrum", updated.ProviderID)
+}
+
+func TestModel_ManualPrice(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	model := InitialModelWithDB(db)
+
+	account := fixtures.NewAccount().Create(t, db)
+	asset := fixtures.NewAsset().WithSymbol("CAR").WithName("Car").WithType(models.AssetTypeOther).Create(t, db)
+	holding := fixtures.NewHolding().WithAccount(account).WithAsset(asset).WithAmount(1).Create(t, db)
+
+	newModel, _ := model.Update(dataLoadedMsg{
+		accounts: []models.Account{*account},
+		assets:   []models.Asset{*asset},
+		holdings: []models.Holding{*holding},
+	})
+	m := newModel.(Model)
+
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("m")})
+	m = newModel.(Model)
+	assert.Equal(t, ViewManualPrice, m.view)
+	assert.Contains(t, m.View(), "Manual Price - CAR")
+
+	for _, key := range []string{"1", "5", "0", "0", "0"} {
+		newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+		m = newModel.(Model)
+	}
+	// Tab past the date field to the Save button
+	for i := 0; i < 2; i++ {
+		newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyTab})
+		m = newModel.(Model)
+	}
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = newModel.(Model)
+
+	assert.Equal(t, ViewMain, m.view)
+	assert.Equal(t, "15000", m.prices[asset.ID].String())
+	assert.Contains(t, m.View(), "CAR (manual)")
+
+	var cache models.PriceCache
+	require.NoError(t, db.Where("asset_id = ?", asset.ID).First(&cache).Error)
+	assert.True(t, cache.Manual)
+}
+
+func TestModel_PerformanceColumns(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	model := InitialModelWithDB(db)
+
+	account := fixtures.NewAccount().WithName("Ledger").Create(t, db)
+	asset := fixtures.NewAsset().WithSymbol("BTC").Create(t, db)
+	gain := fixtures.NewHolding().WithAccount(account).WithAsset(asset).WithAmount(2).
+		WithPurchasePrice(40000).WithPurchaseDate(time.Now().Add(-400*24*time.Hour)).Create(t, db)
+	loss := fixtures.NewHolding().WithAccount(account).WithAsset(asset).WithAmount(1).
+		WithPurchasePrice(60000).WithPurchaseDate(time.Now().Add(-10*24*time.Hour)).Create(t, db)
+
+	model.accounts = []models.Account{*account}
+	model.assets = []models.Asset{*asset}
+	model.holdings = []models.Holding{*gain, *loss}
+	model.prices = map[uint]decimal.Decimal{asset.ID: decimal.NewFromInt(50000)}
+
+	rows := model.buildTableRows()
+	require.Len(t, rows, 3)
+
+	// The asset row aggregates both holdings: cost 140k, value 150k
+	assert.Equal(t, []string{"$140,000.00", "+$10,000.00", "+7.14%", "1y 1m"}, []string(rows[0][3:]))
+	assert.Equal(t, []string{"$80,000.00", "+$20,000.00", "+25.00%", "1y 1m"}, []string(rows[1][3:]))
+	assert.Equal(t, []string{"$60,000.00", "-$10,000.00", "-16.67%", "10d"}, []string(rows[2][3:]))
+}
+
+func TestColorCell(t *testing.T) {
+	renderer := lipgloss.NewRenderer(io.Discard)
+	renderer.SetColorProfile(termenv.ANSI)
+	style := renderer.NewStyle().Foreground(lipgloss.Color("2"))
+
+	styled := colorCell("+$5.00", style, 20)
+	assert.NotEqual(t, "+$5.00", styled)
+	assert.Contains(t, styled, "+$5.00")
+
+	// Too narrow for the escape codes: fall back to plain text
+	assert.Equal(t, "+$5.00", colorCell("+$5.00", style, 8))
+}
+
+func TestFormatHoldingPeriod(t *testing.T) {
+	day := 24 * time.Hour
+	assert.Equal(t, "<1d", formatHoldingPeriod(time.Hour))
+	assert.Equal(t, "12d", formatHoldingPeriod(12*day))
+	assert.Equal(t, "5m", formatHoldingPeriod(155*day))
+	assert.Equal(t, "2y", formatHoldingPeriod(730*day))
+	assert.Equal(t, "1y 3m", formatHoldingPeriod(460*day))
+}
+
+func TestRenderChart(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(4 * time.Hour)
+	snapshots := []models.PortfolioSnapshot{
+		{TotalValueUSD: decimal.NewFromInt(100), Timestamp: start.Add(90 * time.Minute)},
+		{TotalValueUSD: decimal.NewFromInt(200), Timestamp: start.Add(210 * time.Minute)},
+	}
+
+	usd := func(s models.PortfolioSnapshot) decimal.Decimal { return s.TotalValueUSD }
+	values := bucketSnapshots(snapshots, usd, start, end, 4)
+	require.Len(t, values, 4)
+	assert.True(t, math.IsNaN(values[0]), "no data before the first snapshot")
+	assert.Equal(t, 100.0, values[1])
+	assert.Equal(t, 100.0, values[2], "values carry forward between snapshots")
+	assert.Equal(t, 200.0, values[3])
+
+	rows := renderChart(values, 2)
+	require.Len(t, rows, 2)
+	assert.Equal(t, "   █", rows[0])
+	assert.Equal(t, " ▁▁█", rows[1])
+}
+
+func TestModel_BaseCurrency(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	model := InitialModelWithDB(db)
+
+	account := fixtures.NewAccount().WithName("Ledger").Create(t, db)
+	asset := fixtures.NewAsset().WithSymbol("BTC").Create(t, db)
+	holding := fixtures.NewHolding().WithAccount(account).WithAsset(asset).WithAmount(1).
+		WithPurchasePrice(40000).Create(t, db)
+	model.accounts = []models.Account{*account}
+	model.assets = []models.Asset{*asset}
+	model.holdings = []models.Holding{*holding}
+	model.prices = map[uint]decimal.Decimal{asset.ID: decimal.NewFromInt(50000)}
+
+	newModel, _ := model.Update(converterMsg{converter: currency.Converter{Currency: currency.EUR, USDPerUnit: decimal.NewFromFloat(1.25)}})
+	m := newModel.(Model)
+
+	rows := m.buildTableRows()
+	assert.Equal(t, "40.000,00 €", rows[0][2])
+	assert.Equal(t, "+8.000,00 €", rows[0][4])
+	assert.Contains(t, m.View(), "Total: 40.000,00 €")
+
+	// Snapshots taken in EUR keep their own rate, older ones use today's
+	now := time.Now()
+	m.snapshots = []models.PortfolioSnapshot{
+		{TotalValueUSD: decimal.NewFromInt(1000), Timestamp: now.Add(-2 * time.Hour)},
+		{TotalValueUSD: decimal.NewFromInt(1000), Currency: "EUR", TotalValue: decimal.NewFromInt(900), Timestamp: now.Add(-time.Hour)},
+	}
+	assert.Equal(t, "800", m.snapshotValue(m.snapshots[0]).String())
+	assert.Equal(t, "900", m.snapshotValue(m.snapshots[1]).String())
+
+	// Without a rate the table falls back to USD and says so
+	newModel, _ = m.Update(converterMsg{err: assert.AnError})
+	m = newModel.(Model)
+	assert.Equal(t, "$50,000.00", m.buildTableRows()[0][2])
+	assert.Contains(t, m.View(), "Showing USD")
+
+	// 'b' stores the next base currency
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("b")})
+	assert.NotNil(t, cmd)
+	base, err := m.priceService.BaseCurrency()
+	require.NoError(t, err)
+	assert.Equal(t, currency.EUR, base)
+}
+
+func TestModel_Accounts(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	model := InitialModelWithDB(db)
+
+	coinbase := fixtures.NewAccount().WithName("Coinbase").WithType(models.AccountTypeUnknown).Create(t, db)
+	kraken := fixtures.NewAccount().WithName("Kraken").Create(t, db)
+	asset := fixtures.NewAsset().WithSymbol("BTC").Create(t, db)
+	first := fixtures.NewHolding().WithAccount(coinbase).WithAsset(asset).WithAmount(1).WithPurchasePrice(30000).Create(t, db)
+	second := fixtures.NewHolding().WithAccount(kraken).WithAsset(asset).WithAmount(2).WithPurchasePrice(30000).Create(t, db)
+
+	newModel, _ := model.Update(dataLoadedMsg{
+		accounts: []models.Account{*kraken, *coinbase},
+		assets:   []models.Asset{*asset},
+		holdings: []models.Holding{*first, *second},
+	})
+	m := newModel.(Model)
+	press := func(keys ...tea.KeyMsg) {
+		for _, key := range keys {
+			newModel, _ = m.Update(key)
+			m = newModel.(Model)
+		}
+	}
+	runes := func(s string) tea.KeyMsg { return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)} }
+
+	press(runes("a"))
+	assert.Equal(t, ViewAccounts, m.view)
+	assert.Contains(t, m.View(), "Coinbase")
+
+	// Coinbase is listed first; set its type and colour
+	press(runes("t"), runes("c"))
+	account, ok := m.selectedAccount()
+	require.True(t, ok)
+	assert.Equal(t, models.AccountTypeExchange, account.Type)
+	assert.Equal(t, "1", account.Color)
+	assert.Contains(t, m.View(), "Exchange")
+
+	press(runes("r"))
+	assert.Equal(t, ViewRenameAccount, m.view)
+	for range "Coinbase" {
+		press(tea.KeyMsg{Type: tea.KeyBackspace})
+	}
+	press(runes("L"), runes("e"), runes("d"), runes("g"), runes("e"), runes("r"),
+		tea.KeyMsg{Type: tea.KeyTab}, tea.KeyMsg{Type: tea.KeyEnter})
+	assert.Equal(t, ViewAccounts, m.view)
+	account, _ = m.selectedAccount()
+	assert.Equal(t, "Ledger", account.Name, "the selection follows the renamed account")
+
+	// Merge Ledger into Kraken
+	press(runes("m"), tea.KeyMsg{Type: tea.KeyUp}, tea.KeyMsg{Type: tea.KeyEnter})
+	assert.Contains(t, m.View(), "delete Ledger?")
+	press(runes("y"))
+	require.NoError(t, m.err)
+	require.Len(t, m.accounts, 1)
+	assert.Equal(t, "Kraken", m.accounts[0].Name)
+	require.Len(t, m.holdings, 1)
+	assert.Equal(t, "3", m.holdings[0].Amount.String())
+
+	press(tea.KeyMsg{Type: tea.KeyEsc})
+	assert.Equal(t, ViewMain, m.view)
+}
+
+func TestModel_Assets(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	model := InitialModelWithDB(db)
+
+	account := fixtures.NewAccount().Create(t, db)
+	btc := fixtures.NewAsset().WithSymbol("BTC").Create(t, db)
+	doge := fixtures.NewAsset().WithSymbol("DOGE").WithName("Dogecoin").Create(t, db)
+	holding := fixtures.NewHolding().WithAccount(account).WithAsset(btc).WithAmount(1).Create(t, db)
+	require.NoError(t, model.priceService.SetManualPrice(btc.ID, decimal.NewFromInt(50000), time.Now()))
+
+	newModel, _ := model.Update(dataLoadedMsg{
+		accounts: []models.Account{*account},
+		assets:   []models.Asset{*doge, *btc},
+		holdings: []models.Holding{*holding},
+	})
+	m := newModel.(Model)
+	m.prices = map[uint]decimal.Decimal{btc.ID: decimal.NewFromInt(50000)}
+	press := func(keys ...tea.KeyMsg) {
+		for _, key := range keys {
+			newModel, _ = m.Update(key)
+			m = newModel.(Model)
+		}
+	}
+	runes := func(s string) tea.KeyMsg { return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)} }
+
+	press(runes("s"))
+	assert.Equal(t, ViewAssets, m.view)
+	output := m.View()
+	assert.Contains(t, output, "Your Assets")
+	assert.Contains(t, output, "Dogecoin")
+	assert.Contains(t, output, "manual")
+
+	// BTC is listed first; correct its type and name
+	press(runes("t"))
+	assert.Equal(t, models.AssetTypeStock, m.assets[0].Type)
+	press(runes("e"))
+	assert.Equal(t, ViewEditAsset, m.view)
+	press(runes("!"), tea.KeyMsg{Type: tea.KeyTab}, tea.KeyMsg{Type: tea.KeyEnter})
+	assert.Equal(t, ViewAssets, m.view)
+	assert.Equal(t, btc.Name+"!", m.assets[0].Name)
+
+	// A held asset cannot be deleted, an unused one can
+	press(runes("d"), runes("y"))
+	assert.ErrorContains(t, m.err, "still held")
+	press(runes("j"), runes("d"))
+	assert.Contains(t, m.View(), "Delete DOGE")
+	press(runes("y"))
+	require.NoError(t, m.err)
+	require.Len(t, m.assets, 1)
+	assert.Equal(t, "BTC", m.assets[0].Symbol)
+
+	press(tea.KeyMsg{Type: tea.KeyEsc})
+	assert.Equal(t, ViewMain, m.view)
+}
+
+func TestModel_HistoryAccountsAndAssets(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	model := InitialModelWithDB(db)
+
+	account := fixtures.NewAccount().WithName("Ledger").WithType(models.AccountTypeUnknown).Create(t, db)
+	account.Name = "Ledger Nano"
+	account.Type = models.AccountTypeHardwareWallet
+	require.NoError(t, db.Save(account).Error)
+	asset := fixtures.NewAsset().WithSymbol("VWCE").WithName("VWCE").WithType(models.AssetTypeCrypto).Create(t, db)
+	asset.Type = models.AssetTypeStock
+	require.NoError(t, db.Save(asset).Error)
+	require.NoError(t, db.Delete(asset).Error)
+	require.NoError(t, db.Delete(account).Error)
+
+	model.view = ViewHistory
+	output := model.View()
+	for _, line := range []string{
+		"Created account Ledger",
+		"Updated account Ledger Nano:",
+		"Name: Ledger → Ledger Nano",
+		"Type: unknown → hardware_wallet",
+		"Created asset VWCE (VWCE, crypto)",
+		"Type: crypto → stock",
+		"Deleted asset VWCE (VWCE)",
+		"Deleted account Ledger Nano",
+	} {
+		assert.Contains(t, output, line)
+	}
+	assert.NotContains(t, output, "Colour:", "unchanged fields are left out")
+}
+
+func TestModel_UndoRedo(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	model := InitialModelWithDB(db)
+
+	account := fixtures.NewAccount().Create(t, db)
+	btc := fixtures.NewAsset().WithSymbol("BTC").Create(t, db)
+	_, err := model.ledgerService.Record(&models.Transaction{
+		Type: models.TransactionDeposit, AccountID: account.ID, AssetID: btc.ID, Quantity: decimal.NewFromInt(2),
+	})
+	require.NoError(t, err)
+
+	m := model
+	press := func(key tea.KeyMsg) {
+		newModel, _ := m.Update(key)
+		m = newModel.(Model)
+	}
+
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("u")})
+	assert.Empty(t, m.holdings)
+	assert.Contains(t, m.View(), "Undid create of holding #1")
+
+	press(tea.KeyMsg{Type: tea.KeyCtrlR})
+	require.Len(t, m.holdings, 1)
+	assert.Equal(t, "2", m.holdings[0].Amount.String())
+	assert.Contains(t, m.View(), "Redid create of holding #1")
+
+	press(tea.KeyMsg{Type: tea.KeyCtrlR})
+	assert.Contains(t, m.View(), "nothing to redo")
+
+	m.view = ViewHistory
+	assert.Contains(t, m.View(), "CREATE #3\n", "the redo applied the change again")
+	assert.Contains(t, m.View(), "Note: Redo of #3")
+}
+
+func TestModel_Trash(t *testing.T) {
+	db := helpers.SetupTestDB(t)
+	model := InitialModelWithDB(db)
+
+	account := fixtures.NewAccount().WithName("Ledger").Create(t, db)
+	btc := fixtures.NewAsset().WithSymbol("BTC").Create(t, db)
+	holding, err := model.ledgerService.Record(&models.Transaction{
+		Type: models.TransactionDeposit, AccountID: account.ID, AssetID: btc.ID, Quantity: decimal.NewFromInt(2),
+	})
+	require.NoError(t, err)
+	require.NoError(t, model.ledgerService.CloseHolding(*holding))
+
+	m := model
+	press := func(keys ...string) {
+		for _, key := range keys {
+			newModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+			m = newModel.(Model)
+		}
+	}
+
+	press("t")
+	assert.Equal(t, ViewTrash, m.view)
+	output := m.View()
+	assert.Contains(t, output, "2 BTC in Ledger")
+	assert.Contains(t, output, "purged after 30 days")
+
+	press("r")
+	assert.Empty(t, m.trashItems)
+	require.Len(t, m.holdings, 1, "the restored holding is back on the main view")
+
+	require.NoError(t, model.ledgerService.CloseHolding(m.holdings[0]))
+	m.openTrash()
+	press("x")
+	assert.Contains(t, m.View(), "Purge 2 BTC in Ledger for good?")
+	press("y")
+	assert.Contains(t, m.View(), "The trash is empty")
+
+	// Purging the asset takes its ledger with it
+	require.NoError(t, model.assetService.Delete(btc.ID))
+	require.NoError(t, model.trashService.Purge(models.AuditEntityAsset, btc.ID))
+
+	m.view = ViewHistory
+	m.accounts = []models.Account{*account}
+	m.assets = []models.Asset{*btc}
+	output = m.View()
+	assert.Contains(t, output, "Purged 2.0000 BTC from Ledger")
+	assert.Contains(t, output, "Purged deposit of 2.0000 BTC in Ledger")
+	assert.Contains(t, output, "Note: Purged with asset BTC")
+}
@@ -0,0 +1,130 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bioharz/budget/internal/models"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// openResolve lets the user pick the CoinGecko coin behind the selected
+// crypto asset, or the first ambiguous one when the selection is not crypto
+func (m *Model) openResolve() {
+	if m.priceService == nil || m.priceService.Resolver() == nil {
+		return
+	}
+
+	var asset models.Asset
+	if cursor := m.table.Cursor(); cursor >= 0 && cursor < len(m.rowAssetIDs) {
+		asset = m.getAssetByID(m.rowAssetIDs[cursor])
+	}
+	if asset.Type != models.AssetTypeCrypto {
+		if len(m.unresolved) == 0 {
+			return
+		}
+		asset = m.unresolved[0]
+	}
+
+	candidates, err := m.priceService.Resolver().Candidates(asset.Symbol)
+	if err != nil && len(candidates) == 0 {
+		m.err = err
+	}
+
+	m.resolveAsset = asset
+	m.resolveCandidates = candidates
+	m.resolveCursor = 0
+	for i, candidate := range candidates {
+		if candidate.CoinID == asset.ProviderID {
+			m.resolveCursor = i
+			break
+		}
+	}
+	m.view = ViewResolve
+}
+
+func (m *Model) handleResolveKey(key string) tea.Cmd {
+	switch key {
+	case "up", "k":
+		if m.resolveCursor > 0 {
+			m.resolveCursor--
+		}
+	case "down", "j":
+		if m.resolveCursor < len(m.resolveCandidates)-1 {
+			m.resolveCursor++
+		}
+	case "enter":
+		if len(m.resolveCandidates) == 0 {
+			m.view = ViewMain
+			return nil
+		}
+		coinID := m.resolveCandidates[m.resolveCursor].CoinID
+		if err := m.priceService.SetProviderID(m.resolveAsset, coinID); err != nil {
+			m.err = err
+			return nil
+		}
+		m.view = ViewMain
+		m.err = nil
+		return tea.Sequence(m.loadDataCmd(), m.refreshPrices())
+	case "esc":
+		m.view = ViewMain
+	case "ctrl+c", "q":
+		return tea.Quit
+	}
+	return nil
+}
+
+// updateUnresolved refreshes the list of assets whose symbol needs the user
+// to pick a coin
+func (m *Model) updateUnresolved() {
+	if m.priceService == nil || m.priceService.Resolver() == nil {
+		return
+	}
+	m.unresolved = m.priceService.Resolver().Unresolved(m.pricedAssets())
+}
+
+func (m Model) resolveView() string {
+	var b strings.Builder
+
+	b.WriteString(titleStyle.Render("🔎 Choose the coin for "+m.resolveAsset.Symbol) + "\n")
+
+	if len(m.resolveCandidates) == 0 {
+		if m.err != nil {
+			b.WriteString(errorStyle.Render(fmt.Sprintf("Could not load the coin list: %v", m.err)) + "\n\n")
+		} else {
+			b.WriteString("No coin with this symbol is known to CoinGecko.\n\n")
+		}
+		b.WriteString("[ESC] back")
+		return b.String()
+	}
+
+	// Keep the cursor visible on short terminals
+	visible := m.height - 6
+	if visible < 5 {
+		visible = 5
+	}
+	start := 0
+	if m.resolveCursor >= visible {
+		start = m.resolveCursor - visible + 1
+	}
+	end := start + visible
+	if end > len(m.resolveCandidates) {
+		end = len(m.resolveCandidates)
+	}
+
+	for i := start; i < end; i++ {
+		candidate := m.resolveCandidates[i]
+		line := fmt.Sprintf("%s (%s)", candidate.Name, candidate.CoinID)
+		if candidate.CoinID == m.resolveAsset.ProviderID {
+			line += " ✓"
+		}
+		if i == m.resolveCursor {
+			b.WriteString(selectedStyle.Render("> "+line) + "\n")
+		} else {
+			b.WriteString("  " + line + "\n")
+		}
+	}
+
+	b.WriteString("\n[↑/↓] select  [enter] use this coin  [ESC] back")
+	return b.String()
+}
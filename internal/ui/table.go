@@ -4,10 +4,14 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/bioharz/budget/internal/models"
+	"github.com/bioharz/budget/internal/service"
 	"github.com/charmbracelet/bubbles/table"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/mattn/go-runewidth"
+	"github.com/shopspring/decimal"
 )
 
 var (
@@ -27,6 +31,16 @@ var (
 	totalStyle = lipgloss.NewStyle().
 			Bold(true).
 			Foreground(lipgloss.Color("229"))
+
+	warningStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("214"))
+
+	// Basic ANSI colours keep the escape codes short in table cells
+	gainStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("2"))
+
+	lossStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("1"))
 )
 
 func (m *Model) setupTable() {
@@ -36,22 +50,32 @@ func (m *Model) setupTable() {
 		availableWidth = 80 // Minimum width
 	}
 
+	// Coloured cells carry escape codes the table counts as width, so the
+	// account and P/L columns get that much extra room
+	overhead := colorOverhead()
+	availableWidth -= 3 * overhead
+
 	// Distribute width proportionally for new layout
-	assetAccountWidth := int(float64(availableWidth) * 0.45)
-	amountWidth := int(float64(availableWidth) * 0.25)
-	valueWidth := int(float64(availableWidth) * 0.30)
+	assetAccountWidth := int(float64(availableWidth) * 0.25)
+	amountWidth := int(float64(availableWidth) * 0.13)
+	valueWidth := int(float64(availableWidth) * 0.14)
+	costWidth := int(float64(availableWidth) * 0.14)
+	plWidth := int(float64(availableWidth) * 0.14)
+	plPctWidth := int(float64(availableWidth) * 0.10)
+	heldWidth := int(float64(availableWidth) * 0.10)
 
 	columns := []table.Column{
-		{Title: "Asset/Account", Width: assetAccountWidth},
+		{Title: "Asset/Account", Width: assetAccountWidth + overhead},
 		{Title: "Amount", Width: amountWidth},
 		{Title: "Value", Width: valueWidth},
+		{Title: "Cost Basis", Width: costWidth},
+		{Title: "P/L", Width: plWidth + overhead},
+		{Title: "P/L %", Width: plPctWidth + overhead},
+		{Title: "Held", Width: heldWidth},
 	}
 
-	rows := m.buildTableRows()
-
 	t := table.New(
 		table.WithColumns(columns),
-		table.WithRows(rows),
 		table.WithFocused(true),
 		table.WithHeight(10),
 	)
@@ -61,11 +85,16 @@ func (m *Model) setupTable() {
 	s.Selected = selectedStyle
 	t.SetStyles(s)
 
+	// Rows are built once the columns are in place, coloured cells need
+	// their widths
 	m.table = t
+	m.table.SetRows(m.buildTableRows())
 }
 
 func (m *Model) buildTableRows() []table.Row {
 	var rows []table.Row
+	m.rowAssetIDs = nil
+	m.rowHoldingIDs = nil
 
 	// Group holdings by asset
 	assetHoldings := make(map[uint][]models.Holding)
@@ -74,12 +103,12 @@ func (m *Model) buildTableRows() []table.Row {
 	}
 
 	// Calculate total value per asset
-	assetTotalValues := make(map[uint]float64)
+	assetTotalValues := make(map[uint]decimal.Decimal)
 	for assetID, holdings := range assetHoldings {
 		price := m.prices[assetID]
-		totalValue := 0.0
+		totalValue := decimal.Zero
 		for _, holding := range holdings {
-			totalValue += holding.Amount * price
+			totalValue = totalValue.Add(holding.Amount.Mul(price))
 		}
 		assetTotalValues[assetID] = totalValue
 	}
@@ -95,13 +124,13 @@ func (m *Model) buildTableRows() []table.Row {
 		valueJ := assetTotalValues[assetIDs[j]]
 
 		// If values are equal (or both zero), sort by symbol
-		if valueI == valueJ {
+		if valueI.Equal(valueJ) {
 			assetI := m.getAssetByID(assetIDs[i])
 			assetJ := m.getAssetByID(assetIDs[j])
 			return assetI.Symbol < assetJ.Symbol
 		}
 
-		return valueI > valueJ
+		return valueI.GreaterThan(valueJ)
 	})
 
 	// Build rows with tree structure
@@ -112,38 +141,38 @@ func (m *Model) buildTableRows() []table.Row {
 		totalValue := assetTotalValues[assetID]
 
 		// Calculate total amount for this asset
-		totalAmount := 0.0
+		totalAmount := decimal.Zero
 		for _, holding := range holdings {
-			totalAmount += holding.Amount
+			totalAmount = totalAmount.Add(holding.Amount)
 		}
 
-		// Format total amount based on asset type
-		amountStr := ""
-		if asset.Type == models.AssetTypeFiat {
-			amountStr = fmt.Sprintf("%.2f", totalAmount)
-		} else {
-			amountStr = fmt.Sprintf("%.4f", totalAmount)
+		// Mark prices the user entered by hand
+		label := asset.Symbol
+		if _, ok := m.manualPrices[assetID]; ok {
+			label += " (manual)"
 		}
 
 		// Add asset header row
-		assetRow := table.Row{
-			asset.Symbol,
-			amountStr,
-			fmt.Sprintf("$%.2f", totalValue),
-		}
+		assetRow := append(table.Row{
+			label,
+			formatAssetAmount(asset, totalAmount),
+			m.converter.FormatUSD(totalValue),
+		}, m.performanceCells(service.CalculatePerformance(holdings, price))...)
 		rows = append(rows, assetRow)
+		m.rowAssetIDs = append(m.rowAssetIDs, assetID)
+		m.rowHoldingIDs = append(m.rowHoldingIDs, 0)
 
 		// Sort holdings within each asset by value (highest first)
 		sort.Slice(holdings, func(i, j int) bool {
-			valueI := holdings[i].Amount * price
-			valueJ := holdings[j].Amount * price
-			return valueI > valueJ
+			valueI := holdings[i].Amount.Mul(price)
+			valueJ := holdings[j].Amount.Mul(price)
+			return valueI.GreaterThan(valueJ)
 		})
 
 		// Add account rows
 		for i, holding := range holdings {
 			account := m.getAccountByID(holding.AccountID)
-			value := holding.Amount * price
+			value := holding.Amount.Mul(price)
 
 			// Determine tree character
 			var treeChar string
@@ -153,38 +182,119 @@ func (m *Model) buildTableRows() []table.Row {
 				treeChar = "├─ "
 			}
 
-			// Format amount based on asset type
-			amountStr := ""
-			if asset.Type == models.AssetTypeFiat {
-				amountStr = fmt.Sprintf("%.2f", holding.Amount)
-			} else {
-				amountStr = fmt.Sprintf("%.4f", holding.Amount)
-			}
-
-			row := table.Row{
-				"  " + treeChar + account.Name,
-				amountStr,
-				fmt.Sprintf("$%.2f", value),
-			}
+			prefix := "  " + treeChar
+			name := colorCell(account.Name, accountStyle(account), columnWidth(m.table.Columns(), 0)-runewidth.StringWidth(prefix))
+			row := append(table.Row{
+				prefix + name,
+				formatAssetAmount(asset, holding.Amount),
+				m.converter.FormatUSD(value),
+			}, m.performanceCells(service.CalculatePerformance([]models.Holding{holding}, price))...)
 			rows = append(rows, row)
+			m.rowAssetIDs = append(m.rowAssetIDs, assetID)
+			m.rowHoldingIDs = append(m.rowHoldingIDs, holding.ID)
 		}
 	}
 
 	return rows
 }
 
+// performanceCells renders the cost basis, P/L, P/L % and holding period
+// cells, with gains in green and losses in red
+func (m *Model) performanceCells(perf service.Performance) []string {
+	held := "-"
+	if !perf.HeldSince.IsZero() {
+		held = formatHoldingPeriod(time.Since(perf.HeldSince))
+	}
+	if !perf.HasCostBasis {
+		return []string{"-", "-", "-", held}
+	}
+
+	style := gainStyle
+	if perf.ProfitLoss.IsNegative() {
+		style = lossStyle
+	}
+
+	columns := m.table.Columns()
+
+	return []string{
+		m.converter.FormatUSD(perf.CostBasis),
+		colorCell(m.converter.FormatSignedUSD(perf.ProfitLoss), style, columnWidth(columns, 4)),
+		colorCell(formatPercent(perf.ProfitLossPct), style, columnWidth(columns, 5)),
+		held,
+	}
+}
+
+// formatAssetAmount shows fiat amounts to the cent and everything else to
+// four decimals
+func formatAssetAmount(asset models.Asset, amount decimal.Decimal) string {
+	if asset.Type == models.AssetTypeFiat {
+		return amount.StringFixed(2)
+	}
+	return amount.StringFixed(4)
+}
+
+// formatPercent renders a percentage with two decimals and an explicit sign
+func formatPercent(pct decimal.Decimal) string {
+	pct = pct.Round(2)
+	if pct.IsNegative() {
+		return pct.StringFixed(2) + "%"
+	}
+	return "+" + pct.StringFixed(2) + "%"
+}
+
+// colorCell styles text unless the escape codes would push it past the
+// column width; the table truncates by raw width and would cut them in half
+func colorCell(text string, style lipgloss.Style, width int) string {
+	styled := style.Render(text)
+	if width > 0 && runewidth.StringWidth(styled) > width {
+		return text
+	}
+	return styled
+}
+
+// colorOverhead is the width the table sees in a coloured cell's escape codes
+func colorOverhead() int {
+	overhead := runewidth.StringWidth(gainStyle.Render("0")) - 1
+	if loss := runewidth.StringWidth(lossStyle.Render("0")) - 1; loss > overhead {
+		overhead = loss
+	}
+	return overhead
+}
+
+func columnWidth(columns []table.Column, i int) int {
+	if i >= len(columns) {
+		return 0
+	}
+	return columns[i].Width
+}
+
+// formatHoldingPeriod renders a duration as days, months or years and months
+func formatHoldingPeriod(d time.Duration) string {
+	days := int(d.Hours() / 24)
+	switch {
+	case days < 1:
+		return "<1d"
+	case days < 31:
+		return fmt.Sprintf("%dd", days)
+	case days < 365:
+		return fmt.Sprintf("%dm", days/30)
+	default:
+		years := days / 365
+		months := (days % 365) / 30
+		if months == 0 {
+			return fmt.Sprintf("%dy", years)
+		}
+		return fmt.Sprintf("%dy %dm", years, months)
+	}
+}
+
 func (m *Model) updateTableData() {
 	rows := m.buildTableRows()
 	m.table.SetRows(rows)
 }
 
-func (m *Model) calculateTotal() float64 {
-	var total float64
-	for _, holding := range m.holdings {
-		price := m.prices[holding.AssetID]
-		total += holding.Amount * price
-	}
-	return total
+func (m *Model) calculateTotal() decimal.Decimal {
+	return service.CalculateTotal(m.holdings, m.prices)
 }
 
 func (m *Model) getAccountByID(id uint) models.Account {
@@ -212,8 +322,8 @@ func (m *Model) tableView() string {
 
 	// Header with last update time
 	headerLeft := "💰 Minimal Money"
-	headerRight := fmt.Sprintf("Total: $%.2f", total)
-	headerPadding := m.width - len(headerLeft) - len(headerRight) - 2
+	headerRight := "Total: " + m.converter.FormatUSD(total)
+	headerPadding := m.width - runewidth.StringWidth(headerLeft) - runewidth.StringWidth(headerRight) - 2
 	if headerPadding < 1 {
 		headerPadding = 1
 	}
@@ -236,8 +346,27 @@ func (m *Model) tableView() string {
 	// Table
 	b.WriteString(baseStyle.Render(m.table.View()) + "\n\n")
 
+	// Symbols that match several coins stay unpriced until the user picks one
+	if len(m.unresolved) > 0 {
+		symbols := make([]string, len(m.unresolved))
+		for i, asset := range m.unresolved {
+			symbols[i] = asset.Symbol
+		}
+		b.WriteString(warningStyle.Render(fmt.Sprintf("⚠ Ambiguous symbols: %s - press [r] to choose the coin", strings.Join(symbols, ", "))) + "\n")
+	}
+
+	if m.converterErr != nil {
+		b.WriteString(warningStyle.Render(fmt.Sprintf("⚠ Showing USD: %v", m.converterErr)) + "\n")
+	}
+
+	if m.undoErr != nil {
+		b.WriteString(errorStyle.Render(m.undoErr.Error()) + "\n")
+	} else if m.undoStatus != "" {
+		b.WriteString(m.undoStatus + "\n")
+	}
+
 	// Footer
-	footer := "[n]ew  [e]dit  [d]elete  [p]rice update  [h]istory  [q]uit"
+	footer := "[n]ew  [e]dit  [d]elete  [p]rice update  [h]istory  [c]hart  [m]anual price  [r]esolve  [b]ase currency  [a]ccounts  a[s]sets  [t]rash  [u]ndo  [ctrl+r] redo  [q]uit"
 	b.WriteString(footer)
 
 	return b.String()
@@ -0,0 +1,141 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bioharz/budget/internal/service"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// openTrash shows the deleted holdings, accounts and assets
+func (m *Model) openTrash() {
+	m.view = ViewTrash
+	m.purgingTrash = false
+	m.reloadTrash()
+}
+
+func (m *Model) reloadTrash() {
+	m.err = nil
+	items, err := m.trashService.GetAll()
+	if err != nil {
+		m.err = err
+		return
+	}
+	m.trashItems = items
+	if m.trashCursor >= len(m.trashItems) {
+		m.trashCursor = len(m.trashItems) - 1
+	}
+	if m.trashCursor < 0 {
+		m.trashCursor = 0
+	}
+}
+
+func (m *Model) selectedTrashItem() (service.TrashItem, bool) {
+	if m.trashCursor < 0 || m.trashCursor >= len(m.trashItems) {
+		return service.TrashItem{}, false
+	}
+	return m.trashItems[m.trashCursor], true
+}
+
+func (m *Model) handleTrashKey(key string) tea.Cmd {
+	// Confirming a purge
+	if m.purgingTrash {
+		switch key {
+		case "y", "Y":
+			m.purgingTrash = false
+			item, _ := m.selectedTrashItem()
+			if err := m.trashService.Purge(item.Entity, item.ID); err != nil {
+				m.err = err
+				return nil
+			}
+			m.reloadTrash()
+		case "n", "N", "esc":
+			m.purgingTrash = false
+		}
+		return nil
+	}
+
+	switch key {
+	case "up", "k":
+		if m.trashCursor > 0 {
+			m.trashCursor--
+		}
+	case "down", "j":
+		if m.trashCursor < len(m.trashItems)-1 {
+			m.trashCursor++
+		}
+	case "r":
+		item, ok := m.selectedTrashItem()
+		if !ok {
+			return nil
+		}
+		if err := m.trashService.Restore(item.Entity, item.ID); err != nil {
+			m.err = err
+			return nil
+		}
+		m.reloadTrash()
+		m.reloadPortfolio()
+	case "x":
+		if _, ok := m.selectedTrashItem(); ok {
+			m.purgingTrash = true
+			m.err = nil
+		}
+	case "esc":
+		m.view = ViewMain
+		m.err = nil
+	case "ctrl+c", "q":
+		return tea.Quit
+	}
+	return nil
+}
+
+func (m Model) trashView() string {
+	var b strings.Builder
+	b.WriteString(titleStyle.Render("🗑️ Trash") + "\n")
+
+	if len(m.trashItems) == 0 {
+		b.WriteString("The trash is empty.\n\n")
+		if m.err != nil {
+			b.WriteString(errorStyle.Render(m.err.Error()) + "\n\n")
+		}
+		b.WriteString("[ESC] back")
+		return b.String()
+	}
+
+	nameWidth := len("Name")
+	for _, item := range m.trashItems {
+		nameWidth = max(nameWidth, lipgloss.Width(item.Name))
+	}
+	row := func(deleted, entity, name string) string {
+		return fmt.Sprintf("%-16s  %-7s  %s", deleted, entity, padRight(name, nameWidth))
+	}
+
+	b.WriteString("  " + labelStyle.Render(row("Deleted", "Type", "Name")) + "\n")
+	for i, item := range m.trashItems {
+		line := row(item.DeletedAt.Format("2006-01-02 15:04"), strings.ToLower(string(item.Entity)), item.Name)
+		if i == m.trashCursor {
+			b.WriteString(selectedStyle.Render("> "+line) + "\n")
+		} else {
+			b.WriteString("  " + line + "\n")
+		}
+	}
+	b.WriteString("\n")
+
+	if days, err := m.trashService.Days(); err == nil && days > 0 {
+		b.WriteString(fmt.Sprintf("Deleted items are purged after %d days.\n\n", days))
+	}
+	if m.err != nil {
+		b.WriteString(errorStyle.Render(m.err.Error()) + "\n\n")
+	}
+
+	if m.purgingTrash {
+		item, _ := m.selectedTrashItem()
+		b.WriteString(warningStyle.Render(fmt.Sprintf("Purge %s for good? This cannot be undone.", item.Name)) + "\n")
+		b.WriteString("[Y]es     [N]o / [ESC]")
+		return b.String()
+	}
+	b.WriteString("[↑/↓] select  [r]estore  [x] purge  [ESC] back")
+	return b.String()
+}
@@ -0,0 +1,35 @@
+package ui
+
+import (
+	"github.com/bioharz/budget/internal/models"
+	"github.com/bioharz/budget/internal/service"
+)
+
+// undoChange reverts the newest change and redoChange applies the change
+// undone last again; the main view shows which one
+func (m *Model) undoChange() {
+	m.applyUndo("Undid", m.undoService.Undo)
+}
+
+func (m *Model) redoChange() {
+	m.applyUndo("Redid", m.undoService.Redo)
+}
+
+func (m *Model) applyUndo(verb string, apply func() (models.AuditLog, error)) {
+	entry, err := apply()
+	if err != nil {
+		m.undoStatus, m.undoErr = "", err
+		return
+	}
+	m.undoStatus, m.undoErr = verb+" "+service.DescribeChange(entry), nil
+	m.reloadPortfolio()
+}
+
+// reloadPortfolio reads the accounts, assets and holdings again after an
+// undo or redo changed any of them
+func (m *Model) reloadPortfolio() {
+	m.reloadAccounts()
+	if m.err == nil {
+		m.reloadAssets()
+	}
+}
@@ -0,0 +1,115 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bioharz/budget/internal/db"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// maxUnlockAttempts is how often a wrong passphrase may be entered before
+// the unlock screen gives up
+const maxUnlockAttempts = 3
+
+// ErrUnlockCancelled is returned when the unlock screen is left without
+// opening the database
+var ErrUnlockCancelled = errors.New("unlock cancelled")
+
+// UnlockModel asks for the passphrase of an encrypted database and hands it
+// to open until it is accepted
+type UnlockModel struct {
+	open     func(passphrase string) error
+	input    string
+	message  string
+	attempts int
+	err      error // Why the screen was left, nil once unlocked
+	width    int
+	height   int
+}
+
+func NewUnlockModel(open func(passphrase string) error) UnlockModel {
+	return UnlockModel{open: open, err: ErrUnlockCancelled}
+}
+
+// Unlock shows the unlock screen and returns once the database is open or
+// the user gives up
+func Unlock(open func(passphrase string) error) error {
+	final, err := tea.NewProgram(NewUnlockModel(open), tea.WithAltScreen()).Run()
+	if err != nil {
+		return err
+	}
+	return final.(UnlockModel).err
+}
+
+func (m UnlockModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m UnlockModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+	case tea.KeyMsg:
+		switch msg.Type {
+		case tea.KeyEsc, tea.KeyCtrlC:
+			return m, tea.Quit
+		case tea.KeyEnter:
+			return m.submit()
+		case tea.KeyBackspace:
+			if runes := []rune(m.input); len(runes) > 0 {
+				m.input = string(runes[:len(runes)-1])
+			}
+		case tea.KeyRunes, tea.KeySpace:
+			m.input += string(msg.Runes)
+		}
+	}
+	return m, nil
+}
+
+func (m UnlockModel) submit() (tea.Model, tea.Cmd) {
+	if m.input == "" {
+		return m, nil
+	}
+
+	err := m.open(m.input)
+	m.input = ""
+	switch {
+	case err == nil:
+		m.err = nil
+		return m, tea.Quit
+	case errors.Is(err, db.ErrWrongPassphrase):
+		m.attempts++
+		if m.attempts >= maxUnlockAttempts {
+			m.err = err
+			return m, tea.Quit
+		}
+		m.message = fmt.Sprintf("Wrong passphrase, %d of %d attempts left", maxUnlockAttempts-m.attempts, maxUnlockAttempts)
+		return m, nil
+	default:
+		m.err = err
+		return m, tea.Quit
+	}
+}
+
+func (m UnlockModel) View() string {
+	var b strings.Builder
+	b.WriteString(titleStyle.Render("🔒 Unlock Minimal Money") + "\n\n")
+	b.WriteString(labelStyle.Render("The database is encrypted.") + "\n\n")
+
+	masked := strings.Repeat("•", len([]rune(m.input))) + "█"
+	b.WriteString(fmt.Sprintf("%-15s %s\n\n", labelStyle.Render("Passphrase:"), activeInputStyle.Render(masked)))
+
+	if m.message != "" {
+		b.WriteString(errorStyle.Render(m.message) + "\n\n")
+	}
+	b.WriteString(labelStyle.Render("[enter] unlock  [esc] quit"))
+
+	box := modalStyle.Render(b.String())
+	if m.width == 0 || m.height == 0 {
+		return box
+	}
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, box)
+}
@@ -0,0 +1,52 @@
+package ui
+
+import (
+	"testing"
+
+	"github.com/bioharz/budget/internal/db"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+)
+
+func typePassphrase(m UnlockModel, passphrase string) (UnlockModel, tea.Cmd) {
+	next, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(passphrase)})
+	next, cmd := next.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	return next.(UnlockModel), cmd
+}
+
+func TestUnlockModel_WrongPassphrase(t *testing.T) {
+	var tried []string
+	m := NewUnlockModel(func(passphrase string) error {
+		tried = append(tried, passphrase)
+		if passphrase == "secret" {
+			return nil
+		}
+		return db.ErrWrongPassphrase
+	})
+
+	m, cmd := typePassphrase(m, "guess")
+	assert.Nil(t, cmd)
+	assert.Contains(t, m.View(), "Wrong passphrase, 2 of 3 attempts left")
+	assert.NotContains(t, m.View(), "guess")
+
+	m, cmd = typePassphrase(m, "secret")
+	assert.NotNil(t, cmd)
+	assert.NoError(t, m.err)
+	assert.Equal(t, []string{"guess", "secret"}, tried)
+}
+
+func TestUnlockModel_GivesUp(t *testing.T) {
+	m := NewUnlockModel(func(string) error { return db.ErrWrongPassphrase })
+	var cmd tea.Cmd
+	for i := 0; i < maxUnlockAttempts; i++ {
+		m, cmd = typePassphrase(m, "guess")
+	}
+	assert.NotNil(t, cmd)
+	assert.ErrorIs(t, m.err, db.ErrWrongPassphrase)
+
+	// Leaving without a passphrase cancels
+	m = NewUnlockModel(func(string) error { return nil })
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	assert.NotNil(t, cmd)
+	assert.ErrorIs(t, next.(UnlockModel).err, ErrUnlockCancelled)
+}
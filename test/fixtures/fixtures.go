@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/bioharz/budget/internal/models"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/require"
 	"gorm.io/gorm"
 )
@@ -96,7 +97,7 @@ type HoldingBuilder struct {
 func NewHolding() *HoldingBuilder {
 	return &HoldingBuilder{
 		holding: models.Holding{
-			Amount:       1.0,
+			Amount:       decimal.NewFromInt(1),
 			PurchaseDate: time.Now(),
 		},
 	}
@@ -115,12 +116,12 @@ func (b *HoldingBuilder) WithAsset(asset *models.Asset) *HoldingBuilder {
 }
 
 func (b *HoldingBuilder) WithAmount(amount float64) *HoldingBuilder {
-	b.holding.Amount = amount
+	b.holding.Amount = decimal.NewFromFloat(amount)
 	return b
 }
 
 func (b *HoldingBuilder) WithPurchasePrice(price float64) *HoldingBuilder {
-	b.holding.PurchasePrice = price
+	b.holding.PurchasePrice = decimal.NewFromFloat(price)
 	return b
 }
 
@@ -37,9 +37,13 @@ func SetupTestDB(t *testing.T) *gorm.DB {
 		&models.Account{},
 		&models.Asset{},
 		&models.Holding{},
+		&models.Transaction{},
 		&models.AuditLog{},
 		&models.PortfolioSnapshot{},
 		&models.PriceCache{},
+		&models.PriceHistory{},
+		&models.CoinListing{},
+		&models.Setting{},
 	)
 	require.NoError(t, err)
 
@@ -9,6 +9,7 @@ import (
 	"github.com/bioharz/budget/internal/repository"
 	"github.com/bioharz/budget/internal/service"
 	"github.com/bioharz/budget/test/helpers"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -81,22 +82,22 @@ func TestCompletePortfolioWorkflow(t *testing.T) {
 		{
 			AccountID:     hardwareWallet.ID,
 			AssetID:       btc.ID,
-			Amount:        0.5,
-			PurchasePrice: 40000,
+			Amount:        decimal.NewFromFloat(0.5),
+			PurchasePrice: decimal.NewFromInt(40000),
 			PurchaseDate:  time.Now().AddDate(0, -6, 0),
 		},
 		{
 			AccountID:     hardwareWallet.ID,
 			AssetID:       eth.ID,
-			Amount:        10,
-			PurchasePrice: 2000,
+			Amount:        decimal.NewFromInt(10),
+			PurchasePrice: decimal.NewFromInt(2000),
 			PurchaseDate:  time.Now().AddDate(0, -3, 0),
 		},
 		{
 			AccountID:     neobank.ID,
 			AssetID:       eur.ID,
-			Amount:        1000,
-			PurchasePrice: 1.1,
+			Amount:        decimal.NewFromInt(1000),
+			PurchasePrice: decimal.NewFromFloat(1.1),
 			PurchaseDate:  time.Now().AddDate(0, -1, 0),
 		},
 	}
@@ -104,7 +105,7 @@ func TestCompletePortfolioWorkflow(t *testing.T) {
 	for _, holding := range holdings {
 		err := holdingRepo.Create(&holding)
 		require.NoError(t, err)
-		t.Logf("Created holding: Account %d, Asset %d, Amount %.2f",
+		t.Logf("Created holding: Account %d, Asset %d, Amount %s",
 			holding.AccountID, holding.AssetID, holding.Amount)
 	}
 
@@ -121,40 +122,42 @@ func TestCompletePortfolioWorkflow(t *testing.T) {
 	allHoldings, err := holdingRepo.GetAll()
 	require.NoError(t, err)
 
-	totalValue := 0.0
-	totalPL := 0.0
+	totalValue := decimal.Zero
+	totalPL := decimal.Zero
 
 	t.Log("\nPortfolio Summary:")
 	t.Log("==================")
 
 	for _, holding := range allHoldings {
 		currentPrice := prices[holding.AssetID]
-		value := holding.Amount * currentPrice
-		pl := (currentPrice - holding.PurchasePrice) * holding.Amount
+		value := holding.Amount.Mul(currentPrice)
+		pl := currentPrice.Sub(holding.PurchasePrice).Mul(holding.Amount)
 
-		totalValue += value
-		totalPL += pl
+		totalValue = totalValue.Add(value)
+		totalPL = totalPL.Add(pl)
 
-		t.Logf("%s - %s: %.2f @ $%.2f = $%.2f (P&L: $%.2f)",
+		t.Logf("%s - %s: %s @ $%s = $%s (P&L: $%s)",
 			holding.Account.Name,
 			holding.Asset.Symbol,
 			holding.Amount,
-			currentPrice,
-			value,
-			pl)
+			currentPrice.StringFixed(2),
+			value.StringFixed(2),
+			pl.StringFixed(2))
 	}
 
-	t.Logf("\nTotal Portfolio Value: $%.2f", totalValue)
-	t.Logf("Total P&L: $%.2f (%.2f%%)", totalPL, (totalPL/totalValue)*100)
+	t.Logf("\nTotal Portfolio Value: $%s", totalValue.StringFixed(2))
+	if totalValue.IsPositive() {
+		t.Logf("Total P&L: $%s (%s%%)", totalPL.StringFixed(2), totalPL.Div(totalValue).Mul(decimal.NewFromInt(100)).StringFixed(2))
+	}
 
 	// Assertions
-	if totalValue < 1000 {
+	if totalValue.LessThan(decimal.NewFromInt(1000)) {
 		t.Log("Warning: Prices might not have been fetched correctly (API issue or rate limit)")
 		// At least verify the EUR value was calculated
-		assert.Greater(t, totalValue, 500.0, "Portfolio should at least have partial EUR value")
+		assert.True(t, totalValue.GreaterThan(decimal.NewFromInt(500)), "Portfolio should at least have partial EUR value")
 	} else {
 		// If crypto prices were fetched, expect higher value
-		assert.Greater(t, totalValue, 1000.0, "Portfolio should be worth > $1k")
+		assert.True(t, totalValue.GreaterThan(decimal.NewFromInt(1000)), "Portfolio should be worth > $1k")
 	}
 	assert.Len(t, allHoldings, 3, "Should have 3 holdings")
 
@@ -194,8 +197,8 @@ func TestAddNewAssetWorkflow(t *testing.T) {
 	holding := &models.Holding{
 		AccountID:     account.ID,
 		AssetID:       newAsset.ID,
-		Amount:        50,
-		PurchasePrice: 120,
+		Amount:        decimal.NewFromInt(50),
+		PurchasePrice: decimal.NewFromInt(120),
 		PurchaseDate:  time.Now(),
 	}
 	err = holdingRepo.Create(holding)
@@ -215,8 +218,8 @@ func TestAddNewAssetWorkflow(t *testing.T) {
 	}
 
 	require.NotNil(t, solHolding, "Should find SOL holding")
-	assert.Equal(t, 50.0, solHolding.Amount)
-	assert.Equal(t, 120.0, solHolding.PurchasePrice)
+	assert.Equal(t, "50", solHolding.Amount.String())
+	assert.Equal(t, "120", solHolding.PurchasePrice.String())
 
 	t.Logf("Successfully added %s to %s account", newAsset.Symbol, account.Name)
 }
@@ -242,7 +245,7 @@ func TestDatabaseConstraints(t *testing.T) {
 	holding := &models.Holding{
 		AccountID: 9999, // Non-existent account
 		AssetID:   asset1.ID,
-		Amount:    1.0,
+		Amount:    decimal.NewFromInt(1),
 	}
 	err = holdingRepo.Create(holding)
 	// Either it errors (FK enabled) or creates with invalid reference (FK disabled)